@@ -2,16 +2,26 @@ package main
 
 import (
 	"context"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/niksmo/gophkeeper/internal/client"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
 )
 
 const (
 	syncTick    = 10 * time.Second
+	syncMaxTick = time.Minute
 	authTimeout = 10 * time.Second
+	kdfTime     = cipher.DefaultTime
+	kdfMemory   = cipher.DefaultMemory
+	agentSuffix = ".agent.sock"
+	syncSuffix  = ".sync.sock"
+
+	syncTickEnv    = "GOPHKEEPER_SYNC_TICK"
+	syncMaxTickEnv = "GOPHKEEPER_SYNC_MAX_TICK"
 )
 
 // LDFLAGS variables
@@ -21,6 +31,12 @@ var (
 	ServerAddr = "127.0.0.1:8000"
 	Version    = "N/A"
 	BuildDate  = "N/A"
+
+	// SyncTick is the period of the synchronization, the period grows
+	// up to SyncMaxTick while the data is idle. Both are overridden by
+	// the GOPHKEEPER_SYNC_TICK and GOPHKEEPER_SYNC_MAX_TICK variables.
+	SyncTick    = "10s"
+	SyncMaxTick = "1m"
 )
 
 func main() {
@@ -40,7 +56,30 @@ func loadConfig() client.Opt {
 		ServerAddr:  ServerAddr,
 		Version:     Version,
 		BuildDate:   BuildDate,
-		SyncTick:    syncTick,
+		SyncTick:    parseDuration(envOr(syncTickEnv, SyncTick), syncTick),
+		SyncMaxTick: parseDuration(envOr(syncMaxTickEnv, SyncMaxTick), syncMaxTick),
 		AuthTimeout: authTimeout,
+		KDFTime:     kdfTime,
+		KDFMemory:   kdfMemory,
+		AgentSocket: DSN + agentSuffix,
+		SyncSocket:  DSN + syncSuffix,
+	}
+}
+
+// parseDuration returns the positive duration of s or the fallback.
+func parseDuration(s string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// envOr returns the value of the environment variable or the fallback
+// if the variable is empty.
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
 	}
+	return fallback
 }
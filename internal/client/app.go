@@ -6,29 +6,44 @@ import (
 	"time"
 
 	"github.com/niksmo/gophkeeper/internal/client/command"
+	"github.com/niksmo/gophkeeper/internal/client/command/agentcommand"
 	"github.com/niksmo/gophkeeper/internal/client/command/bincommand"
 	"github.com/niksmo/gophkeeper/internal/client/command/cardcommand"
+	"github.com/niksmo/gophkeeper/internal/client/command/conflictcommand"
 	"github.com/niksmo/gophkeeper/internal/client/command/pwdcommand"
+	"github.com/niksmo/gophkeeper/internal/client/command/recoverycommand"
 	"github.com/niksmo/gophkeeper/internal/client/command/synccommand"
 	"github.com/niksmo/gophkeeper/internal/client/command/textcommand"
+	"github.com/niksmo/gophkeeper/internal/client/command/vaultcommand"
 	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/handler/agenthandler"
 	"github.com/niksmo/gophkeeper/internal/client/handler/authhandler"
 	"github.com/niksmo/gophkeeper/internal/client/handler/binhandler"
 	"github.com/niksmo/gophkeeper/internal/client/handler/cardhandler"
+	"github.com/niksmo/gophkeeper/internal/client/handler/conflicthandler"
 	"github.com/niksmo/gophkeeper/internal/client/handler/pwdhandler"
+	"github.com/niksmo/gophkeeper/internal/client/handler/recoveryhandler"
 	"github.com/niksmo/gophkeeper/internal/client/handler/synchandler"
 	"github.com/niksmo/gophkeeper/internal/client/handler/texthandler"
+	"github.com/niksmo/gophkeeper/internal/client/handler/vaulthandler"
 	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service/agentservice"
 	"github.com/niksmo/gophkeeper/internal/client/service/authservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/binservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/conflictservice"
 	"github.com/niksmo/gophkeeper/internal/client/service/genservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/recoveryservice"
 	"github.com/niksmo/gophkeeper/internal/client/service/syncservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/vaultservice"
 	"github.com/niksmo/gophkeeper/internal/client/storage"
 	"github.com/niksmo/gophkeeper/pkg/cipher"
 	"github.com/niksmo/gophkeeper/pkg/encode"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
 	authbp "github.com/niksmo/gophkeeper/proto/auth"
 	usersdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -39,7 +54,12 @@ type Opt struct {
 	Version     string
 	BuildDate   string
 	SyncTick    time.Duration
+	SyncMaxTick time.Duration
 	AuthTimeout time.Duration
+	KDFTime     uint32
+	KDFMemory   uint32
+	AgentSocket string
+	SyncSocket  string
 }
 
 type App struct {
@@ -50,9 +70,18 @@ type App struct {
 	decoder     *encode.Decoder
 	encrypter   *cipher.Encrypter
 	decrypter   *cipher.Decrypter
+	indexer     *cipher.Indexer
+	verifier    *vaultservice.KeyVerifier
+	agent       *agentservice.Client
+	agentSocket string
+	syncSocket  string
 	serverAddr  string
 	syncTick    time.Duration
+	syncMaxTick time.Duration
+	syncOrigin  string
 	authTimeout time.Duration
+	kdfTime     uint32
+	kdfMemory   uint32
 	conn        *grpc.ClientConn
 }
 
@@ -67,11 +96,31 @@ func New(opt Opt) *App {
 		decoder:     encode.NewDecoder(),
 		encrypter:   cipher.NewEncrypter(),
 		decrypter:   cipher.NewDecrypter(),
+		indexer:     cipher.NewIndexer(),
 		serverAddr:  opt.ServerAddr,
 		syncTick:    opt.SyncTick,
+		syncMaxTick: opt.SyncMaxTick,
+		syncOrigin:  uuid.New(),
 		authTimeout: opt.AuthTimeout,
+		kdfTime:     opt.KDFTime,
+		kdfMemory:   opt.KDFMemory,
+		agent:       agentservice.NewClient(log, opt.AgentSocket),
+		agentSocket: opt.AgentSocket,
+		syncSocket:  opt.SyncSocket,
 	}
 
+	keyring := agentservice.NewKeyring(app.agent, func() (string, error) {
+		return command.PromptSecret(command.KeySecret.Prompt)
+	})
+	app.encrypter.SetDeriver(keyring)
+	app.decrypter.SetDeriver(keyring)
+	app.indexer.SetDeriver(keyring)
+
+	app.verifier = vaultservice.NewKeyVerifier(
+		log, repository.NewVault(log, app.storage),
+		app.encrypter, app.decrypter,
+	)
+
 	app.initGRPCConn()
 	app.registerCommands()
 	return app
@@ -79,6 +128,7 @@ func New(opt Opt) *App {
 
 func (a *App) Run(ctx context.Context) {
 	a.storage.MustRun(ctx)
+	a.loadVaultParams(ctx)
 	if err := a.cmd.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
@@ -92,9 +142,38 @@ func (a *App) stop() {
 	a.log.Debug().Msg("stopped")
 }
 
+func (a *App) loadVaultParams(ctx context.Context) {
+	repo := repository.NewVault(a.log, a.storage)
+	loader := vaultservice.NewParamsLoader(
+		a.log, repo, a.kdfTime, a.kdfMemory,
+	)
+	params, err := loader.Load(ctx)
+	if err != nil {
+		a.log.Fatal().Err(err).Msg("failed to load vault params")
+	}
+	a.encrypter.SetParams(params)
+
+	salt, err := repo.ReadIndexSalt(ctx)
+	if err != nil {
+		a.log.Fatal().Err(err).Msg("failed to load vault params")
+	}
+	a.indexer.SetSalt(salt)
+
+	adRequired, err := repo.ReadADRequired(ctx)
+	if err != nil {
+		a.log.Fatal().Err(err).Msg("failed to load vault params")
+	}
+	a.decrypter.SetRequireAD(adRequired)
+}
+
 func (a *App) initGRPCConn() {
 	dialOpt := grpc.WithTransportCredentials(insecure.NewCredentials())
-	conn, err := grpc.NewClient(a.serverAddr, dialOpt)
+	// the sync daemon backs off the unavailable server itself, so the
+	// connection is not kept failing longer than the sync tick
+	reconnect := backoff.DefaultConfig
+	reconnect.MaxDelay = a.syncTick
+	connectOpt := grpc.WithConnectParams(grpc.ConnectParams{Backoff: reconnect})
+	conn, err := grpc.NewClient(a.serverAddr, dialOpt, connectOpt)
 	if err != nil {
 		a.log.Fatal().Err(err).Msg("failed to init gRPC conn")
 	}
@@ -108,25 +187,34 @@ func (a *App) registerCommands() {
 		a.getCardCommand(),
 		a.getTextCommand(),
 		a.getSyncCommand(),
+		a.getVaultCommand(),
+		a.getConflictsCommand(),
 	)
+	a.cmd.AddCommand(a.getAgentCommands()...)
 }
 
 func (a *App) getPasswordCommand() *command.Command {
 	repo := repository.NewPwd(a.log, a.storage)
 
-	addS := genservice.NewAdd[dto.PWD](a.log, repo, a.encoder, a.encrypter)
+	addS := genservice.NewAdd[dto.PWD](
+		a.log, repo, a.verifier, a.encoder, a.encrypter, a.indexer,
+	)
 	addH := pwdhandler.NewAdd(a.log, addS, os.Stdout)
 	addC := pwdcommand.NewAdd(addH)
 
-	readS := genservice.NewRead[dto.PWD](a.log, repo, a.decoder, a.decrypter)
+	readS := genservice.NewRead[dto.PWD](
+		a.log, repo, a.verifier, a.decoder, a.decrypter,
+	)
 	readH := pwdhandler.NewRead(a.log, readS, os.Stdout)
 	readC := pwdcommand.NewRead(readH)
 
-	listS := genservice.NewList(a.log, repo)
+	listS := genservice.NewList(a.log, repo, a.verifier, a.decrypter)
 	listH := pwdhandler.NewList(a.log, listS, os.Stdout)
 	listC := pwdcommand.NewList(listH)
 
-	editS := genservice.NewEdit[dto.PWD](a.log, repo, a.encoder, a.encrypter)
+	editS := genservice.NewEdit[dto.PWD](
+		a.log, repo, a.verifier, a.encoder, a.encrypter, a.indexer,
+	)
 	editH := pwdhandler.NewEdit(a.log, editS, os.Stdout)
 	editC := pwdcommand.NewEdit(editH)
 
@@ -140,22 +228,23 @@ func (a *App) getPasswordCommand() *command.Command {
 }
 
 func (a *App) getBinaryCommand() *command.Command {
-	repo := repository.NewBin(a.log, a.storage)
+	repo := repository.NewBinStream(a.log, a.storage)
+	binS := binservice.New(
+		a.log, repo, a.verifier, a.encoder, a.decoder,
+		a.encrypter, a.decrypter, a.indexer,
+	)
 
-	addS := genservice.NewAdd[dto.BIN](a.log, repo, a.encoder, a.encrypter)
-	addH := binhandler.NewAdd(a.log, addS, os.Stdout)
+	addH := binhandler.NewAdd(a.log, binS, os.Stdout)
 	addC := bincommand.NewAdd(addH)
 
-	readS := genservice.NewRead[dto.BIN](a.log, repo, a.decoder, a.decrypter)
-	readH := binhandler.NewRead(a.log, readS, os.Stdout)
+	readH := binhandler.NewRead(a.log, binS, os.Stdout)
 	readC := bincommand.NewRead(readH)
 
-	listS := genservice.NewList(a.log, repo)
+	listS := genservice.NewList(a.log, repo, a.verifier, a.decrypter)
 	listH := binhandler.NewList(a.log, listS, os.Stdout)
 	listC := bincommand.NewList(listH)
 
-	editS := genservice.NewEdit[dto.BIN](a.log, repo, a.encoder, a.encrypter)
-	editH := binhandler.NewEdit(a.log, editS, os.Stdout)
+	editH := binhandler.NewEdit(a.log, binS, os.Stdout)
 	editC := bincommand.NewEdit(editH)
 
 	removeS := genservice.NewRemove(a.log, repo)
@@ -170,19 +259,25 @@ func (a *App) getBinaryCommand() *command.Command {
 func (a *App) getCardCommand() *command.Command {
 	repo := repository.NewCard(a.log, a.storage)
 
-	addS := genservice.NewAdd[dto.BankCard](a.log, repo, a.encoder, a.encrypter)
+	addS := genservice.NewAdd[dto.BankCard](
+		a.log, repo, a.verifier, a.encoder, a.encrypter, a.indexer,
+	)
 	addH := cardhandler.NewAdd(a.log, addS, os.Stdout)
 	addC := cardcommand.NewAdd(addH)
 
-	readS := genservice.NewRead[dto.BankCard](a.log, repo, a.decoder, a.decrypter)
+	readS := genservice.NewRead[dto.BankCard](
+		a.log, repo, a.verifier, a.decoder, a.decrypter,
+	)
 	readH := cardhandler.NewRead(a.log, readS, os.Stdout)
 	readC := cardcommand.NewRead(readH)
 
-	listS := genservice.NewList(a.log, repo)
+	listS := genservice.NewList(a.log, repo, a.verifier, a.decrypter)
 	listH := cardhandler.NewList(a.log, listS, os.Stdout)
 	listC := cardcommand.NewList(listH)
 
-	editS := genservice.NewEdit[dto.BankCard](a.log, repo, a.encoder, a.encrypter)
+	editS := genservice.NewEdit[dto.BankCard](
+		a.log, repo, a.verifier, a.encoder, a.encrypter, a.indexer,
+	)
 	editH := cardhandler.NewEdit(a.log, editS, os.Stdout)
 	editC := cardcommand.NewEdit(editH)
 
@@ -198,19 +293,25 @@ func (a *App) getCardCommand() *command.Command {
 func (a *App) getTextCommand() *command.Command {
 	repo := repository.NewText(a.log, a.storage)
 
-	addS := genservice.NewAdd[dto.Text](a.log, repo, a.encoder, a.encrypter)
+	addS := genservice.NewAdd[dto.Text](
+		a.log, repo, a.verifier, a.encoder, a.encrypter, a.indexer,
+	)
 	addH := texthandler.NewAdd(a.log, addS, os.Stdout)
 	addC := textcommand.NewAdd(addH)
 
-	readS := genservice.NewRead[dto.Text](a.log, repo, a.decoder, a.decrypter)
+	readS := genservice.NewRead[dto.Text](
+		a.log, repo, a.verifier, a.decoder, a.decrypter,
+	)
 	readH := texthandler.NewRead(a.log, readS, os.Stdout)
 	readC := textcommand.NewRead(readH)
 
-	listS := genservice.NewList(a.log, repo)
+	listS := genservice.NewList(a.log, repo, a.verifier, a.decrypter)
 	listH := texthandler.NewList(a.log, listS, os.Stdout)
 	listC := textcommand.NewList(listH)
 
-	editS := genservice.NewEdit[dto.Text](a.log, repo, a.encoder, a.encrypter)
+	editS := genservice.NewEdit[dto.Text](
+		a.log, repo, a.verifier, a.encoder, a.encrypter, a.indexer,
+	)
 	editH := texthandler.NewEdit(a.log, editS, os.Stdout)
 	editC := textcommand.NewEdit(editH)
 
@@ -225,28 +326,140 @@ func (a *App) getTextCommand() *command.Command {
 
 func (a *App) getSyncCommand() *command.Command {
 	syncRepo := repository.NewSync(a.log, a.storage)
-	authCs := a.getAuthSubCommands(syncRepo)
+	sessionRepo := repository.NewSession(a.log, a.storage)
+	sessions := authservice.NewSessionKeeper(
+		a.log, sessionRepo, a.verifier, a.encrypter, a.decrypter,
+	)
+	daemon := syncservice.NewDaemonClient(a.log, a.syncSocket)
+	authCs := a.getAuthSubCommands(daemon, sessionRepo, sessions)
 	workers := a.initSyncWorkers()
 
-	syncRunner := syncservice.NewWorkerPool(a.log, syncRepo, workers, a.syncTick)
+	subscriber := syncservice.NewGRPCSubscriber(
+		a.log, usersdatapb.NewUsersDataClient(a.conn), a.syncOrigin,
+	)
+	syncRunner := syncservice.NewWorkerPool(
+		a.log, syncRepo, sessionRepo, subscriber,
+		workers, syncservice.Interval{Min: a.syncTick, Max: a.syncMaxTick},
+		a.syncSocket,
+	)
 	startH := synchandler.NewStart(a.log, syncRunner, os.Stdout)
 	startC := synccommand.NewStart(startH)
 
+	statusS := syncservice.NewStatusReader(
+		a.log, daemon, syncRepo, sessionRepo, a.serverAddr,
+	)
+	statusH := synchandler.NewStatus(a.log, statusS, os.Stdout)
+	statusC := synccommand.NewStatus(statusH)
+
+	nowS := syncservice.NewOneShotSyncer(
+		a.log, sessions, daemon, syncRepo, workers,
+	)
+	nowH := synchandler.NewNow(a.log, nowS, os.Stdout)
+	nowC := synccommand.NewNow(nowH)
+
+	pauseS := syncservice.NewSyncPauser(a.log, daemon)
+	pauseH := synchandler.NewPause(a.log, pauseS, os.Stdout)
+	pauseC := synccommand.NewPause(pauseH)
+
 	syncC := synccommand.New()
-	syncC.AddCommand(append(authCs, startC)...)
+	syncC.AddCommand(append(authCs, statusC, nowC, pauseC, startC)...)
 	return syncC
 }
 
+func (a *App) getVaultCommand() *command.Command {
+	repo := repository.NewVault(a.log, a.storage)
+
+	upgradeS := vaultservice.NewUpgrader(
+		a.log, repo, a.verifier, a.encrypter, a.decrypter, a.indexer,
+		a.kdfTime, a.kdfMemory,
+	)
+	upgradeH := vaulthandler.NewUpgrade(a.log, upgradeS, os.Stdout)
+	upgradeC := vaultcommand.NewUpgrade(upgradeH)
+
+	rekeyS := vaultservice.NewRekeyer(
+		a.log, repo, a.verifier, a.encrypter, a.decrypter, a.indexer,
+		agentservice.NewLocker(a.log, a.agent),
+	)
+	rekeyH := vaulthandler.NewRekey(a.log, rekeyS, os.Stdout)
+	rekeyC := vaultcommand.NewRekey(rekeyH)
+
+	vaultC := vaultcommand.New()
+	vaultC.AddCommand(upgradeC, rekeyC, a.getRecoveryCommand())
+	return vaultC
+}
+
+func (a *App) getRecoveryCommand() *command.Command {
+	splitS := recoveryservice.NewSplitter(a.log, a.verifier)
+	splitH := recoveryhandler.NewSplit(a.log, splitS, os.Stdout)
+	splitC := recoverycommand.NewSplit(splitH)
+
+	combineS := recoveryservice.NewCombiner(a.log, a.verifier)
+	combineH := recoveryhandler.NewCombine(a.log, combineS, os.Stdout)
+	combineC := recoverycommand.NewCombine(combineH)
+
+	recoveryC := recoverycommand.New()
+	recoveryC.AddCommand(splitC, combineC)
+	return recoveryC
+}
+
+func (a *App) getConflictsCommand() *command.Command {
+	repo := repository.NewConflict(a.log, a.storage)
+
+	listS := conflictservice.NewLister(a.log, repo, a.verifier, a.decrypter)
+	listH := conflicthandler.NewList(a.log, listS, os.Stdout)
+	listC := conflictcommand.NewList(listH)
+
+	resolveS := conflictservice.NewResolver(
+		a.log, repo, a.verifier, a.encrypter, a.decrypter, a.indexer,
+	)
+	resolveH := conflicthandler.NewResolve(a.log, resolveS, os.Stdout)
+	resolveC := conflictcommand.NewResolve(resolveH)
+
+	conflictsC := conflictcommand.New()
+	conflictsC.AddCommand(listC, resolveC)
+	return conflictsC
+}
+
+func (a *App) getAgentCommands() []*command.Command {
+	unlockS := agentservice.NewUnlocker(a.log, a.verifier, a.agent)
+	unlockH := agenthandler.NewUnlock(a.log, unlockS, os.Stdout)
+	unlockC := agentcommand.NewUnlock(unlockH)
+
+	lockS := agentservice.NewLocker(a.log, a.agent)
+	lockH := agenthandler.NewLock(a.log, lockS, os.Stdout)
+	lockC := agentcommand.NewLock(lockH)
+
+	agentS := agentservice.NewAgent(a.log, a.agentSocket)
+	agentH := agenthandler.NewAgent(a.log, agentS, os.Stdin)
+	agentC := agentcommand.NewAgent(agentH)
+
+	return []*command.Command{unlockC, lockC, agentC}
+}
+
 func (a *App) getAuthSubCommands(
-	syncRepo *repository.SyncRepository,
+	daemon *syncservice.DaemonClient,
+	sessionRepo *repository.SessionRepository,
+	sessions *authservice.SessionKeeper,
 ) []*command.Command {
 	authClient := authservice.NewGRPCAuthClient(
 		a.log, authbp.NewAuthClient(a.conn), a.authTimeout,
 	)
 
-	syncStarter := syncservice.NewSyncExecuter(a.log, syncRepo)
-	userRegistrar := authservice.NewUserRegistrar(a.log, authClient, syncStarter)
-	userAuthorizer := authservice.NewUserAuthorizer(a.log, authClient, syncStarter)
+	reindexer := vaultservice.NewReindexer(
+		a.log, repository.NewVault(a.log, a.storage), a.verifier,
+		a.encrypter, a.decrypter, a.indexer,
+		syncservice.NewGRPCIndexSaltClient(
+			a.log, usersdatapb.NewUsersDataClient(a.conn),
+		),
+	)
+
+	syncStarter := syncservice.NewSyncExecuter(a.log, daemon)
+	userRegistrar := authservice.NewUserRegistrar(
+		a.log, authClient, sessions, reindexer, syncStarter,
+	)
+	userAuthorizer := authservice.NewUserAuthorizer(
+		a.log, authClient, sessions, reindexer, syncStarter,
+	)
 
 	signupH := authhandler.NewSignup(a.log, userRegistrar, os.Stdout)
 	signupC := synccommand.NewSignup(signupH)
@@ -254,27 +467,41 @@ func (a *App) getAuthSubCommands(
 	signinH := authhandler.NewSignin(a.log, userAuthorizer, os.Stdout)
 	signinC := synccommand.NewSignin(signinH)
 
-	syncCloser := syncservice.NewSyncCloser(a.log, syncRepo)
+	sessionResumer := authservice.NewSessionResumer(
+		a.log, sessions, daemon, syncStarter,
+	)
+	resumeH := authhandler.NewResume(a.log, sessionResumer, os.Stdout)
+	resumeC := synccommand.NewResume(resumeH)
+
+	syncCloser := syncservice.NewSyncCloser(a.log, daemon, sessionRepo)
 	logoutH := authhandler.NewLogout(a.log, syncCloser, os.Stdout)
 	logoutC := synccommand.NewLogout(logoutH)
 
-	return []*command.Command{signupC, signinC, logoutC}
+	return []*command.Command{signupC, signinC, resumeC, logoutC}
 }
 
 func (a *App) initSyncWorkers() []syncservice.SyncWorker {
 	usersDataClient := usersdatapb.NewUsersDataClient(a.conn)
 
 	pwdSyncR := repository.NewPwdSync(a.log, a.storage)
-	pwdClient := syncservice.NewGRPCSyncClientPwd(a.log, usersDataClient)
+	pwdClient := syncservice.NewGRPCSyncClientPwd(
+		a.log, usersDataClient, a.syncOrigin,
+	)
 
 	textSyncR := repository.NewTextSync(a.log, a.storage)
-	textClient := syncservice.NewGRPCSyncClientText(a.log, usersDataClient)
+	textClient := syncservice.NewGRPCSyncClientText(
+		a.log, usersDataClient, a.syncOrigin,
+	)
 
 	cardSyncR := repository.NewCardSync(a.log, a.storage)
-	cardClient := syncservice.NewGRPCSyncClientCard(a.log, usersDataClient)
+	cardClient := syncservice.NewGRPCSyncClientCard(
+		a.log, usersDataClient, a.syncOrigin,
+	)
 
 	binSyncR := repository.NewBinSync(a.log, a.storage)
-	binClient := syncservice.NewGRPCSyncClientBin(a.log, usersDataClient)
+	binClient := syncservice.NewGRPCSyncClientBin(
+		a.log, usersDataClient, a.syncOrigin,
+	)
 
 	return []syncservice.SyncWorker{
 		syncservice.NewWorker(a.log, pwdSyncR, pwdClient),
@@ -0,0 +1,78 @@
+package agentcommand
+
+import (
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/command"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SecretKeyFlag   = command.SecreKeyFlag
+	IdleTimeoutFlag = "idle-timeout"
+)
+
+const (
+	idleTimeoutShorthand = "i"
+	idleTimeoutDefault   = 15 * time.Minute
+	idleTimeoutUsage     = "lock the vault after the period of inactivity"
+)
+
+type UnlockCmdFlags struct {
+	Key         string
+	IdleTimeout time.Duration
+}
+
+func NewUnlock(h command.GenCmdHandler[UnlockCmdFlags]) *command.Command {
+	var fv UnlockCmdFlags
+
+	c := &cobra.Command{
+		Use:   "unlock",
+		Short: "Keep the key in the agent to omit it in the next commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+	flagSet := c.Flags()
+
+	command.AddSecretFlag(c, &fv.Key, command.RequiredKeySecret)
+
+	flagSet.DurationVarP(&fv.IdleTimeout,
+		IdleTimeoutFlag, idleTimeoutShorthand,
+		idleTimeoutDefault, idleTimeoutUsage)
+
+	return &command.Command{Command: c}
+}
+
+func NewLock(h command.NoFlagsCmdHandler) *command.Command {
+	c := &cobra.Command{
+		Use:   "lock",
+		Short: "Stop the agent and forget the key",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context())
+		},
+	}
+	return &command.Command{Command: c}
+}
+
+type AgentCmdFlags struct {
+	IdleTimeout time.Duration
+}
+
+func NewAgent(h command.GenCmdHandler[AgentCmdFlags]) *command.Command {
+	var fv AgentCmdFlags
+
+	c := &cobra.Command{
+		Hidden: true,
+		Use:    "agent",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+
+	c.Flags().DurationVarP(&fv.IdleTimeout,
+		IdleTimeoutFlag, idleTimeoutShorthand,
+		idleTimeoutDefault, idleTimeoutUsage)
+
+	return &command.Command{Command: c}
+}
@@ -13,10 +13,6 @@ const (
 )
 
 const (
-	secretKeyShorthand = command.SecretKeyShorthand
-	secretKeyDefault   = command.SecretKeyDefault
-	secretKeyUsage     = command.SecretKeyUsage
-
 	nameShorthand = command.NameShorthand
 	nameDefault   = command.NameDefault
 	nameUsage     = "name for stored file data (required)"
@@ -55,8 +51,7 @@ func NewAdd(h command.GenCmdHandler[AddCmdFlags]) *command.Command {
 
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.StringVarP(&fv.Name,
 		NameFlag, nameShorthand, nameDefault, nameUsage)
@@ -64,7 +59,6 @@ func NewAdd(h command.GenCmdHandler[AddCmdFlags]) *command.Command {
 	flagSet.StringVarP(&fv.Filepath,
 		FilepathFlag, filepathShorthand, filepathDefault, readFilepathUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(NameFlag)
 	c.MarkFlagRequired(FilepathFlag)
 
@@ -87,8 +81,7 @@ func NewEdit(h command.GenCmdHandler[EditCmdFlags]) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.StringVarP(&fv.Name,
 		NameFlag, nameShorthand, nameDefault, nameUsage)
@@ -99,7 +92,6 @@ func NewEdit(h command.GenCmdHandler[EditCmdFlags]) *command.Command {
 	flagSet.IntVarP(&fv.EntryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(NameFlag)
 	c.MarkFlagRequired(EntryNumFlag)
 	c.MarkFlagRequired(FilepathFlag)
@@ -126,8 +118,7 @@ func NewRead(h command.GenCmdHandler[ReadCmdFlags]) *command.Command {
 
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.IntVarP(&fv.EntryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
@@ -136,18 +127,22 @@ func NewRead(h command.GenCmdHandler[ReadCmdFlags]) *command.Command {
 		FilepathFlag, filepathShorthand, filepathDefault, writeFilepathUsage)
 
 	c.MarkFlagRequired(EntryNumFlag)
-	c.MarkFlagRequired(SecretKeyFlag)
 
 	return &command.Command{Command: c}
 }
 
-func NewList(h command.NoFlagsCmdHandler) *command.Command {
+func NewList(h command.ListCmdHandler) *command.Command {
+	var key string
+
 	c := &cobra.Command{
 		Use: "list",
 		Run: func(cmd *cobra.Command, args []string) {
-			h.Handle(cmd.Context())
+			h.Handle(cmd.Context(), key)
 		},
 	}
+
+	command.AddSecretFlag(c, &key, command.KeySecret)
+
 	return &command.Command{Command: c}
 }
 
@@ -15,10 +15,6 @@ const (
 )
 
 const (
-	secretKeyShorthand = command.SecretKeyShorthand
-	secretKeyDefault   = command.SecretKeyDefault
-	secretKeyUsage     = command.SecretKeyUsage
-
 	nameShorthand = command.NameShorthand
 	nameDefault   = command.NameDefault
 	nameUsage     = "title for bank card (required)"
@@ -61,8 +57,7 @@ func NewAdd(h command.GenCmdHandler[AddCmdFlags]) *command.Command {
 
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.StringVarP(&fv.Name,
 		NameFlag, nameShorthand, nameDefault, nameUsage)
@@ -76,7 +71,6 @@ func NewAdd(h command.GenCmdHandler[AddCmdFlags]) *command.Command {
 	flagSet.StringVar(&fv.Holder,
 		HolderNameFlag, holderNameDefault, holderNameUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(NameFlag)
 	c.MarkFlagRequired(CardNumFlag)
 	c.MarkFlagRequired(ExpDateFlag)
@@ -101,8 +95,7 @@ func NewEdit(h command.GenCmdHandler[EditCmdFlags]) *command.Command {
 
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.IntVarP(&fv.EntryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
@@ -119,7 +112,6 @@ func NewEdit(h command.GenCmdHandler[EditCmdFlags]) *command.Command {
 	flagSet.StringVar(&fv.Holder,
 		HolderNameFlag, holderNameDefault, holderNameUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(EntryNumFlag)
 	c.MarkFlagRequired(NameFlag)
 	c.MarkFlagRequired(CardNumFlag)
@@ -142,25 +134,28 @@ func NewRead(h command.ReadCmdHandler) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &key, command.KeySecret)
 
 	flagSet.IntVarP(&entryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(EntryNumFlag)
 
 	return &command.Command{Command: c}
 }
 
-func NewList(h command.NoFlagsCmdHandler) *command.Command {
+func NewList(h command.ListCmdHandler) *command.Command {
+	var key string
+
 	c := &cobra.Command{
 		Use: "list",
 		Run: func(cmd *cobra.Command, args []string) {
-			h.Handle(cmd.Context())
+			h.Handle(cmd.Context(), key)
 		},
 	}
+
+	command.AddSecretFlag(c, &key, command.KeySecret)
+
 	return &command.Command{Command: c}
 }
 
@@ -16,6 +16,8 @@ const (
 	SecretKeyDefault   = ""
 	SecretKeyUsage     = "key for encrypting, decrypting" +
 		" and accessing to stored data (required)"
+	SecretKeyOptionalUsage = "key for encrypting, decrypting" +
+		" and accessing to stored data, omit if the vault is unlocked"
 
 	NameShorthand = "n"
 	NameDefault   = ""
@@ -33,6 +35,10 @@ type (
 		Handle(ctx context.Context, masterKey string, entryNum int)
 	}
 
+	ListCmdHandler interface {
+		Handle(ctx context.Context, masterKey string)
+	}
+
 	NoFlagsCmdHandler interface {
 		Handle(context.Context)
 	}
@@ -0,0 +1,80 @@
+package conflictcommand
+
+import (
+	"github.com/niksmo/gophkeeper/internal/client/command"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SecretKeyFlag = command.SecreKeyFlag
+	IDFlag        = "id"
+	KeepFlag      = "keep"
+
+	KeepEntry = "entry"
+	KeepCopy  = "copy"
+	KeepBoth  = "both"
+)
+
+const (
+	idShorthand = "i"
+	idDefault   = 0
+	idUsage     = "conflict number (required)"
+
+	keepDefault = ""
+	keepUsage   = "version to keep: entry, copy or both," +
+		" the copy is saved under a new name if both (required)"
+)
+
+func New() *command.Command {
+	c := &cobra.Command{
+		Use:   "conflicts",
+		Short: "Use the conflicts command to resolve the synchronization conflicts",
+	}
+	return &command.Command{Command: c}
+}
+
+func NewList(h command.ListCmdHandler) *command.Command {
+	var key string
+
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List the conflict copies kept beside the entries",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), key)
+		},
+	}
+
+	command.AddSecretFlag(c, &key, command.KeySecret)
+
+	return &command.Command{Command: c}
+}
+
+type ResolveCmdFlags struct {
+	Key  string
+	ID   int
+	Keep string
+}
+
+func NewResolve(h command.GenCmdHandler[ResolveCmdFlags]) *command.Command {
+	var fv ResolveCmdFlags
+
+	c := &cobra.Command{
+		Use:   "resolve",
+		Short: "Keep the entry, the conflict copy or both of them",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+	flagSet := c.Flags()
+
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
+
+	flagSet.IntVarP(&fv.ID, IDFlag, idShorthand, idDefault, idUsage)
+
+	flagSet.StringVar(&fv.Keep, KeepFlag, keepDefault, keepUsage)
+
+	c.MarkFlagRequired(IDFlag)
+	c.MarkFlagRequired(KeepFlag)
+
+	return &command.Command{Command: c}
+}
@@ -14,10 +14,6 @@ const (
 )
 
 const (
-	secretKeyShorthand = command.SecretKeyShorthand
-	secretKeyDefault   = command.SecretKeyDefault
-	secretKeyUsage     = command.SecretKeyUsage
-
 	nameShorthand = command.NameShorthand
 	nameDefault   = command.NameDefault
 	nameUsage     = "title for account (required)"
@@ -26,15 +22,20 @@ const (
 	entryNumDefault   = command.EntryNumDefault
 	entryNumUsage     = "entry number of stored account (required)"
 
-	passwordShorthand = "p"
-	passwordDefault   = ""
-	passwordUsage     = "account password (required)"
-
 	loginShorthand = "l"
 	loginDefault   = ""
 	loginUsage     = "account login"
 )
 
+var passwordSecret = command.SecretFlag{
+	Name:      PasswordFlag,
+	Shorthand: "p",
+	Usage:     "account password (required)",
+	Env:       "GOPHKEEPER_PASSWORD",
+	Prompt:    "account password",
+	Required:  true,
+}
+
 func New() *command.Command {
 	c := &cobra.Command{
 		Use:   "password",
@@ -58,22 +59,17 @@ func NewAdd(h command.GenCmdHandler[AddCmdFlags]) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.StringVarP(&fv.Name,
 		NameFlag, nameShorthand, nameDefault, nameUsage)
 
-	flagSet.StringVarP(&fv.Password,
-		PasswordFlag, passwordShorthand, passwordDefault, passwordUsage,
-	)
+	command.AddSecretFlag(c, &fv.Password, passwordSecret)
 
 	flagSet.StringVarP(&fv.Login,
 		LoginFlag, loginShorthand, loginDefault, loginUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(NameFlag)
-	c.MarkFlagRequired(PasswordFlag)
 	return &command.Command{Command: c}
 }
 
@@ -93,14 +89,12 @@ func NewEdit(h command.GenCmdHandler[EditCmdFlags]) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.StringVarP(&fv.Name,
 		NameFlag, nameShorthand, nameDefault, nameUsage)
 
-	flagSet.StringVarP(&fv.Password,
-		PasswordFlag, passwordShorthand, passwordDefault, passwordUsage)
+	command.AddSecretFlag(c, &fv.Password, passwordSecret)
 
 	flagSet.StringVarP(&fv.Login,
 		LoginFlag, loginShorthand, loginDefault, loginUsage)
@@ -108,10 +102,8 @@ func NewEdit(h command.GenCmdHandler[EditCmdFlags]) *command.Command {
 	flagSet.IntVarP(&fv.EntryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(NameFlag)
 	c.MarkFlagRequired(EntryNumFlag)
-	c.MarkFlagRequired(PasswordFlag)
 
 	return &command.Command{Command: c}
 }
@@ -130,25 +122,28 @@ func NewRead(h command.ReadCmdHandler) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &key, command.KeySecret)
 
 	flagSet.IntVarP(&entryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(EntryNumFlag)
 
 	return &command.Command{Command: c}
 }
 
-func NewList(h command.NoFlagsCmdHandler) *command.Command {
+func NewList(h command.ListCmdHandler) *command.Command {
+	var key string
+
 	c := &cobra.Command{
 		Use: "list",
 		Run: func(cmd *cobra.Command, args []string) {
-			h.Handle(cmd.Context())
+			h.Handle(cmd.Context(), key)
 		},
 	}
+
+	command.AddSecretFlag(c, &key, command.KeySecret)
+
 	return &command.Command{Command: c}
 }
 
@@ -84,6 +84,22 @@ func TestAdd(t *testing.T) {
 		st.h.AssertNumberOfCalls(t, "Handle", expectedCalls)
 	})
 
+	t.Run("KeyOmitted", func(t *testing.T) {
+		st := newAddSuite(t)
+		fv := pwdcommand.AddCmdFlags{
+			Name:     "testName",
+			Password: "testPassword",
+		}
+		st.SetArgs(
+			"--"+pwdcommand.NameFlag, fv.Name,
+			"--"+pwdcommand.PasswordFlag, fv.Password,
+		)
+		st.h.On("Handle", st.ctx, fv)
+		st.cmd.ExecuteContext(st.ctx)
+		expectedCalls := 1
+		st.h.AssertNumberOfCalls(t, "Handle", expectedCalls)
+	})
+
 	t.Run("MissedRequiredFlags", func(t *testing.T) {
 		st := newAddSuite(t)
 		fv := pwdcommand.AddCmdFlags{
@@ -156,6 +172,18 @@ func TestRead(t *testing.T) {
 		st.h.AssertNumberOfCalls(t, "Handle", expectedCalls)
 	})
 
+	t.Run("KeyOmitted", func(t *testing.T) {
+		st := newReadSuite(t)
+		entryNum := 1
+		st.SetArgs(
+			"--"+pwdcommand.EntryNumFlag, strconv.Itoa(entryNum),
+		)
+		st.h.On("Handle", st.ctx, "", entryNum)
+		st.cmd.ExecuteContext(st.ctx)
+		expectedCalls := 1
+		st.h.AssertNumberOfCalls(t, "Handle", expectedCalls)
+	})
+
 	t.Run("MissedEntryNumFlag", func(t *testing.T) {
 		st := newReadSuite(t)
 		masterKey := "testKey"
@@ -175,8 +203,8 @@ type MockListCmdHandler struct {
 	mock.Mock
 }
 
-func (h *MockListCmdHandler) Handle(ctx context.Context) {
-	h.Called(ctx)
+func (h *MockListCmdHandler) Handle(ctx context.Context, key string) {
+	h.Called(ctx, key)
 }
 
 func TestList(t *testing.T) {
@@ -185,14 +213,27 @@ func TestList(t *testing.T) {
 		os.Args = osArgs
 	})
 
-	mockListH := new(MockListCmdHandler)
-	listCmd := pwdcommand.NewList(mockListH)
-	os.Args = os.Args[:1]
+	t.Run("Ordinary", func(t *testing.T) {
+		mockListH := new(MockListCmdHandler)
+		listCmd := pwdcommand.NewList(mockListH)
+		os.Args = append(os.Args[:1], "--"+pwdcommand.SecretKeyFlag, "testKey")
+
+		mockListH.On("Handle", t.Context(), "testKey")
+		listCmd.ExecuteContext(t.Context())
+		expectedCalls := 1
+		mockListH.AssertNumberOfCalls(t, "Handle", expectedCalls)
+	})
+
+	t.Run("KeyOmitted", func(t *testing.T) {
+		mockListH := new(MockListCmdHandler)
+		listCmd := pwdcommand.NewList(mockListH)
+		os.Args = os.Args[:1]
 
-	mockListH.On("Handle", t.Context())
-	listCmd.ExecuteContext(t.Context())
-	expectedCalls := 1
-	mockListH.AssertNumberOfCalls(t, "Handle", expectedCalls)
+		mockListH.On("Handle", t.Context(), "")
+		listCmd.ExecuteContext(t.Context())
+		expectedCalls := 1
+		mockListH.AssertNumberOfCalls(t, "Handle", expectedCalls)
+	})
 }
 
 // Test *EditPassword* command
@@ -0,0 +1,79 @@
+package recoverycommand
+
+import (
+	"github.com/niksmo/gophkeeper/internal/client/command"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SecretKeyFlag = command.SecreKeyFlag
+	SharesFlag    = "shares"
+	ThresholdFlag = "threshold"
+	DirFlag       = "dir"
+)
+
+const (
+	sharesDefault = 5
+	sharesUsage   = "number of the shares to produce"
+
+	thresholdDefault = 3
+	thresholdUsage   = "number of the shares that recover the key"
+
+	dirDefault = "."
+	dirUsage   = "directory for the share files"
+)
+
+func New() *command.Command {
+	c := &cobra.Command{
+		Use:   "recovery",
+		Short: "Use the recovery command to split the master key into shares",
+	}
+	return &command.Command{Command: c}
+}
+
+type SplitCmdFlags struct {
+	Key               string
+	Shares, Threshold int
+	Dir               string
+}
+
+func NewSplit(h command.GenCmdHandler[SplitCmdFlags]) *command.Command {
+	var fv SplitCmdFlags
+
+	c := &cobra.Command{
+		Use:   "split",
+		Short: "Split the master key into the printable share files",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+
+	flagSet := c.Flags()
+
+	command.AddSecretFlag(c, &fv.Key, command.RequiredKeySecret)
+
+	flagSet.IntVar(&fv.Shares, SharesFlag, sharesDefault, sharesUsage)
+
+	flagSet.IntVar(&fv.Threshold,
+		ThresholdFlag, thresholdDefault, thresholdUsage)
+
+	flagSet.StringVar(&fv.Dir, DirFlag, dirDefault, dirUsage)
+
+	return &command.Command{Command: c}
+}
+
+type CombineCmdFlags struct {
+	Files []string
+}
+
+func NewCombine(h command.GenCmdHandler[CombineCmdFlags]) *command.Command {
+	c := &cobra.Command{
+		Use:   "combine FILE...",
+		Short: "Recover the master key from the share files",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), CombineCmdFlags{Files: args})
+		},
+	}
+	return &command.Command{Command: c}
+}
@@ -0,0 +1,178 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+const (
+	secretFileSuffix = "-file"
+	secretStdin      = "-"
+	secretAnnotation = "secret"
+)
+
+var (
+	ErrNoTerminal = errors.New("terminal is not available")
+	ErrManyStdin  = errors.New("only one secret can be read from stdin")
+)
+
+// SecretFlag describes the flag of a secret value. The value is taken from
+// the flag, the file flag, the environment variable or the terminal prompt
+// in that order, "-" as the flag or the file value means the stdin.
+// The prompt of the lazy secret is postponed until the value is needed,
+// see PromptSecret.
+type SecretFlag struct {
+	Name      string
+	Shorthand string
+	Usage     string
+	Env       string
+	Prompt    string
+	Multiline bool
+	Required  bool
+	Lazy      bool
+}
+
+var (
+	KeySecret = SecretFlag{
+		Name:      SecreKeyFlag,
+		Shorthand: SecretKeyShorthand,
+		Usage:     SecretKeyOptionalUsage,
+		Env:       "GOPHKEEPER_KEY",
+		Prompt:    "master key",
+		Lazy:      true,
+	}
+
+	RequiredKeySecret = SecretFlag{
+		Name:      SecreKeyFlag,
+		Shorthand: SecretKeyShorthand,
+		Usage:     SecretKeyUsage,
+		Env:       "GOPHKEEPER_KEY",
+		Prompt:    "master key",
+		Required:  true,
+	}
+)
+
+// AddSecretFlag registers the secret flag and the file flag on the command
+// and resolves the value into dst before the command runs.
+func AddSecretFlag(c *cobra.Command, dst *string, sf SecretFlag) {
+	flagSet := c.Flags()
+
+	flagSet.StringVarP(dst, sf.Name, sf.Shorthand, "", sf.Usage)
+
+	fileFlag := sf.Name + secretFileSuffix
+	flagSet.String(fileFlag, "",
+		fmt.Sprintf("read %s from the file, - for stdin", sf.Name))
+
+	c.MarkFlagsMutuallyExclusive(sf.Name, fileFlag)
+	for _, name := range []string{sf.Name, fileFlag} {
+		flagSet.SetAnnotation(name, secretAnnotation, []string{"true"})
+	}
+
+	preRunE := c.PreRunE
+	c.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if preRunE != nil {
+			if err := preRunE(cmd, args); err != nil {
+				return err
+			}
+		}
+		return resolveSecret(cmd, dst, sf)
+	}
+}
+
+func resolveSecret(cmd *cobra.Command, dst *string, sf SecretFlag) error {
+	flagSet := cmd.Flags()
+	fileFlag := sf.Name + secretFileSuffix
+
+	if stdinSecrets(flagSet) > 1 {
+		return ErrManyStdin
+	}
+
+	var err error
+	switch {
+	case flagSet.Changed(sf.Name):
+		if *dst == secretStdin {
+			*dst, err = readSecret(os.Stdin, sf.Multiline)
+		}
+	case flagSet.Changed(fileFlag):
+		path, _ := flagSet.GetString(fileFlag)
+		*dst, err = readSecretFile(path, sf.Multiline)
+	case sf.Env != "" && os.Getenv(sf.Env) != "":
+		*dst = os.Getenv(sf.Env)
+	case !sf.Lazy && sf.Prompt != "":
+		*dst, err = PromptSecret(sf.Prompt)
+		if errors.Is(err, ErrNoTerminal) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", sf.Name, err)
+	}
+
+	if sf.Required && *dst == "" {
+		return fmt.Errorf("%s is not provided", sf.Name)
+	}
+	return nil
+}
+
+// stdinSecrets returns the number of the secret flags set to stdin.
+// The resolved secret replaces the flag value, so the flags are counted
+// before the first secret is read.
+func stdinSecrets(flagSet *pflag.FlagSet) int {
+	var n int
+	flagSet.Visit(func(f *pflag.Flag) {
+		if _, ok := f.Annotations[secretAnnotation]; ok &&
+			f.Value.String() == secretStdin {
+			n++
+		}
+	})
+	return n
+}
+
+func readSecretFile(path string, multiline bool) (string, error) {
+	if path == secretStdin {
+		return readSecret(os.Stdin, multiline)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readSecret(f, multiline)
+}
+
+// readSecret reads the first line or the whole content if multiline.
+func readSecret(r io.Reader, multiline bool) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if multiline {
+		return string(b), nil
+	}
+	line, _, _ := bytes.Cut(b, []byte("\n"))
+	return string(bytes.TrimSuffix(line, []byte("\r"))), nil
+}
+
+// PromptSecret reads the secret from the terminal without echo.
+func PromptSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrNoTerminal
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
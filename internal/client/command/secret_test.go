@@ -0,0 +1,133 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/client/command"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEnv = "GOPHKEEPER_TEST_SECRET"
+
+func runSecretCmd(
+	t *testing.T, sf command.SecretFlag, args ...string,
+) (string, error) {
+	t.Helper()
+	var secret string
+	c := &cobra.Command{
+		Use:  "test",
+		Run:  func(cmd *cobra.Command, args []string) {},
+		Args: cobra.NoArgs,
+	}
+	c.SilenceErrors = true
+	c.SilenceUsage = true
+	command.AddSecretFlag(c, &secret, sf)
+	c.SetArgs(args)
+	err := c.Execute()
+	return secret, err
+}
+
+func setStdin(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestSecretFlag(t *testing.T) {
+	sf := command.SecretFlag{
+		Name:     "secret",
+		Env:      testEnv,
+		Prompt:   "secret",
+		Required: true,
+	}
+
+	t.Run("Flag", func(t *testing.T) {
+		t.Setenv(testEnv, "fromEnv")
+		secret, err := runSecretCmd(t, sf, "--secret", "fromFlag")
+		require.NoError(t, err)
+		assert.Equal(t, "fromFlag", secret)
+	})
+
+	t.Run("Stdin", func(t *testing.T) {
+		setStdin(t, "fromStdin\nsecondLine\n")
+		secret, err := runSecretCmd(t, sf, "--secret", "-")
+		require.NoError(t, err)
+		assert.Equal(t, "fromStdin", secret)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secret")
+		require.NoError(t, os.WriteFile(path, []byte("fromFile\r\n"), 0o600))
+		secret, err := runSecretCmd(t, sf, "--secret-file", path)
+		require.NoError(t, err)
+		assert.Equal(t, "fromFile", secret)
+	})
+
+	t.Run("FileStdinMultiline", func(t *testing.T) {
+		setStdin(t, "first\nsecond\n")
+		multiline := sf
+		multiline.Multiline = true
+		secret, err := runSecretCmd(t, multiline, "--secret-file", "-")
+		require.NoError(t, err)
+		assert.Equal(t, "first\nsecond\n", secret)
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		t.Setenv(testEnv, "fromEnv")
+		secret, err := runSecretCmd(t, sf)
+		require.NoError(t, err)
+		assert.Equal(t, "fromEnv", secret)
+	})
+
+	t.Run("FlagAndFileConflict", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secret")
+		require.NoError(t, os.WriteFile(path, []byte("fromFile"), 0o600))
+		_, err := runSecretCmd(t, sf, "--secret", "a", "--secret-file", path)
+		require.Error(t, err)
+	})
+
+	t.Run("RequiredNotProvided", func(t *testing.T) {
+		setStdin(t, "")
+		_, err := runSecretCmd(t, sf)
+		require.Error(t, err)
+	})
+
+	t.Run("LazyNotProvided", func(t *testing.T) {
+		lazy := sf
+		lazy.Required = false
+		lazy.Lazy = true
+		secret, err := runSecretCmd(t, lazy)
+		require.NoError(t, err)
+		assert.Empty(t, secret)
+	})
+}
+
+func TestSecretFlagsStdin(t *testing.T) {
+	var first, second string
+	c := &cobra.Command{
+		Use:  "test",
+		Run:  func(cmd *cobra.Command, args []string) {},
+		Args: cobra.NoArgs,
+	}
+	c.SilenceErrors = true
+	c.SilenceUsage = true
+	command.AddSecretFlag(c, &first, command.SecretFlag{Name: "first"})
+	command.AddSecretFlag(c, &second, command.SecretFlag{Name: "second"})
+
+	setStdin(t, "secret\n")
+	c.SetArgs([]string{"--first", "-", "--second-file", "-"})
+	require.ErrorIs(t, c.Execute(), command.ErrManyStdin)
+}
@@ -9,21 +9,30 @@ const (
 	PasswordFlag = "password"
 	LoginFlag    = "login"
 	TokenFlag    = "token"
+	DryRunFlag   = "dry-run"
 )
 
 const (
 	loginShorthand = "l"
 	loginDefault   = ""
 	loginUsage     = "sync account login (required)"
+)
 
-	passwordShorthand = "p"
-	passwordDefault   = ""
-	passwordUsage     = "sync account password (required)"
+var tokenSecret = command.SecretFlag{
+	Name:      TokenFlag,
+	Shorthand: "t",
+	Usage:     "sync session token",
+	Required:  true,
+}
 
-	tokenShorthand = "t"
-	tokenDefault   = ""
-	tokenUsage     = ""
-)
+var passwordSecret = command.SecretFlag{
+	Name:      PasswordFlag,
+	Shorthand: "p",
+	Usage:     "sync account password (required)",
+	Env:       "GOPHKEEPER_SYNC_PASSWORD",
+	Prompt:    "sync account password",
+	Required:  true,
+}
 
 func New() *command.Command {
 	c := &cobra.Command{
@@ -34,7 +43,7 @@ func New() *command.Command {
 }
 
 type AuthFlags struct {
-	Login, Password string
+	Key, Login, Password string
 }
 
 func NewSignup(h command.GenCmdHandler[AuthFlags]) *command.Command {
@@ -52,11 +61,10 @@ func NewSignup(h command.GenCmdHandler[AuthFlags]) *command.Command {
 	flagSet.StringVarP(&fv.Login,
 		LoginFlag, loginShorthand, loginDefault, loginUsage)
 
-	flagSet.StringVarP(&fv.Password,
-		PasswordFlag, passwordShorthand, passwordDefault, passwordUsage)
+	command.AddSecretFlag(c, &fv.Password, passwordSecret)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	c.MarkFlagRequired(LoginFlag)
-	c.MarkFlagRequired(PasswordFlag)
 	return &command.Command{Command: c}
 }
 
@@ -75,11 +83,10 @@ func NewSignin(h command.GenCmdHandler[AuthFlags]) *command.Command {
 	flagSet.StringVarP(&fv.Login,
 		LoginFlag, loginShorthand, loginDefault, loginUsage)
 
-	flagSet.StringVarP(&fv.Password,
-		PasswordFlag, passwordShorthand, passwordDefault, passwordUsage)
+	command.AddSecretFlag(c, &fv.Password, passwordSecret)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	c.MarkFlagRequired(LoginFlag)
-	c.MarkFlagRequired(PasswordFlag)
 	return &command.Command{Command: c}
 }
 
@@ -94,6 +101,70 @@ func NewLogout(h command.NoFlagsCmdHandler) *command.Command {
 	return &command.Command{Command: c}
 }
 
+func NewStatus(h command.NoFlagsCmdHandler) *command.Command {
+	c := &cobra.Command{
+		Use:   "status",
+		Short: "Show the synchronization state and the pending changes",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context())
+		},
+	}
+	return &command.Command{Command: c}
+}
+
+type NowCmdFlags struct {
+	Key    string
+	DryRun bool
+}
+
+func NewNow(h command.GenCmdHandler[NowCmdFlags]) *command.Command {
+	var fv NowCmdFlags
+
+	c := &cobra.Command{
+		Use:   "now",
+		Short: "Synchronize all data in the foreground and print the summary",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+
+	c.Flags().BoolVar(&fv.DryRun, DryRunFlag, false,
+		"print the changes to synchronize without applying them")
+
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
+	return &command.Command{Command: c}
+}
+
+func NewPause(h command.NoFlagsCmdHandler) *command.Command {
+	c := &cobra.Command{
+		Use:   "pause",
+		Short: "Pause synchronization until resume",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context())
+		},
+	}
+	return &command.Command{Command: c}
+}
+
+type ResumeCmdFlags struct {
+	Key string
+}
+
+func NewResume(h command.GenCmdHandler[ResumeCmdFlags]) *command.Command {
+	var fv ResumeCmdFlags
+
+	c := &cobra.Command{
+		Use:   "resume",
+		Short: "Resume paused synchronization or start it with the saved session",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
+	return &command.Command{Command: c}
+}
+
 type StartCmdFlags struct {
 	Token string
 }
@@ -107,10 +178,7 @@ func NewStart(h command.GenCmdHandler[StartCmdFlags]) *command.Command {
 			h.Handle(cmd.Context(), fv)
 		},
 	}
-	c.Flags().StringVarP(&fv.Token,
-		TokenFlag, tokenShorthand, tokenDefault, tokenUsage,
-	)
-	c.MarkFlagRequired(TokenFlag)
+	command.AddSecretFlag(c, &fv.Token, tokenSecret)
 
 	return &command.Command{Command: c}
 }
@@ -13,10 +13,6 @@ const (
 )
 
 const (
-	secretKeyShorthand = command.SecretKeyShorthand
-	secretKeyDefault   = command.SecretKeyDefault
-	secretKeyUsage     = command.SecretKeyUsage
-
 	nameShorthand = command.NameShorthand
 	nameDefault   = command.NameDefault
 	nameUsage     = "title for text (required)"
@@ -24,12 +20,18 @@ const (
 	entryNumShorthand = command.EntryNumShorthand
 	entryNumDefault   = command.EntryNumDefault
 	entryNumUsage     = "entry number of stored text (required)"
-
-	textShorthand = "t"
-	textDefault   = ""
-	textUsage     = "text (required)"
 )
 
+var textSecret = command.SecretFlag{
+	Name:      TextFlag,
+	Shorthand: "t",
+	Usage:     "text (required)",
+	Env:       "GOPHKEEPER_TEXT",
+	Prompt:    "text",
+	Multiline: true,
+	Required:  true,
+}
+
 func New() *command.Command {
 	c := &cobra.Command{
 		Use:   "text",
@@ -53,18 +55,14 @@ func NewAdd(h command.GenCmdHandler[AddCmdFlags]) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.StringVarP(&fv.Name,
 		NameFlag, nameShorthand, nameDefault, nameUsage)
 
-	flagSet.StringVarP(&fv.Text,
-		TextFlag, textShorthand, textDefault, textUsage)
+	command.AddSecretFlag(c, &fv.Text, textSecret)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(NameFlag)
-	c.MarkFlagRequired(TextFlag)
 	return &command.Command{Command: c}
 }
 
@@ -84,23 +82,18 @@ func NewEdit(h command.GenCmdHandler[EditCmdFlags]) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&fv.Key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &fv.Key, command.KeySecret)
 
 	flagSet.StringVarP(&fv.Name,
 		NameFlag, nameShorthand, nameDefault, nameUsage)
 
-	flagSet.StringVarP(&fv.Text,
-		TextFlag, textShorthand, textDefault, textUsage,
-	)
+	command.AddSecretFlag(c, &fv.Text, textSecret)
 
 	flagSet.IntVarP(&fv.EntryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(NameFlag)
 	c.MarkFlagRequired(EntryNumFlag)
-	c.MarkFlagRequired(TextFlag)
 
 	return &command.Command{Command: c}
 }
@@ -119,25 +112,28 @@ func NewRead(h command.ReadCmdHandler) *command.Command {
 	}
 	flagSet := c.Flags()
 
-	flagSet.StringVarP(&key,
-		SecretKeyFlag, secretKeyShorthand, secretKeyDefault, secretKeyUsage)
+	command.AddSecretFlag(c, &key, command.KeySecret)
 
 	flagSet.IntVarP(&entryNum,
 		EntryNumFlag, entryNumShorthand, entryNumDefault, entryNumUsage)
 
-	c.MarkFlagRequired(SecretKeyFlag)
 	c.MarkFlagRequired(EntryNumFlag)
 
 	return &command.Command{Command: c}
 }
 
-func NewList(h command.NoFlagsCmdHandler) *command.Command {
+func NewList(h command.ListCmdHandler) *command.Command {
+	var key string
+
 	c := &cobra.Command{
 		Use: "list",
 		Run: func(cmd *cobra.Command, args []string) {
-			h.Handle(cmd.Context())
+			h.Handle(cmd.Context(), key)
 		},
 	}
+
+	command.AddSecretFlag(c, &key, command.KeySecret)
+
 	return &command.Command{Command: c}
 }
 
@@ -0,0 +1,71 @@
+package vaultcommand
+
+import (
+	"github.com/niksmo/gophkeeper/internal/client/command"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SecretKeyFlag = command.SecreKeyFlag
+	NewKeyFlag    = "new-key"
+)
+
+var newKeySecret = command.SecretFlag{
+	Name:      NewKeyFlag,
+	Shorthand: "N",
+	Usage:     "new key for encrypting stored data (required)",
+	Env:       "GOPHKEEPER_NEW_KEY",
+	Prompt:    "new master key",
+	Required:  true,
+}
+
+func New() *command.Command {
+	c := &cobra.Command{
+		Use:   "vault",
+		Short: "Use the vault command to maintain the encrypted storage",
+	}
+	return &command.Command{Command: c}
+}
+
+type UpgradeCmdFlags struct {
+	Key string
+}
+
+func NewUpgrade(h command.GenCmdHandler[UpgradeCmdFlags]) *command.Command {
+	var fv UpgradeCmdFlags
+
+	c := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Reencrypt stored data with the current key derivation params",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+
+	command.AddSecretFlag(c, &fv.Key, command.RequiredKeySecret)
+
+	return &command.Command{Command: c}
+}
+
+type RekeyCmdFlags struct {
+	Key    string
+	NewKey string
+}
+
+func NewRekey(h command.GenCmdHandler[RekeyCmdFlags]) *command.Command {
+	var fv RekeyCmdFlags
+
+	c := &cobra.Command{
+		Use:   "rekey",
+		Short: "Reencrypt stored data with the new key and lock the vault",
+		Run: func(cmd *cobra.Command, args []string) {
+			h.Handle(cmd.Context(), fv)
+		},
+	}
+
+	command.AddSecretFlag(c, &fv.Key, command.RequiredKeySecret)
+
+	command.AddSecretFlag(c, &fv.NewKey, newKeySecret)
+
+	return &command.Command{Command: c}
+}
@@ -11,10 +11,13 @@ type (
 		Password string
 	}
 
+	// BIN describes the binary content, the content saved before
+	// the streams is kept in the Data.
 	BIN struct {
 		Name string
 		Ext  string
 		Data []byte
+		Size int64
 	}
 
 	BankCard struct {
@@ -35,4 +38,47 @@ type (
 		StartedAt time.Time
 		StoppedAt *time.Time
 	}
+
+	// SyncState is the result of the last synchronization of the entity,
+	// Pending is the number of the local changes are not sent yet.
+	SyncState struct {
+		Entity   string
+		SyncedAt *time.Time
+		Error    string
+		Pending  int
+	}
+
+	// SyncPlan is the difference between the local and the server data
+	// of the entity, the server lists hold the server IDs and the lists
+	// to the server hold the local IDs. Conflicts are the local IDs
+	// of the entries changed on the both sides or taking the same name.
+	SyncPlan struct {
+		Entity           string
+		InsertFromServer []int64
+		UpdateFromServer []int64
+		InsertToServer   []int64
+		UpdateToServer   []int64
+		Conflicts        []int64
+	}
+
+	// Conflict is the version of the entry kept beside it by the
+	// synchronization. SameName reports the copy lost the entry name,
+	// otherwise the entry is changed on the both sides.
+	Conflict struct {
+		ID        int
+		Entity    string
+		EntryNum  int
+		Name      string
+		SameName  bool
+		Deleted   bool
+		UpdatedAt time.Time
+		CreatedAt time.Time
+	}
+
+	// Session is the sync account session, the token is encrypted.
+	Session struct {
+		Login   string
+		Token   []byte
+		Expired bool
+	}
 )
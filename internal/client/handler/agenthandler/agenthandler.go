@@ -0,0 +1,136 @@
+package agenthandler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/command/agentcommand"
+	"github.com/niksmo/gophkeeper/internal/client/handler"
+	"github.com/niksmo/gophkeeper/internal/client/service/agentservice"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+type (
+	Unlocker interface {
+		Unlock(ctx context.Context, key string, idleTimeout time.Duration) error
+	}
+
+	Locker interface {
+		Lock(context.Context) error
+	}
+
+	AgentRunner interface {
+		Run(ctx context.Context, key string, idleTimeout time.Duration) error
+	}
+)
+
+type UnlockHandler struct {
+	l logger.Logger
+	s Unlocker
+	w io.Writer
+}
+
+func NewUnlock(l logger.Logger, s Unlocker, w io.Writer) *UnlockHandler {
+	return &UnlockHandler{l, s, w}
+}
+
+func (h *UnlockHandler) Handle(
+	ctx context.Context, fv agentcommand.UnlockCmdFlags,
+) {
+	const op = "UnlockHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	err := h.s.Unlock(ctx, fv.Key, fv.IdleTimeout)
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		h.handleAlreadyUnlockedErr(err)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	fmt.Fprintf(h.w, "the vault is unlocked for %s of inactivity\n",
+		fv.IdleTimeout)
+}
+
+func (h *UnlockHandler) handleAlreadyUnlockedErr(err error) {
+	if !errors.Is(err, agentservice.ErrAlreadyUnlocked) {
+		return
+	}
+	fmt.Fprintln(h.w, "the vault is already unlocked")
+	os.Exit(1)
+}
+
+type LockHandler struct {
+	l logger.Logger
+	s Locker
+	w io.Writer
+}
+
+func NewLock(l logger.Logger, s Locker, w io.Writer) *LockHandler {
+	return &LockHandler{l, s, w}
+}
+
+func (h *LockHandler) Handle(ctx context.Context) {
+	const op = "LockHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	err := h.s.Lock(ctx)
+	if err != nil {
+		h.handleNotUnlockedErr(err)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	fmt.Fprintln(h.w, "the vault is locked")
+}
+
+func (h *LockHandler) handleNotUnlockedErr(err error) {
+	if !errors.Is(err, agentservice.ErrNotUnlocked) {
+		return
+	}
+	fmt.Fprintln(h.w, "the vault is not unlocked")
+	os.Exit(1)
+}
+
+type AgentHandler struct {
+	l logger.Logger
+	s AgentRunner
+	r io.Reader
+}
+
+func NewAgent(l logger.Logger, s AgentRunner, r io.Reader) *AgentHandler {
+	return &AgentHandler{l, s, r}
+}
+
+func (h *AgentHandler) Handle(
+	ctx context.Context, fv agentcommand.AgentCmdFlags,
+) {
+	const op = "AgentHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	key, err := bufio.NewReader(h.r).ReadString('\n')
+	key = strings.TrimSuffix(key, "\n")
+	if err != nil || key == "" {
+		log.Error().Err(err).Msg("failed to read key")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(
+		ctx, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT,
+	)
+	defer stop()
+
+	if err := h.s.Run(ctx, key, fv.IdleTimeout); err != nil {
+		log.Error().Err(err).Msg("agent failed")
+		os.Exit(1)
+	}
+}
@@ -16,11 +16,15 @@ import (
 
 type (
 	UserRegistrar interface {
-		RegisterUser(ctx context.Context, login, password string) error
+		RegisterUser(ctx context.Context, key, login, password string) error
 	}
 
 	UserAuthorizer interface {
-		AuthorizeUser(ctx context.Context, login, password string) error
+		AuthorizeUser(ctx context.Context, key, login, password string) error
+	}
+
+	SessionResumer interface {
+		Resume(ctx context.Context, key string) error
 	}
 
 	SyncCloser interface {
@@ -45,8 +49,10 @@ func (h *SignupHandler) Handle(ctx context.Context, fv synccommand.AuthFlags) {
 
 	// TODO: verify login and password to match pattern
 
-	err := h.s.RegisterUser(ctx, fv.Login, fv.Password)
+	err := h.s.RegisterUser(ctx, fv.Key, fv.Login, fv.Password)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		handler.HandleAlreadyExistsErr(err, log, h.w, "account", "login")
 		handleSyncRunningErr(err, h.w)
 		handler.HandleUnexpectedErr(err, log, h.w)
@@ -77,8 +83,10 @@ func (h *SigninHandler) Handle(ctx context.Context, fv synccommand.AuthFlags) {
 
 	// TODO: verify login and password to match pattern
 
-	err := h.s.AuthorizeUser(ctx, fv.Login, fv.Password)
+	err := h.s.AuthorizeUser(ctx, fv.Key, fv.Login, fv.Password)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		h.handleCredentialsErr(err)
 		handleSyncRunningErr(err, h.w)
 		handler.HandleUnexpectedErr(err, log, h.w)
@@ -100,6 +108,52 @@ func (h *SigninHandler) printOutput(formated string, args ...any) {
 	fmt.Fprintln(h.w)
 }
 
+type ResumeHandler struct {
+	l logger.Logger
+	s SessionResumer
+	w io.Writer
+}
+
+func NewResume(l logger.Logger, s SessionResumer, w io.Writer) *ResumeHandler {
+	return &ResumeHandler{l, s, w}
+}
+
+func (h *ResumeHandler) Handle(
+	ctx context.Context, fv synccommand.ResumeCmdFlags,
+) {
+	const op = "ResumeHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	err := h.s.Resume(ctx, fv.Key)
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		h.handleSessionErr(err)
+		handleSyncRunningErr(err, h.w)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	h.printOutput("synchronization resumed")
+}
+
+func (h *ResumeHandler) handleSessionErr(err error) {
+	switch {
+	case errors.Is(err, authservice.ErrNoSession):
+		h.printOutput("there is no saved sync session, sign in first")
+	case errors.Is(err, authservice.ErrSessionExpired):
+		h.printOutput("the sync session is expired, sign in again")
+	default:
+		return
+	}
+	os.Exit(1)
+}
+
+func (h *ResumeHandler) printOutput(formated string, args ...any) {
+	fmt.Fprintf(h.w, formated, args...)
+	fmt.Fprintln(h.w)
+}
+
 type LogoutHandler struct {
 	l logger.Logger
 	s SyncCloser
@@ -116,22 +170,16 @@ func (h *LogoutHandler) Handle(ctx context.Context) {
 	log := h.l.WithOp(op)
 
 	err := h.s.CloseSynchronization(ctx)
-	if err != nil {
-		h.handleNoSyncErr(err)
-		handler.HandleUnexpectedErr(err, log, h.w)
+	if errors.Is(err, syncservice.ErrNoSync) {
+		// the session is deleted already, there is nothing to stop
+		h.printOutput("synchronization is not running, logged out")
+		return
 	}
+	handler.HandleUnexpectedErr(err, log, h.w)
 
 	h.printOutput("synchronization stopped")
 }
 
-func (h *LogoutHandler) handleNoSyncErr(err error) {
-	if !errors.Is(err, syncservice.ErrNoSync) {
-		return
-	}
-	h.printOutput("synchronization is not running")
-	os.Exit(1)
-}
-
 func (h *LogoutHandler) printOutput(formated string, args ...any) {
 	fmt.Fprintf(h.w, formated, args...)
 	fmt.Fprintln(h.w)
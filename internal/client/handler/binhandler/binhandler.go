@@ -17,14 +17,36 @@ import (
 
 const entity = "binary"
 
+type (
+	AddService interface {
+		Add(
+			ctx context.Context,
+			key, name string, obj dto.BIN, r io.Reader,
+		) (int, error)
+	}
+
+	EditService interface {
+		Edit(
+			ctx context.Context,
+			key string, entryNum int, name string, obj dto.BIN, r io.Reader,
+		) error
+	}
+
+	ReadService interface {
+		Read(
+			ctx context.Context, key string, entryNum int, w io.Writer,
+		) (dto.BIN, error)
+	}
+)
+
 type AddCmdHandler struct {
 	l logger.Logger
-	s handler.AddService[dto.BIN]
+	s AddService
 	w io.Writer
 }
 
 func NewAdd(
-	l logger.Logger, s handler.AddService[dto.BIN], w io.Writer,
+	l logger.Logger, s AddService, w io.Writer,
 ) *AddCmdHandler {
 	return &AddCmdHandler{l, s, w}
 }
@@ -34,13 +56,16 @@ func (h *AddCmdHandler) Handle(ctx context.Context, fv bincommand.AddCmdFlags) {
 
 	log := h.l.WithOp(op)
 
-	o, err := getBinDto(fv.Name, fv.Filepath)
+	f, o, err := openBinFile(fv.Name, fv.Filepath)
 	if err != nil {
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
+	defer f.Close()
 
-	entryNum, err := h.s.Add(ctx, fv.Key, fv.Name, o)
+	entryNum, err := h.s.Add(ctx, fv.Key, fv.Name, o, f)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		handler.HandleAlreadyExistsErr(err, log, h.w, entity, fv.Name)
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
@@ -50,12 +75,12 @@ func (h *AddCmdHandler) Handle(ctx context.Context, fv bincommand.AddCmdFlags) {
 
 type EditCmdHandler struct {
 	l logger.Logger
-	s handler.EditService[dto.BIN]
+	s EditService
 	w io.Writer
 }
 
 func NewEdit(
-	l logger.Logger, s handler.EditService[dto.BIN], w io.Writer,
+	l logger.Logger, s EditService, w io.Writer,
 ) *EditCmdHandler {
 	return &EditCmdHandler{l, s, w}
 }
@@ -67,13 +92,16 @@ func (h *EditCmdHandler) Handle(
 
 	log := h.l.WithOp(op)
 
-	o, err := getBinDto(fv.Name, fv.Filepath)
+	f, o, err := openBinFile(fv.Name, fv.Filepath)
 	if err != nil {
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
+	defer f.Close()
 
-	err = h.s.Edit(ctx, fv.Key, fv.EntryNum, fv.Name, o)
+	err = h.s.Edit(ctx, fv.Key, fv.EntryNum, fv.Name, o, f)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		handler.HandleAlreadyExistsErr(err, log, h.w, entity, fv.Name)
 		handler.HandleNotExistsErr(err, log, h.w, entity, fv.EntryNum)
 		handler.HandleUnexpectedErr(err, log, h.w)
@@ -84,12 +112,12 @@ func (h *EditCmdHandler) Handle(
 
 type ReadCmdHandler struct {
 	l logger.Logger
-	s handler.ReadService[dto.BIN]
+	s ReadService
 	w io.Writer
 }
 
 func NewRead(
-	l logger.Logger, s handler.ReadService[dto.BIN], w io.Writer,
+	l logger.Logger, s ReadService, w io.Writer,
 ) *ReadCmdHandler {
 	return &ReadCmdHandler{l, s, w}
 }
@@ -101,31 +129,45 @@ func (h *ReadCmdHandler) Handle(
 
 	log := h.l.WithOp(op)
 
-	obj, err := h.s.Read(ctx, fv.Key, fv.EntryNum)
+	f, fileErr := h.createFile(fv.Filepath)
+
+	var w io.Writer
+	if f != nil {
+		w = f
+	}
+
+	obj, err := h.s.Read(ctx, fv.Key, fv.EntryNum, w)
+	if f != nil {
+		f.Close()
+		if err != nil {
+			os.Remove(fv.Filepath)
+		}
+	}
 	if err != nil {
 		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		handler.HandleNotBoundErr(err, log, h.w)
 		handler.HandleNotExistsErr(err, log, h.w, entity, fv.EntryNum)
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
 
-	output := h.buildOutput(fv.EntryNum, fv.Filepath, obj)
+	output := h.buildOutput(fv.EntryNum, fv.Filepath, obj, fileErr)
 	h.printOutput(output)
 }
 
 func (h *ReadCmdHandler) buildOutput(
-	entryNum int, filepath string, o dto.BIN,
+	entryNum int, filepath string, o dto.BIN, fileErr error,
 ) string {
 	var b strings.Builder
 	b.WriteString(
 		fmt.Sprintf(
 			"the binary data with entry %d: name=%q size=%d ext=%q \n",
-			entryNum, o.Name, len(o.Data), o.Ext,
+			entryNum, o.Name, o.Size, o.Ext,
 		))
 
 	if h.writeToFile(filepath) {
-		err := h.writeData(filepath, o.Data)
-		if err != nil {
-			b.WriteString(err.Error() + "\n")
+		if fileErr != nil {
+			b.WriteString(fileErr.Error() + "\n")
 		} else {
 			b.WriteString(fmt.Sprintf("saved to filepath: %s\n", filepath))
 		}
@@ -134,12 +176,17 @@ func (h *ReadCmdHandler) buildOutput(
 	return b.String()
 }
 
-func (h *ReadCmdHandler) writeData(filepath string, data []byte) error {
-	err := writeData(filepath, data)
+// createFile creates the file the binary content is written to,
+// the existing file is never overwritten.
+func (h *ReadCmdHandler) createFile(filepath string) (*os.File, error) {
+	if !h.writeToFile(filepath) {
+		return nil, nil
+	}
+	f, err := createFile(filepath)
 	if err != nil {
-		return fmt.Errorf("write file error: %w", err)
+		return nil, fmt.Errorf("write file error: %w", err)
 	}
-	return nil
+	return f, nil
 }
 
 func (h *ReadCmdHandler) writeToFile(filepath string) bool {
@@ -173,15 +220,15 @@ func NewRemove(
 	}
 }
 
-func getBinDto(name, path string) (dto.BIN, error) {
-	data, ext, err := getFileData(path)
+func openBinFile(name, path string) (*os.File, dto.BIN, error) {
+	f, ext, err := openFile(path)
 	if err != nil {
-		return dto.BIN{}, fmt.Errorf("file error: %w", err)
+		return nil, dto.BIN{}, fmt.Errorf("file error: %w", err)
 	}
-	return dto.BIN{Name: name, Data: data, Ext: ext}, nil
+	return f, dto.BIN{Name: name, Ext: ext}, nil
 }
 
-func getFileData(path string) ([]byte, string, error) {
+func openFile(path string) (*os.File, string, error) {
 	path, err := filepath.Abs(path)
 	if err != nil {
 		return nil, "", err
@@ -191,12 +238,12 @@ func getFileData(path string) ([]byte, string, error) {
 		return nil, "", err
 	}
 
-	data, err := os.ReadFile(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, "", err
 	}
 	ext := filepath.Ext(path)
-	return data, ext, nil
+	return f, ext, nil
 }
 
 func verifyReadingFile(path string) error {
@@ -208,26 +255,13 @@ func verifyReadingFile(path string) error {
 	if stat.IsDir() {
 		return errors.New("filepath is directory")
 	}
-
-	if stat.Size() > 1024*1024*100 {
-		return errors.New("the file size should be less or equal 100Mb")
-	}
 	return nil
 }
 
-func writeData(path string, data []byte) error {
-	if path == "" {
-		return nil
-	}
-
-	if fileExists(path) {
-		return fmt.Errorf("the file is exists")
+func createFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("the file is exists")
 	}
-
-	return os.WriteFile(path, data, 0o644)
-}
-
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return !errors.Is(err, os.ErrNotExist)
+	return f, err
 }
@@ -40,6 +40,8 @@ func (h *AddCmdHandler) Handle(
 	}
 	entryNum, err := h.s.Add(ctx, fv.Key, fv.Name, o)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		handler.HandleAlreadyExistsErr(err, log, h.w, entity, fv.Name)
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
@@ -74,6 +76,8 @@ func (h *EditCmdHandler) Handle(
 	}
 	err := h.s.Edit(ctx, fv.Key, fv.EntryNum, fv.Name, o)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		handler.HandleAlreadyExistsErr(err, log, h.w, entity, fv.Name)
 		handler.HandleNotExistsErr(err, log, h.w, entity, fv.EntryNum)
 		handler.HandleUnexpectedErr(err, log, h.w)
@@ -104,6 +108,8 @@ func (h *ReadCmdHandler) Handle(
 	obj, err := h.s.Read(ctx, key, entryNum)
 	if err != nil {
 		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		handler.HandleNotBoundErr(err, log, h.w)
 		handler.HandleNotExistsErr(err, log, h.w, entity, entryNum)
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
@@ -0,0 +1,150 @@
+package conflicthandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/command/conflictcommand"
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/handler"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+type (
+	ConflictLister interface {
+		List(ctx context.Context, key string) ([]dto.Conflict, error)
+	}
+
+	ConflictResolver interface {
+		KeepEntry(ctx context.Context, id int) (int, error)
+		KeepCopy(ctx context.Context, id int) (int, error)
+		KeepBoth(ctx context.Context, key string, id int) (int, string, error)
+	}
+)
+
+const timeLayout = time.DateTime
+
+type ListHandler struct {
+	l logger.Logger
+	s ConflictLister
+	w io.Writer
+}
+
+func NewList(l logger.Logger, s ConflictLister, w io.Writer) *ListHandler {
+	return &ListHandler{l, s, w}
+}
+
+func (h *ListHandler) Handle(ctx context.Context, key string) {
+	const op = "ListHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	conflicts, err := h.s.List(ctx, key)
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	h.printOutput(conflicts)
+}
+
+func (h *ListHandler) printOutput(conflicts []dto.Conflict) {
+	if len(conflicts) == 0 {
+		fmt.Fprintln(h.w, "there are no conflicts")
+		return
+	}
+
+	tw := tabwriter.NewWriter(h.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tENTITY\tENTRY\tCOPY NAME\tCOPY CHANGED\tREASON")
+	for _, c := range conflicts {
+		name := c.Name
+		if c.Deleted {
+			name = "(deleted)"
+		}
+		reason := "changed on both sides"
+		if c.SameName {
+			reason = "same name"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n",
+			c.ID, c.Entity, c.EntryNum, name,
+			c.UpdatedAt.Local().Format(timeLayout), reason)
+	}
+	tw.Flush()
+}
+
+type ResolveHandler struct {
+	l logger.Logger
+	s ConflictResolver
+	w io.Writer
+}
+
+func NewResolve(
+	l logger.Logger, s ConflictResolver, w io.Writer,
+) *ResolveHandler {
+	return &ResolveHandler{l, s, w}
+}
+
+func (h *ResolveHandler) Handle(
+	ctx context.Context, fv conflictcommand.ResolveCmdFlags,
+) {
+	const op = "ResolveHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	var (
+		entryNum int
+		name     string
+		err      error
+	)
+	switch fv.Keep {
+	case conflictcommand.KeepEntry:
+		entryNum, err = h.s.KeepEntry(ctx, fv.ID)
+	case conflictcommand.KeepCopy:
+		entryNum, err = h.s.KeepCopy(ctx, fv.ID)
+	case conflictcommand.KeepBoth:
+		entryNum, name, err = h.s.KeepBoth(ctx, fv.Key, fv.ID)
+	default:
+		fmt.Fprintf(h.w, "invalid --%s value %q, use %s, %s or %s\n",
+			conflictcommand.KeepFlag, fv.Keep, conflictcommand.KeepEntry,
+			conflictcommand.KeepCopy, conflictcommand.KeepBoth)
+		os.Exit(1)
+	}
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		h.handleResolveErr(err, fv.ID)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	if name != "" {
+		fmt.Fprintf(h.w,
+			"the conflict copy is saved as '%s' under the record number %d\n",
+			name, entryNum)
+		return
+	}
+	fmt.Fprintf(h.w,
+		"the conflict is resolved, the record number %d is kept\n", entryNum)
+}
+
+func (h *ResolveHandler) handleResolveErr(err error, id int) {
+	switch {
+	case errors.Is(err, service.ErrNotExists):
+		fmt.Fprintf(h.w, "the conflict %d is not exists\n", id)
+	case errors.Is(err, service.ErrDeletedCopy):
+		fmt.Fprintln(h.w,
+			"the conflict copy is the deleted version, keep the entry or the copy")
+	case errors.Is(err, service.ErrAlreadyExists):
+		fmt.Fprintln(h.w,
+			"the name of the conflict copy is taken by another entry, keep both")
+	default:
+		return
+	}
+	os.Exit(1)
+}
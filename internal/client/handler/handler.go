@@ -22,7 +22,7 @@ type (
 	}
 
 	ListService interface {
-		List(context.Context) ([][2]string, error)
+		List(ctx context.Context, key string) ([][2]string, error)
 	}
 
 	EditService[T any] interface {
@@ -44,13 +44,16 @@ type ListCmdHandler struct {
 	Name, NamePlural string
 }
 
-func (h *ListCmdHandler) Handle(ctx context.Context) {
+func (h *ListCmdHandler) Handle(ctx context.Context, key string) {
 	const op = "ListCmdHandler.Handle"
 
 	log := h.Log.WithOp(op)
 
-	idNamePairs, err := h.Service.List(ctx)
+	idNamePairs, err := h.Service.List(ctx, key)
 	if err != nil {
+		HandleInvalidKeyErr(err, log, h.Writer)
+		HandleVaultLockedErr(err, log, h.Writer)
+		HandleNotBoundErr(err, log, h.Writer)
 		HandleUnexpectedErr(err, log, h.Writer)
 	}
 
@@ -144,7 +147,20 @@ func HandleInvalidKeyErr(err error, l logger.Logger, w io.Writer) {
 
 	l.Debug().Err(err).Msg("invalid key")
 
-	fmt.Fprintln(w, "invalid key provided")
+	fmt.Fprintln(w, "invalid key provided, it does not match the vault master key")
+	os.Exit(1)
+}
+
+func HandleNotBoundErr(err error, l logger.Logger, w io.Writer) {
+	if !errors.Is(err, service.ErrNotBound) {
+		return
+	}
+
+	l.Debug().Err(err).Msg("not bound")
+
+	fmt.Fprintln(w,
+		"the entry is not bound to its record, run vault upgrade "+
+			"if it was saved by an older client")
 	os.Exit(1)
 }
 
@@ -159,3 +175,14 @@ func HandleUnexpectedErr(err error, log logger.Logger, w io.Writer) {
 		"the application completed with an error: %s\n", err.Error())
 	os.Exit(1)
 }
+
+func HandleVaultLockedErr(err error, l logger.Logger, w io.Writer) {
+	if !errors.Is(err, service.ErrVaultLocked) {
+		return
+	}
+
+	l.Debug().Err(err).Msg("vault is locked")
+
+	fmt.Fprintln(w, "the vault is locked, provide the key or run unlock")
+	os.Exit(1)
+}
@@ -35,6 +35,8 @@ func (h *AddCmdHandler) Handle(
 	o := dto.PWD{Name: fv.Name, Login: fv.Login, Password: fv.Password}
 	entryNum, err := h.s.Add(ctx, fv.Key, fv.Name, o)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		handler.HandleAlreadyExistsErr(err, log, h.w, entity, fv.Name)
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
@@ -64,6 +66,8 @@ func (h *EditCmdHandler) Handle(
 	o := dto.PWD{Name: fv.Name, Login: fv.Login, Password: fv.Password}
 	err := h.s.Edit(ctx, fv.Key, fv.EntryNum, fv.Name, o)
 	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
 		handler.HandleAlreadyExistsErr(err, log, h.w, entity, fv.Name)
 		handler.HandleNotExistsErr(err, log, h.w, entity, fv.EntryNum)
 		handler.HandleUnexpectedErr(err, log, h.w)
@@ -94,6 +98,8 @@ func (h *ReadCmdHandler) Handle(
 	obj, err := h.s.Read(ctx, key, entryNum)
 	if err != nil {
 		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		handler.HandleNotBoundErr(err, log, h.w)
 		handler.HandleNotExistsErr(err, log, h.w, entity, entryNum)
 		handler.HandleUnexpectedErr(err, log, h.w)
 	}
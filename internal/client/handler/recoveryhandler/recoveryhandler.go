@@ -0,0 +1,183 @@
+package recoveryhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/niksmo/gophkeeper/internal/client/command/recoverycommand"
+	"github.com/niksmo/gophkeeper/internal/client/handler"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/shamir"
+)
+
+type (
+	KeySplitter interface {
+		Split(
+			ctx context.Context, key string, shares, threshold int,
+		) ([][]byte, error)
+	}
+
+	KeyCombiner interface {
+		Combine(ctx context.Context, files [][]byte) (string, error)
+	}
+)
+
+type SplitHandler struct {
+	l logger.Logger
+	s KeySplitter
+	w io.Writer
+}
+
+func NewSplit(l logger.Logger, s KeySplitter, w io.Writer) *SplitHandler {
+	return &SplitHandler{l, s, w}
+}
+
+func (h *SplitHandler) Handle(
+	ctx context.Context, fv recoverycommand.SplitCmdFlags,
+) {
+	const op = "SplitHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	paths := sharePaths(fv.Dir, fv.Shares)
+	for _, path := range paths {
+		if fileExists(path) {
+			fmt.Fprintf(h.w, "the file %s is exists\n", path)
+			os.Exit(1)
+		}
+	}
+
+	files, err := h.s.Split(ctx, fv.Key, fv.Shares, fv.Threshold)
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		h.handleInvalidParamsErr(err)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	if err := writeFiles(paths, files); err != nil {
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	fmt.Fprintf(h.w,
+		"the master key is split into %d shares, any %d of them "+
+			"recover the key:\n", fv.Shares, fv.Threshold)
+	for _, path := range paths {
+		fmt.Fprintln(h.w, path)
+	}
+}
+
+func (h *SplitHandler) handleInvalidParamsErr(err error) {
+	if !errors.Is(err, shamir.ErrInvalidParams) {
+		return
+	}
+	fmt.Fprintf(h.w,
+		"the threshold should be at least 2 and at most the shares number, "+
+			"the shares number should be at most %d\n", shamir.MaxShares)
+	os.Exit(1)
+}
+
+type CombineHandler struct {
+	l logger.Logger
+	s KeyCombiner
+	w io.Writer
+}
+
+func NewCombine(l logger.Logger, s KeyCombiner, w io.Writer) *CombineHandler {
+	return &CombineHandler{l, s, w}
+}
+
+func (h *CombineHandler) Handle(
+	ctx context.Context, fv recoverycommand.CombineCmdFlags,
+) {
+	const op = "CombineHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	files := make([][]byte, len(fv.Files))
+	for i, path := range fv.Files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			handler.HandleUnexpectedErr(
+				fmt.Errorf("file error: %w", err), log, h.w,
+			)
+		}
+		files[i] = b
+	}
+
+	key, err := h.s.Combine(ctx, files)
+	if err != nil {
+		h.handleRecoveryErr(err)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		if errors.Is(err, service.ErrInvalidKey) {
+			fmt.Fprintln(h.w,
+				"the recovered key does not match the vault master key")
+			os.Exit(1)
+		}
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	fmt.Fprintf(h.w, "the vault master key is recovered: %s\n", key)
+}
+
+func (h *CombineHandler) handleRecoveryErr(err error) {
+	var msg string
+	switch {
+	case errors.Is(err, service.ErrInvalidShare):
+		msg = "the share file is damaged or is not a recovery share"
+	case errors.Is(err, service.ErrNotEnoughShares):
+		msg = "not enough shares to recover the master key"
+	case errors.Is(err, service.ErrDifferentSplits):
+		msg = "the shares are produced by different splits"
+	default:
+		return
+	}
+	fmt.Fprintln(h.w, msg)
+	os.Exit(1)
+}
+
+func sharePaths(dir string, shares int) []string {
+	paths := make([]string, max(shares, 0))
+	for i := range paths {
+		paths[i] = filepath.Join(
+			dir, fmt.Sprintf("gophkeeper-share-%d-of-%d.txt", i+1, shares),
+		)
+	}
+	return paths
+}
+
+// writeFiles writes every share file or none of them.
+func writeFiles(paths []string, files [][]byte) error {
+	for i, path := range paths {
+		if err := writeFile(path, files[i]); err != nil {
+			for _, written := range paths[:i] {
+				os.Remove(written)
+			}
+			return fmt.Errorf("write file error: %w", err)
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, os.ErrNotExist)
+}
@@ -2,17 +2,44 @@ package synchandler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"os"
 	"os/signal"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/niksmo/gophkeeper/internal/client/command/synccommand"
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/handler"
+	"github.com/niksmo/gophkeeper/internal/client/service/authservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/syncservice"
 	"github.com/niksmo/gophkeeper/pkg/logger"
 )
 
-type SyncRunner interface {
-	Run(ctx context.Context, token string)
-}
+type (
+	SyncRunner interface {
+		Run(ctx context.Context, token string) error
+	}
+
+	SyncPauser interface {
+		PauseSynchronization(context.Context) error
+	}
+
+	OneShotSyncer interface {
+		SyncNow(
+			ctx context.Context, key string, dryRun bool,
+		) ([]syncservice.SyncResult, error)
+	}
+
+	StatusReader interface {
+		Status(context.Context) (syncservice.SyncStatus, error)
+	}
+)
+
+const timeLayout = time.DateTime
 
 type StartHandler struct {
 	l logger.Logger
@@ -25,14 +52,201 @@ func NewStart(l logger.Logger, s SyncRunner, w io.Writer) *StartHandler {
 }
 
 func (h *StartHandler) Handle(ctx context.Context, fv synccommand.StartCmdFlags) {
-	const op = "SignupHandler.Handle"
+	const op = "StartHandler.Handle"
 
-	h.l.Debug().Str("op", op).Msg("handle start")
+	log := h.l.WithOp(op)
 
 	ctx, stop := signal.NotifyContext(
 		ctx, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT,
 	)
 	defer stop()
 
-	h.s.Run(ctx, fv.Token)
+	if err := h.s.Run(ctx, fv.Token); err != nil {
+		log.Error().Err(err).Msg("synchronization failed")
+		os.Exit(1)
+	}
+}
+
+type PauseHandler struct {
+	l logger.Logger
+	s SyncPauser
+	w io.Writer
+}
+
+func NewPause(l logger.Logger, s SyncPauser, w io.Writer) *PauseHandler {
+	return &PauseHandler{l, s, w}
+}
+
+func (h *PauseHandler) Handle(ctx context.Context) {
+	const op = "PauseHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	err := h.s.PauseSynchronization(ctx)
+	if err != nil {
+		handleNoSyncErr(err, h.w)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	fmt.Fprintln(h.w, "synchronization paused")
+}
+
+type StatusHandler struct {
+	l logger.Logger
+	s StatusReader
+	w io.Writer
+}
+
+func NewStatus(l logger.Logger, s StatusReader, w io.Writer) *StatusHandler {
+	return &StatusHandler{l, s, w}
+}
+
+func (h *StatusHandler) Handle(ctx context.Context) {
+	const op = "StatusHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	status, err := h.s.Status(ctx)
+	if err != nil {
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+	h.printOutput(status)
+}
+
+func (h *StatusHandler) printOutput(o syncservice.SyncStatus) {
+	daemon := o.State
+	if o.State != syncservice.StateStopped {
+		daemon = fmt.Sprintf("%s (PID %d, since %s)",
+			o.State, o.PID, o.StartedAt.Local().Format(timeLayout))
+	}
+	if o.State == syncservice.StateExpired {
+		daemon += ", the session is expired, sign in again to resume"
+	}
+	if o.RetryAt != nil {
+		daemon += fmt.Sprintf(", the server is unavailable, retry at %s",
+			o.RetryAt.Local().Format(timeLayout))
+	}
+
+	login := o.Login
+	switch {
+	case login == "":
+		login = "not signed in"
+	case o.SessionExpired:
+		login += " (the session is expired, sign in again)"
+	}
+
+	fmt.Fprintf(h.w, "daemon: %s\nserver: %s\nlogin:  %s\n\n",
+		daemon, o.ServerAddr, login)
+
+	tw := tabwriter.NewWriter(h.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENTITY\tLAST SYNC\tPENDING\tLAST ERROR")
+	for _, e := range o.Entities {
+		syncedAt := "never"
+		if e.SyncedAt != nil {
+			syncedAt = e.SyncedAt.Local().Format(timeLayout)
+		}
+		lastErr := e.Error
+		if lastErr == "" {
+			lastErr = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n",
+			e.Entity, syncedAt, e.Pending, lastErr)
+	}
+	tw.Flush()
+}
+
+type NowHandler struct {
+	l logger.Logger
+	s OneShotSyncer
+	w io.Writer
+}
+
+func NewNow(l logger.Logger, s OneShotSyncer, w io.Writer) *NowHandler {
+	return &NowHandler{l, s, w}
+}
+
+func (h *NowHandler) Handle(ctx context.Context, fv synccommand.NowCmdFlags) {
+	const op = "NowHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	results, err := h.s.SyncNow(ctx, fv.Key, fv.DryRun)
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleVaultLockedErr(err, log, h.w)
+		handleSessionErr(err, h.w)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	var failed bool
+	for _, r := range results {
+		if r.Err != nil {
+			failed = true
+			h.printFailed(r)
+			continue
+		}
+		if fv.DryRun {
+			h.printPlan(r.SyncPlan)
+			continue
+		}
+		h.printSummary(r.SyncPlan)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func (h *NowHandler) printSummary(p dto.SyncPlan) {
+	fmt.Fprintf(h.w,
+		"%s: %d sent, %d updated on server, %d received, %d updated locally",
+		p.Entity, len(p.InsertToServer), len(p.UpdateToServer),
+		len(p.InsertFromServer), len(p.UpdateFromServer))
+	if len(p.Conflicts) != 0 {
+		fmt.Fprintf(h.w,
+			", %d conflicts, see the conflicts command", len(p.Conflicts))
+	}
+	fmt.Fprintln(h.w)
+}
+
+func (h *NowHandler) printPlan(p dto.SyncPlan) {
+	fmt.Fprintf(h.w, "%s:\n", p.Entity)
+	tw := tabwriter.NewWriter(h.w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "  insert to server:\tlocal entries %v\n", p.InsertToServer)
+	fmt.Fprintf(tw, "  update on server:\tlocal entries %v\n", p.UpdateToServer)
+	fmt.Fprintf(tw, "  insert from server:\tserver IDs %v\n", p.InsertFromServer)
+	fmt.Fprintf(tw, "  update from server:\tserver IDs %v\n", p.UpdateFromServer)
+	fmt.Fprintf(tw, "  conflicts:\tlocal entries %v\n", p.Conflicts)
+	tw.Flush()
+}
+
+func (h *NowHandler) printFailed(r syncservice.SyncResult) {
+	msg := r.Err.Error()
+	switch {
+	case errors.Is(r.Err, syncservice.ErrUnauthenticated):
+		msg = "the sync session is expired, sign in again"
+	case errors.Is(r.Err, syncservice.ErrServerUnavailable):
+		msg = syncservice.ErrServerUnavailable.Error()
+	}
+	fmt.Fprintf(h.w, "%s: failed: %s\n", r.Entity, msg)
+}
+
+func handleSessionErr(err error, w io.Writer) {
+	switch {
+	case errors.Is(err, authservice.ErrNoSession):
+		fmt.Fprintln(w, "there is no saved sync session, sign in first")
+	case errors.Is(err, authservice.ErrSessionExpired):
+		fmt.Fprintln(w, "the sync session is expired, sign in again")
+	default:
+		return
+	}
+	os.Exit(1)
+}
+
+func handleNoSyncErr(err error, w io.Writer) {
+	if !errors.Is(err, syncservice.ErrNoSync) {
+		return
+	}
+	fmt.Fprintln(w, "synchronization is not running")
+	os.Exit(1)
 }
@@ -0,0 +1,90 @@
+package vaulthandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/niksmo/gophkeeper/internal/client/command/vaultcommand"
+	"github.com/niksmo/gophkeeper/internal/client/handler"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+type (
+	VaultUpgrader interface {
+		Upgrade(ctx context.Context, key string) (int, error)
+	}
+
+	VaultRekeyer interface {
+		Rekey(ctx context.Context, key, newKey string) (int, error)
+	}
+)
+
+type UpgradeHandler struct {
+	l logger.Logger
+	s VaultUpgrader
+	w io.Writer
+}
+
+func NewUpgrade(
+	l logger.Logger, s VaultUpgrader, w io.Writer,
+) *UpgradeHandler {
+	return &UpgradeHandler{l, s, w}
+}
+
+func (h *UpgradeHandler) Handle(
+	ctx context.Context, fv vaultcommand.UpgradeCmdFlags,
+) {
+	const op = "UpgradeHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	n, err := h.s.Upgrade(ctx, fv.Key)
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		h.handleNotUniqueErr(err)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	fmt.Fprintf(h.w, "the vault is upgraded, reencrypted entries: %d\n", n)
+}
+
+func (h *UpgradeHandler) handleNotUniqueErr(err error) {
+	if !errors.Is(err, service.ErrAlreadyExists) {
+		return
+	}
+	fmt.Fprintln(h.w,
+		"the vault has entries with the same name, rename them and try again")
+	os.Exit(1)
+}
+
+type RekeyHandler struct {
+	l logger.Logger
+	s VaultRekeyer
+	w io.Writer
+}
+
+func NewRekey(
+	l logger.Logger, s VaultRekeyer, w io.Writer,
+) *RekeyHandler {
+	return &RekeyHandler{l, s, w}
+}
+
+func (h *RekeyHandler) Handle(
+	ctx context.Context, fv vaultcommand.RekeyCmdFlags,
+) {
+	const op = "RekeyHandler.Handle"
+
+	log := h.l.WithOp(op)
+
+	n, err := h.s.Rekey(ctx, fv.Key, fv.NewKey)
+	if err != nil {
+		handler.HandleInvalidKeyErr(err, log, h.w)
+		handler.HandleUnexpectedErr(err, log, h.w)
+	}
+
+	fmt.Fprintf(h.w, "the vault key is changed, reencrypted entries: %d\n", n)
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+// BinRepository keeps the binary content as the stream of chunks,
+// the data of the binary is the encrypted description of the content.
+type BinRepository struct {
+	*Repository
+}
+
+func NewBinStream(l logger.Logger, db Storage) *BinRepository {
+	return &BinRepository{NewBin(l, db)}
+}
+
+// CreateStream saves the binary with the record UUID and the stream
+// written by the write, the write returns the data and the content hash
+// of the binary.
+func (r *BinRepository) CreateStream(
+	ctx context.Context,
+	entryUUID string,
+	nameIndex string,
+	name []byte,
+	write func(w io.Writer) ([]byte, string, error),
+) (int, error) {
+	const op = "BinRepository.CreateStream"
+	log := r.log.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	streamID := newStreamID()
+	data, hash, err := writeStream(ctx, tx, streamID, write)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to write stream")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to tick clock")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int
+	t := time.Now()
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO binaries
+		  (uuid, name_index, name, data, stream_id, created_at, updated_at,
+		  clock, hash, hash_clock)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id;`,
+		entryUUID, nameIndex, name, data, streamID, t, t, clock,
+		nullHash(hash), clock,
+	).Scan(&id)
+	if err != nil {
+		if isSQLiteEniqueErr(err) {
+			log.Debug().Err(err).Msg("object already exists")
+			return 0, fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+		}
+		log.Error().Err(err).Msg("failed to insert")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = appendOutbox(ctx, tx, binaries, int64(id), opCreate, clock)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to append outbox")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// UpdateStream replaces the binary and its stream.
+func (r *BinRepository) UpdateStream(
+	ctx context.Context,
+	entryNum int,
+	nameIndex string,
+	name []byte,
+	write func(w io.Writer) ([]byte, string, error),
+) error {
+	const op = "BinRepository.UpdateStream"
+	log := r.log.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	oldStreamID, err := selectStreamID(ctx, tx, entryNum)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Err(err).Msg("object is not exists")
+			return fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select stream")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	streamID := newStreamID()
+	data, hash, err := writeStream(ctx, tx, streamID, write)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to write stream")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to tick clock")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE binaries SET
+		  name_index=?, name=?, data=?, stream_id=?, updated_at=?, clock=?,
+		  hash=?, hash_clock=?
+		WHERE id=?;`,
+		nameIndex, name, data, streamID, time.Now(), clock,
+		nullHash(hash), clock, entryNum,
+	)
+	if err != nil {
+		if isSQLiteEniqueErr(err) {
+			log.Debug().Err(err).Msg("object already exists")
+			return fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+		}
+		log.Error().Err(err).Msg("failed to update")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = appendOutbox(ctx, tx, binaries, int64(entryNum), opUpdate, clock)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to append outbox")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := deleteStream(ctx, tx, oldStreamID); err != nil {
+		log.Error().Err(err).Msg("failed to delete old stream")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ReadStream returns the record UUID, the name index, the data and
+// the stream reader of the binary. The stream is nil for the binary saved
+// before the streams.
+func (r *BinRepository) ReadStream(
+	ctx context.Context, id int,
+) (SealedEntry, error) {
+	const op = "BinRepository.ReadStream"
+	log := r.log.WithOp(op)
+
+	var (
+		entryUUID sql.NullString
+		nameIndex sql.NullString
+		streamID  sql.NullString
+	)
+	e := SealedEntry{Entity: binaries}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT uuid, name_index, data, stream_id FROM binaries
+		WHERE id=? AND deleted=FALSE;`, id,
+	).Scan(&entryUUID, &nameIndex, &e.Data, &streamID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Err(err).Msg("object is not exists")
+			return SealedEntry{}, fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select row")
+		return SealedEntry{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	e.UUID, e.Index = entryUUID.String, nameIndex.String
+	if streamID.Valid {
+		e.Stream = newChunkReader(ctx, r.db, streamID.String)
+	}
+	return e, nil
+}
+
+// Delete marks the binary deleted and removes its stream.
+func (r *BinRepository) Delete(ctx context.Context, entryNum int) error {
+	const op = "BinRepository.Delete"
+	log := r.log.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	streamID, err := selectStreamID(ctx, tx, entryNum)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Err(err).Msg("object is not exists")
+			return fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select stream")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to tick clock")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE binaries SET
+		  name_index=NULL, name=NULL, data=NULL, stream_id=NULL,
+		  updated_at=?, clock=?, deleted=TRUE
+		WHERE id=?;`, time.Now(), clock, entryNum,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = appendOutbox(ctx, tx, binaries, int64(entryNum), opDelete, clock)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to append outbox")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := deleteStream(ctx, tx, streamID); err != nil {
+		log.Error().Err(err).Msg("failed to delete stream")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func selectStreamID(
+	ctx context.Context, db execQuerier, entryNum int,
+) (string, error) {
+	var streamID sql.NullString
+	err := db.QueryRowContext(ctx,
+		`SELECT stream_id FROM binaries WHERE id=?;`, entryNum,
+	).Scan(&streamID)
+	return streamID.String, err
+}
+
+func writeStream(
+	ctx context.Context,
+	db execQuerier,
+	streamID string,
+	write func(w io.Writer) ([]byte, string, error),
+) ([]byte, string, error) {
+	w := newChunkWriter(ctx, db, streamID)
+	data, hash, err := write(w)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return data, hash, nil
+}
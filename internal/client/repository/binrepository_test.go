@@ -0,0 +1,208 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type binRepoSuite struct {
+	ctx context.Context
+	log logger.Logger
+	r   *repository.BinRepository
+	s   *storage.Storage
+}
+
+func newBinSuite(t *testing.T) *binRepoSuite {
+	log := logger.NewPretty("debug")
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		os.Remove(dsn)
+	})
+
+	s := storage.New(log, dsn)
+	s.MustRun(ctx)
+	r := repository.NewBinStream(log, s)
+	return &binRepoSuite{ctx, log, r, s}
+}
+
+func writeContent(
+	data, content []byte,
+) func(w io.Writer) ([]byte, string, error) {
+	return func(w io.Writer) ([]byte, string, error) {
+		_, err := w.Write(content)
+		return data, "hash", err
+	}
+}
+
+// bytesStream is the payload stream of the data downloaded from the server.
+type bytesStream []byte
+
+func (s bytesStream) Open(context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(s)), nil
+}
+
+func (s bytesStream) Size() int64 {
+	return int64(len(s))
+}
+
+func (st *binRepoSuite) countChunks(t *testing.T) int {
+	t.Helper()
+	var n int
+	err := st.s.QueryRowContext(st.ctx,
+		`SELECT COUNT(*) FROM binary_chunks;`,
+	).Scan(&n)
+	require.NoError(t, err)
+	return n
+}
+
+func TestBinStream(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 20*1024)
+
+	t.Run("CreateRead", func(t *testing.T) {
+		st := newBinSuite(t)
+		id, err := st.r.CreateStream(st.ctx, uuid.New(), "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.NoError(t, err)
+		assert.Equal(t, 1, id)
+		assert.Equal(t, 4, st.countChunks(t))
+
+		e, err := st.r.ReadStream(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "index", e.Index)
+		assert.Equal(t, []byte("data"), e.Data)
+		actual, err := io.ReadAll(e.Stream)
+		require.NoError(t, err)
+		assert.Equal(t, content, actual)
+	})
+
+	t.Run("AlreadyExists", func(t *testing.T) {
+		st := newBinSuite(t)
+		_, err := st.r.CreateStream(st.ctx, uuid.New(), "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.NoError(t, err)
+
+		_, err = st.r.CreateStream(st.ctx, uuid.New(), "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.ErrorIs(t, err, repository.ErrAlreadyExists)
+		assert.Equal(t, 4, st.countChunks(t))
+	})
+
+	t.Run("WithoutStream", func(t *testing.T) {
+		st := newBinSuite(t)
+		id, err := st.r.Create(st.ctx, uuid.New(), "index", []byte("name"), []byte("data"), "")
+		require.NoError(t, err)
+
+		e, err := st.r.ReadStream(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), e.Data)
+		assert.Nil(t, e.Stream)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		st := newBinSuite(t)
+		id, err := st.r.CreateStream(st.ctx, uuid.New(), "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.NoError(t, err)
+
+		err = st.r.UpdateStream(st.ctx, id, "newIndex", []byte("newName"),
+			writeContent([]byte("newData"), []byte("newContent")))
+		require.NoError(t, err)
+		assert.Equal(t, 1, st.countChunks(t))
+
+		e, err := st.r.ReadStream(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "newIndex", e.Index)
+		assert.Equal(t, []byte("newData"), e.Data)
+		actual, err := io.ReadAll(e.Stream)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("newContent"), actual)
+	})
+
+	t.Run("UpdateNotExists", func(t *testing.T) {
+		st := newBinSuite(t)
+		err := st.r.UpdateStream(st.ctx, 1, "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.ErrorIs(t, err, repository.ErrNotExists)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		st := newBinSuite(t)
+		id, err := st.r.CreateStream(st.ctx, uuid.New(), "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.NoError(t, err)
+
+		require.NoError(t, st.r.Delete(st.ctx, id))
+		assert.Zero(t, st.countChunks(t))
+
+		_, err = st.r.ReadStream(st.ctx, id)
+		require.ErrorIs(t, err, repository.ErrNotExists)
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		src := newBinSuite(t)
+		_, err := src.r.CreateStream(src.ctx, uuid.New(), "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.NoError(t, err)
+
+		payload, err := repository.NewBinSync(src.log, src.s).GetAll(src.ctx, 0, 100)
+		require.NoError(t, err)
+		require.Len(t, payload, 1)
+		payload[0].SyncID = 1
+
+		dst := newBinSuite(t)
+		err = repository.NewBinSync(dst.log, dst.s).InsertSlice(dst.ctx, payload)
+		require.NoError(t, err)
+
+		e, err := dst.r.ReadStream(dst.ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, payload[0].UUID, e.UUID)
+		assert.Equal(t, "index", e.Index)
+		assert.Equal(t, []byte("data"), e.Data)
+		actual, err := io.ReadAll(e.Stream)
+		require.NoError(t, err)
+		assert.Equal(t, content, actual)
+	})
+	t.Run("SyncStream", func(t *testing.T) {
+		src := newBinSuite(t)
+		_, err := src.r.CreateStream(src.ctx, uuid.New(), "index", []byte("name"),
+			writeContent([]byte("data"), content))
+		require.NoError(t, err)
+
+		payload, err := repository.NewBinSync(src.log, src.s).GetAll(src.ctx, 0, 100)
+		require.NoError(t, err)
+		require.Len(t, payload, 1)
+		payload[0].SyncID = 1
+		require.NotNil(t, payload[0].Stream)
+		assert.Equal(t, int64(len(content)), payload[0].Stream.Size())
+		r, err := payload[0].OpenData(src.ctx)
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+		payload[0].Data, payload[0].Stream = data[:2], bytesStream(data[2:])
+
+		dst := newBinSuite(t)
+		err = repository.NewBinSync(dst.log, dst.s).InsertSlice(dst.ctx, payload)
+		require.NoError(t, err)
+
+		e, err := dst.r.ReadStream(dst.ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), e.Data)
+		actual, err := io.ReadAll(e.Stream)
+		require.NoError(t, err)
+		assert.Equal(t, content, actual)
+	})
+}
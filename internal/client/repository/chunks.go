@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// chunkSize is the size of the stream part kept in one row,
+// so the stream is never loaded in memory as a whole.
+const chunkSize = 64 * 1024
+
+// streamMagic prefixes the sync payload data of the binary with the stream.
+const streamMagic = "GKS\x01"
+
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func newStreamID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// chunkWriter splits the stream by the rows of the binary_chunks table.
+type chunkWriter struct {
+	ctx      context.Context
+	db       execQuerier
+	streamID string
+	seq      int
+	buf      []byte
+}
+
+func newChunkWriter(
+	ctx context.Context, db execQuerier, streamID string,
+) *chunkWriter {
+	return &chunkWriter{
+		ctx: ctx, db: db, streamID: streamID,
+		buf: make([]byte, 0, chunkSize),
+	}
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		m := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+		if len(w.buf) == chunkSize {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close writes the rest of the stream.
+func (w *chunkWriter) Close() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *chunkWriter) flush() error {
+	_, err := w.db.ExecContext(w.ctx,
+		`INSERT INTO binary_chunks (stream_id, seq, data) VALUES (?, ?, ?);`,
+		w.streamID, w.seq, w.buf,
+	)
+	if err != nil {
+		return err
+	}
+	w.seq++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// chunkReader reads the stream row by row.
+type chunkReader struct {
+	ctx      context.Context
+	db       execQuerier
+	streamID string
+	seq      int
+	buf      []byte
+}
+
+func newChunkReader(
+	ctx context.Context, db execQuerier, streamID string,
+) *chunkReader {
+	return &chunkReader{ctx: ctx, db: db, streamID: streamID}
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		err := r.db.QueryRowContext(r.ctx,
+			`SELECT data FROM binary_chunks WHERE stream_id=? AND seq=?;`,
+			r.streamID, r.seq,
+		).Scan(&r.buf)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		r.seq++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func deleteStream(ctx context.Context, db execQuerier, streamID string) error {
+	if streamID == "" {
+		return nil
+	}
+	_, err := db.ExecContext(ctx,
+		`DELETE FROM binary_chunks WHERE stream_id=?;`, streamID,
+	)
+	return err
+}
+
+// packHead returns the sync payload data of the binary up to the stream,
+// it is the magic, the data length and the data. The payload data is
+// followed by the stream, see chunkStream.
+func packHead(data []byte) []byte {
+	b := make([]byte, 0, len(streamMagic)+binary.MaxVarintLen64+len(data))
+	b = append(b, streamMagic...)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// chunkStream is the stream of the binary read from its chunks,
+// see model.Stream.
+type chunkStream struct {
+	db       execQuerier
+	streamID string
+	size     int64
+}
+
+func (s *chunkStream) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(newChunkReader(ctx, s.db, s.streamID)), nil
+}
+
+func (s *chunkStream) Size() int64 {
+	return s.size
+}
+
+func selectStreamSize(
+	ctx context.Context, db execQuerier, streamID string,
+) (int64, error) {
+	var size int64
+	err := db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(length(data)), 0) FROM binary_chunks
+		WHERE stream_id=?;`, streamID,
+	).Scan(&size)
+	return size, err
+}
+
+// readStreamHead reads the sync payload data of the binary up to
+// the stream, see packHead. The payload data without the stream
+// is read as a whole and returned with ok unset.
+func readStreamHead(br *bufio.Reader) (data []byte, ok bool, err error) {
+	// the error of the short data is returned by the next read
+	if magic, _ := br.Peek(len(streamMagic)); string(magic) != streamMagic {
+		data, err := io.ReadAll(br)
+		return data, false, err
+	}
+	br.Discard(len(streamMagic))
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err = io.ReadAll(io.LimitReader(br, int64(n)))
+	if err != nil {
+		return nil, false, err
+	}
+	if uint64(len(data)) != n {
+		return nil, false, io.ErrUnexpectedEOF
+	}
+	return data, true, nil
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/hlc"
+)
+
+// tick advances the hybrid logical clock of the device for the local
+// change in one statement, so the concurrent changes get the different
+// timestamps.
+func tick(ctx context.Context, db execQuerier) (hlc.Timestamp, error) {
+	var ts hlc.Timestamp
+	err := db.QueryRowContext(ctx,
+		`UPDATE clock SET last=MAX(last + 1, ?) WHERE id=1 RETURNING last;`,
+		hlc.FromTime(time.Now()),
+	).Scan(&ts)
+	return ts, err
+}
+
+// observe moves the clock of the device to the server version, so the
+// following local changes are ordered after it.
+func observe(ctx context.Context, db execQuerier, ts hlc.Timestamp) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE clock SET last=MAX(last, ?) WHERE id=1;`, ts,
+	)
+	return err
+}
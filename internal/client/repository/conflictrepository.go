@@ -0,0 +1,425 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+)
+
+// The reasons of the conflict copy. The edit copy is the local version
+// of the entry changed on the both sides. The name copy is the version
+// that lost the entry name: the not synchronized local version or the
+// server version held until the name is released.
+const (
+	ConflictEdit = "edit"
+	ConflictName = "name"
+)
+
+// Conflict is the version of the entry kept beside it.
+type Conflict struct {
+	SealedEntry
+	ID        int
+	EntryID   int
+	Reason    string
+	Deleted   bool
+	UpdatedAt time.Time
+	CreatedAt time.Time
+
+	syncID    int64
+	syncRev   sql.NullInt64
+	syncClock sql.NullInt64
+	uuid      sql.NullString
+}
+
+type ConflictRepository struct {
+	log logger.Logger
+	db  Storage
+}
+
+func NewConflict(l logger.Logger, db Storage) *ConflictRepository {
+	return &ConflictRepository{l, db}
+}
+
+const conflictCols = `id, entity, entry_id, reason, sync_id, sync_rev,
+	sync_clock, name_index, name, data, stream_id, deleted, updated_at,
+	created_at, uuid`
+
+func (r *ConflictRepository) List(ctx context.Context) ([]Conflict, error) {
+	const op = "ConflictRepository.List"
+	log := r.log.WithOp(op)
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM conflicts ORDER BY id ASC;", conflictCols,
+	))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select rows")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	data := make([]Conflict, 0)
+	for rows.Next() {
+		var c Conflict
+		if err := c.scanRow(rows); err != nil {
+			log.Error().Err(err).Msg("failed to scan row")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		data = append(data, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed while iterate rows")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return data, nil
+}
+
+func (r *ConflictRepository) Read(ctx context.Context, id int) (Conflict, error) {
+	const op = "ConflictRepository.Read"
+	log := r.log.WithOp(op)
+
+	c, err := r.selectConflict(ctx, r.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Int("id", id).Msg("conflict is not exists")
+			return Conflict{}, fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select conflict")
+		return Conflict{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+// IndexExists reports whether the entry of the entity or any conflict copy
+// has the name index.
+func (r *ConflictRepository) IndexExists(
+	ctx context.Context, entity, index string,
+) (bool, error) {
+	const op = "ConflictRepository.IndexExists"
+	log := r.log.WithOp(op)
+
+	if !slices.Contains(entityTables, entity) {
+		return false, fmt.Errorf("%s: invalid entity %q", op, entity)
+	}
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s WHERE name_index=?) OR
+		  EXISTS (SELECT 1 FROM conflicts WHERE entity=? AND name_index=?);`,
+		entity,
+	), index, entity, index).Scan(&exists)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select index")
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return exists, nil
+}
+
+// Discard deletes the conflict copy and keeps the entry. The held server
+// version is deleted on the server by the next synchronization.
+func (r *ConflictRepository) Discard(ctx context.Context, id int) error {
+	const op = "ConflictRepository.Discard"
+
+	err := r.resolve(ctx, id, func(tx *sql.Tx, c Conflict) error {
+		if err := r.deleteHeld(ctx, tx, c); err != nil {
+			return err
+		}
+		return r.deleteConflict(ctx, tx, c, true)
+	})
+	if err != nil {
+		return r.error(op, err)
+	}
+	return nil
+}
+
+// Replace puts the conflict copy in place of the entry. The held server
+// version is moved to the entry, so it is deleted on the server.
+func (r *ConflictRepository) Replace(ctx context.Context, id int) error {
+	const op = "ConflictRepository.Replace"
+
+	err := r.resolve(ctx, id, func(tx *sql.Tx, c Conflict) error {
+		clock, err := tick(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		var entryID int64
+		err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+			UPDATE %s
+			SET name_index=?, name=?, data=?, deleted=?, updated_at=?,
+			  clock=?
+			WHERE id=? RETURNING id;`, c.Entity,
+		), nullIndex(c.Index), c.Name, c.Data, c.Deleted, time.Now(),
+			clock, c.EntryID,
+		).Scan(&entryID)
+		if err != nil {
+			return err
+		}
+		op := opUpdate
+		if c.Deleted {
+			op = opDelete
+		}
+		err = appendOutbox(ctx, tx, c.Entity, entryID, op, clock)
+		if err != nil {
+			return err
+		}
+		if c.Entity == binaries {
+			err := replaceStream(ctx, tx, entryID, c.streamID)
+			if err != nil {
+				return err
+			}
+		}
+		if err := r.deleteHeld(ctx, tx, c); err != nil {
+			return err
+		}
+		return r.deleteConflict(ctx, tx, c, false)
+	})
+	if err != nil {
+		return r.error(op, err)
+	}
+	return nil
+}
+
+// Restore saves the conflict copy as the separate entry, the entryFn gets
+// the copy with the stream of the binary and the UUID of the restored entry
+// and renames it. The held server version stays bound to its server object
+// and its UUID, the other copy gets the new UUID. Returns the entry number.
+func (r *ConflictRepository) Restore(
+	ctx context.Context,
+	id int,
+	entryFn func(e *SealedEntry, entryUUID string) error,
+) (int, error) {
+	const op = "ConflictRepository.Restore"
+
+	var entryID int64
+	err := r.resolve(ctx, id, func(tx *sql.Tx, c Conflict) error {
+		held, err := r.isHeld(ctx, tx, c)
+		if err != nil {
+			return err
+		}
+		t := time.Now()
+		entryUUID := c.uuid
+		if !held || !entryUUID.Valid {
+			entryUUID = sql.NullString{String: uuid.NewAt(t), Valid: true}
+		}
+
+		if c.streamID != "" {
+			c.Stream = newChunkReader(ctx, tx, c.streamID)
+		}
+		if err := entryFn(&c.SealedEntry, entryUUID.String); err != nil {
+			return err
+		}
+
+		clock, err := tick(ctx, tx)
+		if err != nil {
+			return err
+		}
+		err = sql.ErrNoRows
+		if held {
+			err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+				UPDATE %s
+				SET uuid=?, name_index=?, name=?, data=?, deleted=FALSE,
+				  updated_at=?, clock=?, sync_rev=?, sync_clock=?
+				WHERE sync_id=? RETURNING id;`, c.Entity,
+			), entryUUID, c.Index, c.Name, c.Data, t, clock, c.syncRev,
+				c.syncClock, c.syncID,
+			).Scan(&entryID)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			syncID := sql.NullInt64{Int64: c.syncID, Valid: held}
+			syncRev, syncClock := c.syncRev, c.syncClock
+			if !held {
+				syncRev, syncClock = sql.NullInt64{}, sql.NullInt64{}
+			}
+			err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+				INSERT INTO %s
+				  (uuid, name_index, name, data, created_at, updated_at, clock,
+				  sync_id, sync_rev, sync_clock)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id;`, c.Entity,
+			), entryUUID, c.Index, c.Name, c.Data, t, t, clock, syncID, syncRev,
+				syncClock,
+			).Scan(&entryID)
+		}
+		if err != nil {
+			return err
+		}
+		op := opUpdate
+		if !held {
+			op = opCreate
+		}
+		err = appendOutbox(ctx, tx, c.Entity, entryID, op, clock)
+		if err != nil {
+			return err
+		}
+
+		if c.SealStream != nil {
+			streamID := newStreamID()
+			w := newChunkWriter(ctx, tx, streamID)
+			if err := c.SealStream(w); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			if err := replaceStream(ctx, tx, entryID, streamID); err != nil {
+				return err
+			}
+		}
+		return r.deleteConflict(ctx, tx, c, true)
+	})
+	if err != nil {
+		return 0, r.error(op, err)
+	}
+	return int(entryID), nil
+}
+
+// resolve passes the conflict copy to the fn in one transaction.
+func (r *ConflictRepository) resolve(
+	ctx context.Context, id int, fn func(tx *sql.Tx, c Conflict) error,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	c, err := r.selectConflict(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotExists
+		}
+		return err
+	}
+	if !slices.Contains(entityTables, c.Entity) {
+		return fmt.Errorf("invalid entity %q", c.Entity)
+	}
+
+	if err := fn(tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isHeld reports whether the copy is the server object other than
+// the server object of the entry.
+func (r *ConflictRepository) isHeld(
+	ctx context.Context, tx *sql.Tx, c Conflict,
+) (bool, error) {
+	if c.syncID == 0 {
+		return false, nil
+	}
+	var entrySyncID sql.NullInt64
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT sync_id FROM %s WHERE id=?;", c.Entity,
+	), c.EntryID).Scan(&entrySyncID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	return entrySyncID.Int64 != c.syncID, nil
+}
+
+// deleteHeld marks the server object of the held copy deleted, the copy
+// version becomes the base revision, so the deletion is sent to the server.
+func (r *ConflictRepository) deleteHeld(
+	ctx context.Context, tx *sql.Tx, c Conflict,
+) error {
+	held, err := r.isHeld(ctx, tx, c)
+	if err != nil || !held {
+		return err
+	}
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		return err
+	}
+	t := time.Now()
+	var id int64
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		UPDATE %s
+		SET name_index=NULL, name=NULL, data=NULL, deleted=TRUE,
+		  updated_at=?, clock=?, sync_rev=?, sync_clock=?
+		WHERE sync_id=? RETURNING id;`, c.Entity,
+	), t, clock, c.syncRev, c.syncClock, c.syncID).Scan(&id)
+	if err == nil {
+		err = appendOutbox(ctx, tx, c.Entity, id, opDelete, clock)
+		if err != nil {
+			return err
+		}
+		if c.Entity == binaries {
+			return replaceStream(ctx, tx, id, "")
+		}
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s
+		  (uuid, created_at, updated_at, clock, deleted, sync_id, sync_rev,
+		  sync_clock)
+		VALUES (?, ?, ?, ?, TRUE, ?, ?, ?) RETURNING id;`, c.Entity,
+	), c.uuid, t, t, clock, c.syncID, c.syncRev, c.syncClock).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return appendOutbox(ctx, tx, c.Entity, id, opDelete, clock)
+}
+
+func (r *ConflictRepository) deleteConflict(
+	ctx context.Context, tx *sql.Tx, c Conflict, withStream bool,
+) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM conflicts WHERE id=?;`, c.ID)
+	if err != nil || !withStream {
+		return err
+	}
+	return deleteStream(ctx, tx, c.streamID)
+}
+
+func (r *ConflictRepository) selectConflict(
+	ctx context.Context, db execQuerier, id int,
+) (Conflict, error) {
+	var c Conflict
+	err := c.scanRow(db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM conflicts WHERE id=?;", conflictCols,
+	), id))
+	return c, err
+}
+
+func (r *ConflictRepository) error(op string, err error) error {
+	log := r.log.WithOp(op)
+	switch {
+	case errors.Is(err, ErrNotExists), errors.Is(err, sql.ErrNoRows):
+		log.Debug().Err(err).Msg("conflict or entry is not exists")
+		return fmt.Errorf("%s: %w", op, ErrNotExists)
+	case isSQLiteEniqueErr(err):
+		log.Debug().Err(err).Msg("entry name already exists")
+		return fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+	}
+	log.Debug().Err(err).Msg("failed to resolve conflict")
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func (c *Conflict) scanRow(row interface{ Scan(...any) error }) error {
+	var (
+		index    sql.NullString
+		syncID   sql.NullInt64
+		streamID sql.NullString
+	)
+	err := row.Scan(&c.ID, &c.Entity, &c.EntryID, &c.Reason, &syncID,
+		&c.syncRev, &c.syncClock, &index, &c.Name, &c.Data, &streamID,
+		&c.Deleted, &c.UpdatedAt, &c.CreatedAt, &c.uuid)
+	if err != nil {
+		return err
+	}
+	c.UUID, c.Index = c.uuid.String, index.String
+	c.syncID = syncID.Int64
+	c.streamID = streamID.String
+	return nil
+}
@@ -0,0 +1,273 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/pkg/hlc"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type conflictSuite struct {
+	ctx       context.Context
+	s         *storage.Storage
+	pwd       *repository.Repository
+	sync      *repository.SyncEntityRepository
+	conflicts *repository.ConflictRepository
+}
+
+func newConflictSuite(t *testing.T) *conflictSuite {
+	log := logger.NewPretty("debug")
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		os.Remove(dsn)
+	})
+
+	s := storage.New(log, dsn)
+	s.MustRun(ctx)
+	return &conflictSuite{
+		ctx:       ctx,
+		s:         s,
+		pwd:       repository.NewPwd(log, s),
+		sync:      repository.NewPwdSync(log, s),
+		conflicts: repository.NewConflict(log, s),
+	}
+}
+
+// createSynced saves the entry synchronized with the server object syncID.
+func (st *conflictSuite) createSynced(
+	t *testing.T, index string, syncID, revision int64,
+) int {
+	t.Helper()
+	id, err := st.pwd.Create(
+		st.ctx, uuid.New(), index, []byte(index), []byte("local"), "",
+	)
+	require.NoError(t, err)
+	comp := st.comparable(t, id)
+	err = st.sync.SetSliceSyncBase(st.ctx, []model.SyncBase{
+		{ID: int64(id), SyncID: syncID, Revision: revision, Clock: comp.Clock},
+	})
+	require.NoError(t, err)
+	return id
+}
+
+func (st *conflictSuite) comparable(
+	t *testing.T, id int,
+) model.LocalComparable {
+	t.Helper()
+	comp, err := st.sync.GetComparable(st.ctx)
+	require.NoError(t, err)
+	for _, o := range comp {
+		if o.ID == int64(id) {
+			return o
+		}
+	}
+	t.Fatalf("no comparable of %d", id)
+	return model.LocalComparable{}
+}
+
+func (st *conflictSuite) list(t *testing.T) []repository.Conflict {
+	t.Helper()
+	conflicts, err := st.conflicts.List(st.ctx)
+	require.NoError(t, err)
+	return conflicts
+}
+
+func srvPayload(
+	syncID int64, index string, revision int64, clock hlc.Timestamp,
+) model.SyncPayload {
+	return model.SyncPayload{
+		ID:        syncID,
+		UUID:      uuid.New(),
+		NameIndex: index,
+		Name:      []byte(index),
+		Data:      []byte("server"),
+		CreatedAt: clock.Time(),
+		Revision:  revision,
+		Clock:     clock,
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	const (
+		baseRev = 1
+		srvRev  = 2
+	)
+	// the server version is written by the device with the clock ahead
+	srvClock := hlc.FromTime(time.Now().Add(time.Hour))
+
+	t.Run("EditReplace", func(t *testing.T) {
+		st := newConflictSuite(t)
+		id := st.createSynced(t, "a", 10, baseRev)
+
+		err := st.sync.KeepConflicts(st.ctx, []model.SyncConflict{
+			{
+				SyncPayload: srvPayload(10, "b", srvRev, srvClock),
+				LocalID:     int64(id),
+			},
+		})
+		require.NoError(t, err)
+
+		e, err := st.pwd.ReadByID(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "b", e.Index)
+		assert.Equal(t, []byte("server"), e.Data)
+
+		comp := st.comparable(t, id)
+		assert.Equal(t, int64(srvRev), comp.Revision)
+		assert.Equal(t, srvClock, comp.Clock)
+		assert.Equal(t, srvClock, comp.SyncClock)
+
+		conflicts := st.list(t)
+		require.Len(t, conflicts, 1)
+		c := conflicts[0]
+		assert.Equal(t, repository.ConflictEdit, c.Reason)
+		assert.Equal(t, id, c.EntryID)
+		assert.Equal(t, "a", c.Index)
+		assert.Equal(t, []byte("local"), c.Data)
+
+		require.NoError(t, st.conflicts.Replace(st.ctx, c.ID))
+		e, err = st.pwd.ReadByID(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "a", e.Index)
+		assert.Equal(t, []byte("local"), e.Data)
+		assert.Empty(t, st.list(t))
+
+		comp = st.comparable(t, id)
+		assert.Greater(t, comp.Clock, srvClock,
+			"the kept copy is ordered after the observed server version")
+		assert.Equal(t, int64(srvRev), comp.Revision,
+			"the kept copy is sent to the server")
+	})
+
+	t.Run("DeletedOnBothSides", func(t *testing.T) {
+		st := newConflictSuite(t)
+		id := st.createSynced(t, "a", 10, baseRev)
+		require.NoError(t, st.pwd.Delete(st.ctx, id))
+
+		srvObj := srvPayload(10, "", srvRev, srvClock)
+		srvObj.Deleted = true
+		err := st.sync.KeepConflicts(st.ctx, []model.SyncConflict{
+			{SyncPayload: srvObj, LocalID: int64(id)},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, st.list(t))
+	})
+
+	t.Run("InsertSameName", func(t *testing.T) {
+		st := newConflictSuite(t)
+		id, err := st.pwd.Create(
+			st.ctx, uuid.New(), "a", []byte("a"), []byte("local"), "",
+		)
+		require.NoError(t, err)
+
+		err = st.sync.InsertSlice(st.ctx, []model.LocalPayload{
+			{SyncPayload: srvPayload(-1, "a", srvRev, srvClock), SyncID: 20},
+		})
+		require.NoError(t, err)
+
+		e, err := st.pwd.ReadByID(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("server"), e.Data)
+
+		conflicts := st.list(t)
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, repository.ConflictName, conflicts[0].Reason)
+		assert.Equal(t, id, conflicts[0].EntryID)
+
+		require.NoError(t, st.conflicts.Discard(st.ctx, conflicts[0].ID))
+		assert.Empty(t, st.list(t))
+		e, err = st.pwd.ReadByID(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("server"), e.Data)
+	})
+
+	t.Run("HeldDiscard", func(t *testing.T) {
+		st := newConflictSuite(t)
+		id := st.createSynced(t, "a", 10, baseRev)
+
+		for range 2 {
+			err := st.sync.InsertSlice(st.ctx, []model.LocalPayload{
+				{SyncPayload: srvPayload(-1, "a", srvRev, srvClock), SyncID: 20},
+			})
+			require.NoError(t, err)
+		}
+
+		conflicts := st.list(t)
+		require.Len(t, conflicts, 1, "the held version is replaced")
+		assert.Equal(t, id, conflicts[0].EntryID)
+
+		require.NoError(t, st.conflicts.Discard(st.ctx, conflicts[0].ID))
+
+		comp, err := st.sync.GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 2)
+		tombstone := comp[1]
+		assert.Equal(t, int64(20), tombstone.SyncID)
+		assert.Empty(t, tombstone.NameIndex)
+		assert.Equal(t, int64(srvRev), tombstone.Revision)
+		assert.Equal(t, srvClock, tombstone.SyncClock)
+		assert.Greater(t, tombstone.Clock, tombstone.SyncClock,
+			"the deletion is sent to the server")
+	})
+
+	t.Run("HeldRestore", func(t *testing.T) {
+		st := newConflictSuite(t)
+		st.createSynced(t, "a", 10, baseRev)
+
+		err := st.sync.InsertSlice(st.ctx, []model.LocalPayload{
+			{SyncPayload: srvPayload(-1, "a", srvRev, srvClock), SyncID: 20},
+		})
+		require.NoError(t, err)
+		conflicts := st.list(t)
+		require.Len(t, conflicts, 1)
+
+		exists, err := st.conflicts.IndexExists(st.ctx, "passwords", "b")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		var restoredUUID string
+		entryNum, err := st.conflicts.Restore(st.ctx, conflicts[0].ID,
+			func(e *repository.SealedEntry, entryUUID string) error {
+				assert.Equal(t, e.UUID, entryUUID,
+					"the held version keeps the UUID of the server object")
+				e.Index, e.Name = "b", []byte("b")
+				restoredUUID = entryUUID
+				return nil
+			})
+		require.NoError(t, err)
+
+		e, err := st.pwd.ReadByID(st.ctx, entryNum)
+		require.NoError(t, err)
+		assert.Equal(t, restoredUUID, e.UUID)
+		assert.Equal(t, "b", e.Index)
+		assert.Equal(t, []byte("server"), e.Data)
+		assert.Empty(t, st.list(t))
+
+		comp, err := st.sync.GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 2)
+		assert.Equal(t, int64(20), comp[1].SyncID,
+			"the renamed copy updates its server object")
+		assert.Equal(t, int64(srvRev), comp[1].Revision)
+	})
+
+	t.Run("NotExists", func(t *testing.T) {
+		st := newConflictSuite(t)
+		assert.ErrorIs(t,
+			st.conflicts.Discard(st.ctx, 1), repository.ErrNotExists)
+		_, err := st.conflicts.Read(st.ctx, 1)
+		assert.ErrorIs(t, err, repository.ErrNotExists)
+	})
+}
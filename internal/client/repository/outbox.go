@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/hlc"
+)
+
+// The operations of the outbox records.
+const (
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+// appendOutbox records the local change of the entry in the transaction
+// of the change. The record is pending until the server confirms the version
+// of the entry, its key makes the repeated request idempotent. The deletion
+// of the entry never sent to the server acknowledges the records instead,
+// there is nothing to send.
+func appendOutbox(
+	ctx context.Context,
+	db execQuerier,
+	entity string,
+	entryID int64,
+	op string,
+	clock hlc.Timestamp,
+) error {
+	if op == opDelete {
+		var synced bool
+		err := db.QueryRowContext(ctx, fmt.Sprintf(
+			`SELECT sync_id IS NOT NULL FROM %s WHERE id=?;`, entity,
+		), entryID).Scan(&synced)
+		if err != nil {
+			return err
+		}
+		if !synced {
+			return ackOutbox(ctx, db, entity, entryID, clock)
+		}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO outbox (entity, entry_id, op, key, clock, created_at)
+		VALUES (?, ?, ?, ?, ?, ?);`,
+		entity, entryID, op, newOutboxKey(), clock, time.Now(),
+	)
+	return err
+}
+
+// ackOutbox acknowledges the pending records of the entry made up to
+// the clock, the later changes stay pending.
+func ackOutbox(
+	ctx context.Context,
+	db execQuerier,
+	entity string,
+	entryID int64,
+	clock hlc.Timestamp,
+) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE outbox SET acked_at=?
+		WHERE entity=? AND entry_id=? AND clock<=? AND acked_at IS NULL;`,
+		time.Now(), entity, entryID, clock,
+	)
+	return err
+}
+
+// supersedeOutbox acknowledges all the pending records of the entry, its
+// local changes are replaced by the server version or kept as the conflict
+// copy.
+func supersedeOutbox(
+	ctx context.Context, db execQuerier, entity string, entryID int64,
+) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE outbox SET acked_at=?
+		WHERE entity=? AND entry_id=? AND acked_at IS NULL;`,
+		time.Now(), entity, entryID,
+	)
+	return err
+}
+
+func newOutboxKey() string {
+	return rand.Text()
+}
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/mattn/go-sqlite3"
 	"github.com/niksmo/gophkeeper/internal/model"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
 )
 
 var (
@@ -33,6 +36,14 @@ type Storage interface {
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 }
 
+// SealedName is the encrypted entry name with its blind index. The name
+// saved before the names encryption is plaintext and has the empty index.
+type SealedName struct {
+	ID    int
+	Index string
+	Name  []byte
+}
+
 type Repository struct {
 	log   logger.Logger
 	db    Storage
@@ -55,21 +66,47 @@ func NewBin(l logger.Logger, db Storage) *Repository {
 	return &Repository{l, db, binaries}
 }
 
+// Entity returns the entity type of the stored entries.
+func (r *Repository) Entity() string {
+	return r.table
+}
+
+// Create saves the entry with the record UUID the data is bound to
+// and the content hash of the entry.
 func (r *Repository) Create(
-	ctx context.Context, name string, data []byte,
+	ctx context.Context, entryUUID, nameIndex string, name, data []byte,
+	hash string,
 ) (int, error) {
 	const op = "Repository.Create"
 	log := r.log.With().Str("op", op).Logger()
 
 	stmt := fmt.Sprintf(`
-	INSERT INTO %s (name, data, created_at, updated_at)
-	VALUES (?, ?, ?, ?) RETURNING id;`,
+	INSERT INTO %s
+	  (uuid, name_index, name, data, created_at, updated_at, clock, hash,
+	  hash_clock)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id;`,
 		r.table,
 	)
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to tick clock")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	var id int
 	t := time.Now()
-	err := r.db.QueryRowContext(ctx, stmt, name, data, t, t).Scan(&id)
+	err = tx.QueryRowContext(
+		ctx, stmt, entryUUID, nameIndex, name, data, t, t, clock,
+		nullHash(hash), clock,
+	).Scan(&id)
 	if err != nil {
 		if isSQLiteEniqueErr(err) {
 			log.Debug().Err(err).Msg("object already exists")
@@ -78,40 +115,87 @@ func (r *Repository) Create(
 		log.Error().Err(err).Msg("failed to insert")
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
+
+	err = appendOutbox(ctx, tx, r.table, int64(id), opCreate, clock)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to append outbox")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
 	return id, nil
 }
 
-func (r *Repository) ReadByID(ctx context.Context, id int) ([]byte, error) {
+// ReadByID returns the record UUID, the name index and the data
+// of the entry.
+func (r *Repository) ReadByID(
+	ctx context.Context, id int,
+) (SealedEntry, error) {
 	const op = "Repository.ReadByID"
 	log := r.log.With().Str("op", op).Logger()
 
 	stmt := fmt.Sprintf(
-		`SELECT data FROM %s WHERE id=? AND deleted=FALSE;`, r.table,
+		`SELECT uuid, name_index, data FROM %s WHERE id=? AND deleted=FALSE;`,
+		r.table,
 	)
 
-	var data []byte
-	err := r.db.QueryRowContext(ctx, stmt, id).Scan(&data)
+	var (
+		entryUUID sql.NullString
+		nameIndex sql.NullString
+	)
+	e := SealedEntry{Entity: r.table}
+	err := r.db.QueryRowContext(ctx, stmt, id).Scan(
+		&entryUUID, &nameIndex, &e.Data,
+	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			log.Debug().Err(err).Msg("object is not exists")
-			return nil, fmt.Errorf("%s: %w", op, ErrNotExists)
+			return SealedEntry{}, fmt.Errorf("%s: %w", op, ErrNotExists)
 		}
 
 		log.Error().Err(err).Msg("failed to select row")
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return SealedEntry{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return data, nil
+	e.UUID, e.Index = entryUUID.String, nameIndex.String
+	return e, nil
+}
+
+// ReadUUID returns the record UUID of the entry.
+func (r *Repository) ReadUUID(ctx context.Context, id int) (string, error) {
+	const op = "Repository.ReadUUID"
+	log := r.log.With().Str("op", op).Logger()
+
+	stmt := fmt.Sprintf(
+		`SELECT uuid FROM %s WHERE id=? AND deleted=FALSE;`, r.table,
+	)
+
+	var entryUUID sql.NullString
+	err := r.db.QueryRowContext(ctx, stmt, id).Scan(&entryUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Err(err).Msg("object is not exists")
+			return "", fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+
+		log.Error().Err(err).Msg("failed to select row")
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return entryUUID.String, nil
 }
 
-func (r *Repository) ListNames(ctx context.Context) ([][2]string, error) {
+// ListNames returns the encrypted names of not deleted entries.
+func (r *Repository) ListNames(ctx context.Context) ([]SealedName, error) {
 	const op = "Repository.ListNames"
 	log := r.log.With().Str("op", op).Logger()
 
 	stmt := fmt.Sprintf(
 		`
-		SELECT id, name FROM %s WHERE deleted=FALSE
-		ORDER BY name ASC, created_at ASC;
+		SELECT id, name_index, name FROM %s WHERE deleted=FALSE
+		ORDER BY id ASC;
 		`,
 		r.table,
 	)
@@ -123,15 +207,17 @@ func (r *Repository) ListNames(ctx context.Context) ([][2]string, error) {
 	}
 	defer rows.Close()
 
-	data := make([][2]string, 0)
-	var id int
-	var name string
+	data := make([]SealedName, 0)
 	for rows.Next() {
-		if err := rows.Scan(&id, &name); err != nil {
+		var (
+			item      SealedName
+			nameIndex sql.NullString
+		)
+		if err := rows.Scan(&item.ID, &nameIndex, &item.Name); err != nil {
 			log.Error().Err(err).Msg("failed to scan row")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		item := [2]string{strconv.Itoa(id), name}
+		item.Index = nameIndex.String
 		data = append(data, item)
 	}
 
@@ -144,21 +230,40 @@ func (r *Repository) ListNames(ctx context.Context) ([][2]string, error) {
 }
 
 func (r *Repository) Update(
-	ctx context.Context, entryNum int, name string, data []byte,
+	ctx context.Context,
+	entryNum int,
+	nameIndex string,
+	name, data []byte,
+	hash string,
 ) error {
 	const op = "Repository.Update"
 	log := r.log.With().Str("op", op).Logger()
 
 	stmt := fmt.Sprintf(`
 	UPDATE %s SET
-	  name=?, data=?, updated_at=?
+	  name_index=?, name=?, data=?, updated_at=?, clock=?, hash=?,
+	  hash_clock=?
 	WHERE id=? RETURNING id;`,
 		r.table,
 	)
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to tick clock")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	var id int
-	err := r.db.QueryRowContext(
-		ctx, stmt, name, data, time.Now(), entryNum,
+	err = tx.QueryRowContext(
+		ctx, stmt, nameIndex, name, data, time.Now(), clock, nullHash(hash),
+		clock, entryNum,
 	).Scan(&id)
 	if err != nil {
 		if isSQLiteEniqueErr(err) {
@@ -172,6 +277,17 @@ func (r *Repository) Update(
 		log.Error().Err(err).Msg("failed to update")
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
+	err = appendOutbox(ctx, tx, r.table, int64(id), opUpdate, clock)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to append outbox")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	return nil
 }
 
@@ -181,13 +297,29 @@ func (r *Repository) Delete(ctx context.Context, entryNum int) error {
 
 	stmt := fmt.Sprintf(`
 	UPDATE %s SET
-	  name=NULL, data=NULL, updated_at=?, deleted=TRUE
+	  name_index=NULL, name=NULL, data=NULL, updated_at=?, clock=?,
+	  deleted=TRUE
 	WHERE id=? RETURNING id;`,
 		r.table,
 	)
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to tick clock")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	var id int
-	err := r.db.QueryRowContext(ctx, stmt, time.Now(), entryNum).Scan(&id)
+	err = tx.QueryRowContext(
+		ctx, stmt, time.Now(), clock, entryNum,
+	).Scan(&id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			log.Debug().Err(err).Msg("table is not exists")
@@ -196,31 +328,62 @@ func (r *Repository) Delete(ctx context.Context, entryNum int) error {
 		log.Error().Err(err).Msg("failed to delete")
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
+	err = appendOutbox(ctx, tx, r.table, int64(id), opDelete, clock)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to append outbox")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	return nil
 }
 
+// syncableCond excludes the entries with the plaintext name, they are
+// synchronized after the vault upgrade, and the entries deleted before
+// the first synchronization.
+const syncableCond = "name_index IS NOT NULL OR " +
+	"(deleted=TRUE AND sync_id IS NOT NULL)"
+
+// hashCol selects the content hash of the object. The hash is written
+// with the object and is valid for its clock, so the object written
+// without the hash, e.g. before the content hashes, has no hash.
+const hashCol = "CASE WHEN hash_clock=clock THEN hash END"
+
+// maxPageBytes bounds the names and the data of the objects read
+// at once, the page is cut short before the object exceeding it.
+// The streams of the binaries are not read with the page.
+const maxPageBytes = 4 << 20
+
+// SyncEntityRepository puts the stream of the binary into the payload
+// stream, the payload data is its head, see packHead.
 type SyncEntityRepository struct {
-	logger logger.Logger
-	db     Storage
-	table  string
+	logger  logger.Logger
+	db      Storage
+	table   string
+	streams bool
 }
 
 func NewPwdSync(l logger.Logger, s Storage) *SyncEntityRepository {
-	return &SyncEntityRepository{l, s, passwords}
+	return &SyncEntityRepository{l, s, passwords, false}
 }
 
 func NewCardSync(l logger.Logger, s Storage) *SyncEntityRepository {
-	return &SyncEntityRepository{l, s, cards}
+	return &SyncEntityRepository{l, s, cards, false}
 }
 
 func NewTextSync(l logger.Logger, s Storage) *SyncEntityRepository {
-	return &SyncEntityRepository{l, s, texts}
+	return &SyncEntityRepository{l, s, texts, false}
 }
 
 func NewBinSync(l logger.Logger, s Storage) *SyncEntityRepository {
-	return &SyncEntityRepository{l, s, binaries}
+	return &SyncEntityRepository{l, s, binaries, true}
 }
 
+// GetComparable returns the syncable objects with their content hashes.
 func (r *SyncEntityRepository) GetComparable(
 	ctx context.Context,
 ) ([]model.LocalComparable, error) {
@@ -228,8 +391,10 @@ func (r *SyncEntityRepository) GetComparable(
 	log := r.logger.WithOp(op)
 
 	stmt := fmt.Sprintf(
-		"SELECT id, name, updated_at, sync_id FROM %s;",
-		r.table,
+		`SELECT id, name_index, sync_rev, clock, sync_id, sync_clock, %s, uuid,
+		  %s
+		FROM %s WHERE %s;`,
+		r.pendingCol(), hashCol, r.table, syncableCond,
 	)
 
 	rows, err := r.db.QueryContext(ctx, stmt)
@@ -260,21 +425,70 @@ func (r *SyncEntityRepository) GetComparable(
 	return data, nil
 }
 
-func (r *SyncEntityRepository) GetAll(
+// GetLeaves returns the server IDs and the base revisions of the objects
+// synchronized, see merkle.Tree.
+func (r *SyncEntityRepository) GetLeaves(
 	ctx context.Context,
+) ([]merkle.Leaf, error) {
+	const op = "SyncEntityRepository.GetLeaves"
+	log := r.logger.WithOp(op)
+
+	stmt := fmt.Sprintf(
+		`SELECT sync_id, IFNULL(sync_rev, 0) FROM %s
+		WHERE sync_id IS NOT NULL AND (%s)
+		ORDER BY sync_id;`,
+		r.table, syncableCond,
+	)
+
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select rows")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var s []merkle.Leaf
+	for rows.Next() {
+		var l merkle.Leaf
+		if err := rows.Scan(&l.ID, &l.Revision); err != nil {
+			log.Error().Err(err).Msg("failed to scan row")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s = append(s, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to select rows")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return s, nil
+}
+
+// GetAll returns the page of the syncable objects with the ID greater than
+// after ordered by ID. The page is cut short before limit if the objects
+// exceed maxPageBytes, so the empty page is the last one.
+func (r *SyncEntityRepository) GetAll(
+	ctx context.Context, after int64, limit int,
 ) ([]model.LocalPayload, error) {
 	const op = "SyncEntityRepository.GetAll"
 	log := r.logger.WithOp(op)
 
 	stmt := fmt.Sprintf(`
-		SELECT id, name, data, created_at, updated_at, deleted, sync_id
-		FROM %s;`,
-		r.table,
+		SELECT
+		  id, name_index, name, data, created_at, sync_rev, clock, deleted,
+		  sync_id, %s, uuid, %s
+		FROM %s
+		WHERE (%s) AND id>?
+		ORDER BY id
+		LIMIT ?;`,
+		r.keyCol(), hashCol, r.table, syncableCond,
 	)
 
-	return r.querySlice(ctx, log, op, stmt)
+	return r.querySlice(ctx, log, op, stmt, after, limit)
 }
 
+// GetSliceByIDs returns the objects with the IDs ordered by ID, the slice
+// is cut short if the objects exceed maxPageBytes.
 func (r *SyncEntityRepository) GetSliceByIDs(
 	ctx context.Context, sID []int64,
 ) ([]model.LocalPayload, error) {
@@ -282,74 +496,239 @@ func (r *SyncEntityRepository) GetSliceByIDs(
 	log := r.logger.WithOp(op)
 
 	stmt := fmt.Sprintf(`
-		SELECT id, name, data, created_at, updated_at, deleted, sync_id
+		SELECT
+		  id, name_index, name, data, created_at, sync_rev, clock, deleted,
+		  sync_id, %s, uuid, %s
 		FROM %s
-		WHERE id IN (%s);`,
-		r.table, r.makeStrIDList(sID),
+		WHERE id IN (%s)
+		ORDER BY id;`,
+		r.keyCol(), hashCol, r.table, r.makeStrIDList(sID),
 	)
 
 	return r.querySlice(ctx, log, op, stmt)
 }
 
+// UpdateSliceBySyncIDs applies the server versions to the local objects,
+// see applyServer.
 func (r *SyncEntityRepository) UpdateSliceBySyncIDs(
 	ctx context.Context, data []model.SyncPayload,
 ) error {
 	const op = "SyncEntityRepository.UpdateSliceBySyncIDs"
 	log := r.logger.WithOp(op)
 
-	q := fmt.Sprintf(`
-		UPDATE %s
-		SET name=?, data=?, created_at=?, updated_at=?, deleted=?
-		WHERE sync_id=?;
-		`,
-		r.table,
-	)
-
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to begin transaction")
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, q)
+	for i, o := range data {
+		var id int64
+		err := tx.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT id FROM %s WHERE sync_id=?;", r.table), o.ID,
+		).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Debug().Int64("syncID", o.ID).Msg("object is not exists")
+				continue
+			}
+			log.Error().Err(err).Int("index", i).Msg("failed to select object")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := r.applyServer(ctx, tx, id, o); err != nil {
+			log.Error().Err(err).Int("index", i).Msg("failed to apply update")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// InsertSlice saves the server objects are not synchronized yet. The server
+// object takes the place of the not synchronized local object with the same
+// name, the local version is kept as the conflict copy.
+func (r *SyncEntityRepository) InsertSlice(
+	ctx context.Context, data []model.LocalPayload,
+) error {
+	const op = "SyncEntityRepository.InsertSlice"
+	log := r.logger.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to prepare stmt")
+		log.Error().Err(err).Msg("failed to begin transaction")
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
 	for i, o := range data {
-		_, execErr := stmt.ExecContext(ctx, o.Name, o.Data,
-			o.CreatedAt, o.UpdatedAt, o.Deleted, o.ID)
-		if execErr != nil {
-			if isSQLiteEniqueErr(err) {
-				log.Error().Err(err).Int("index", i).Msg("unexpected name")
+		srvObj := o.SyncPayload
+		srvObj.ID = o.SyncID
+		if err := r.insertServer(ctx, tx, srvObj); err != nil {
+			log.Error().Err(err).Int("index", i).Msg("failed to insert")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SyncEntityRepository) insertServer(
+	ctx context.Context, tx *sql.Tx, o model.SyncPayload,
+) error {
+	holder, err := r.selectNameHolder(ctx, tx, o.NameIndex, 0)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case holder.id == 0:
+		err := tx.QueryRowContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (created_at, updated_at, deleted)
+			VALUES (?, ?, ?)
+			RETURNING id;`, r.table,
+		), o.CreatedAt, o.Clock.Time(), o.Deleted).Scan(&holder.id)
+		if err != nil {
+			return err
+		}
+	case holder.syncID == 0:
+		err := r.keepConflict(ctx, tx, holder.id, holder.id, ConflictName)
+		if err != nil {
+			return err
+		}
+	case holder.syncID != o.ID:
+		return r.holdServer(ctx, tx, holder.id, o)
+	}
+	return r.applyServer(ctx, tx, holder.id, o)
+}
+
+// KeepConflicts applies the server versions to the local objects changed
+// concurrently, the local versions are kept as the conflict copies. The
+// object deleted on the both sides is not a conflict.
+func (r *SyncEntityRepository) KeepConflicts(
+	ctx context.Context, data []model.SyncConflict,
+) error {
+	const op = "SyncEntityRepository.KeepConflicts"
+	log := r.logger.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for i, c := range data {
+		var (
+			deleted bool
+			syncID  sql.NullInt64
+		)
+		err := tx.QueryRowContext(ctx, fmt.Sprintf(
+			"SELECT deleted, sync_id FROM %s WHERE id=?;", r.table,
+		), c.LocalID).Scan(&deleted, &syncID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Debug().Int64("id", c.LocalID).Msg("object is not exists")
 				continue
 			}
-			log.Error().Err(err).Int("index", i).Msg("failed to exec upsert")
-			return tx.Rollback()
+			log.Error().Err(err).Int("index", i).Msg("failed to select object")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if !deleted || !c.Deleted {
+			reason := ConflictName
+			if syncID.Int64 == c.ID {
+				reason = ConflictEdit
+			}
+			err := r.keepConflict(ctx, tx, c.LocalID, c.LocalID, reason)
+			if err != nil {
+				log.Error().Err(err).Int("index", i).Msg(
+					"failed to keep conflict copy")
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if err := r.applyServer(ctx, tx, c.LocalID, c.SyncPayload); err != nil {
+			log.Error().Err(err).Int("index", i).Msg("failed to apply conflict")
+			return fmt.Errorf("%s: %w", op, err)
 		}
 	}
 	return tx.Commit()
 }
 
-func (r *SyncEntityRepository) InsertSlice(
-	ctx context.Context, data []model.LocalPayload,
+// pendingCol selects whether the object has the pending outbox records.
+func (r *SyncEntityRepository) pendingCol() string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM outbox
+		WHERE entity='%[1]s' AND entry_id=%[1]s.id AND acked_at IS NULL)`,
+		r.table,
+	)
+}
+
+// keyCol selects the idempotency key of the object version. The object
+// not sent yet is inserted with the key of its first pending record,
+// so the repeated insert is recognized whatever changes are made since.
+// The synchronized object is updated with the key of its last record.
+func (r *SyncEntityRepository) keyCol() string {
+	return fmt.Sprintf(`CASE WHEN %[1]s.sync_id IS NULL
+		THEN (
+		  SELECT key FROM outbox
+		  WHERE entity='%[1]s' AND entry_id=%[1]s.id AND acked_at IS NULL
+		  ORDER BY id LIMIT 1)
+		ELSE (
+		  SELECT key FROM outbox
+		  WHERE entity='%[1]s' AND entry_id=%[1]s.id AND acked_at IS NULL
+		  ORDER BY id DESC LIMIT 1)
+		END`,
+		r.table,
+	)
+}
+
+// GetOutbox returns the pending outbox records of the entity.
+func (r *SyncEntityRepository) GetOutbox(
+	ctx context.Context,
+) ([]model.OutboxEntry, error) {
+	const op = "SyncEntityRepository.GetOutbox"
+	log := r.logger.WithOp(op)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, entry_id, op, key, clock FROM outbox
+		WHERE entity=? AND acked_at IS NULL
+		ORDER BY id;`, r.table,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select rows")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	data := make([]model.OutboxEntry, 0)
+	for rows.Next() {
+		var e model.OutboxEntry
+		err := rows.Scan(&e.ID, &e.EntryID, &e.Op, &e.Key, &e.Clock)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan row")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		data = append(data, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to select rows")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return data, nil
+}
+
+// SetSliceSyncBase saves the sync ID, the base revision and the clock
+// of the objects confirmed by the server and acknowledges their outbox
+// records made up to the clock.
+func (r *SyncEntityRepository) SetSliceSyncBase(
+	ctx context.Context, data []model.SyncBase,
 ) error {
-	const op = "SyncEntityRepository.InsertSlice"
+	const op = "SyncEntityRepository.SetSliceSyncBase"
 	log := r.logger.WithOp(op)
 
-	q := fmt.Sprintf(`
-		INSERT INTO %s
-		  (name, data, created_at, updated_at, deleted, sync_id)
-		VALUES (?, ?, ?, ?, ?, ?)
-		ON CONFLICT (name) DO UPDATE
-		SET name=excluded.name,
-			data=excluded.data,
-			updated_at=excluded.updated_at,
-			deleted=excluded.deleted,
-			sync_id=excluded.sync_id;
-		`,
+	q := fmt.Sprintf(
+		"UPDATE %s SET sync_id=?, sync_rev=?, sync_clock=? WHERE id=?;",
 		r.table,
 	)
 
@@ -358,6 +737,7 @@ func (r *SyncEntityRepository) InsertSlice(
 		log.Error().Err(err).Msg("failed to begin transaction")
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
 	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
@@ -367,49 +747,278 @@ func (r *SyncEntityRepository) InsertSlice(
 	defer stmt.Close()
 
 	for i, o := range data {
-		_, execErr := stmt.ExecContext(ctx, o.Name, o.Data,
-			o.CreatedAt, o.UpdatedAt, o.Deleted, o.SyncID)
-		if execErr != nil {
-			if isSQLiteEniqueErr(err) {
-				log.Error().Err(err).Int("index", i).Msg("unexpected name")
-				continue
-			}
+		_, err := stmt.ExecContext(ctx, o.SyncID, o.Revision, o.Clock, o.ID)
+		if err != nil {
 			log.Error().Err(err).Int("index", i).Msg("failed to exec update")
-			return tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := ackOutbox(ctx, tx, r.table, o.ID, o.Clock); err != nil {
+			log.Error().Err(err).Int("index", i).Msg("failed to ack outbox")
+			return fmt.Errorf("%s: %w", op, err)
 		}
 	}
 	return tx.Commit()
 }
 
-func (r *SyncEntityRepository) InsertSliceSyncID(
-	ctx context.Context, IDSyncIDPairs [][2]int64,
-) error {
-	const op = "SyncEntityRepository.InsertSliceSyncID"
+// ReadCursor returns the server change cursor of the entity, the zero cursor
+// is returned before the first synchronization.
+func (r *SyncEntityRepository) ReadCursor(ctx context.Context) (int64, error) {
+	const op = "SyncEntityRepository.ReadCursor"
 	log := r.logger.WithOp(op)
 
-	q := fmt.Sprintf("UPDATE %s SET sync_id=? WHERE id=?;", r.table)
+	var cursor int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT cursor FROM sync_states WHERE entity=?;`, r.table,
+	).Scan(&cursor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select cursor")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return cursor, nil
+}
+
+// SaveCursor saves the server change cursor of the entity, the changes
+// applied before the cursor are not requested again.
+func (r *SyncEntityRepository) SaveCursor(
+	ctx context.Context, cursor int64,
+) error {
+	const op = "SyncEntityRepository.SaveCursor"
+	log := r.logger.WithOp(op)
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sync_states (entity, cursor, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (entity) DO UPDATE
+		SET cursor=excluded.cursor,
+		    updated_at=excluded.updated_at;`,
+		r.table, cursor, time.Now(),
+	)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to begin transaction")
+		log.Error().Err(err).Msg("failed to save cursor")
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	return nil
+}
 
-	stmt, err := tx.PrepareContext(ctx, q)
+// ReadReconciledAt returns the time the entity is reconciled with
+// the server last, the zero time is returned if it is never reconciled.
+func (r *SyncEntityRepository) ReadReconciledAt(
+	ctx context.Context,
+) (time.Time, error) {
+	const op = "SyncEntityRepository.ReadReconciledAt"
+	log := r.logger.WithOp(op)
+
+	var reconciledAt sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		`SELECT reconciled_at FROM sync_states WHERE entity=?;`, r.table,
+	).Scan(&reconciledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
 	if err != nil {
-		log.Error().Err(err).Msg("failed to prepare stmt")
+		log.Error().Err(err).Msg("failed to select reconciled at")
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return reconciledAt.Time, nil
+}
+
+// SaveReconciled saves the server change cursor of the entity with
+// the time of the reconciliation, see SaveCursor.
+func (r *SyncEntityRepository) SaveReconciled(
+	ctx context.Context, cursor int64,
+) error {
+	const op = "SyncEntityRepository.SaveReconciled"
+	log := r.logger.WithOp(op)
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sync_states (entity, cursor, reconciled_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (entity) DO UPDATE
+		SET cursor=excluded.cursor,
+		    reconciled_at=excluded.reconciled_at,
+		    updated_at=excluded.updated_at;`,
+		r.table, cursor, now, now,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to save reconciled cursor")
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	defer stmt.Close()
+	return nil
+}
+
+// applyServer writes the server version to the local object, the server
+// revision becomes the base revision of the object and the device clock
+// observes the version clock. The pending outbox records of the object are
+// superseded, the changes kept as the conflict copy are sent after the user
+// resolves it. If the name is taken by the other not synchronized object,
+// that object is moved to the conflict copies. The name taken by the other
+// synchronized object can not be released without the key, so the server
+// version is held as the conflict copy until the user resolves it.
+func (r *SyncEntityRepository) applyServer(
+	ctx context.Context, tx *sql.Tx, id int64, o model.SyncPayload,
+) error {
+	if err := observe(ctx, tx, o.Clock); err != nil {
+		return err
+	}
 
-	for i, p := range IDSyncIDPairs {
-		_, execErr := stmt.ExecContext(ctx, p[1], p[0])
-		if execErr != nil {
-			log.Error().Err(err).Int("index", i).Msg("failed to exec upsert")
-			return tx.Rollback()
+	holder, err := r.selectNameHolder(ctx, tx, o.NameIndex, id)
+	if err != nil {
+		return err
+	}
+	if holder.id != 0 {
+		if holder.syncID != 0 {
+			return r.holdServer(ctx, tx, holder.id, o)
+		}
+		err := r.keepConflict(ctx, tx, holder.id, id, ConflictName)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE id=?;", r.table), holder.id,
+		)
+		if err != nil {
+			return err
+		}
+		if err := supersedeOutbox(ctx, tx, r.table, holder.id); err != nil {
+			return err
 		}
 	}
-	return tx.Commit()
+
+	payloadData, streamID, err := r.writeStream(ctx, tx, o)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE %s
+		SET name_index=?, name=?, data=?, created_at=?, updated_at=?,
+		  deleted=?, clock=?, sync_id=?, sync_rev=?, sync_clock=?,
+		  uuid=COALESCE(NULLIF(?, ''), uuid), hash=?, hash_clock=?
+		WHERE id=?;
+		`, r.table,
+	), nullIndex(o.NameIndex), o.Name, payloadData, o.CreatedAt,
+		o.Clock.Time(), o.Deleted, o.Clock, o.ID, o.Revision, o.Clock,
+		o.UUID, nullHash(o.Hash), o.Clock, id,
+	)
+	if err != nil {
+		return err
+	}
+	if err := supersedeOutbox(ctx, tx, r.table, id); err != nil {
+		return err
+	}
+	if !r.streams {
+		return nil
+	}
+	return replaceStream(ctx, tx, id, streamID)
+}
+
+type nameHolder struct {
+	id     int64
+	syncID int64
+}
+
+// selectNameHolder returns the object other than the exceptID with the name
+// index, the zero holder is returned if the name is free.
+func (r *SyncEntityRepository) selectNameHolder(
+	ctx context.Context, tx *sql.Tx, nameIndex string, exceptID int64,
+) (nameHolder, error) {
+	if nameIndex == "" {
+		return nameHolder{}, nil
+	}
+
+	var (
+		h      nameHolder
+		syncID sql.NullInt64
+	)
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT id, sync_id FROM %s WHERE name_index=? AND id<>?;", r.table,
+	), nameIndex, exceptID).Scan(&h.id, &syncID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nameHolder{}, nil
+		}
+		return nameHolder{}, err
+	}
+	h.syncID = syncID.Int64
+	return h, nil
+}
+
+// keepConflict copies the local object to the conflicts of the entry,
+// the stream of the binary is moved to the copy.
+func (r *SyncEntityRepository) keepConflict(
+	ctx context.Context, tx *sql.Tx, id, entryID int64, reason string,
+) error {
+	streamCol := "NULL"
+	if r.streams {
+		streamCol = "stream_id"
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO conflicts
+		  (entity, entry_id, reason, sync_id, sync_rev, sync_clock,
+		  name_index, name, data, stream_id, deleted, updated_at, created_at,
+		  uuid)
+		SELECT
+		  ?, ?, ?, sync_id, sync_rev, sync_clock, name_index, name,
+		  data, %s, deleted, updated_at, ?, uuid
+		FROM %s
+		WHERE id=?;
+		`, streamCol, r.table,
+	), r.table, entryID, reason, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if !r.streams {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE binaries SET stream_id=NULL WHERE id=?;`, id,
+	)
+	return err
+}
+
+// holdServer keeps the server version as the conflict copy of the entry
+// with the same name, the copy replaces the server version held before.
+func (r *SyncEntityRepository) holdServer(
+	ctx context.Context, tx *sql.Tx, entryID int64, o model.SyncPayload,
+) error {
+	if err := observe(ctx, tx, o.Clock); err != nil {
+		return err
+	}
+
+	var oldStreamID sql.NullString
+	err := tx.QueryRowContext(ctx, `
+		DELETE FROM conflicts
+		WHERE entity=? AND sync_id=? AND reason=?
+		RETURNING stream_id;`,
+		r.table, o.ID, ConflictName,
+	).Scan(&oldStreamID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err := deleteStream(ctx, tx, oldStreamID.String); err != nil {
+		return err
+	}
+
+	payloadData, streamID, err := r.writeStream(ctx, tx, o)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO conflicts
+		  (entity, entry_id, reason, sync_id, sync_rev, sync_clock,
+		  name_index, name, data, stream_id, deleted, updated_at, created_at,
+		  uuid)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		r.table, entryID, ConflictName, o.ID, o.Revision, o.Clock,
+		nullIndex(o.NameIndex), o.Name, payloadData,
+		sql.NullString{String: streamID, Valid: streamID != ""},
+		o.Deleted, o.Clock.Time(), time.Now(), nullUUID(o.UUID),
+	)
+	return err
 }
 
 func (r *SyncEntityRepository) makeStrIDList(sID []int64) string {
@@ -425,10 +1034,12 @@ func (r *SyncEntityRepository) makeStrIDList(sID []int64) string {
 	return b.String()
 }
 
-func (r *SyncEntityRepository) querySlice(
-	ctx context.Context, log logger.Logger, op string, stmt string,
+// querySlice returns the objects ordered by the statement, the slice is
+// cut short if the objects exceed maxPageBytes, see GetAll.
+func (r *SyncEntityRepository) querySlice(ctx context.Context,
+	log logger.Logger, op string, stmt string, args ...any,
 ) ([]model.LocalPayload, error) {
-	rows, err := r.db.QueryContext(ctx, stmt)
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to select rows")
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -437,6 +1048,7 @@ func (r *SyncEntityRepository) querySlice(
 
 	data := make([]model.LocalPayload, 0)
 
+	var size int
 	for rows.Next() {
 		var m model.LocalPayload
 
@@ -445,6 +1057,11 @@ func (r *SyncEntityRepository) querySlice(
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
+		n := len(m.Name) + len(m.Data)
+		if len(data) != 0 && size+n > maxPageBytes {
+			break
+		}
+		size += n
 		data = append(data, m)
 	}
 
@@ -452,12 +1069,122 @@ func (r *SyncEntityRepository) querySlice(
 		log.Error().Err(err).Msg("failed to select rows")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	rows.Close()
+
+	if err := r.attachStreams(ctx, data); err != nil {
+		log.Error().Err(err).Msg("failed to select streams")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
 	return data, nil
 }
 
+// attachStreams sets the streams of the binaries, the payload data
+// becomes the head of the stream.
+func (r *SyncEntityRepository) attachStreams(
+	ctx context.Context, data []model.LocalPayload,
+) error {
+	if !r.streams {
+		return nil
+	}
+	for i, m := range data {
+		streamID, err := selectStreamID(ctx, r.db, int(m.ID))
+		if err != nil {
+			return err
+		}
+		if streamID == "" {
+			continue
+		}
+		size, err := selectStreamSize(ctx, r.db, streamID)
+		if err != nil {
+			return err
+		}
+		data[i].Data = packHead(m.Data)
+		data[i].Stream = &chunkStream{r.db, streamID, size}
+	}
+	return nil
+}
+
+// writeStream saves the stream of the binary payload data and returns
+// the data without the stream and the new stream id. The payload data
+// is read chunk by chunk, so the stream downloaded from the server goes
+// to the chunks without being held in memory.
+func (r *SyncEntityRepository) writeStream(
+	ctx context.Context, tx *sql.Tx, o model.SyncPayload,
+) ([]byte, string, error) {
+	if !r.streams && o.Stream == nil {
+		return o.Data, "", nil
+	}
+	src, err := o.OpenData(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+	if !r.streams {
+		data, err := io.ReadAll(src)
+		return data, "", err
+	}
+
+	br := bufio.NewReader(src)
+	data, ok, err := readStreamHead(br)
+	if err != nil || !ok {
+		return data, "", err
+	}
+	streamID := newStreamID()
+	w := newChunkWriter(ctx, tx, streamID)
+	if _, err := io.Copy(w, br); err != nil {
+		return nil, "", err
+	}
+	return data, streamID, w.Close()
+}
+
+func replaceStream(
+	ctx context.Context, tx *sql.Tx, id int64, streamID string,
+) error {
+	var oldStreamID sql.NullString
+	err := tx.QueryRowContext(ctx,
+		`SELECT stream_id FROM binaries WHERE id=?;`, id,
+	).Scan(&oldStreamID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE binaries SET stream_id=? WHERE id=?;`,
+		sql.NullString{String: streamID, Valid: streamID != ""}, id,
+	)
+	if err != nil {
+		return err
+	}
+	return deleteStream(ctx, tx, oldStreamID.String)
+}
+
+// nullUUID stores the empty UUID of the object synchronized before
+// the UUIDs as NULL.
+func nullUUID(uuid string) sql.NullString {
+	return sql.NullString{String: uuid, Valid: uuid != ""}
+}
+
+// nullHash stores the empty content hash of the object written without
+// the hash as NULL.
+func nullHash(hash string) sql.NullString {
+	return sql.NullString{String: hash, Valid: hash != ""}
+}
+
+// nullIndex stores the empty index of the deleted entry as NULL,
+// so it does not violate the index uniqueness.
+func nullIndex(nameIndex string) sql.NullString {
+	return sql.NullString{String: nameIndex, Valid: nameIndex != ""}
+}
+
 func isSQLiteEniqueErr(err error) bool {
 	var sqliteErr sqlite3.Error
 	return errors.As(err, &sqliteErr) &&
 		sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
 }
+
+func isSQLitePrimaryKeyErr(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
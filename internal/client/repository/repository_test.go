@@ -11,6 +11,7 @@ import (
 	"github.com/niksmo/gophkeeper/internal/client/repository"
 	"github.com/niksmo/gophkeeper/internal/client/storage"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -42,44 +43,54 @@ func TestAdd(t *testing.T) {
 	t.Run("Ordinary", func(t *testing.T) {
 		st := newSuite(t, repository.NewPwd)
 		expectedID := 1
-		expectedName := "testName"
+		expectedIndex := "testIndex"
+		expectedName := []byte("testName")
 		expectedData := []byte("helloWorld")
-		id, err := st.r.Create(st.ctx, expectedName, expectedData)
+		expectedUUID := uuid.New()
+		id, err := st.r.Create(
+			st.ctx, expectedUUID, expectedIndex, expectedName, expectedData, "",
+		)
 		require.NoError(t, err)
 		assert.Equal(t, expectedID, id)
 
 		stmt := `
-		SELECT name, data FROM passwords;
+		SELECT uuid, name_index, name, data FROM passwords;
 		`
 		rows, err := st.s.QueryContext(st.ctx, stmt)
 		require.NoError(t, err)
 		defer rows.Close()
 		var nRows int
-		var name string
-		var data []byte
+		var entryUUID, index string
+		var name, data []byte
 		for rows.Next() {
 			nRows++
-			err := rows.Scan(&name, &data)
+			err := rows.Scan(&entryUUID, &index, &name, &data)
 			require.NoError(t, err)
 		}
 		err = rows.Err()
 		require.NoError(t, err)
 		require.Equal(t, 1, nRows)
+		assert.Equal(t, expectedUUID, entryUUID)
+		assert.Equal(t, expectedIndex, index)
 		assert.Equal(t, expectedName, name)
 		assert.Equal(t, expectedData, data)
 	})
 
 	t.Run("UniqueNameConstraintErr", func(t *testing.T) {
-		st := newSuite(t, repository.NewBin)
-		objectName := "testName"
+		st := newSuite(t, repository.NewPwd)
+		objectIndex := "testIndex"
 		objectData := []byte("testData")
 		expectedID := 1
-		actualID, err := st.r.Create(st.ctx, objectName, objectData)
+		actualID, err := st.r.Create(
+			st.ctx, uuid.New(), objectIndex, []byte("testName1"), objectData, "",
+		)
 		require.NoError(t, err)
 		require.Equal(t, expectedID, actualID)
 
-		_, err = st.r.Create(st.ctx, objectName, objectData)
-		assert.Error(t, err)
+		_, err = st.r.Create(
+			st.ctx, uuid.New(), objectIndex, []byte("testName2"), objectData, "",
+		)
+		assert.ErrorIs(t, err, repository.ErrAlreadyExists)
 	})
 }
 
@@ -101,9 +112,10 @@ func TestReadByID(t *testing.T) {
 		require.NoError(t, err)
 
 		id := 1
-		retData, err := st.r.ReadByID(st.ctx, id)
+		e, err := st.r.ReadByID(st.ctx, id)
 		require.NoError(t, err)
-		assert.Equal(t, data, retData)
+		assert.Empty(t, e.Index)
+		assert.Equal(t, data, e.Data)
 	})
 
 	t.Run("NotExistsID", func(t *testing.T) {
@@ -123,9 +135,9 @@ func TestReadByID(t *testing.T) {
 		require.NoError(t, err)
 
 		id := 2
-		retData, err := st.r.ReadByID(st.ctx, id)
+		e, err := st.r.ReadByID(st.ctx, id)
 		require.ErrorIs(t, err, repository.ErrNotExists)
-		assert.Nil(t, retData)
+		assert.Nil(t, e.Data)
 	})
 
 	t.Run("ExistsIDButDeleted", func(t *testing.T) {
@@ -146,111 +158,107 @@ func TestReadByID(t *testing.T) {
 		require.NoError(t, err)
 
 		id := 1
-		retData, err := st.r.ReadByID(st.ctx, id)
+		e, err := st.r.ReadByID(st.ctx, id)
+		require.ErrorIs(t, err, repository.ErrNotExists)
+		assert.Nil(t, e.Data)
+	})
+}
+
+func TestReadUUID(t *testing.T) {
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newSuite(t, repository.NewPwd)
+		expected := uuid.New()
+		id, err := st.r.Create(
+			st.ctx, expected, "testIndex", []byte("testName"), []byte("data"), "",
+		)
+		require.NoError(t, err)
+
+		actual, err := st.r.ReadUUID(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		st := newSuite(t, repository.NewPwd)
+		id, err := st.r.Create(
+			st.ctx, uuid.New(), "testIndex", []byte("testName"), []byte("data"), "",
+		)
+		require.NoError(t, err)
+		require.NoError(t, st.r.Delete(st.ctx, id))
+
+		_, err = st.r.ReadUUID(st.ctx, id)
 		require.ErrorIs(t, err, repository.ErrNotExists)
-		assert.Nil(t, retData)
 	})
 }
 
 func TestListNames(t *testing.T) {
 	type obj struct {
-		name      string
-		data      []byte
+		index     sql.NullString
+		name      []byte
 		createdAt time.Time
-		updatedAt time.Time
 		deleted   bool
 	}
 
-	t.Run("Ordinary", func(t *testing.T) {
-		st := newSuite(t, repository.NewPwd)
+	insert := func(t *testing.T, st *suite, inserts []obj) {
 		stmt, err := st.s.PrepareContext(
 			st.ctx,
 			`INSERT INTO
-			  passwords (name, data, created_at, updated_at, deleted)
+			  passwords (name_index, name, created_at, updated_at, deleted)
 			VALUES (?, ?, ?, ?, ?);`,
 		)
 		require.NoError(t, err)
-
-		tNow := time.Now()
-		notDeleted := false
-		inserts := []obj{
-			{"A", []byte("A"), tNow, tNow, notDeleted},
-			{"C", []byte("C"), tNow, tNow, notDeleted},
-			{"B", []byte("B"), tNow, tNow, notDeleted},
-		}
+		defer stmt.Close()
 
 		for _, obj := range inserts {
-			stmt.ExecContext(
+			_, err := stmt.ExecContext(
 				st.ctx,
-				obj.name, obj.data, obj.createdAt, obj.updatedAt, obj.deleted,
+				obj.index, obj.name, obj.createdAt, obj.createdAt, obj.deleted,
 			)
+			require.NoError(t, err)
 		}
-		err = stmt.Close()
-		require.NoError(t, err)
+	}
+
+	index := func(s string) sql.NullString {
+		return sql.NullString{String: s, Valid: true}
+	}
+
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newSuite(t, repository.NewPwd)
+		tNow := time.Now()
+		insert(t, st, []obj{
+			{index("a"), []byte("A"), tNow, false},
+			{index("c"), []byte("C"), tNow, false},
+			{sql.NullString{}, []byte("legacy"), tNow, false},
+		})
 
 		data, err := st.r.ListNames(st.ctx)
 		require.NoError(t, err)
-		require.Len(t, data, len(inserts))
-
-		for i, item := range data {
-			id, name := item[0], item[1]
-			switch i {
-			case 0:
-				assert.Equal(t, "1", id)
-				assert.Equal(t, "A", name)
-			case 1:
-				assert.Equal(t, "3", id)
-				assert.Equal(t, "B", name)
-			case 2:
-				assert.Equal(t, "2", id)
-				assert.Equal(t, "C", name)
-			}
+
+		expected := []repository.SealedName{
+			{ID: 1, Index: "a", Name: []byte("A")},
+			{ID: 2, Index: "c", Name: []byte("C")},
+			{ID: 3, Index: "", Name: []byte("legacy")},
 		}
+		assert.Equal(t, expected, data)
 	})
 
 	t.Run("HaveDeleted", func(t *testing.T) {
 		st := newSuite(t, repository.NewPwd)
-		stmt, err := st.s.PrepareContext(
-			st.ctx,
-			`INSERT INTO
-			  passwords (name, data, created_at, updated_at, deleted)
-			VALUES (?, ?, ?, ?, ?);`,
-		)
-		require.NoError(t, err)
-
 		tNow := time.Now()
-		notDeleted := false
-		deleted := true
-		inserts := []obj{
-			{"A", []byte("A"), tNow, tNow, notDeleted},
-			{"C", []byte("C"), tNow, tNow, deleted},
-			{"B", []byte("B"), tNow, tNow, notDeleted},
-		}
-
-		for _, obj := range inserts {
-			stmt.ExecContext(
-				st.ctx,
-				obj.name, obj.data, obj.createdAt, obj.updatedAt, obj.deleted,
-			)
-		}
-		err = stmt.Close()
-		require.NoError(t, err)
+		insert(t, st, []obj{
+			{index("a"), []byte("A"), tNow, false},
+			{sql.NullString{}, nil, tNow, true},
+			{index("b"), []byte("B"), tNow, false},
+		})
 
 		data, err := st.r.ListNames(st.ctx)
 		require.NoError(t, err)
-		require.Len(t, data, len(inserts)-1)
-
-		for i, item := range data {
-			id, name := item[0], item[1]
-			switch i {
-			case 0:
-				assert.Equal(t, "1", id)
-				assert.Equal(t, "A", name)
-			case 1:
-				assert.Equal(t, "3", id)
-				assert.Equal(t, "B", name)
-			}
+
+		expected := []repository.SealedName{
+			{ID: 1, Index: "a", Name: []byte("A")},
+			{ID: 3, Index: "b", Name: []byte("B")},
 		}
+		assert.Equal(t, expected, data)
 	})
 }
 
@@ -272,19 +280,25 @@ func TestUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		entryNum := 1
-		updateName := "updateName"
+		updateIndex := "updateIndex"
+		updateName := []byte("updateName")
 		updateData := []byte("updateData")
-		err = st.r.Update(st.ctx, entryNum, updateName, updateData)
+		err = st.r.Update(
+			st.ctx, entryNum, updateIndex, updateName, updateData, "",
+		)
 		require.NoError(t, err)
 
-		var actualName string
+		var actualIndex string
+		var actualName []byte
 		var actualData []byte
 		var actualUpdatedAt time.Time
 
-		err = st.s.QueryRowContext(
-			st.ctx, `SELECT name, data, updated_at FROM passwords WHERE id=1;`,
-		).Scan(&actualName, &actualData, &actualUpdatedAt)
+		err = st.s.QueryRowContext(st.ctx, `
+			SELECT name_index, name, data, updated_at
+			FROM passwords WHERE id=1;`,
+		).Scan(&actualIndex, &actualName, &actualData, &actualUpdatedAt)
 		require.NoError(t, err)
+		assert.Equal(t, updateIndex, actualIndex)
 		assert.Equal(t, updateName, actualName)
 		assert.Equal(t, updateData, actualData)
 		assert.Less(t, insertTime, actualUpdatedAt)
@@ -293,9 +307,11 @@ func TestUpdate(t *testing.T) {
 	t.Run("NotExistsEntry", func(t *testing.T) {
 		st := newSuite(t, repository.NewPwd)
 		entryNum := 1
-		updateName := "updateName"
+		updateName := []byte("updateName")
 		updateData := []byte("updateData")
-		err := st.r.Update(st.ctx, entryNum, updateName, updateData)
+		err := st.r.Update(
+			st.ctx, entryNum, "updateIndex", updateName, updateData, "",
+		)
 		assert.ErrorIs(t, err, repository.ErrNotExists)
 	})
 }
@@ -309,11 +325,12 @@ func TestDelete(t *testing.T) {
 		insertTime := time.Now()
 		_, err := st.s.ExecContext(st.ctx,
 			`
-			INSERT INTO passwords (name, data, created_at, updated_at)
+			INSERT INTO passwords
+			  (name_index, name, data, created_at, updated_at)
 			VALUES
-			  (?, ?, ?, ?);
+			  (?, ?, ?, ?, ?);
 			`,
-			insertName, insertData, insertTime, insertTime,
+			"insertIndex", insertName, insertData, insertTime, insertTime,
 		)
 		require.NoError(t, err)
 
@@ -321,16 +338,22 @@ func TestDelete(t *testing.T) {
 		err = st.r.Delete(st.ctx, entryNum)
 		require.NoError(t, err)
 
+		var actualIndex sql.NullString
 		var actualName sql.NullString
 		var actualData []byte
 		var actualUpdatedAt time.Time
 		var actualDeleted bool
-		err = st.s.QueryRowContext(
-			st.ctx, `SELECT name, data, updated_at, deleted FROM passwords WHERE id=1;`,
-		).Scan(&actualName, &actualData, &actualUpdatedAt, &actualDeleted)
+		err = st.s.QueryRowContext(st.ctx, `
+			SELECT name_index, name, data, updated_at, deleted
+			FROM passwords WHERE id=1;`,
+		).Scan(
+			&actualIndex, &actualName, &actualData,
+			&actualUpdatedAt, &actualDeleted,
+		)
 		require.NoError(t, err)
 
 		var expectedData []byte
+		assert.False(t, actualIndex.Valid)
 		assert.False(t, actualName.Valid)
 		assert.Equal(t, expectedData, actualData)
 		assert.True(t, actualDeleted)
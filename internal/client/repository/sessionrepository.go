@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+// SessionRepository keeps the only sync session.
+type SessionRepository struct {
+	log logger.Logger
+	db  Storage
+}
+
+func NewSession(l logger.Logger, db Storage) *SessionRepository {
+	return &SessionRepository{l, db}
+}
+
+// Save replaces the session with the new not expired one.
+func (r *SessionRepository) Save(
+	ctx context.Context, login string, token []byte,
+) error {
+	const op = "SessionRepository.Save"
+	log := r.log.WithOp(op)
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sessions (id, login, token, expired, updated_at)
+		VALUES (1, ?, ?, FALSE, ?)
+		ON CONFLICT (id) DO UPDATE
+		SET login=excluded.login,
+		    token=excluded.token,
+		    expired=FALSE,
+		    updated_at=excluded.updated_at;`,
+		login, token, time.Now(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to save session")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Read(ctx context.Context) (dto.Session, error) {
+	const op = "SessionRepository.Read"
+	log := r.log.WithOp(op)
+
+	var s dto.Session
+	err := r.db.QueryRowContext(ctx,
+		`SELECT login, token, expired FROM sessions WHERE id=1;`,
+	).Scan(&s.Login, &s.Token, &s.Expired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Msg("session is not exists")
+			return dto.Session{}, fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to read session")
+		return dto.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return s, nil
+}
+
+// SetExpired marks the session rejected by the server.
+func (r *SessionRepository) SetExpired(ctx context.Context) error {
+	const op = "SessionRepository.SetExpired"
+	log := r.log.WithOp(op)
+
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET expired=TRUE, updated_at=? WHERE id=1;`,
+		time.Now(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to expire session")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Delete(ctx context.Context) error {
+	const op = "SessionRepository.Delete"
+	log := r.log.WithOp(op)
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sessions;`); err != nil {
+		log.Error().Err(err).Msg("failed to delete session")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
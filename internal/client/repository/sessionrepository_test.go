@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSessionRepo(t *testing.T) *repository.SessionRepository {
+	log := logger.NewPretty("debug")
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	t.Cleanup(func() {
+		os.Remove(dsn)
+	})
+
+	s := storage.New(log, dsn)
+	s.MustRun(context.Background())
+	return repository.NewSession(log, s)
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("NotExists", func(t *testing.T) {
+		r := newSessionRepo(t)
+		_, err := r.Read(ctx)
+		assert.ErrorIs(t, err, repository.ErrNotExists)
+	})
+
+	t.Run("SaveRead", func(t *testing.T) {
+		r := newSessionRepo(t)
+		require.NoError(t, r.Save(ctx, "first", []byte("token1")))
+		require.NoError(t, r.Save(ctx, "second", []byte("token2")))
+
+		s, err := r.Read(ctx)
+		require.NoError(t, err)
+		expected := dto.Session{Login: "second", Token: []byte("token2")}
+		assert.Equal(t, expected, s)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		r := newSessionRepo(t)
+		require.NoError(t, r.Save(ctx, "login", []byte("token")))
+		require.NoError(t, r.SetExpired(ctx))
+
+		s, err := r.Read(ctx)
+		require.NoError(t, err)
+		assert.True(t, s.Expired)
+
+		require.NoError(t, r.Save(ctx, "login", []byte("token")))
+		s, err = r.Read(ctx)
+		require.NoError(t, err)
+		assert.False(t, s.Expired)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		r := newSessionRepo(t)
+		require.NoError(t, r.Save(ctx, "login", []byte("token")))
+		require.NoError(t, r.Delete(ctx))
+		require.NoError(t, r.Delete(ctx))
+
+		_, err := r.Read(ctx)
+		assert.ErrorIs(t, err, repository.ErrNotExists)
+	})
+}
@@ -99,3 +99,92 @@ func (r *SyncRepository) Update(ctx context.Context, dto dto.Sync) error {
 
 	return nil
 }
+
+// syncEntities are the synchronized tables in the order of the workers.
+var syncEntities = []string{passwords, texts, cards, binaries}
+
+// SaveSynced records the successful synchronization of the entity,
+// the changes made after syncedAt are pending.
+func (r *SyncRepository) SaveSynced(
+	ctx context.Context, entity string, syncedAt time.Time,
+) error {
+	const op = "SyncRepository.SaveSynced"
+
+	log := r.log.WithOp(op)
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sync_states (entity, synced_at, error, updated_at)
+		VALUES (?, ?, NULL, ?)
+		ON CONFLICT (entity) DO UPDATE
+		SET synced_at=excluded.synced_at,
+		    error=NULL,
+		    updated_at=excluded.updated_at;`,
+		entity, syncedAt, time.Now(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to save sync state")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SaveError records the failed synchronization of the entity,
+// the time of the last successful synchronization is kept.
+func (r *SyncRepository) SaveError(
+	ctx context.Context, entity string, msg string,
+) error {
+	const op = "SyncRepository.SaveError"
+
+	log := r.log.WithOp(op)
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sync_states (entity, error, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (entity) DO UPDATE
+		SET error=excluded.error,
+		    updated_at=excluded.updated_at;`,
+		entity, msg, time.Now(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to save sync state")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ReadStates returns the state of every synchronized entity. The entries
+// with the outbox records not acknowledged by the server are pending.
+func (r *SyncRepository) ReadStates(
+	ctx context.Context,
+) ([]dto.SyncState, error) {
+	const op = "SyncRepository.ReadStates"
+
+	log := r.log.WithOp(op)
+
+	states := make([]dto.SyncState, 0, len(syncEntities))
+	for _, entity := range syncEntities {
+		state := dto.SyncState{Entity: entity}
+		var msg sql.NullString
+		err := r.db.QueryRowContext(ctx,
+			`SELECT synced_at, error FROM sync_states WHERE entity=?;`,
+			entity,
+		).Scan(&state.SyncedAt, &msg)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Error().Err(err).Msg("failed to read sync state")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		state.Error = msg.String
+
+		err = r.db.QueryRowContext(ctx, `
+			SELECT COUNT(DISTINCT entry_id) FROM outbox
+			WHERE entity=? AND acked_at IS NULL;`,
+			entity,
+		).Scan(&state.Pending)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to count pending entries")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
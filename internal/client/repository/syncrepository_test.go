@@ -1,7 +1,9 @@
 package repository_test
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,7 +12,11 @@ import (
 	"github.com/niksmo/gophkeeper/internal/client/dto"
 	"github.com/niksmo/gophkeeper/internal/client/repository"
 	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/pkg/hlc"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -106,3 +112,323 @@ func TestSyncUpdate(t *testing.T) {
 		assert.Zero(t, obj.StoppedAt.Compare(*updated.StoppedAt))
 	})
 }
+
+func TestSyncStates(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	pwd := repository.NewPwd(log, st.s)
+
+	findState := func(t *testing.T, entity string) dto.SyncState {
+		t.Helper()
+		states, err := st.r.ReadStates(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, states, 4)
+		for _, s := range states {
+			if s.Entity == entity {
+				return s
+			}
+		}
+		t.Fatalf("no state of %q", entity)
+		return dto.SyncState{}
+	}
+
+	state := findState(t, "passwords")
+	assert.Nil(t, state.SyncedAt)
+	assert.Zero(t, state.Pending)
+
+	id, err := pwd.Create(st.ctx, uuid.New(), "index", []byte("name"), []byte("data"), "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, findState(t, "passwords").Pending)
+
+	pwdSync := repository.NewPwdSync(log, st.s)
+	setBase := func(t *testing.T) {
+		t.Helper()
+		comp, err := pwdSync.GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 1)
+		err = pwdSync.SetSliceSyncBase(st.ctx, []model.SyncBase{
+			{ID: int64(id), SyncID: 100, Revision: 1, Clock: comp[0].Clock},
+		})
+		require.NoError(t, err)
+	}
+
+	setBase(t)
+	require.NoError(t, st.r.SaveSynced(st.ctx, "passwords", time.Now()))
+	state = findState(t, "passwords")
+	require.NotNil(t, state.SyncedAt)
+	assert.Zero(t, state.Pending)
+	assert.Empty(t, state.Error)
+
+	err = pwd.Update(st.ctx, id, "index", []byte("name"), []byte("new"), "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, findState(t, "passwords").Pending,
+		"changed since the base version")
+
+	syncedAt := *state.SyncedAt
+	require.NoError(t, st.r.SaveError(st.ctx, "passwords", "failed"))
+	state = findState(t, "passwords")
+	assert.Equal(t, "failed", state.Error)
+	require.NotNil(t, state.SyncedAt)
+	assert.True(t, syncedAt.Equal(*state.SyncedAt))
+
+	setBase(t)
+	require.NoError(t, st.r.SaveSynced(st.ctx, "passwords", time.Now()))
+	state = findState(t, "passwords")
+	assert.Empty(t, state.Error)
+	assert.Zero(t, state.Pending)
+}
+
+func TestSyncCursor(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	pwdSync := repository.NewPwdSync(log, st.s)
+	cardSync := repository.NewCardSync(log, st.s)
+
+	cursor, err := pwdSync.ReadCursor(st.ctx)
+	require.NoError(t, err)
+	assert.Zero(t, cursor)
+
+	require.NoError(t, pwdSync.SaveCursor(st.ctx, 7))
+	require.NoError(t, st.r.SaveSynced(st.ctx, "passwords", time.Now()))
+	require.NoError(t, pwdSync.SaveCursor(st.ctx, 9))
+
+	cursor, err = pwdSync.ReadCursor(st.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), cursor)
+
+	cursor, err = cardSync.ReadCursor(st.ctx)
+	require.NoError(t, err)
+	assert.Zero(t, cursor, "the cursor is kept per entity")
+
+	reconciledAt, err := pwdSync.ReadReconciledAt(st.ctx)
+	require.NoError(t, err)
+	assert.True(t, reconciledAt.IsZero())
+
+	require.NoError(t, pwdSync.SaveReconciled(st.ctx, 11))
+	cursor, err = pwdSync.ReadCursor(st.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), cursor)
+	reconciledAt, err = pwdSync.ReadReconciledAt(st.ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), reconciledAt, time.Minute)
+
+	states, err := st.r.ReadStates(st.ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, states[0].SyncedAt, "the state is kept with the cursor")
+}
+
+func TestSyncOutbox(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	pwd := repository.NewPwd(log, st.s)
+	pwdSync := repository.NewPwdSync(log, st.s)
+
+	outbox := func(t *testing.T) []model.OutboxEntry {
+		t.Helper()
+		entries, err := pwdSync.GetOutbox(st.ctx)
+		require.NoError(t, err)
+		return entries
+	}
+	payloadKey := func(t *testing.T) string {
+		t.Helper()
+		data, err := pwdSync.GetAll(st.ctx, 0, 100)
+		require.NoError(t, err)
+		require.Len(t, data, 1)
+		return data[0].Key
+	}
+
+	id, err := pwd.Create(st.ctx, uuid.New(), "index", []byte("name"), []byte("data"), "")
+	require.NoError(t, err)
+	err = pwd.Update(st.ctx, id, "index", []byte("name"), []byte("new"), "")
+	require.NoError(t, err)
+
+	entries := outbox(t)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "create", entries[0].Op)
+	assert.Equal(t, "update", entries[1].Op)
+	assert.Less(t, entries[0].Clock, entries[1].Clock)
+	assert.NotEqual(t, entries[0].Key, entries[1].Key)
+	assert.Equal(t, entries[0].Key, payloadKey(t),
+		"the object not sent yet is inserted with the first key")
+
+	err = pwdSync.SetSliceSyncBase(st.ctx, []model.SyncBase{
+		{ID: int64(id), SyncID: 100, Revision: 1, Clock: entries[0].Clock},
+	})
+	require.NoError(t, err)
+	entries = outbox(t)
+	require.Len(t, entries, 1, "the records up to the base clock are acked")
+	assert.Equal(t, entries[0].Key, payloadKey(t))
+
+	comp, err := pwdSync.GetComparable(st.ctx)
+	require.NoError(t, err)
+	require.Len(t, comp, 1)
+	assert.True(t, comp[0].Pending)
+
+	require.NoError(t, pwd.Delete(st.ctx, id))
+	entries = outbox(t)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "delete", entries[1].Op)
+	assert.Equal(t, entries[1].Key, payloadKey(t),
+		"the synchronized object is updated with the last key")
+
+	id, err = pwd.Create(st.ctx, uuid.New(), "other", []byte("other"), []byte("data"), "")
+	require.NoError(t, err)
+	require.Len(t, outbox(t), 3)
+	require.NoError(t, pwd.Delete(st.ctx, id))
+	assert.Len(t, outbox(t), 2,
+		"the entry deleted before it is sent is not sent")
+}
+
+func TestSyncUUID(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	pwd := repository.NewPwd(log, st.s)
+	pwdSync := repository.NewPwdSync(log, st.s)
+
+	_, err := pwd.Create(st.ctx, uuid.New(), "a", []byte("a"), []byte("data"), "")
+	require.NoError(t, err)
+	data, err := pwdSync.GetAll(st.ctx, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.True(t, uuid.Valid(data[0].UUID))
+
+	srvUUID := uuid.New()
+	clock := hlc.FromTime(time.Now())
+	err = pwdSync.InsertSlice(st.ctx, []model.LocalPayload{
+		{
+			SyncPayload: model.SyncPayload{
+				ID: -1, NameIndex: "b", Name: []byte("b"), Data: []byte("data"),
+				CreatedAt: clock.Time(), Revision: 1, Clock: clock,
+				UUID: srvUUID,
+			},
+			SyncID: 10,
+		},
+	})
+	require.NoError(t, err)
+
+	comp, err := pwdSync.GetComparable(st.ctx)
+	require.NoError(t, err)
+	require.Len(t, comp, 2)
+	assert.Equal(t, data[0].UUID, comp[0].UUID)
+	assert.Equal(t, srvUUID, comp[1].UUID,
+		"the server object keeps its identity")
+}
+
+func TestSyncGetAllPages(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	pwd := repository.NewPwd(log, st.s)
+	pwdSync := repository.NewPwdSync(log, st.s)
+
+	var IDs []int64
+	for _, name := range []string{"a", "b", "c"} {
+		id, err := pwd.Create(st.ctx, uuid.New(), name, []byte(name), []byte("data"), "")
+		require.NoError(t, err)
+		IDs = append(IDs, int64(id))
+	}
+
+	page, err := pwdSync.GetAll(st.ctx, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, IDs[:2], []int64{page[0].ID, page[1].ID})
+
+	page, err = pwdSync.GetAll(st.ctx, page[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, IDs[2], page[0].ID)
+
+	page, err = pwdSync.GetAll(st.ctx, IDs[2], 2)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+func TestSyncGetAllPageBytes(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	text := repository.NewText(log, st.s)
+	textSync := repository.NewTextSync(log, st.s)
+
+	data := bytes.Repeat([]byte("x"), 3<<20)
+	var IDs []int64
+	for _, name := range []string{"a", "b"} {
+		id, err := text.Create(st.ctx, uuid.New(), name, []byte(name), data, "")
+		require.NoError(t, err)
+		IDs = append(IDs, int64(id))
+	}
+
+	page, err := textSync.GetAll(st.ctx, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, page, 1, "the page is cut short by the data size")
+	assert.Equal(t, IDs[0], page[0].ID)
+
+	page, err = textSync.GetAll(st.ctx, page[0].ID, 100)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, IDs[1], page[0].ID)
+
+	page, err = textSync.GetSliceByIDs(st.ctx, IDs)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, IDs[0], page[0].ID)
+}
+
+func TestSyncContentHash(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	pwd := repository.NewPwd(log, st.s)
+	pwdSync := repository.NewPwdSync(log, st.s)
+
+	id, err := pwd.Create(st.ctx, uuid.New(), "a", []byte("a"), []byte("data"), "h1")
+	require.NoError(t, err)
+
+	comp, err := pwdSync.GetComparable(st.ctx)
+	require.NoError(t, err)
+	require.Len(t, comp, 1)
+	assert.Equal(t, "h1", comp[0].Hash)
+
+	require.NoError(t, pwd.Update(st.ctx, id, "a", []byte("a"), []byte("new"), "h2"))
+	comp, err = pwdSync.GetComparable(st.ctx)
+	require.NoError(t, err)
+	require.Len(t, comp, 1)
+	assert.Equal(t, "h2", comp[0].Hash)
+
+	require.NoError(t, pwd.Update(st.ctx, id, "a", []byte("a"), []byte("old"), ""))
+	comp, err = pwdSync.GetComparable(st.ctx)
+	require.NoError(t, err)
+	require.Len(t, comp, 1)
+	assert.Empty(t, comp[0].Hash, "the entry written without the hash has no hash")
+
+	var hash sql.NullString
+	err = st.s.QueryRowContext(st.ctx,
+		`SELECT hash FROM passwords WHERE id=?;`, id,
+	).Scan(&hash)
+	require.NoError(t, err)
+	assert.False(t, hash.Valid, "the hash is not computed on compare")
+}
+
+func TestSyncLeaves(t *testing.T) {
+	st := newSyncSuite(t)
+	log := logger.NewPretty("debug")
+	pwd := repository.NewPwd(log, st.s)
+	pwdSync := repository.NewPwdSync(log, st.s)
+
+	_, err := pwd.Create(st.ctx, uuid.New(), "a", []byte("a"), []byte("data"), "")
+	require.NoError(t, err)
+	clock := hlc.FromTime(time.Now())
+	err = pwdSync.InsertSlice(st.ctx, []model.LocalPayload{
+		{
+			SyncPayload: model.SyncPayload{
+				ID: -1, NameIndex: "b", Name: []byte("b"), Data: []byte("data"),
+				CreatedAt: clock.Time(), Revision: 3, Clock: clock,
+				UUID: uuid.New(),
+			},
+			SyncID: 10,
+		},
+	})
+	require.NoError(t, err)
+
+	leaves, err := pwdSync.GetLeaves(st.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []merkle.Leaf{{ID: 10, Revision: 3}}, leaves,
+		"the leaves are the synchronized objects")
+}
@@ -0,0 +1,558 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/hlc"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+var entityTables = []string{passwords, cards, texts, binaries}
+
+type VaultRepository struct {
+	log logger.Logger
+	db  Storage
+}
+
+func NewVault(l logger.Logger, db Storage) *VaultRepository {
+	return &VaultRepository{l, db}
+}
+
+func (r *VaultRepository) ReadParams(ctx context.Context) ([]byte, error) {
+	const op = "VaultRepository.ReadParams"
+	log := r.log.WithOp(op)
+
+	var params []byte
+	err := r.db.QueryRowContext(
+		ctx, `SELECT kdf_params FROM vault WHERE id=1;`,
+	).Scan(&params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Msg("vault params are not exists")
+			return nil, fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select vault params")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return params, nil
+}
+
+func (r *VaultRepository) CreateParams(
+	ctx context.Context, params []byte,
+) error {
+	const op = "VaultRepository.CreateParams"
+	log := r.log.WithOp(op)
+
+	stmt := `
+	INSERT INTO vault (id, kdf_params, created_at, updated_at)
+	VALUES (1, ?, ?, ?);
+	`
+	t := time.Now()
+	_, err := r.db.ExecContext(ctx, stmt, params, t, t)
+	if err != nil {
+		if isSQLitePrimaryKeyErr(err) {
+			log.Debug().Err(err).Msg("vault params already exists")
+			return fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+		}
+		log.Error().Err(err).Msg("failed to insert vault params")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *VaultRepository) ReadVerifier(ctx context.Context) ([]byte, error) {
+	const op = "VaultRepository.ReadVerifier"
+	log := r.log.WithOp(op)
+
+	var verifier []byte
+	err := r.db.QueryRowContext(
+		ctx, `SELECT verifier FROM vault WHERE id=1;`,
+	).Scan(&verifier)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Error().Err(err).Msg("failed to select verifier")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if verifier == nil {
+		log.Debug().Msg("verifier is not exists")
+		return nil, fmt.Errorf("%s: %w", op, ErrNotExists)
+	}
+	return verifier, nil
+}
+
+func (r *VaultRepository) SaveVerifier(
+	ctx context.Context, verifier []byte,
+) error {
+	const op = "VaultRepository.SaveVerifier"
+	log := r.log.WithOp(op)
+
+	stmt := `
+	UPDATE vault SET verifier=?, updated_at=?
+	WHERE id=1 RETURNING id;
+	`
+	var id int
+	err := r.db.QueryRowContext(ctx, stmt, verifier, time.Now()).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Err(err).Msg("vault is not exists")
+			return fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to update verifier")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ReadIndexSalt returns the salt of the blind index key,
+// it is nil for the legacy salt.
+func (r *VaultRepository) ReadIndexSalt(ctx context.Context) ([]byte, error) {
+	const op = "VaultRepository.ReadIndexSalt"
+	log := r.log.WithOp(op)
+
+	var salt []byte
+	err := r.db.QueryRowContext(
+		ctx, `SELECT index_salt FROM vault WHERE id=1;`,
+	).Scan(&salt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Msg("vault is not exists")
+			return nil, fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select index salt")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return salt, nil
+}
+
+// ReadADRequired reports whether every entry of the vault
+// is bound to its record with the associated data.
+func (r *VaultRepository) ReadADRequired(ctx context.Context) (bool, error) {
+	const op = "VaultRepository.ReadADRequired"
+	log := r.log.WithOp(op)
+
+	var required bool
+	err := r.db.QueryRowContext(
+		ctx, `SELECT ad_required FROM vault WHERE id=1;`,
+	).Scan(&required)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Msg("vault is not exists")
+			return false, fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select ad required")
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return required, nil
+}
+
+// SealedEntry is the encrypted entry with its entity type, record UUID
+// and blind index.
+type SealedEntry struct {
+	Entity string
+	UUID   string
+	Index  string
+	Name   []byte
+	Data   []byte
+
+	// Stream reads the chunked content of the binary, it is nil
+	// for the entry without the stream.
+	Stream io.Reader
+
+	// SealStream is set to replace the stream with the written content.
+	SealStream func(w io.Writer) error
+
+	// Hash is the content hash of the sealed entry, it is set
+	// after the SealStream is written.
+	Hash string
+
+	// Hashed reports whether the entry has the content hash of its clock.
+	Hashed bool
+
+	streamID string
+}
+
+// ReadSample returns any not deleted entry.
+func (r *VaultRepository) ReadSample(ctx context.Context) (SealedEntry, error) {
+	const op = "VaultRepository.ReadSample"
+	log := r.log.WithOp(op)
+
+	var b strings.Builder
+	for i, table := range entityTables {
+		if i != 0 {
+			b.WriteString(" UNION ALL ")
+		}
+		fmt.Fprintf(&b,
+			"SELECT '%s', uuid, name_index, name, data FROM %s "+
+				"WHERE deleted=FALSE",
+			table, table,
+		)
+	}
+	b.WriteString(" LIMIT 1;")
+
+	var (
+		e         SealedEntry
+		entryUUID sql.NullString
+		index     sql.NullString
+	)
+	err := r.db.QueryRowContext(ctx, b.String()).Scan(
+		&e.Entity, &entryUUID, &index, &e.Name, &e.Data,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug().Msg("vault is empty")
+			return SealedEntry{}, fmt.Errorf("%s: %w", op, ErrNotExists)
+		}
+		log.Error().Err(err).Msg("failed to select sample")
+		return SealedEntry{}, fmt.Errorf("%s: %w", op, err)
+	}
+	e.UUID, e.Index = entryUUID.String, index.String
+	return e, nil
+}
+
+// Reencrypt passes the verifier to the fn and every not deleted entry
+// to the entryFn and saves the changes in one transaction together with
+// the vault params and the index salt. The verifier is kept if the fn returns nil, the entry
+// is saved if the entryFn reports it is changed. The entryFn gets the empty
+// index for the plaintext name and may replace the stream of the binary.
+// The Hash set to the not changed entry without it is saved alone.
+// After the reencryption every entry is bound to its record, so the vault
+// requires the associated data. Returns the number of updated entries.
+func (r *VaultRepository) Reencrypt(
+	ctx context.Context,
+	params, indexSalt []byte,
+	fn func(data []byte) ([]byte, error),
+	entryFn func(e *SealedEntry) (bool, error),
+) (int, error) {
+	const op = "VaultRepository.Reencrypt"
+	log := r.log.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	clock, err := tick(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to tick clock")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var nUpdated int
+	updatedAt := time.Now()
+	for _, table := range entityTables {
+		n, err := r.reencryptTable(ctx, tx, table, updatedAt, clock, entryFn)
+		if err != nil {
+			if isSQLiteEniqueErr(err) {
+				log.Debug().Err(err).Str("table", table).Msg(
+					"names are not unique")
+				return 0, fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+			}
+			log.Error().Err(err).Str("table", table).Msg(
+				"failed to reencrypt table")
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		nUpdated += n
+	}
+
+	if err := r.reencryptConflicts(ctx, tx, entryFn); err != nil {
+		log.Error().Err(err).Msg("failed to reencrypt conflicts")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = r.reencryptVault(ctx, tx, params, indexSalt, updatedAt, fn)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to update vault")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return nUpdated, nil
+}
+
+func (r *VaultRepository) reencryptVault(
+	ctx context.Context,
+	tx *sql.Tx,
+	params, indexSalt []byte,
+	updatedAt time.Time,
+	fn func(data []byte) ([]byte, error),
+) error {
+	var verifier []byte
+	err := tx.QueryRowContext(
+		ctx, `SELECT verifier FROM vault WHERE id=1;`,
+	).Scan(&verifier)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if verifier != nil {
+		newVerifier, err := fn(verifier)
+		if err != nil {
+			return err
+		}
+		if newVerifier != nil {
+			verifier = newVerifier
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE vault SET kdf_params=?, index_salt=?, verifier=?,
+		ad_required=TRUE, updated_at=? WHERE id=1;`,
+		params, indexSalt, verifier, updatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return r.reencryptSession(ctx, tx, fn)
+}
+
+// reencryptSession reencrypts the sync session token the same way
+// as the verifier.
+func (r *VaultRepository) reencryptSession(
+	ctx context.Context,
+	tx *sql.Tx,
+	fn func(data []byte) ([]byte, error),
+) error {
+	var token []byte
+	err := tx.QueryRowContext(
+		ctx, `SELECT token FROM sessions WHERE id=1;`,
+	).Scan(&token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	newToken, err := fn(token)
+	if err != nil || newToken == nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE sessions SET token=? WHERE id=1;`, newToken,
+	)
+	return err
+}
+
+func (r *VaultRepository) reencryptTable(
+	ctx context.Context,
+	tx *sql.Tx,
+	table string,
+	updatedAt time.Time,
+	clock hlc.Timestamp,
+	entryFn func(e *SealedEntry) (bool, error),
+) (int, error) {
+	entries, err := r.selectEntries(ctx, tx, table)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET name_index=?, name=?, data=?, updated_at=?, clock=?,
+		  hash=?, hash_clock=?
+		WHERE id=?;`, table,
+	))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	hashStmt, err := tx.PrepareContext(ctx, fmt.Sprintf(
+		`UPDATE %s SET hash=?, hash_clock=clock WHERE id=?;`, table,
+	))
+	if err != nil {
+		return 0, err
+	}
+	defer hashStmt.Close()
+
+	var nUpdated int
+	for id, e := range entries {
+		if e.streamID != "" {
+			e.Stream = newChunkReader(ctx, tx, e.streamID)
+		}
+		changed, err := entryFn(&e)
+		if err != nil {
+			return 0, err
+		}
+		if !changed {
+			if e.Hashed || e.Hash == "" {
+				continue
+			}
+			if _, err := hashStmt.ExecContext(ctx, e.Hash, id); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if e.SealStream != nil {
+			if err := r.resealStream(ctx, tx, id, &e); err != nil {
+				return 0, err
+			}
+		}
+		_, err = stmt.ExecContext(
+			ctx, nullIndex(e.Index), e.Name, e.Data, updatedAt, clock,
+			nullHash(e.Hash), clock, id,
+		)
+		if err != nil {
+			return 0, err
+		}
+		err = appendOutbox(ctx, tx, table, int64(id), opUpdate, clock)
+		if err != nil {
+			return 0, err
+		}
+		nUpdated++
+	}
+	return nUpdated, nil
+}
+
+// reencryptConflicts reencrypts the conflict copies the same way
+// as the entries, the copies are not counted.
+func (r *VaultRepository) reencryptConflicts(
+	ctx context.Context,
+	tx *sql.Tx,
+	entryFn func(e *SealedEntry) (bool, error),
+) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, entity, uuid, name_index, name, data, stream_id
+		FROM conflicts WHERE deleted=FALSE;`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	entries := make(map[int]SealedEntry)
+	for rows.Next() {
+		var (
+			id        int
+			e         SealedEntry
+			entryUUID sql.NullString
+			index     sql.NullString
+			streamID  sql.NullString
+		)
+		err := rows.Scan(
+			&id, &e.Entity, &entryUUID, &index, &e.Name, &e.Data, &streamID,
+		)
+		if err != nil {
+			return err
+		}
+		e.UUID, e.Index = entryUUID.String, index.String
+		e.streamID = streamID.String
+		entries[id] = e
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for id, e := range entries {
+		if e.streamID != "" {
+			e.Stream = newChunkReader(ctx, tx, e.streamID)
+		}
+		changed, err := entryFn(&e)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+
+		streamID := e.streamID
+		if e.SealStream != nil {
+			streamID = newStreamID()
+			w := newChunkWriter(ctx, tx, streamID)
+			if err := e.SealStream(w); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE conflicts SET name_index=?, name=?, data=?, stream_id=?
+			WHERE id=?;`,
+			nullIndex(e.Index), e.Name, e.Data,
+			sql.NullString{String: streamID, Valid: streamID != ""}, id,
+		)
+		if err != nil {
+			return err
+		}
+		if streamID != e.streamID {
+			if err := deleteStream(ctx, tx, e.streamID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *VaultRepository) selectEntries(
+	ctx context.Context, tx *sql.Tx, table string,
+) (map[int]SealedEntry, error) {
+	streamCol := "NULL"
+	if table == binaries {
+		streamCol = "stream_id"
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, uuid, name_index, name, data, %s,
+		  hash IS NOT NULL AND hash_clock=clock
+		FROM %s
+		WHERE deleted=FALSE;`,
+		streamCol, table,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[int]SealedEntry)
+	for rows.Next() {
+		var (
+			id        int
+			entryUUID sql.NullString
+			index     sql.NullString
+			streamID  sql.NullString
+		)
+		e := SealedEntry{Entity: table}
+		err := rows.Scan(
+			&id, &entryUUID, &index, &e.Name, &e.Data, &streamID, &e.Hashed,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.UUID, e.Index = entryUUID.String, index.String
+		e.streamID = streamID.String
+		entries[id] = e
+	}
+	return entries, rows.Err()
+}
+
+func (r *VaultRepository) resealStream(
+	ctx context.Context, tx *sql.Tx, id int, e *SealedEntry,
+) error {
+	streamID := newStreamID()
+	w := newChunkWriter(ctx, tx, streamID)
+	if err := e.SealStream(w); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`UPDATE binaries SET stream_id=? WHERE id=?;`, streamID, id,
+	)
+	if err != nil {
+		return err
+	}
+	return deleteStream(ctx, tx, e.streamID)
+}
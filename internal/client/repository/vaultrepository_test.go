@@ -0,0 +1,296 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type vaultRepoSuite struct {
+	ctx context.Context
+	r   *repository.VaultRepository
+	s   *storage.Storage
+}
+
+func newVaultSuite(t *testing.T) *vaultRepoSuite {
+	log := logger.NewPretty("debug")
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		os.Remove(dsn)
+	})
+
+	s := storage.New(log, dsn)
+	s.MustRun(ctx)
+	r := repository.NewVault(log, s)
+	return &vaultRepoSuite{ctx, r, s}
+}
+
+func TestVaultParams(t *testing.T) {
+	t.Run("NotExists", func(t *testing.T) {
+		st := newVaultSuite(t)
+		_, err := st.r.ReadParams(st.ctx)
+		require.ErrorIs(t, err, repository.ErrNotExists)
+	})
+
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newVaultSuite(t)
+		expected := []byte("params")
+		err := st.r.CreateParams(st.ctx, expected)
+		require.NoError(t, err)
+
+		actual, err := st.r.ReadParams(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("AlreadyExists", func(t *testing.T) {
+		st := newVaultSuite(t)
+		err := st.r.CreateParams(st.ctx, []byte("params"))
+		require.NoError(t, err)
+
+		err = st.r.CreateParams(st.ctx, []byte("params"))
+		require.ErrorIs(t, err, repository.ErrAlreadyExists)
+	})
+}
+
+func TestVaultVerifier(t *testing.T) {
+	t.Run("NotExists", func(t *testing.T) {
+		st := newVaultSuite(t)
+		require.NoError(t, st.r.CreateParams(st.ctx, []byte("params")))
+		_, err := st.r.ReadVerifier(st.ctx)
+		require.ErrorIs(t, err, repository.ErrNotExists)
+	})
+
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newVaultSuite(t)
+		require.NoError(t, st.r.CreateParams(st.ctx, []byte("params")))
+
+		expected := []byte("verifier")
+		err := st.r.SaveVerifier(st.ctx, expected)
+		require.NoError(t, err)
+
+		actual, err := st.r.ReadVerifier(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("SaveWithoutParams", func(t *testing.T) {
+		st := newVaultSuite(t)
+		err := st.r.SaveVerifier(st.ctx, []byte("verifier"))
+		require.ErrorIs(t, err, repository.ErrNotExists)
+	})
+}
+
+func TestVaultReadSample(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		st := newVaultSuite(t)
+		_, err := st.s.ExecContext(st.ctx,
+			`INSERT INTO texts (name, data, created_at, updated_at, deleted)
+			VALUES ('A', NULL, ?, ?, TRUE);`, time.Now(), time.Now(),
+		)
+		require.NoError(t, err)
+
+		_, err = st.r.ReadSample(st.ctx)
+		require.ErrorIs(t, err, repository.ErrNotExists)
+	})
+
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newVaultSuite(t)
+		_, err := st.s.ExecContext(st.ctx,
+			`INSERT INTO cards (name, data, created_at, updated_at, deleted)
+			VALUES ('A', 'sample', ?, ?, FALSE);`, time.Now(), time.Now(),
+		)
+		require.NoError(t, err)
+
+		sample, err := st.r.ReadSample(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "cards", sample.Entity)
+		assert.Equal(t, []byte("A"), sample.Name)
+		assert.Equal(t, []byte("sample"), sample.Data)
+	})
+}
+
+func keepData([]byte) ([]byte, error) {
+	return nil, nil
+}
+
+func TestVaultReencrypt(t *testing.T) {
+	insert := func(t *testing.T, st *vaultRepoSuite, table, name string,
+		data []byte, updatedAt time.Time, deleted bool) {
+		t.Helper()
+		_, err := st.s.ExecContext(st.ctx,
+			`INSERT INTO `+table+` (name, data, created_at, updated_at, deleted)
+			VALUES (?, ?, ?, ?, ?);`,
+			name, data, updatedAt, updatedAt, deleted,
+		)
+		require.NoError(t, err)
+	}
+
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newVaultSuite(t)
+		require.NoError(t, st.r.CreateParams(st.ctx, []byte("old")))
+		require.NoError(t, st.r.SaveVerifier(st.ctx, []byte("v")))
+
+		insertTime := time.Now().Add(-time.Hour)
+		insert(t, st, "passwords", "A", []byte("a"), insertTime, false)
+		insert(t, st, "cards", "B", []byte("b"), insertTime, false)
+		insert(t, st, "texts", "C", []byte("skip"), insertTime, false)
+		insert(t, st, "binaries", "D", nil, insertTime, true)
+
+		var entities []string
+		n, err := st.r.Reencrypt(st.ctx, []byte("new"), []byte("salt"),
+			func(data []byte) ([]byte, error) {
+				return append([]byte("new_"), data...), nil
+			},
+			func(e *repository.SealedEntry) (bool, error) {
+				entities = append(entities, e.Entity)
+				if bytes.Equal(e.Data, []byte("skip")) {
+					return false, nil
+				}
+				e.Data = append([]byte("new_"), e.Data...)
+				return true, nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.ElementsMatch(t,
+			[]string{"passwords", "cards", "texts"}, entities)
+
+		var data []byte
+		var updatedAt time.Time
+		err = st.s.QueryRowContext(st.ctx,
+			`SELECT data, updated_at FROM cards WHERE id=1;`,
+		).Scan(&data, &updatedAt)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new_b"), data)
+		assert.Less(t, insertTime, updatedAt)
+
+		err = st.s.QueryRowContext(st.ctx,
+			`SELECT data, updated_at FROM texts WHERE id=1;`,
+		).Scan(&data, &updatedAt)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("skip"), data)
+		assert.Zero(t, insertTime.Compare(updatedAt))
+
+		params, err := st.r.ReadParams(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), params)
+
+		salt, err := st.r.ReadIndexSalt(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("salt"), salt)
+
+		verifier, err := st.r.ReadVerifier(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new_v"), verifier)
+
+		adRequired, err := st.r.ReadADRequired(st.ctx)
+		require.NoError(t, err)
+		assert.True(t, adRequired)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		st := newVaultSuite(t)
+		require.NoError(t, st.r.CreateParams(st.ctx, []byte("old")))
+
+		insertTime := time.Now()
+		insert(t, st, "passwords", "A", []byte("a"), insertTime, false)
+		insert(t, st, "texts", "B", []byte("b"), insertTime, false)
+
+		fnErr := errors.New("invalid key")
+		_, err := st.r.Reencrypt(st.ctx, []byte("new"), nil, keepData,
+			func(e *repository.SealedEntry) (bool, error) {
+				if bytes.Equal(e.Data, []byte("b")) {
+					return false, fnErr
+				}
+				e.Data = []byte("new")
+				return true, nil
+			})
+		require.ErrorIs(t, err, fnErr)
+
+		var data []byte
+		err = st.s.QueryRowContext(st.ctx,
+			`SELECT data FROM passwords WHERE id=1;`,
+		).Scan(&data)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), data)
+
+		params, err := st.r.ReadParams(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), params)
+
+		adRequired, err := st.r.ReadADRequired(st.ctx)
+		require.NoError(t, err)
+		assert.False(t, adRequired)
+	})
+
+	t.Run("SealNames", func(t *testing.T) {
+		st := newVaultSuite(t)
+		require.NoError(t, st.r.CreateParams(st.ctx, []byte("old")))
+
+		insertTime := time.Now().Add(-time.Hour)
+		insert(t, st, "passwords", "A", []byte("a"), insertTime, false)
+		insert(t, st, "cards", "B", []byte("b"), insertTime, false)
+		_, err := st.s.ExecContext(st.ctx,
+			`UPDATE cards SET name_index='sealed' WHERE id=1;`,
+		)
+		require.NoError(t, err)
+
+		n, err := st.r.Reencrypt(st.ctx, []byte("new"), nil, keepData,
+			func(e *repository.SealedEntry) (bool, error) {
+				if e.Index != "" {
+					return false, nil
+				}
+				e.Index = "index_" + string(e.Name)
+				e.Name = []byte("sealed_A")
+				return true, nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		var index string
+		var name, data []byte
+		err = st.s.QueryRowContext(st.ctx,
+			`SELECT name_index, name, data FROM passwords WHERE id=1;`,
+		).Scan(&index, &name, &data)
+		require.NoError(t, err)
+		assert.Equal(t, "index_A", index)
+		assert.Equal(t, []byte("sealed_A"), name)
+		assert.Equal(t, []byte("a"), data)
+
+		err = st.s.QueryRowContext(st.ctx,
+			`SELECT name_index, name FROM cards WHERE id=1;`,
+		).Scan(&index, &name)
+		require.NoError(t, err)
+		assert.Equal(t, "sealed", index)
+		assert.Equal(t, []byte("B"), name)
+	})
+
+	t.Run("NameIndexConflict", func(t *testing.T) {
+		st := newVaultSuite(t)
+		require.NoError(t, st.r.CreateParams(st.ctx, []byte("old")))
+
+		insertTime := time.Now()
+		insert(t, st, "passwords", "A", []byte("a"), insertTime, false)
+		insert(t, st, "passwords", "A", []byte("b"), insertTime, false)
+
+		_, err := st.r.Reencrypt(st.ctx, []byte("new"), nil, keepData,
+			func(e *repository.SealedEntry) (bool, error) {
+				e.Index = "index_" + string(e.Name)
+				e.Name = []byte("sealed")
+				return true, nil
+			})
+		require.ErrorIs(t, err, repository.ErrAlreadyExists)
+	})
+}
@@ -0,0 +1,312 @@
+package agentservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/sockrpc"
+)
+
+const (
+	methodDerive = "derive"
+	methodStatus = "status"
+	methodLock   = "lock"
+)
+
+var (
+	ErrAlreadyUnlocked = errors.New("vault already unlocked")
+	ErrNotUnlocked     = errors.New("vault is not unlocked")
+)
+
+type (
+	Verifier interface {
+		Verify(ctx context.Context, key string) error
+	}
+
+	deriveParams struct {
+		Params []byte `json:"params"`
+	}
+
+	deriveResult struct {
+		Key []byte `json:"key"`
+	}
+)
+
+// Agent holds the master key in memory and serves the derived keys
+// on the unix socket until it is locked or idle timeout expires.
+type Agent struct {
+	logger logger.Logger
+	socket string
+	mu     sync.Mutex
+	key    string
+	keys   map[string][]byte
+}
+
+func NewAgent(l logger.Logger, socket string) *Agent {
+	return &Agent{logger: l, socket: socket}
+}
+
+func (a *Agent) Run(
+	ctx context.Context, key string, idleTimeout time.Duration,
+) error {
+	const op = "Agent.Run"
+	log := a.logger.WithOp(op)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	a.key = key
+	a.keys = make(map[string][]byte)
+	defer a.wipe()
+
+	timer := time.AfterFunc(idleTimeout, func() {
+		log.Debug().Msg("idle timeout expired")
+		cancel()
+	})
+	defer timer.Stop()
+
+	srv := sockrpc.NewServer(a.socket)
+	srv.Handle(methodDerive, func(
+		_ context.Context, params json.RawMessage,
+	) (any, error) {
+		timer.Reset(idleTimeout)
+		return a.derive(params)
+	})
+	srv.Handle(methodStatus, func(context.Context, json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	srv.Handle(methodLock, func(context.Context, json.RawMessage) (any, error) {
+		log.Debug().Msg("lock requested")
+		cancel()
+		return nil, nil
+	})
+
+	log.Debug().Str("socket", a.socket).Msg("agent started")
+	if err := srv.Serve(ctx); err != nil {
+		log.Debug().Err(err).Msg("failed to serve")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Debug().Msg("agent stopped")
+	return nil
+}
+
+func (a *Agent) derive(params json.RawMessage) (any, error) {
+	var dp deriveParams
+	if err := json.Unmarshal(params, &dp); err != nil {
+		return nil, err
+	}
+
+	var p cipher.Params
+	if err := p.UnmarshalBinary(dp.Params); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if key, ok := a.keys[string(dp.Params)]; ok {
+		return deriveResult{key}, nil
+	}
+
+	key, err := cipher.DeriveKey(a.key, p)
+	if err != nil {
+		return nil, err
+	}
+	a.keys[string(dp.Params)] = key
+	return deriveResult{key}, nil
+}
+
+func (a *Agent) wipe() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, key := range a.keys {
+		clear(key)
+	}
+	clear(a.keys)
+	a.key = ""
+}
+
+// Client requests the derived keys from the agent, it is used by
+// the encrypter and decrypter when the master key is not provided.
+type Client struct {
+	logger logger.Logger
+	rpc    *sockrpc.Client
+}
+
+func NewClient(l logger.Logger, socket string) *Client {
+	return &Client{l, sockrpc.NewClient(socket)}
+}
+
+func (c *Client) DeriveKey(p cipher.Params) ([]byte, error) {
+	const op = "Client.DeriveKey"
+	log := c.logger.WithOp(op)
+
+	b, err := p.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var result deriveResult
+	err = c.rpc.Call(
+		context.Background(), methodDerive, deriveParams{b}, &result,
+	)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get key from agent")
+		if errors.Is(err, sockrpc.ErrUnavailable) {
+			return nil, fmt.Errorf("%s: %w", op, cipher.ErrNoKey)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return result.Key, nil
+}
+
+func (c *Client) Status(ctx context.Context) error {
+	return c.rpc.Call(ctx, methodStatus, nil, nil)
+}
+
+func (c *Client) Lock(ctx context.Context) error {
+	return c.rpc.Call(ctx, methodLock, nil, nil)
+}
+
+// Keyring requests the derived key from the agent and falls back to
+// the key prompt if the vault is not unlocked. The prompted key is kept
+// until the process exits.
+type Keyring struct {
+	client *Client
+	prompt func() (string, error)
+	key    string
+}
+
+func NewKeyring(c *Client, prompt func() (string, error)) *Keyring {
+	return &Keyring{client: c, prompt: prompt}
+}
+
+func (k *Keyring) DeriveKey(p cipher.Params) ([]byte, error) {
+	key, err := k.client.DeriveKey(p)
+	if !errors.Is(err, cipher.ErrNoKey) || k.prompt == nil {
+		return key, err
+	}
+
+	if k.key == "" {
+		key, promptErr := k.prompt()
+		if promptErr != nil {
+			return nil, promptErr
+		}
+		if key == "" {
+			return nil, err
+		}
+		k.key = key
+	}
+	return cipher.DeriveKey(k.key, p)
+}
+
+type Unlocker struct {
+	logger   logger.Logger
+	verifier Verifier
+	client   *Client
+}
+
+func NewUnlocker(l logger.Logger, v Verifier, c *Client) *Unlocker {
+	return &Unlocker{l, v, c}
+}
+
+// Unlock verifies the key and starts the agent process. The key is passed
+// to the agent through the pipe to keep it out of the process arguments.
+func (u *Unlocker) Unlock(
+	ctx context.Context, key string, idleTimeout time.Duration,
+) error {
+	const op = "Unlocker.Unlock"
+	log := u.logger.WithOp(op)
+
+	if err := u.client.Status(ctx); err == nil {
+		log.Debug().Msg("agent already started")
+		return ErrAlreadyUnlocked
+	}
+
+	if err := u.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, err := u.execAgent(key, idleTimeout)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to exec agent")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.waitReady(ctx); err != nil {
+		log.Debug().Err(err).Msg("agent is not ready")
+		p.Kill()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	p.Release()
+
+	log.Debug().Int("PID", p.Pid).Msg("agent started")
+	return nil
+}
+
+func (u *Unlocker) execAgent(
+	key string, idleTimeout time.Duration,
+) (*os.Process, error) {
+	cmd := exec.Command(
+		os.Args[0], "agent", "--idle-timeout", idleTimeout.String(),
+	)
+	cmd.Env = os.Environ()
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintln(stdin, key)
+	stdin.Close()
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+func (u *Unlocker) waitReady(ctx context.Context) error {
+	return sockrpc.WaitReady(ctx, func(ctx context.Context) bool {
+		return u.client.Status(ctx) == nil
+	})
+}
+
+type Locker struct {
+	logger logger.Logger
+	client *Client
+}
+
+func NewLocker(l logger.Logger, c *Client) *Locker {
+	return &Locker{l, c}
+}
+
+func (l *Locker) Lock(ctx context.Context) error {
+	const op = "Locker.Lock"
+	log := l.logger.WithOp(op)
+
+	if err := l.client.Lock(ctx); err != nil {
+		if errors.Is(err, sockrpc.ErrUnavailable) {
+			log.Debug().Msg("agent is not started")
+			return ErrNotUnlocked
+		}
+		log.Debug().Err(err).Msg("failed to lock")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
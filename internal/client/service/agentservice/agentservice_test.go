@@ -0,0 +1,109 @@
+package agentservice_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/service/agentservice"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startAgent(
+	t *testing.T, key string, idleTimeout time.Duration,
+) (*agentservice.Client, chan error) {
+	t.Helper()
+	log := logger.NewPretty("debug")
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- agentservice.NewAgent(log, socket).Run(ctx, key, idleTimeout)
+	}()
+
+	c := agentservice.NewClient(log, socket)
+	require.Eventually(t, func() bool {
+		return c.Status(ctx) == nil
+	}, time.Second, 10*time.Millisecond)
+	return c, done
+}
+
+func TestAgent(t *testing.T) {
+	const key = "testMasterKey"
+	params := cipher.NewParams(1, 8*1024)
+
+	t.Run("Ordinary", func(t *testing.T) {
+		c, _ := startAgent(t, key, time.Minute)
+
+		expected, err := cipher.DeriveKey(key, params)
+		require.NoError(t, err)
+
+		actual, err := c.DeriveKey(params)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("Lock", func(t *testing.T) {
+		c, done := startAgent(t, key, time.Minute)
+
+		require.NoError(t, c.Lock(context.Background()))
+		require.NoError(t, <-done)
+
+		_, err := c.DeriveKey(params)
+		require.ErrorIs(t, err, cipher.ErrNoKey)
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		c, done := startAgent(t, key, 100*time.Millisecond)
+
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("agent is not stopped")
+		}
+
+		_, err := c.DeriveKey(params)
+		require.ErrorIs(t, err, cipher.ErrNoKey)
+	})
+}
+
+func TestLocker(t *testing.T) {
+	log := logger.NewPretty("debug")
+	c := agentservice.NewClient(log, filepath.Join(t.TempDir(), "none.sock"))
+	err := agentservice.NewLocker(log, c).Lock(context.Background())
+	require.ErrorIs(t, err, agentservice.ErrNotUnlocked)
+}
+
+func TestKeyring(t *testing.T) {
+	log := logger.NewPretty("debug")
+	c := agentservice.NewClient(log, filepath.Join(t.TempDir(), "none.sock"))
+	params := cipher.NewParams(1, 8*1024)
+
+	t.Run("Prompt", func(t *testing.T) {
+		k := agentservice.NewKeyring(c, func() (string, error) {
+			return "testMasterKey", nil
+		})
+		expected, err := cipher.DeriveKey("testMasterKey", params)
+		require.NoError(t, err)
+		actual, err := k.DeriveKey(params)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("PromptFailed", func(t *testing.T) {
+		errPrompt := errors.New("no terminal")
+		k := agentservice.NewKeyring(c, func() (string, error) {
+			return "", errPrompt
+		})
+		_, err := k.DeriveKey(params)
+		require.ErrorIs(t, err, errPrompt)
+	})
+}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/repository"
 	"github.com/niksmo/gophkeeper/internal/client/service"
 	"github.com/niksmo/gophkeeper/internal/client/service/syncservice"
 	"github.com/niksmo/gophkeeper/pkg/logger"
@@ -19,7 +22,9 @@ var (
 	ErrCredentials           = errors.New("invalid credentials")
 	ErrTimeoutExpired        = errors.New("deadline exceeded")
 	ErrAuthServerUnavailable = errors.New("authorization service unavailable")
-	ErrSyncAlreadyRunning    = errors.New("synchronization is already running")
+	ErrSyncAlreadyRunning    = syncservice.ErrAlreadyRunning
+	ErrNoSession             = errors.New("sync session is not exists")
+	ErrSessionExpired        = errors.New("sync session is expired")
 )
 
 type (
@@ -31,6 +36,39 @@ type (
 	SyncExecuter interface {
 		ExecSynchronization(ctx context.Context, token string) error
 	}
+
+	SessionRepo interface {
+		Save(ctx context.Context, login string, token []byte) error
+		Read(context.Context) (dto.Session, error)
+	}
+
+	KeyVerifier interface {
+		Verify(ctx context.Context, key string) error
+	}
+
+	Encrypter interface {
+		SetKey(string)
+		Encrypt([]byte) ([]byte, error)
+	}
+
+	Decrypter interface {
+		SetKey(string)
+		Decrypt([]byte) ([]byte, error)
+	}
+
+	SyncResumer interface {
+		Resume(context.Context) error
+	}
+
+	VaultReindexer interface {
+		Reindex(ctx context.Context, key, token string) (int, error)
+	}
+
+	SessionStore interface {
+		Verify(ctx context.Context, key string) error
+		Save(ctx context.Context, key, login, token string) error
+		Load(ctx context.Context, key string) (string, error)
+	}
 )
 
 type gRPCAuthClient struct {
@@ -146,25 +184,45 @@ func (c *gRPCAuthClient) handleAuthorizationErr(err error) error {
 type UserRegistrar struct {
 	logger      logger.Logger
 	authClient  AuthClient
+	sessions    SessionStore
+	reindexer   VaultReindexer
 	syncStarter SyncExecuter
 }
 
 func NewUserRegistrar(
-	logger logger.Logger, authClient AuthClient, syncStarter SyncExecuter,
+	logger logger.Logger,
+	authClient AuthClient,
+	sessions SessionStore,
+	reindexer VaultReindexer,
+	syncStarter SyncExecuter,
 ) *UserRegistrar {
-	return &UserRegistrar{logger, authClient, syncStarter}
+	return &UserRegistrar{logger, authClient, sessions, reindexer, syncStarter}
 }
 
+// RegisterUser registers the user and computes the name indexes of the vault
+// with the index salt generated for the account before the synchronization.
 func (r *UserRegistrar) RegisterUser(
-	ctx context.Context, login, password string,
+	ctx context.Context, key, login, password string,
 ) error {
 	const op = "UserRegistrar.RegisterUser"
 
+	if err := r.sessions.Verify(ctx, key); err != nil {
+		return r.error(op, err)
+	}
+
 	token, err := r.registerUser(ctx, login, password)
 	if err != nil {
 		return r.error(op, err)
 	}
 
+	if _, err := r.reindexer.Reindex(ctx, key, token); err != nil {
+		return r.error(op, err)
+	}
+
+	if err := r.sessions.Save(ctx, key, login, token); err != nil {
+		return r.error(op, err)
+	}
+
 	if err := r.startSynchronization(ctx, token); err != nil {
 		return r.error(op, err)
 	}
@@ -198,25 +256,46 @@ func (r *UserRegistrar) error(op string, err error) error {
 type UserAuthorizer struct {
 	logger      logger.Logger
 	authClient  AuthClient
+	sessions    SessionStore
+	reindexer   VaultReindexer
 	syncStarter SyncExecuter
 }
 
 func NewUserAuthorizer(
-	logger logger.Logger, authClient AuthClient, syncStarter SyncExecuter,
+	logger logger.Logger,
+	authClient AuthClient,
+	sessions SessionStore,
+	reindexer VaultReindexer,
+	syncStarter SyncExecuter,
 ) *UserAuthorizer {
-	return &UserAuthorizer{logger, authClient, syncStarter}
+	return &UserAuthorizer{logger, authClient, sessions, reindexer, syncStarter}
 }
 
+// AuthorizeUser authorizes the user and computes the name indexes of the
+// vault with the index salt of the account before the synchronization,
+// if the vault has the other salt.
 func (a *UserAuthorizer) AuthorizeUser(
-	ctx context.Context, login, password string,
+	ctx context.Context, key, login, password string,
 ) error {
 	const op = "AuthService.AuthorizeUser"
 
+	if err := a.sessions.Verify(ctx, key); err != nil {
+		return a.error(op, err)
+	}
+
 	token, err := a.authorizeUser(ctx, login, password)
 	if err != nil {
 		return a.error(op, err)
 	}
 
+	if _, err := a.reindexer.Reindex(ctx, key, token); err != nil {
+		return a.error(op, err)
+	}
+
+	if err := a.sessions.Save(ctx, key, login, token); err != nil {
+		return a.error(op, err)
+	}
+
 	if err := a.startSynchronization(ctx, token); err != nil {
 		return a.error(op, err)
 	}
@@ -249,7 +328,7 @@ func startSynchronization(
 ) error {
 	err := ss.ExecSynchronization(ctx, token)
 	if err != nil {
-		if errors.Is(err, syncservice.ErrPIDConflict) {
+		if errors.Is(err, syncservice.ErrAlreadyRunning) {
 			log.Debug().Err(err).Msg("synchronization is already running")
 			return ErrSyncAlreadyRunning
 		}
@@ -258,3 +337,131 @@ func startSynchronization(
 	}
 	return nil
 }
+
+type SessionResumer struct {
+	logger      logger.Logger
+	sessions    SessionStore
+	daemon      SyncResumer
+	syncStarter SyncExecuter
+}
+
+func NewSessionResumer(
+	logger logger.Logger,
+	sessions SessionStore,
+	daemon SyncResumer,
+	syncStarter SyncExecuter,
+) *SessionResumer {
+	return &SessionResumer{logger, sessions, daemon, syncStarter}
+}
+
+// Resume resumes the paused sync daemon or starts the synchronization
+// with the saved session, e.g. after the reboot. ErrSessionExpired is
+// returned if the daemon is expired.
+func (r *SessionResumer) Resume(ctx context.Context, key string) error {
+	const op = "SessionResumer.Resume"
+	log := r.logger.WithOp(op)
+
+	err := r.daemon.Resume(ctx)
+	switch {
+	case err == nil:
+		log.Debug().Msg("sync daemon resumed")
+		return nil
+	case errors.Is(err, syncservice.ErrUnauthenticated):
+		return fmt.Errorf("%s: %w", op, ErrSessionExpired)
+	case !errors.Is(err, syncservice.ErrNoSync):
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := r.sessions.Load(ctx, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := startSynchronization(ctx, log, r.syncStarter, token); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SessionKeeper keeps the session token encrypted with the vault key,
+// so the token is not readable from the client storage.
+type SessionKeeper struct {
+	logger    logger.Logger
+	repo      SessionRepo
+	verifier  KeyVerifier
+	encrypter Encrypter
+	decrypter Decrypter
+}
+
+func NewSessionKeeper(
+	l logger.Logger, r SessionRepo, v KeyVerifier, e Encrypter, d Decrypter,
+) *SessionKeeper {
+	return &SessionKeeper{l, r, v, e, d}
+}
+
+func (k *SessionKeeper) Verify(ctx context.Context, key string) error {
+	return k.verifier.Verify(ctx, key)
+}
+
+func (k *SessionKeeper) Save(
+	ctx context.Context, key, login, token string,
+) error {
+	const op = "SessionKeeper.Save"
+	log := k.logger.WithOp(op)
+
+	k.encrypter.SetKey(key)
+	b, err := k.encrypter.Encrypt([]byte(token))
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to encrypt token")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := k.repo.Save(ctx, login, b); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Load returns the session token. The token rejected by the server
+// or expired by its claims is not returned.
+func (k *SessionKeeper) Load(ctx context.Context, key string) (string, error) {
+	const op = "SessionKeeper.Load"
+	log := k.logger.WithOp(op)
+
+	if err := k.verifier.Verify(ctx, key); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	s, err := k.repo.Read(ctx)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotExists) {
+			return "", ErrNoSession
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	k.decrypter.SetKey(key)
+	b, err := k.decrypter.Decrypt(s.Token)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to decrypt token")
+		return "", fmt.Errorf("%s: %w", op, service.ErrInvalidKey)
+	}
+
+	token := string(b)
+	if s.Expired || tokenExpired(token, time.Now()) {
+		log.Debug().Str("login", s.Login).Msg("session is expired")
+		return "", ErrSessionExpired
+	}
+	return token, nil
+}
+
+// tokenExpired reports whether the token expiration claim is in the past,
+// the token signature is verified by the server only.
+func tokenExpired(token string, now time.Time) bool {
+	var claims jwt.RegisteredClaims
+	_, _, err := jwt.NewParser().ParseUnverified(token, &claims)
+	if err != nil || claims.ExpiresAt == nil {
+		return false
+	}
+	return claims.ExpiresAt.Before(now)
+}
@@ -0,0 +1,327 @@
+package binservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+)
+
+type (
+	binRepo interface {
+		Entity() string
+		CreateStream(
+			ctx context.Context,
+			entryUUID string,
+			nameIndex string,
+			name []byte,
+			write func(w io.Writer) ([]byte, string, error),
+		) (int, error)
+		UpdateStream(
+			ctx context.Context,
+			entryNum int,
+			nameIndex string,
+			name []byte,
+			write func(w io.Writer) ([]byte, string, error),
+		) error
+		ReadUUID(ctx context.Context, id int) (string, error)
+		ReadStream(
+			ctx context.Context, id int,
+		) (repository.SealedEntry, error)
+	}
+
+	keyVerifier interface {
+		Verify(ctx context.Context, key string) error
+	}
+
+	encoder interface {
+		Encode(src any) ([]byte, error)
+	}
+
+	decoder interface {
+		Decode(dst any, src []byte) error
+	}
+
+	encrypter interface {
+		SetKey(string)
+		EncryptAD(data, ad []byte) ([]byte, error)
+		EncryptStream(w io.Writer, ad []byte) (io.WriteCloser, error)
+	}
+
+	decrypter interface {
+		SetKey(string)
+		DecryptAD(data, ad []byte) ([]byte, error)
+		DecryptStream(r io.Reader, ad []byte) (io.Reader, error)
+	}
+
+	indexer interface {
+		service.ContentHasher
+		SetKey(string)
+		Index(string) (string, error)
+	}
+)
+
+// BinService saves the binary content as the encrypted stream,
+// so the content is never kept in memory as a whole.
+type BinService struct {
+	l         logger.Logger
+	r         binRepo
+	verifier  keyVerifier
+	encoder   encoder
+	decoder   decoder
+	encrypter encrypter
+	decrypter decrypter
+	indexer   indexer
+}
+
+func New(
+	logger logger.Logger,
+	repository binRepo,
+	verifier keyVerifier,
+	encoder encoder,
+	decoder decoder,
+	encrypter encrypter,
+	decrypter decrypter,
+	indexer indexer,
+) *BinService {
+	return &BinService{
+		l:         logger,
+		r:         repository,
+		verifier:  verifier,
+		encoder:   encoder,
+		decoder:   decoder,
+		encrypter: encrypter,
+		decrypter: decrypter,
+		indexer:   indexer,
+	}
+}
+
+// Add saves the binary with the content read from the r.
+func (s *BinService) Add(
+	ctx context.Context, key, name string, obj dto.BIN, r io.Reader,
+) (int, error) {
+	const op = "BinService.Add"
+	log := s.l.With().Str("op", op).Logger()
+
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	nameIndex, sealedName, err := s.sealName(key, name)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to seal name")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entryUUID := uuid.New()
+	entryNum, err := s.r.CreateStream(
+		ctx, entryUUID, nameIndex, sealedName,
+		s.sealContent(entryUUID, name, obj, r),
+	)
+	if err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			log.Debug().Str("name", name).Msg("object already exists")
+			return 0, service.ErrAlreadyExists
+		}
+		log.Debug().Err(err).Msg("failed to add object to repository")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entryNum, nil
+}
+
+// Edit replaces the binary and its content with the content read from the r.
+func (s *BinService) Edit(
+	ctx context.Context,
+	key string, entryNum int, name string, obj dto.BIN, r io.Reader,
+) error {
+	const op = "BinService.Edit"
+	log := s.l.With().Str("op", op).Logger()
+
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	entryUUID, err := s.r.ReadUUID(ctx, entryNum)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotExists) {
+			log.Debug().Int("entryNum", entryNum).Msg("object not exists")
+			return service.ErrNotExists
+		}
+		log.Debug().Err(err).Msg("failed to read object from repository")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	nameIndex, sealedName, err := s.sealName(key, name)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to seal name")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.r.UpdateStream(
+		ctx, entryNum, nameIndex, sealedName, s.sealContent(entryUUID, name, obj, r),
+	)
+	if err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			log.Debug().Str("name", name).Msg("object already exists")
+			return service.ErrAlreadyExists
+		}
+		if errors.Is(err, repository.ErrNotExists) {
+			log.Debug().Int("entryNum", entryNum).Msg("object not exists")
+			return service.ErrNotExists
+		}
+		log.Debug().Err(err).Msg("failed to save updated object to repository")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Read returns the description of the binary and writes its content
+// to the w, the content is skipped if the w is nil.
+func (s *BinService) Read(
+	ctx context.Context, key string, entryNum int, w io.Writer,
+) (dto.BIN, error) {
+	const op = "BinService.Read"
+	log := s.l.With().Str("op", op).Logger()
+
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return dto.BIN{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	e, err := s.r.ReadStream(ctx, entryNum)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotExists) {
+			log.Debug().Msg("object not exists")
+			return dto.BIN{}, service.ErrNotExists
+		}
+		log.Debug().Err(err).Msg("failed to read object from repository")
+		return dto.BIN{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entity := s.r.Entity()
+	s.decrypter.SetKey(key)
+	b, bound, err := service.OpenData(
+		s.decrypter, e.Data, entity, e.UUID, e.Index,
+	)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to decrypt")
+		return dto.BIN{}, decryptErr(err)
+	}
+
+	var obj dto.BIN
+	if err := s.decoder.Decode(&obj, b); err != nil {
+		log.Error().Err(err).Msg("failed to decode bytes to object")
+		return dto.BIN{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if e.Stream == nil {
+		obj.Size = int64(len(obj.Data))
+		content := obj.Data
+		obj.Data = nil
+		if w != nil {
+			if _, err := w.Write(content); err != nil {
+				return dto.BIN{}, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		return obj, nil
+	}
+
+	if w == nil {
+		return obj, nil
+	}
+
+	r, err := s.decrypter.DecryptStream(
+		e.Stream, service.StreamAD(entity, bound),
+	)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to decrypt stream")
+		return dto.BIN{}, decryptErr(err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		log.Debug().Err(err).Msg("failed to read stream")
+		if errors.Is(err, cipher.ErrTruncated) ||
+			errors.Is(err, cipher.ErrChunkNotValid) {
+			return dto.BIN{}, service.ErrInvalidKey
+		}
+		return dto.BIN{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return obj, nil
+}
+
+// sealName returns the blind index and the encrypted name.
+func (s *BinService) sealName(key, name string) (string, []byte, error) {
+	s.encrypter.SetKey(key)
+	s.indexer.SetKey(key)
+	index, err := s.indexer.Index(name)
+	if err != nil {
+		return "", nil, err
+	}
+	sealedName, err := s.encrypter.EncryptAD(
+		[]byte(name), service.NameAD(s.r.Entity(), index),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	return index, sealedName, nil
+}
+
+// sealContent returns the write of the encrypted content stream,
+// the write returns the encrypted description with the content size.
+// Both are bound to the record UUID. The content hash is summed
+// from the plaintext while it is written.
+func (s *BinService) sealContent(
+	entryUUID, name string, obj dto.BIN, r io.Reader,
+) func(w io.Writer) ([]byte, string, error) {
+	entity := s.r.Entity()
+	return func(w io.Writer) ([]byte, string, error) {
+		h, err := service.NewContentHash(s.indexer, name)
+		if err != nil {
+			return nil, "", err
+		}
+		sw, err := s.encrypter.EncryptStream(
+			w, service.StreamAD(entity, entryUUID),
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		n, err := io.Copy(sw, io.TeeReader(r, h))
+		if err != nil {
+			return nil, "", err
+		}
+		if err := sw.Close(); err != nil {
+			return nil, "", err
+		}
+
+		obj.Size, obj.Data = n, nil
+		b, err := s.encoder.Encode(obj)
+		if err != nil {
+			return nil, "", err
+		}
+		data, err := s.encrypter.EncryptAD(
+			b, service.DataAD(entity, entryUUID),
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, service.SumContent(h, b), nil
+	}
+}
+
+func decryptErr(err error) error {
+	if errors.Is(err, cipher.ErrNotBound) {
+		return service.ErrNotBound
+	}
+	return service.ErrInvalidKey
+}
@@ -0,0 +1,210 @@
+package binservice_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/internal/client/service/binservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/vaultservice"
+	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/encode"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const key = "key"
+
+type suite struct {
+	ctx     context.Context
+	s       *storage.Storage
+	repo    *repository.BinRepository
+	service *binservice.BinService
+}
+
+func newSuite(t *testing.T) *suite {
+	log := logger.NewPretty("debug")
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		os.Remove(dsn)
+	})
+
+	s := storage.New(log, dsn)
+	s.MustRun(ctx)
+
+	vault := repository.NewVault(log, s)
+	loader := vaultservice.NewParamsLoader(log, vault, 1, 8*1024)
+	params, err := loader.Load(ctx)
+	require.NoError(t, err)
+
+	e := cipher.NewEncrypter()
+	e.SetParams(params)
+	d := cipher.NewDecrypter()
+	v := vaultservice.NewKeyVerifier(log, vault, e, d)
+
+	repo := repository.NewBinStream(log, s)
+	bs := binservice.New(
+		log, repo, v, encode.NewEncoder(), encode.NewDecoder(),
+		e, d, cipher.NewIndexer(),
+	)
+	return &suite{ctx, s, repo, bs}
+}
+
+func content(size int) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(rand.IntN(256))
+	}
+	return b
+}
+
+func TestAddRead(t *testing.T) {
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newSuite(t)
+		data := content(3*cipher.StreamChunkSize + 7)
+		obj := dto.BIN{Name: "A", Ext: ".bin"}
+
+		id, err := st.service.Add(
+			st.ctx, key, "A", obj, bytes.NewReader(data),
+		)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		actual, err := st.service.Read(st.ctx, key, id, &buf)
+		require.NoError(t, err)
+		assert.Equal(t, "A", actual.Name)
+		assert.Equal(t, ".bin", actual.Ext)
+		assert.Equal(t, int64(len(data)), actual.Size)
+		assert.Nil(t, actual.Data)
+		assert.Equal(t, data, buf.Bytes())
+	})
+
+	t.Run("WithoutWriter", func(t *testing.T) {
+		st := newSuite(t)
+		id, err := st.service.Add(
+			st.ctx, key, "A", dto.BIN{Name: "A"}, bytes.NewReader(content(10)),
+		)
+		require.NoError(t, err)
+
+		actual, err := st.service.Read(st.ctx, key, id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), actual.Size)
+	})
+
+	t.Run("AlreadyExists", func(t *testing.T) {
+		st := newSuite(t)
+		_, err := st.service.Add(
+			st.ctx, key, "A", dto.BIN{Name: "A"}, bytes.NewReader(nil),
+		)
+		require.NoError(t, err)
+
+		_, err = st.service.Add(
+			st.ctx, key, "A", dto.BIN{Name: "A"}, bytes.NewReader(nil),
+		)
+		require.ErrorIs(t, err, service.ErrAlreadyExists)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		st := newSuite(t)
+		id, err := st.service.Add(
+			st.ctx, key, "A", dto.BIN{Name: "A"}, bytes.NewReader(content(10)),
+		)
+		require.NoError(t, err)
+
+		_, err = st.service.Read(st.ctx, "wrongKey", id, nil)
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		st := newSuite(t)
+		id, err := st.service.Add(
+			st.ctx, key, "A", dto.BIN{Name: "A"},
+			bytes.NewReader(content(2*cipher.StreamChunkSize)),
+		)
+		require.NoError(t, err)
+
+		_, err = st.s.ExecContext(st.ctx,
+			`UPDATE binary_chunks SET data=zeroblob(length(data)) WHERE seq=1;`,
+		)
+		require.NoError(t, err)
+
+		_, err = st.service.Read(st.ctx, key, id, &bytes.Buffer{})
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+	})
+
+	t.Run("Swapped", func(t *testing.T) {
+		st := newSuite(t)
+		idA, err := st.service.Add(
+			st.ctx, key, "A", dto.BIN{Name: "A"}, bytes.NewReader(content(10)),
+		)
+		require.NoError(t, err)
+		idB, err := st.service.Add(
+			st.ctx, key, "B", dto.BIN{Name: "B"}, bytes.NewReader(content(10)),
+		)
+		require.NoError(t, err)
+
+		_, err = st.s.ExecContext(st.ctx, `
+			UPDATE binaries SET data=(SELECT data FROM binaries WHERE id=?)
+			WHERE id=?;`, idB, idA,
+		)
+		require.NoError(t, err)
+
+		_, err = st.service.Read(st.ctx, key, idA, nil)
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+	})
+
+	t.Run("NotExists", func(t *testing.T) {
+		st := newSuite(t)
+		_, err := st.service.Read(st.ctx, key, 1, nil)
+		require.ErrorIs(t, err, service.ErrNotExists)
+	})
+}
+
+func TestEdit(t *testing.T) {
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newSuite(t)
+		id, err := st.service.Add(
+			st.ctx, key, "A", dto.BIN{Name: "A"}, bytes.NewReader(content(10)),
+		)
+		require.NoError(t, err)
+
+		data := content(cipher.StreamChunkSize + 1)
+		err = st.service.Edit(
+			st.ctx, key, id, "B", dto.BIN{Name: "B", Ext: ".txt"},
+			bytes.NewReader(data),
+		)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		actual, err := st.service.Read(st.ctx, key, id, &buf)
+		require.NoError(t, err)
+		assert.Equal(t, "B", actual.Name)
+		assert.Equal(t, ".txt", actual.Ext)
+		assert.Equal(t, data, buf.Bytes())
+
+		var nStreams int
+		err = st.s.QueryRowContext(st.ctx,
+			`SELECT COUNT(DISTINCT stream_id) FROM binary_chunks;`,
+		).Scan(&nStreams)
+		require.NoError(t, err)
+		assert.Equal(t, 1, nStreams)
+	})
+
+	t.Run("NotExists", func(t *testing.T) {
+		st := newSuite(t)
+		err := st.service.Edit(
+			st.ctx, key, 1, "A", dto.BIN{Name: "A"}, bytes.NewReader(nil),
+		)
+		require.ErrorIs(t, err, service.ErrNotExists)
+	})
+}
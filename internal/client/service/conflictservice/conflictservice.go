@@ -0,0 +1,331 @@
+package conflictservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+type (
+	ConflictRepo interface {
+		List(context.Context) ([]repository.Conflict, error)
+		Read(ctx context.Context, id int) (repository.Conflict, error)
+		IndexExists(ctx context.Context, entity, index string) (bool, error)
+		Discard(ctx context.Context, id int) error
+		Replace(ctx context.Context, id int) error
+		Restore(
+			ctx context.Context,
+			id int,
+			entryFn func(e *repository.SealedEntry, entryUUID string) error,
+		) (int, error)
+	}
+
+	Encrypter interface {
+		SetKey(string)
+		EncryptAD(data, ad []byte) ([]byte, error)
+		EncryptStream(w io.Writer, ad []byte) (io.WriteCloser, error)
+	}
+
+	Decrypter interface {
+		SetKey(string)
+		Decrypt([]byte) ([]byte, error)
+		DecryptAD(data, ad []byte) ([]byte, error)
+		DecryptStream(r io.Reader, ad []byte) (io.Reader, error)
+	}
+
+	Indexer interface {
+		SetKey(string)
+		Index(string) (string, error)
+	}
+
+	Verifier interface {
+		Verify(ctx context.Context, key string) error
+	}
+)
+
+// maxRenames limits the search of the free name for the kept copy.
+const maxRenames = 100
+
+type Lister struct {
+	logger    logger.Logger
+	repo      ConflictRepo
+	verifier  Verifier
+	decrypter Decrypter
+}
+
+func NewLister(
+	l logger.Logger, r ConflictRepo, v Verifier, d Decrypter,
+) *Lister {
+	return &Lister{l, r, v, d}
+}
+
+// List returns the conflict copies with the decrypted names.
+func (s *Lister) List(ctx context.Context, key string) ([]dto.Conflict, error) {
+	const op = "Lister.List"
+	log := s.logger.WithOp(op)
+
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	conflicts, err := s.repo.List(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list conflicts")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.decrypter.SetKey(key)
+	data := make([]dto.Conflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		var name []byte
+		if !c.Deleted {
+			name, err = openName(&c.SealedEntry, s.decrypter)
+			if err != nil {
+				log.Debug().Err(err).Int("id", c.ID).Msg(
+					"failed to decrypt name")
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		data = append(data, dto.Conflict{
+			ID:        c.ID,
+			Entity:    c.Entity,
+			EntryNum:  c.EntryID,
+			Name:      string(name),
+			SameName:  c.Reason == repository.ConflictName,
+			Deleted:   c.Deleted,
+			UpdatedAt: c.UpdatedAt,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+	return data, nil
+}
+
+type Resolver struct {
+	logger    logger.Logger
+	repo      ConflictRepo
+	verifier  Verifier
+	encrypter Encrypter
+	decrypter Decrypter
+	indexer   Indexer
+}
+
+func NewResolver(
+	l logger.Logger,
+	r ConflictRepo,
+	v Verifier,
+	e Encrypter,
+	d Decrypter,
+	i Indexer,
+) *Resolver {
+	return &Resolver{l, r, v, e, d, i}
+}
+
+// KeepEntry discards the conflict copy. Returns the entry number.
+func (s *Resolver) KeepEntry(ctx context.Context, id int) (int, error) {
+	const op = "Resolver.KeepEntry"
+
+	c, err := s.repo.Read(ctx, id)
+	if err != nil {
+		return 0, s.error(op, err)
+	}
+	if err := s.repo.Discard(ctx, id); err != nil {
+		return 0, s.error(op, err)
+	}
+	return c.EntryID, nil
+}
+
+// KeepCopy puts the conflict copy in place of the entry, the entry version
+// is lost. Returns the entry number.
+func (s *Resolver) KeepCopy(ctx context.Context, id int) (int, error) {
+	const op = "Resolver.KeepCopy"
+
+	c, err := s.repo.Read(ctx, id)
+	if err != nil {
+		return 0, s.error(op, err)
+	}
+	if err := s.repo.Replace(ctx, id); err != nil {
+		return 0, s.error(op, err)
+	}
+	return c.EntryID, nil
+}
+
+// KeepBoth saves the conflict copy as the separate entry renamed
+// to the free "<name> (conflict N)". Returns the new entry number
+// and the new name.
+func (s *Resolver) KeepBoth(
+	ctx context.Context, key string, id int,
+) (int, string, error) {
+	const op = "Resolver.KeepBoth"
+	log := s.logger.WithOp(op)
+
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	c, err := s.repo.Read(ctx, id)
+	if err != nil {
+		return 0, "", s.error(op, err)
+	}
+	if c.Deleted {
+		log.Debug().Int("id", id).Msg("conflict copy is deleted")
+		return 0, "", fmt.Errorf("%s: %w", op, service.ErrDeletedCopy)
+	}
+
+	s.encrypter.SetKey(key)
+	s.decrypter.SetKey(key)
+	s.indexer.SetKey(key)
+
+	name, err := openName(&c.SealedEntry, s.decrypter)
+	if err != nil {
+		return 0, "", s.error(op, err)
+	}
+	newName, err := s.freeName(ctx, c.Entity, string(name))
+	if err != nil {
+		return 0, "", s.error(op, err)
+	}
+
+	entryNum, err := s.repo.Restore(ctx, id,
+		func(e *repository.SealedEntry, entryUUID string) error {
+			return s.rename(e, entryUUID, newName)
+		})
+	if err != nil {
+		return 0, "", s.error(op, err)
+	}
+	return entryNum, newName, nil
+}
+
+func (s *Resolver) freeName(
+	ctx context.Context, entity, name string,
+) (string, error) {
+	for n := 1; n <= maxRenames; n++ {
+		newName := fmt.Sprintf("%s (conflict)", name)
+		if n > 1 {
+			newName = fmt.Sprintf("%s (conflict %d)", name, n)
+		}
+		index, err := s.indexer.Index(newName)
+		if err != nil {
+			return "", err
+		}
+		exists, err := s.repo.IndexExists(ctx, entity, index)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return newName, nil
+		}
+	}
+	return "", service.ErrAlreadyExists
+}
+
+// rename reencrypts the name of the entry bound to the index of the new
+// name and the data and the stream bound to the UUID of the entry.
+func (s *Resolver) rename(
+	e *repository.SealedEntry, entryUUID, name string,
+) error {
+	data, bound, err := openData(s.decrypter, e)
+	if err != nil {
+		return err
+	}
+
+	var stream io.Reader
+	if e.Stream != nil {
+		stream, err = s.decrypter.DecryptStream(
+			e.Stream, service.StreamAD(e.Entity, bound),
+		)
+		if err != nil {
+			return service.ErrInvalidKey
+		}
+	}
+
+	index, err := s.indexer.Index(name)
+	if err != nil {
+		return err
+	}
+	sealedName, err := s.encrypter.EncryptAD(
+		[]byte(name), service.NameAD(e.Entity, index),
+	)
+	if err != nil {
+		return err
+	}
+	sealedData, err := s.encrypter.EncryptAD(
+		data, service.DataAD(e.Entity, entryUUID),
+	)
+	if err != nil {
+		return err
+	}
+	e.UUID, e.Index, e.Name, e.Data = entryUUID, index, sealedName, sealedData
+
+	if stream != nil {
+		ad := service.StreamAD(e.Entity, entryUUID)
+		e.SealStream = func(w io.Writer) error {
+			sw, err := s.encrypter.EncryptStream(w, ad)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(sw, stream); err != nil {
+				if errors.Is(err, cipher.ErrTruncated) ||
+					errors.Is(err, cipher.ErrChunkNotValid) {
+					return service.ErrInvalidKey
+				}
+				return err
+			}
+			return sw.Close()
+		}
+	}
+	return nil
+}
+
+func (s *Resolver) error(op string, err error) error {
+	log := s.logger.WithOp(op)
+	switch {
+	case errors.Is(err, repository.ErrNotExists):
+		log.Debug().Err(err).Msg("conflict is not exists")
+		return fmt.Errorf("%s: %w", op, service.ErrNotExists)
+	case errors.Is(err, repository.ErrAlreadyExists),
+		errors.Is(err, service.ErrAlreadyExists):
+		log.Debug().Err(err).Msg("entry name already exists")
+		return fmt.Errorf("%s: %w", op, service.ErrAlreadyExists)
+	case errors.Is(err, service.ErrInvalidKey):
+		log.Debug().Err(err).Msg("failed to decrypt conflict copy")
+		return fmt.Errorf("%s: %w", op, service.ErrInvalidKey)
+	}
+	log.Error().Err(err).Msg("failed to resolve conflict")
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func openName(e *repository.SealedEntry, d Decrypter) ([]byte, error) {
+	name, err := d.DecryptAD(e.Name, service.NameAD(e.Entity, e.Index))
+	if err != nil {
+		return nil, service.ErrInvalidKey
+	}
+	return name, nil
+}
+
+// openData decrypts the entry data, see service.OpenData. The data
+// encrypted without the associated data is decrypted as is. Returns
+// the data and the UUID or the index the stream of the entry is bound to.
+func openData(
+	d Decrypter, e *repository.SealedEntry,
+) ([]byte, string, error) {
+	if !cipher.IsBound(e.Data) {
+		b, err := d.Decrypt(e.Data)
+		if err != nil {
+			return nil, "", service.ErrInvalidKey
+		}
+		return b, e.Index, nil
+	}
+	b, bound, err := service.OpenData(d, e.Data, e.Entity, e.UUID, e.Index)
+	if err != nil {
+		return nil, "", service.ErrInvalidKey
+	}
+	return b, bound, nil
+}
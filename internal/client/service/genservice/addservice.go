@@ -8,32 +8,43 @@ import (
 	"github.com/niksmo/gophkeeper/internal/client/repository"
 	"github.com/niksmo/gophkeeper/internal/client/service"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
 )
 
 type (
 	addRepo interface {
-		Create(ctx context.Context, name string, data []byte) (int, error)
+		entityRepo
+		Create(
+			ctx context.Context, entryUUID, nameIndex string, name, data []byte,
+			hash string,
+		) (int, error)
 	}
 )
 
 type AddService[T any] struct {
 	l         logger.Logger
 	r         addRepo
+	verifier  keyVerifier
 	encoder   encoder
 	encrypter encrypter
+	indexer   indexer
 }
 
 func NewAdd[T any](
 	logger logger.Logger,
 	repository addRepo,
+	verifier keyVerifier,
 	encoder encoder,
 	encrypter encrypter,
+	indexer indexer,
 ) *AddService[T] {
 	return &AddService[T]{
 		l:         logger,
 		r:         repository,
+		verifier:  verifier,
 		encoder:   encoder,
 		encrypter: encrypter,
+		indexer:   indexer,
 	}
 }
 
@@ -43,6 +54,11 @@ func (s *AddService[T]) Add(
 	const op = "AddService.Add"
 	log := s.l.With().Str("op", op).Logger()
 
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	b, err := s.encoder.Encode(dto)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to encode object to bytes")
@@ -50,13 +66,25 @@ func (s *AddService[T]) Add(
 	}
 
 	s.encrypter.SetKey(key)
-	data, err := s.encrypter.Encrypt(b)
+	s.indexer.SetKey(key)
+	entryUUID := uuid.New()
+	nameIndex, sealedName, data, err := sealEntry(
+		s.r.Entity(), entryUUID, name, b, s.indexer, s.encrypter,
+	)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to encrypt")
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	entryNum, err := s.r.Create(ctx, name, data)
+	hash, err := service.HashContent(s.indexer, name, b)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to hash content")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entryNum, err := s.r.Create(
+		ctx, entryUUID, nameIndex, sealedName, data, hash,
+	)
 	if err != nil {
 		if errors.Is(err, repository.ErrAlreadyExists) {
 			log.Debug().Str("name", name).Msg("object already exists")
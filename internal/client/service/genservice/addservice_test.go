@@ -5,8 +5,10 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/niksmo/gophkeeper/internal/client/service"
 	"github.com/niksmo/gophkeeper/internal/client/service/genservice"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -16,10 +18,15 @@ type MockCreater struct {
 	mock.Mock
 }
 
+func (r *MockCreater) Entity() string {
+	return entity
+}
+
 func (r *MockCreater) Create(
-	ctx context.Context, name string, data []byte,
+	ctx context.Context,
+	entryUUID, nameIndex string, name, data []byte, hash string,
 ) (int, error) {
-	args := r.Called(ctx, name, data)
+	args := r.Called(ctx, entryUUID, nameIndex, name, data, hash)
 	return args.Int(0), args.Error(1)
 }
 
@@ -28,8 +35,10 @@ type AddSuite struct {
 	ctx       context.Context
 	log       logger.Logger
 	repo      *MockCreater
+	verifier  *verifier
 	encoder   *encoder
 	encrypter *encrypter
+	indexer   *indexer
 	service   *genservice.AddService[any]
 }
 
@@ -38,13 +47,19 @@ func newAddSuiteAdd(t *testing.T) *AddSuite {
 	log := logger.NewPretty("debug")
 	encoder := &encoder{}
 	encrypter := &encrypter{}
+	indexer := &indexer{}
+	verifier := &verifier{}
 	repo := &MockCreater{}
-	service := genservice.NewAdd[any](log, repo, encoder, encrypter)
+	service := genservice.NewAdd[any](
+		log, repo, verifier, encoder, encrypter, indexer,
+	)
 	st := &AddSuite{
 		t, ctx, log,
 		repo,
+		verifier,
 		encoder,
 		encrypter,
+		indexer,
 		service,
 	}
 	return st
@@ -60,11 +75,13 @@ func (st *AddSuite) PrettyPanic() {
 
 func TestAddService(t *testing.T) {
 	const (
-		Add     = "Add"
-		Create  = "Create"
-		Encode  = "Encode"
-		SetKey  = "SetKey"
-		Encrypt = "Encrypt"
+		Add       = "Add"
+		Verify    = "Verify"
+		Create    = "Create"
+		Encode    = "Encode"
+		SetKey    = "SetKey"
+		EncryptAD = "EncryptAD"
+		Index     = "Index"
 	)
 
 	key := "testMasterKey"
@@ -73,40 +90,70 @@ func TestAddService(t *testing.T) {
 	}
 	encodedData := []byte("encodedData")
 	encryptedData := []byte("encryptedData")
+	nameIndex := "nameIndex"
+	nameAD := service.NameAD(entity, nameIndex)
+	contentHash, _ := service.HashContent(&indexer{}, obj.Name, encodedData)
+	sealedName := []byte("sealedName")
 
 	t.Run("Ordinary", func(t *testing.T) {
 		st := newAddSuiteAdd(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		var encodeErr error
 		var repoAddErr error
 		expected := 1
 
 		st.encoder.On(Encode, obj).Return(encodedData, encodeErr)
 		st.encrypter.On(SetKey, key)
-		st.encrypter.On(Encrypt, encodedData).Return(encryptedData, nil)
+		st.encrypter.On(EncryptAD, encodedData, mock.Anything).Return(
+			encryptedData, nil,
+		)
+		st.encrypter.On(EncryptAD, []byte(obj.Name), nameAD).Return(
+			sealedName, nil,
+		)
+		st.indexer.On(SetKey, key)
+		st.indexer.On(Index, obj.Name).Return(nameIndex, nil)
 		st.repo.On(
-			Create, st.ctx, obj.Name, encryptedData,
+			Create, st.ctx, mock.Anything, nameIndex, sealedName, encryptedData,
+			contentHash,
 		).Return(expected, repoAddErr)
 
 		actual, err := st.service.Add(st.ctx, key, obj.Name, obj)
 		require.NoError(t, err)
 		assert.Equal(t, expected, actual)
+
+		entryUUID := st.repo.Calls[0].Arguments.String(1)
+		assert.True(t, uuid.Valid(entryUUID))
+		st.encrypter.AssertCalled(
+			t, EncryptAD, encodedData, service.DataAD(entity, entryUUID),
+		)
 	})
 
 	t.Run("EncodeFailed", func(t *testing.T) {
 		st := newAddSuiteAdd(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		var repoAddErr error
 		var encodeErr = errors.New("encode failed")
 		expected := 0
 
 		st.encoder.On(Encode, obj).Return(encodedData, encodeErr)
 		st.encrypter.On(SetKey, key)
-		st.encrypter.On(Encrypt, encodedData).Return(encryptedData, nil)
+		st.encrypter.On(EncryptAD, encodedData, mock.Anything).Return(
+			encryptedData, nil,
+		)
+		st.encrypter.On(EncryptAD, []byte(obj.Name), nameAD).Return(
+			sealedName, nil,
+		)
+		st.indexer.On(SetKey, key)
+		st.indexer.On(Index, obj.Name).Return(nameIndex, nil)
 		st.repo.On(
-			Create, st.ctx, obj.Name, encryptedData,
+			Create, st.ctx, mock.Anything, nameIndex, sealedName, encryptedData,
+			contentHash,
 		).Return(expected, repoAddErr)
 
 		actual, err := st.service.Add(st.ctx, key, obj.Name, obj)
@@ -118,19 +165,47 @@ func TestAddService(t *testing.T) {
 		st := newAddSuiteAdd(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		var encodeErr error
 		var repoAddErr = errors.New("repo add failed")
 		expected := 0
 
 		st.encoder.On(Encode, obj).Return(encodedData, encodeErr)
 		st.encrypter.On(SetKey, key)
-		st.encrypter.On(Encrypt, encodedData).Return(encryptedData, nil)
+		st.encrypter.On(EncryptAD, encodedData, mock.Anything).Return(
+			encryptedData, nil,
+		)
+		st.encrypter.On(EncryptAD, []byte(obj.Name), nameAD).Return(
+			sealedName, nil,
+		)
+		st.indexer.On(SetKey, key)
+		st.indexer.On(Index, obj.Name).Return(nameIndex, nil)
 		st.repo.On(
-			Create, st.ctx, obj.Name, encryptedData,
+			Create, st.ctx, mock.Anything, nameIndex, sealedName, encryptedData,
+			contentHash,
 		).Return(expected, repoAddErr)
 
 		actual, err := st.service.Add(st.ctx, key, obj.Name, obj)
 		require.ErrorIs(t, err, repoAddErr)
 		assert.Equal(t, expected, actual)
 	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		st := newAddSuiteAdd(t)
+		defer st.PrettyPanic()
+
+		st.verifier.On(Verify, st.ctx, key).Return(service.ErrInvalidKey)
+
+		actual, err := st.service.Add(st.ctx, key, obj.Name, obj)
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+		assert.Zero(t, actual)
+		st.encrypter.AssertNotCalled(
+			t, EncryptAD, mock.Anything, mock.Anything,
+		)
+		st.repo.AssertNotCalled(
+			t, Create, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything,
+		)
+	})
 }
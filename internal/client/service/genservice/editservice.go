@@ -12,28 +12,39 @@ import (
 
 type (
 	updateRepo interface {
-		Update(ctx context.Context, id int, name string, data []byte) error
+		entityRepo
+		ReadUUID(ctx context.Context, id int) (string, error)
+		Update(
+			ctx context.Context, id int, nameIndex string, name, data []byte,
+			hash string,
+		) error
 	}
 )
 
 type EditService[T any] struct {
 	l         logger.Logger
 	r         updateRepo
+	verifier  keyVerifier
 	encoder   encoder
 	encrypter encrypter
+	indexer   indexer
 }
 
 func NewEdit[T any](
 	logger logger.Logger,
 	repository updateRepo,
+	verifier keyVerifier,
 	encoder encoder,
 	encrypter encrypter,
+	indexer indexer,
 ) *EditService[T] {
 	return &EditService[T]{
 		l:         logger,
 		r:         repository,
+		verifier:  verifier,
 		encoder:   encoder,
 		encrypter: encrypter,
+		indexer:   indexer,
 	}
 }
 
@@ -43,20 +54,45 @@ func (s *EditService[T]) Edit(
 	const op = "EditService.Update"
 	log := s.l.With().Str("op", op).Logger()
 
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	b, err := s.encoder.Encode(dto)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to encode object to bytes")
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	entryUUID, err := s.r.ReadUUID(ctx, entryNum)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotExists) {
+			log.Debug().Int("entryNum", entryNum).Msg("object not exists")
+			return service.ErrNotExists
+		}
+		log.Debug().Err(err).Msg("failed to read object from repository")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	s.encrypter.SetKey(key)
-	data, err := s.encrypter.Encrypt(b)
+	s.indexer.SetKey(key)
+	nameIndex, sealedName, data, err := sealEntry(
+		s.r.Entity(), entryUUID, name, b, s.indexer, s.encrypter,
+	)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to encrypt")
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err = s.r.Update(ctx, entryNum, name, data); err != nil {
+	hash, err := service.HashContent(s.indexer, name, b)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to hash content")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.r.Update(ctx, entryNum, nameIndex, sealedName, data, hash)
+	if err != nil {
 		if errors.Is(err, repository.ErrAlreadyExists) {
 			log.Debug().Str("name", name).Msg("object already exists")
 			return service.ErrAlreadyExists
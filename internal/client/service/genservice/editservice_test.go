@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
 	"github.com/niksmo/gophkeeper/internal/client/service/genservice"
 	"github.com/niksmo/gophkeeper/pkg/logger"
 	"github.com/stretchr/testify/mock"
@@ -15,10 +17,20 @@ type MockUpdater struct {
 	mock.Mock
 }
 
+func (r *MockUpdater) Entity() string {
+	return entity
+}
+
+func (r *MockUpdater) ReadUUID(ctx context.Context, id int) (string, error) {
+	args := r.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
 func (r *MockUpdater) Update(
-	ctx context.Context, entryNum int, name string, data []byte,
+	ctx context.Context,
+	entryNum int, nameIndex string, name, data []byte, hash string,
 ) error {
-	args := r.Called(ctx, entryNum, name, data)
+	args := r.Called(ctx, entryNum, nameIndex, name, data, hash)
 	return args.Error(0)
 }
 
@@ -27,8 +39,10 @@ type EditSuite struct {
 	ctx       context.Context
 	log       logger.Logger
 	repo      *MockUpdater
+	verifier  *verifier
 	encoder   *encoder
 	encrypter *encrypter
+	indexer   *indexer
 	service   *genservice.EditService[any]
 }
 
@@ -37,13 +51,19 @@ func newEditSuite(t *testing.T) *EditSuite {
 	log := logger.NewPretty("debug")
 	encoder := &encoder{}
 	encrypter := &encrypter{}
+	indexer := &indexer{}
+	verifier := &verifier{}
 	repo := &MockUpdater{}
-	service := genservice.NewEdit[any](log, repo, encoder, encrypter)
+	service := genservice.NewEdit[any](
+		log, repo, verifier, encoder, encrypter, indexer,
+	)
 	st := &EditSuite{
 		t, ctx, log,
 		repo,
+		verifier,
 		encoder,
 		encrypter,
+		indexer,
 		service,
 	}
 	return st
@@ -59,10 +79,13 @@ func (st *EditSuite) PrettyPanic() {
 
 func TestEditService(t *testing.T) {
 	const (
-		Update  = "Update"
-		Encode  = "Encode"
-		SetKey  = "SetKey"
-		Encrypt = "Encrypt"
+		Update    = "Update"
+		ReadUUID  = "ReadUUID"
+		Verify    = "Verify"
+		Encode    = "Encode"
+		SetKey    = "SetKey"
+		EncryptAD = "EncryptAD"
+		Index     = "Index"
 	)
 	key := "testMasterKey"
 	entryNum := 1
@@ -71,19 +94,36 @@ func TestEditService(t *testing.T) {
 	}
 	encodedData := []byte("encodedData")
 	encryptedData := []byte("encryptedData")
+	entryUUID := "entryUUID"
+	nameIndex := "nameIndex"
+	nameAD := service.NameAD(entity, nameIndex)
+	contentHash, _ := service.HashContent(&indexer{}, obj.Name, encodedData)
+	dataAD := service.DataAD(entity, entryUUID)
+	sealedName := []byte("sealedName")
 
 	t.Run("Ordinary", func(t *testing.T) {
 		st := newEditSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+		st.repo.On(ReadUUID, st.ctx, entryNum).Return(entryUUID, nil)
+
 		var encodeErr error
 		var repoAddErr error
 
 		st.encoder.On(Encode, obj).Return(encodedData, encodeErr)
 		st.encrypter.On(SetKey, key)
-		st.encrypter.On(Encrypt, encodedData).Return(encryptedData, nil)
+		st.encrypter.On(EncryptAD, encodedData, dataAD).Return(
+			encryptedData, nil,
+		)
+		st.encrypter.On(EncryptAD, []byte(obj.Name), nameAD).Return(
+			sealedName, nil,
+		)
+		st.indexer.On(SetKey, key)
+		st.indexer.On(Index, obj.Name).Return(nameIndex, nil)
 		st.repo.On(
-			Update, st.ctx, entryNum, obj.Name, encryptedData,
+			Update, st.ctx, entryNum, nameIndex, sealedName, encryptedData,
+			contentHash,
 		).Return(repoAddErr)
 
 		err := st.service.Edit(st.ctx, key, entryNum, obj.Name, obj)
@@ -94,14 +134,25 @@ func TestEditService(t *testing.T) {
 		st := newEditSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+		st.repo.On(ReadUUID, st.ctx, entryNum).Return(entryUUID, nil)
+
 		var repoAddErr error
 		var encodeErr = errors.New("encode failed")
 
 		st.encoder.On(Encode, obj).Return(encodedData, encodeErr)
 		st.encrypter.On(SetKey, key)
-		st.encrypter.On(Encrypt, encodedData).Return(encryptedData, nil)
+		st.encrypter.On(EncryptAD, encodedData, dataAD).Return(
+			encryptedData, nil,
+		)
+		st.encrypter.On(EncryptAD, []byte(obj.Name), nameAD).Return(
+			sealedName, nil,
+		)
+		st.indexer.On(SetKey, key)
+		st.indexer.On(Index, obj.Name).Return(nameIndex, nil)
 		st.repo.On(
-			Update, st.ctx, entryNum, obj.Name, encryptedData,
+			Update, st.ctx, entryNum, nameIndex, sealedName, encryptedData,
+			contentHash,
 		).Return(repoAddErr)
 
 		err := st.service.Edit(st.ctx, key, entryNum, obj.Name, obj)
@@ -112,17 +163,60 @@ func TestEditService(t *testing.T) {
 		st := newEditSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+		st.repo.On(ReadUUID, st.ctx, entryNum).Return(entryUUID, nil)
+
 		var encodeErr error
 		var repoAddErr = errors.New("repo add failed")
 
 		st.encoder.On(Encode, obj).Return(encodedData, encodeErr)
 		st.encrypter.On(SetKey, key)
-		st.encrypter.On(Encrypt, encodedData).Return(encryptedData, nil)
+		st.encrypter.On(EncryptAD, encodedData, dataAD).Return(
+			encryptedData, nil,
+		)
+		st.encrypter.On(EncryptAD, []byte(obj.Name), nameAD).Return(
+			sealedName, nil,
+		)
+		st.indexer.On(SetKey, key)
+		st.indexer.On(Index, obj.Name).Return(nameIndex, nil)
 		st.repo.On(
-			Update, st.ctx, entryNum, obj.Name, encryptedData,
+			Update, st.ctx, entryNum, nameIndex, sealedName, encryptedData,
+			contentHash,
 		).Return(repoAddErr)
 
 		err := st.service.Edit(st.ctx, key, entryNum, obj.Name, obj)
 		require.ErrorIs(t, err, repoAddErr)
 	})
+
+	t.Run("NotExists", func(t *testing.T) {
+		st := newEditSuite(t)
+		defer st.PrettyPanic()
+
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+		st.encoder.On(Encode, obj).Return(encodedData, nil)
+		st.repo.On(ReadUUID, st.ctx, entryNum).Return(
+			"", repository.ErrNotExists,
+		)
+
+		err := st.service.Edit(st.ctx, key, entryNum, obj.Name, obj)
+		require.ErrorIs(t, err, service.ErrNotExists)
+		st.repo.AssertNotCalled(
+			t, Update, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		st := newEditSuite(t)
+		defer st.PrettyPanic()
+
+		st.verifier.On(Verify, st.ctx, key).Return(service.ErrInvalidKey)
+
+		err := st.service.Edit(st.ctx, key, entryNum, obj.Name, obj)
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+		st.repo.AssertNotCalled(
+			t, Update, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		)
+	})
 }
@@ -1,12 +1,53 @@
 package genservice
 
+import (
+	"context"
+
+	"github.com/niksmo/gophkeeper/internal/client/service"
+)
+
 type (
+	keyVerifier interface {
+		Verify(ctx context.Context, key string) error
+	}
+
+	entityRepo interface {
+		Entity() string
+	}
+
 	encoder interface {
 		Encode(src any) ([]byte, error)
 	}
 
 	encrypter interface {
 		SetKey(string)
-		Encrypt([]byte) ([]byte, error)
+		EncryptAD(data, ad []byte) ([]byte, error)
+	}
+
+	indexer interface {
+		SetKey(string)
+		Index(string) (string, error)
+		service.ContentHasher
 	}
 )
+
+// sealEntry returns the blind index of the name, the encrypted name bound
+// to the entity type and the index and the encrypted data bound to
+// the entity type and the record UUID.
+func sealEntry(
+	entity, entryUUID, name string, data []byte, i indexer, e encrypter,
+) (string, []byte, []byte, error) {
+	index, err := i.Index(name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	sealedName, err := e.EncryptAD([]byte(name), service.NameAD(entity, index))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	sealedData, err := e.EncryptAD(data, service.DataAD(entity, entryUUID))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return index, sealedName, sealedData, nil
+}
@@ -1,6 +1,24 @@
 package genservice_test
 
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+
+	"github.com/stretchr/testify/mock"
+)
+
+const entity = "passwords"
+
+type verifier struct {
+	mock.Mock
+}
+
+func (v *verifier) Verify(ctx context.Context, key string) error {
+	args := v.Called(ctx, key)
+	return args.Error(0)
+}
 
 type encoder struct {
 	mock.Mock
@@ -19,7 +37,24 @@ func (e *encrypter) SetKey(k string) {
 	e.Called(k)
 }
 
-func (e *encrypter) Encrypt(data []byte) ([]byte, error) {
-	args := e.Called(data)
+func (e *encrypter) EncryptAD(data, ad []byte) ([]byte, error) {
+	args := e.Called(data, ad)
 	return args.Get(0).([]byte), args.Error(1)
 }
+
+type indexer struct {
+	mock.Mock
+}
+
+func (i *indexer) SetKey(k string) {
+	i.Called(k)
+}
+
+func (i *indexer) Index(value string) (string, error) {
+	args := i.Called(value)
+	return args.String(0), args.Error(1)
+}
+
+func (i *indexer) NewHash() (hash.Hash, error) {
+	return hmac.New(sha256.New, []byte("key")), nil
+}
@@ -1,39 +1,86 @@
 package genservice
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
 
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
 	"github.com/niksmo/gophkeeper/pkg/logger"
 )
 
 type listRepo interface {
-	ListNames(ctx context.Context) ([][2]string, error)
+	entityRepo
+	ListNames(ctx context.Context) ([]repository.SealedName, error)
 }
 
 type ListService struct {
-	l logger.Logger
-	r listRepo
+	l         logger.Logger
+	r         listRepo
+	verifier  keyVerifier
+	decrypter decrypter
 }
 
 func NewList(
 	logger logger.Logger,
 	repository listRepo,
+	verifier keyVerifier,
+	decrypter decrypter,
 ) *ListService {
 	return &ListService{
-		l: logger,
-		r: repository,
+		l:         logger,
+		r:         repository,
+		verifier:  verifier,
+		decrypter: decrypter,
 	}
 }
 
-func (s *ListService) List(ctx context.Context) ([][2]string, error) {
+// List returns the entry numbers with the decrypted names ordered by name.
+// The names saved before the names encryption are returned as is.
+func (s *ListService) List(
+	ctx context.Context, key string,
+) ([][2]string, error) {
 	const op = "ListService.List"
 	log := s.l.With().Str("op", op).Logger()
 
-	idNameSlice, err := s.r.ListNames(ctx)
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealedNames, err := s.r.ListNames(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get list of names")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	s.decrypter.SetKey(key)
+	for i, sn := range sealedNames {
+		if sn.Index == "" {
+			continue
+		}
+		name, err := s.decrypter.DecryptAD(
+			sn.Name, service.NameAD(s.r.Entity(), sn.Index),
+		)
+		if err != nil {
+			log.Debug().Err(err).Int("id", sn.ID).Msg("failed to decrypt name")
+			return nil, decryptErr(err)
+		}
+		sealedNames[i].Name = name
+	}
+
+	slices.SortStableFunc(sealedNames, func(a, b repository.SealedName) int {
+		return cmp.Compare(string(a.Name), string(b.Name))
+	})
+
+	idNameSlice := make([][2]string, 0, len(sealedNames))
+	for _, sn := range sealedNames {
+		idNameSlice = append(
+			idNameSlice, [2]string{strconv.Itoa(sn.ID), string(sn.Name)},
+		)
+	}
 	return idNameSlice, nil
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
 	"github.com/niksmo/gophkeeper/internal/client/service/genservice"
 	"github.com/niksmo/gophkeeper/pkg/logger"
 	"github.com/stretchr/testify/assert"
@@ -16,27 +18,39 @@ type MockListNames struct {
 	mock.Mock
 }
 
-func (r *MockListNames) ListNames(ctx context.Context) ([][2]string, error) {
+func (r *MockListNames) Entity() string {
+	return entity
+}
+
+func (r *MockListNames) ListNames(
+	ctx context.Context,
+) ([]repository.SealedName, error) {
 	args := r.Called(ctx)
-	return args.Get(0).([][2]string), args.Error(1)
+	return args.Get(0).([]repository.SealedName), args.Error(1)
 }
 
 type ListSuite struct {
-	t       *testing.T
-	ctx     context.Context
-	log     logger.Logger
-	repo    *MockListNames
-	service *genservice.ListService
+	t         *testing.T
+	ctx       context.Context
+	log       logger.Logger
+	repo      *MockListNames
+	verifier  *verifier
+	decrypter *decrypter
+	service   *genservice.ListService
 }
 
 func newListSuite(t *testing.T) *ListSuite {
 	ctx := context.Background()
 	log := logger.NewPretty("debug")
 	repo := &MockListNames{}
-	service := genservice.NewList(log, repo)
+	verifier := &verifier{}
+	decrypter := &decrypter{}
+	service := genservice.NewList(log, repo, verifier, decrypter)
 	return &ListSuite{
 		t, ctx, log,
 		repo,
+		verifier,
+		decrypter,
 		service,
 	}
 }
@@ -50,20 +64,47 @@ func (st *ListSuite) PrettyPanic() {
 }
 
 func TestList(t *testing.T) {
-	const ListNames = "ListNames"
+	const (
+		ListNames = "ListNames"
+		Verify    = "Verify"
+		SetKey    = "SetKey"
+		DecryptAD = "DecryptAD"
+	)
+
+	key := "testMasterKey"
+
 	t.Run("Ordinary", func(t *testing.T) {
 		st := newListSuite(t)
 		defer st.PrettyPanic()
 
+		sealedNames := []repository.SealedName{
+			{ID: 1, Index: "index2", Name: []byte("sealed2")},
+			{ID: 2, Index: "", Name: []byte("testName3")},
+			{ID: 3, Index: "index1", Name: []byte("sealed1")},
+		}
+
 		expectedData := [][2]string{
-			{"1", "testName1"},
-			{"2", "testName2"},
+			{"3", "testName1"},
+			{"1", "testName2"},
+			{"2", "testName3"},
 		}
 
-		st.repo.On(ListNames, st.ctx).Return(expectedData, nil)
-		actual, err := st.service.List(st.ctx)
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+		st.repo.On(ListNames, st.ctx).Return(sealedNames, nil)
+		st.decrypter.On(SetKey, key)
+		st.decrypter.On(
+			DecryptAD, []byte("sealed1"), service.NameAD(entity, "index1"),
+		).Return([]byte("testName1"), nil)
+		st.decrypter.On(
+			DecryptAD, []byte("sealed2"), service.NameAD(entity, "index2"),
+		).Return([]byte("testName2"), nil)
+
+		actual, err := st.service.List(st.ctx, key)
 		require.NoError(t, err)
 		assert.Equal(t, expectedData, actual)
+		st.decrypter.AssertNotCalled(
+			t, DecryptAD, []byte("testName3"), mock.Anything,
+		)
 	})
 
 	t.Run("EmptyList", func(t *testing.T) {
@@ -72,8 +113,10 @@ func TestList(t *testing.T) {
 
 		expectedData := [][2]string{}
 
-		st.repo.On(ListNames, st.ctx).Return(expectedData, nil)
-		actual, err := st.service.List(st.ctx)
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+		st.repo.On(ListNames, st.ctx).Return([]repository.SealedName{}, nil)
+		st.decrypter.On(SetKey, key)
+		actual, err := st.service.List(st.ctx, key)
 		require.NoError(t, err)
 		assert.Equal(t, expectedData, actual)
 	})
@@ -82,16 +125,25 @@ func TestList(t *testing.T) {
 		st := newListSuite(t)
 		defer st.PrettyPanic()
 
-		data := [][2]string{
-			{"1", "testName1"},
-			{"2", "testName2"},
-		}
-
 		listNamesErr := errors.New("something happened with repo")
 
-		st.repo.On(ListNames, st.ctx).Return(data, listNamesErr)
-		actual, err := st.service.List(st.ctx)
-		require.Error(t, err)
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+		st.repo.On(ListNames, st.ctx).Return(
+			[]repository.SealedName{}, listNamesErr)
+		actual, err := st.service.List(st.ctx, key)
+		require.ErrorIs(t, err, listNamesErr)
+		assert.Nil(t, actual)
+	})
+
+	t.Run("VaultLocked", func(t *testing.T) {
+		st := newListSuite(t)
+		defer st.PrettyPanic()
+
+		st.verifier.On(Verify, st.ctx, "").Return(service.ErrVaultLocked)
+
+		actual, err := st.service.List(st.ctx, "")
+		require.ErrorIs(t, err, service.ErrVaultLocked)
 		assert.Nil(t, actual)
+		st.repo.AssertNotCalled(t, ListNames, mock.Anything)
 	})
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/niksmo/gophkeeper/internal/client/repository"
 	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
 	"github.com/niksmo/gophkeeper/pkg/logger"
 )
 
@@ -17,17 +18,19 @@ type (
 
 	decrypter interface {
 		SetKey(string)
-		Decrypt([]byte) ([]byte, error)
+		DecryptAD(data, ad []byte) ([]byte, error)
 	}
 
 	readRepo interface {
-		ReadByID(ctx context.Context, id int) ([]byte, error)
+		entityRepo
+		ReadByID(ctx context.Context, id int) (repository.SealedEntry, error)
 	}
 )
 
 type ReadService[T any] struct {
 	l         logger.Logger
 	r         readRepo
+	verifier  keyVerifier
 	decoder   decoder
 	decrypter decrypter
 	dto       T
@@ -36,12 +39,14 @@ type ReadService[T any] struct {
 func NewRead[T any](
 	logger logger.Logger,
 	repository readRepo,
+	verifier keyVerifier,
 	decoder decoder,
 	decrypter decrypter,
 ) *ReadService[T] {
 	return &ReadService[T]{
 		l:         logger,
 		r:         repository,
+		verifier:  verifier,
 		decoder:   decoder,
 		decrypter: decrypter,
 	}
@@ -53,7 +58,12 @@ func (s *ReadService[T]) Read(
 	const op = "ReadService.Read"
 	log := s.l.With().Str("op", op).Logger()
 
-	data, err := s.r.ReadByID(ctx, entryNum)
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return s.dto, fmt.Errorf("%s: %w", op, err)
+	}
+
+	e, err := s.r.ReadByID(ctx, entryNum)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExists) {
 			log.Debug().Msg("object not exists")
@@ -64,10 +74,12 @@ func (s *ReadService[T]) Read(
 	}
 
 	s.decrypter.SetKey(key)
-	b, err := s.decrypter.Decrypt(data)
+	b, _, err := service.OpenData(
+		s.decrypter, e.Data, s.r.Entity(), e.UUID, e.Index,
+	)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to decrypt")
-		return s.dto, service.ErrInvalidKey
+		return s.dto, decryptErr(err)
 	}
 
 	if err := s.decoder.Decode(&s.dto, b); err != nil {
@@ -77,3 +89,11 @@ func (s *ReadService[T]) Read(
 
 	return s.dto, nil
 }
+
+// decryptErr returns the service error of the failed decryption.
+func decryptErr(err error) error {
+	if errors.Is(err, cipher.ErrNotBound) {
+		return service.ErrNotBound
+	}
+	return service.ErrInvalidKey
+}
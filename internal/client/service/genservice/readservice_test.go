@@ -8,6 +8,7 @@ import (
 	"github.com/niksmo/gophkeeper/internal/client/repository"
 	"github.com/niksmo/gophkeeper/internal/client/service"
 	"github.com/niksmo/gophkeeper/internal/client/service/genservice"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
 	"github.com/niksmo/gophkeeper/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -44,8 +45,8 @@ func (d *decrypter) SetKey(k string) {
 	d.Called(k)
 }
 
-func (d *decrypter) Decrypt(data []byte) ([]byte, error) {
-	args := d.Called(data)
+func (d *decrypter) DecryptAD(data, ad []byte) ([]byte, error) {
+	args := d.Called(data, ad)
 	return args.Get(0).([]byte), args.Error(1)
 }
 
@@ -53,9 +54,15 @@ type MockReaderByID struct {
 	mock.Mock
 }
 
-func (r *MockReaderByID) ReadByID(ctx context.Context, id int) ([]byte, error) {
+func (r *MockReaderByID) Entity() string {
+	return entity
+}
+
+func (r *MockReaderByID) ReadByID(
+	ctx context.Context, id int,
+) (repository.SealedEntry, error) {
 	args := r.Called(ctx, id)
-	return args.Get(0).([]byte), args.Error(1)
+	return args.Get(0).(repository.SealedEntry), args.Error(1)
 }
 
 type ReadSuite struct {
@@ -63,6 +70,7 @@ type ReadSuite struct {
 	ctx       context.Context
 	log       logger.Logger
 	repo      *MockReaderByID
+	verifier  *verifier
 	decoder   *decoder
 	decrypter *decrypter
 	service   *genservice.ReadService[dto]
@@ -73,11 +81,13 @@ func newReadSuite(t *testing.T) *ReadSuite {
 	log := logger.NewPretty("debug")
 	decoder := &decoder{}
 	decrypter := &decrypter{}
+	verifier := &verifier{}
 	repo := &MockReaderByID{}
-	service := genservice.NewRead[dto](log, repo, decoder, decrypter)
+	service := genservice.NewRead[dto](log, repo, verifier, decoder, decrypter)
 	return &ReadSuite{
 		t, ctx, log,
 		repo,
+		verifier,
 		decoder,
 		decrypter,
 		service,
@@ -94,47 +104,84 @@ func (st *ReadSuite) PrettyPanic() {
 
 func TestRead(t *testing.T) {
 	const (
-		ReadByID = "ReadByID"
-		SetKey   = "SetKey"
-		Decrypt  = "Decrypt"
-		Decode   = "Decode"
+		ReadByID  = "ReadByID"
+		Verify    = "Verify"
+		SetKey    = "SetKey"
+		DecryptAD = "DecryptAD"
+		Decode    = "Decode"
 	)
 	key := "testMasterKey"
 	id := 1
 	encryptedData := []byte("encryptedData")
 	encodedData := []byte("encodedData")
+	entryUUID := "entryUUID"
+	nameIndex := "nameIndex"
+	entry := repository.SealedEntry{
+		Entity: entity, UUID: entryUUID, Index: nameIndex, Data: encryptedData,
+	}
+	dataAD := service.DataAD(entity, entryUUID)
+	legacyAD := service.DataAD(entity, nameIndex)
 	var obj dto
 	t.Run("Ordinary", func(t *testing.T) {
 		st := newReadSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		expectedObj := dto{
 			Name: "testName",
 			Data: "decodedData",
 		}
 
 		st.decrypter.On(SetKey, key)
-		st.decrypter.On(Decrypt, encryptedData).Return(encodedData, nil)
+		st.decrypter.On(DecryptAD, encryptedData, dataAD).Return(
+			encodedData, nil,
+		)
 		st.decoder.On(Decode, &obj, encodedData).Return(nil)
-		st.repo.On(ReadByID, st.ctx, id).Return(encryptedData, nil)
+		st.repo.On(ReadByID, st.ctx, id).Return(entry, nil)
 
 		obj, err := st.service.Read(st.ctx, key, id)
 		require.NoError(t, err)
 		assert.Equal(t, expectedObj, obj)
 	})
 
+	t.Run("LegacyIndexBound", func(t *testing.T) {
+		st := newReadSuite(t)
+		defer st.PrettyPanic()
+
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
+		st.decrypter.On(SetKey, key)
+		st.decrypter.On(DecryptAD, encryptedData, dataAD).Return(
+			[]byte(nil), errors.New("invalid key"),
+		)
+		st.decrypter.On(DecryptAD, encryptedData, legacyAD).Return(
+			encodedData, nil,
+		)
+		st.decoder.On(Decode, &obj, encodedData).Return(nil)
+		st.repo.On(ReadByID, st.ctx, id).Return(entry, nil)
+
+		obj, err := st.service.Read(st.ctx, key, id)
+		require.NoError(t, err)
+		assert.Equal(t, "decodedData", obj.Data)
+	})
+
 	t.Run("NotExists", func(t *testing.T) {
 		st := newReadSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		expectedObj := dto{}
 
 		st.decrypter.On(SetKey, key)
-		st.decrypter.On(Decrypt, encryptedData).Return(encodedData, nil)
+		st.decrypter.On(DecryptAD, encryptedData, dataAD).Return(
+			encodedData, nil,
+		)
 		st.decoder.On(Decode, &obj, encodedData).Return(nil)
 
 		st.repo.On(ReadByID, st.ctx, id).Return(
-			encryptedData, repository.ErrNotExists,
+			repository.SealedEntry{}, repository.ErrNotExists,
 		)
 
 		obj, err := st.service.Read(st.ctx, key, id)
@@ -146,13 +193,19 @@ func TestRead(t *testing.T) {
 		st := newReadSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		repoErr := errors.New("something happened with repo")
 		expectedObj := dto{}
 
 		st.decrypter.On(SetKey, key)
-		st.decrypter.On(Decrypt, encryptedData).Return(encodedData, nil)
+		st.decrypter.On(DecryptAD, encryptedData, dataAD).Return(
+			encodedData, nil,
+		)
 		st.decoder.On(Decode, &obj, encodedData).Return(nil)
-		st.repo.On(ReadByID, st.ctx, id).Return(encryptedData, repoErr)
+		st.repo.On(ReadByID, st.ctx, id).Return(
+			repository.SealedEntry{}, repoErr,
+		)
 
 		obj, err := st.service.Read(st.ctx, key, id)
 		require.ErrorIs(t, err, repoErr)
@@ -163,36 +216,77 @@ func TestRead(t *testing.T) {
 		st := newReadSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		expectedObj := dto{}
 
 		st.decrypter.On(SetKey, key)
 
 		st.decrypter.On(
-			"Decrypt", encryptedData,
+			DecryptAD, encryptedData, dataAD,
+		).Return(encodedData, errors.New("invalid key"))
+		st.decrypter.On(
+			DecryptAD, encryptedData, legacyAD,
 		).Return(encodedData, errors.New("invalid key"))
 
 		st.decoder.On(Decode, &obj, encodedData).Return(nil)
-		st.repo.On(ReadByID, st.ctx, id).Return(encryptedData, nil)
+		st.repo.On(ReadByID, st.ctx, id).Return(entry, nil)
 
 		obj, err := st.service.Read(st.ctx, key, id)
 		require.ErrorIs(t, err, service.ErrInvalidKey)
 		assert.Equal(t, expectedObj, obj)
 	})
 
+	t.Run("DecryptFailedNotBound", func(t *testing.T) {
+		st := newReadSuite(t)
+		defer st.PrettyPanic()
+
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
+		st.decrypter.On(SetKey, key)
+		st.decrypter.On(
+			DecryptAD, encryptedData, dataAD,
+		).Return([]byte(nil), cipher.ErrNotBound)
+		st.decrypter.On(
+			DecryptAD, encryptedData, legacyAD,
+		).Return([]byte(nil), cipher.ErrNotBound)
+		st.repo.On(ReadByID, st.ctx, id).Return(entry, nil)
+
+		obj, err := st.service.Read(st.ctx, key, id)
+		require.ErrorIs(t, err, service.ErrNotBound)
+		assert.Equal(t, dto{}, obj)
+	})
+
 	t.Run("DecodeFailed", func(t *testing.T) {
 		st := newReadSuite(t)
 		defer st.PrettyPanic()
 
+		st.verifier.On(Verify, st.ctx, key).Return(nil)
+
 		decodeErr := errors.New("failed to decode")
 		expectedObj := dto{}
 
 		st.decrypter.On(SetKey, key)
-		st.decrypter.On(Decrypt, encryptedData).Return(encodedData, nil)
+		st.decrypter.On(DecryptAD, encryptedData, dataAD).Return(
+			encodedData, nil,
+		)
 		st.decoder.On(Decode, &obj, encodedData).Return(decodeErr)
-		st.repo.On(ReadByID, st.ctx, id).Return(encryptedData, nil)
+		st.repo.On(ReadByID, st.ctx, id).Return(entry, nil)
 
 		obj, err := st.service.Read(st.ctx, key, id)
 		require.ErrorIs(t, err, decodeErr)
 		assert.Equal(t, expectedObj, obj)
 	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		st := newReadSuite(t)
+		defer st.PrettyPanic()
+
+		st.verifier.On(Verify, st.ctx, key).Return(service.ErrInvalidKey)
+
+		obj, err := st.service.Read(st.ctx, key, id)
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+		assert.Equal(t, dto{}, obj)
+		st.repo.AssertNotCalled(t, ReadByID, mock.Anything, mock.Anything)
+	})
 }
@@ -0,0 +1,184 @@
+package recoveryservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/shamir"
+)
+
+type keyVerifier interface {
+	Verify(ctx context.Context, key string) error
+}
+
+// The share file is the PEM block preceded by the hint. The block payload
+// is version | split id | threshold | shares number | share | crc32,
+// the headers are informational only.
+const (
+	pemType      = "GOPHKEEPER RECOVERY SHARE"
+	shareVersion = 1
+	splitIDSize  = 4
+	shareFixLen  = 1 + splitIDSize + 1 + 1
+	checksumSize = 4
+)
+
+type share struct {
+	splitID   []byte
+	threshold int
+	shares    int
+	data      []byte
+}
+
+type Splitter struct {
+	l        logger.Logger
+	verifier keyVerifier
+}
+
+func NewSplitter(l logger.Logger, v keyVerifier) *Splitter {
+	return &Splitter{l, v}
+}
+
+// Split divides the master key into the printable share files,
+// any threshold number of them recovers the key.
+func (s *Splitter) Split(
+	ctx context.Context, key string, shares, threshold int,
+) ([][]byte, error) {
+	const op = "Splitter.Split"
+	log := s.l.WithOp(op)
+
+	if err := s.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	parts, err := shamir.Split([]byte(key), shares, threshold)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to split key")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	splitID := make([]byte, splitIDSize)
+	rand.Read(splitID)
+
+	files := make([][]byte, len(parts))
+	for i, part := range parts {
+		files[i] = marshalShare(share{splitID, threshold, shares, part}, i+1)
+	}
+	return files, nil
+}
+
+type Combiner struct {
+	l        logger.Logger
+	verifier keyVerifier
+}
+
+func NewCombiner(l logger.Logger, v keyVerifier) *Combiner {
+	return &Combiner{l, v}
+}
+
+// Combine recovers the master key from the share files, the key
+// is returned only if it matches the vault.
+func (c *Combiner) Combine(
+	ctx context.Context, files [][]byte,
+) (string, error) {
+	const op = "Combiner.Combine"
+	log := c.l.WithOp(op)
+
+	shares := make([]share, len(files))
+	for i, file := range files {
+		s, err := unmarshalShare(file)
+		if err != nil {
+			log.Debug().Err(err).Int("file", i).Msg("failed to parse share")
+			return "", fmt.Errorf("%s: %w", op, service.ErrInvalidShare)
+		}
+		shares[i] = s
+	}
+
+	if len(shares) == 0 {
+		return "", service.ErrNotEnoughShares
+	}
+	parts := make([][]byte, len(shares))
+	for i, s := range shares {
+		if !bytes.Equal(s.splitID, shares[0].splitID) {
+			return "", service.ErrDifferentSplits
+		}
+		parts[i] = s.data
+	}
+	if len(shares) < shares[0].threshold {
+		return "", service.ErrNotEnoughShares
+	}
+
+	key, err := shamir.Combine(parts)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to combine shares")
+		return "", fmt.Errorf("%s: %w", op, service.ErrInvalidShare)
+	}
+
+	if err := c.verifier.Verify(ctx, string(key)); err != nil {
+		log.Debug().Err(err).Msg("failed to verify recovered key")
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return string(key), nil
+}
+
+func marshalShare(s share, n int) []byte {
+	b := make([]byte, 0, shareFixLen+len(s.data)+checksumSize)
+	b = append(b, shareVersion)
+	b = append(b, s.splitID...)
+	b = append(b, byte(s.threshold), byte(s.shares))
+	b = append(b, s.data...)
+	b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf,
+		"gophkeeper vault recovery share %d of %d, any %d shares "+
+			"recover the vault master key.\n"+
+			"Keep the shares in different places.\n\n",
+		n, s.shares, s.threshold,
+	)
+	pem.Encode(&buf, &pem.Block{
+		Type: pemType,
+		Headers: map[string]string{
+			"Share":     strconv.Itoa(n) + "/" + strconv.Itoa(s.shares),
+			"Threshold": strconv.Itoa(s.threshold),
+		},
+		Bytes: b,
+	})
+	return buf.Bytes()
+}
+
+func unmarshalShare(file []byte) (share, error) {
+	block, _ := pem.Decode(file)
+	if block == nil || block.Type != pemType {
+		return share{}, errors.New("share block is not found")
+	}
+
+	b := block.Bytes
+	if len(b) < shareFixLen+2+checksumSize {
+		return share{}, errors.New("share is too short")
+	}
+
+	payload, sum := b[:len(b)-checksumSize], b[len(b)-checksumSize:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(sum) {
+		return share{}, errors.New("share checksum mismatch")
+	}
+	if payload[0] != shareVersion {
+		return share{}, fmt.Errorf("unknown share version %d", payload[0])
+	}
+
+	return share{
+		splitID:   payload[1 : 1+splitIDSize],
+		threshold: int(payload[1+splitIDSize]),
+		shares:    int(payload[2+splitIDSize]),
+		data:      payload[shareFixLen:],
+	}, nil
+}
@@ -0,0 +1,133 @@
+package recoveryservice_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/internal/client/service/recoveryservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/vaultservice"
+	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/shamir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const key = "master key"
+
+type suite struct {
+	ctx      context.Context
+	splitter *recoveryservice.Splitter
+	combiner *recoveryservice.Combiner
+}
+
+func newSuite(t *testing.T, key string) *suite {
+	log := logger.NewPretty("debug")
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		os.Remove(dsn)
+	})
+
+	s := storage.New(log, dsn)
+	s.MustRun(ctx)
+
+	vault := repository.NewVault(log, s)
+	loader := vaultservice.NewParamsLoader(log, vault, 1, 8*1024)
+	params, err := loader.Load(ctx)
+	require.NoError(t, err)
+
+	e := cipher.NewEncrypter()
+	e.SetParams(params)
+	v := vaultservice.NewKeyVerifier(log, vault, e, cipher.NewDecrypter())
+	require.NoError(t, v.Verify(ctx, key))
+
+	return &suite{
+		ctx,
+		recoveryservice.NewSplitter(log, v),
+		recoveryservice.NewCombiner(log, v),
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newSuite(t, key)
+		files, err := st.splitter.Split(st.ctx, key, 5, 3)
+		require.NoError(t, err)
+		require.Len(t, files, 5)
+		assert.Contains(t, string(files[1]), "share 2 of 5")
+
+		actual, err := st.combiner.Combine(
+			st.ctx, [][]byte{files[4], files[0], files[2]},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, key, actual)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		st := newSuite(t, key)
+		_, err := st.splitter.Split(st.ctx, "wrong key", 5, 3)
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+	})
+
+	t.Run("InvalidParams", func(t *testing.T) {
+		st := newSuite(t, key)
+		_, err := st.splitter.Split(st.ctx, key, 2, 3)
+		require.ErrorIs(t, err, shamir.ErrInvalidParams)
+	})
+
+	t.Run("NotEnoughShares", func(t *testing.T) {
+		st := newSuite(t, key)
+		files, err := st.splitter.Split(st.ctx, key, 5, 3)
+		require.NoError(t, err)
+
+		_, err = st.combiner.Combine(st.ctx, files[:2])
+		require.ErrorIs(t, err, service.ErrNotEnoughShares)
+	})
+
+	t.Run("DifferentSplits", func(t *testing.T) {
+		st := newSuite(t, key)
+		files1, err := st.splitter.Split(st.ctx, key, 3, 2)
+		require.NoError(t, err)
+		files2, err := st.splitter.Split(st.ctx, key, 3, 2)
+		require.NoError(t, err)
+
+		_, err = st.combiner.Combine(st.ctx, [][]byte{files1[0], files2[1]})
+		require.ErrorIs(t, err, service.ErrDifferentSplits)
+	})
+
+	t.Run("DamagedShare", func(t *testing.T) {
+		st := newSuite(t, key)
+		files, err := st.splitter.Split(st.ctx, key, 3, 2)
+		require.NoError(t, err)
+
+		lines := bytes.Split(files[0], []byte("\n"))
+		for i, line := range lines {
+			if len(line) > 10 && !bytes.ContainsAny(line, " :") {
+				lines[i][5] ^= 'A' ^ 'B'
+				break
+			}
+		}
+		damaged := bytes.Join(lines, []byte("\n"))
+
+		_, err = st.combiner.Combine(st.ctx, [][]byte{damaged, files[1]})
+		require.ErrorIs(t, err, service.ErrInvalidShare)
+	})
+
+	t.Run("OtherVault", func(t *testing.T) {
+		st := newSuite(t, key)
+		other := newSuite(t, "other key")
+		files, err := other.splitter.Split(other.ctx, "other key", 3, 2)
+		require.NoError(t, err)
+
+		_, err = st.combiner.Combine(st.ctx, files[:2])
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+	})
+}
@@ -1,9 +1,103 @@
 package service
 
-import "errors"
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+)
 
 var (
 	ErrAlreadyExists = errors.New("object already exists")
 	ErrNotExists     = errors.New("object not exists")
 	ErrInvalidKey    = errors.New("invalid key provided")
+	ErrVaultLocked   = errors.New("vault is locked")
+	ErrNotBound      = errors.New("object is not bound to its record")
+	ErrDeletedCopy   = errors.New("conflict copy is deleted")
+
+	ErrInvalidShare    = errors.New("invalid recovery share")
+	ErrNotEnoughShares = errors.New("not enough recovery shares")
+	ErrDifferentSplits = errors.New("recovery shares of different splits")
 )
+
+// NameAD returns the associated data of the entry name, it binds
+// the name to the entity type and the record identified by the name index.
+func NameAD(entity, index string) []byte {
+	return entryAD(entity, "name", index)
+}
+
+// DataAD returns the associated data of the entry data, it binds the data
+// to the entity type and the record UUID, so the data stays bound through
+// the renames and the rekeys.
+func DataAD(entity, uuid string) []byte {
+	return entryAD(entity, "data", uuid)
+}
+
+// StreamAD returns the associated data of the binary content stream,
+// the stream is bound the same way as the data.
+func StreamAD(entity, uuid string) []byte {
+	return entryAD(entity, "stream", uuid)
+}
+
+// ADDecrypter decrypts the data bound to the associated data.
+type ADDecrypter interface {
+	DecryptAD(data, ad []byte) ([]byte, error)
+}
+
+// OpenData decrypts the entry data bound to the record UUID. The data
+// sealed before is bound to the name index, the index is tried if the UUID
+// does not match. Returns the data and the UUID or the index it is bound
+// to, the stream of the entry is bound to the same one.
+func OpenData(
+	d ADDecrypter, data []byte, entity, uuid, index string,
+) ([]byte, string, error) {
+	b, err := d.DecryptAD(data, DataAD(entity, uuid))
+	if err == nil || index == "" {
+		return b, uuid, err
+	}
+	b, legacyErr := d.DecryptAD(data, DataAD(entity, index))
+	if legacyErr != nil {
+		return nil, uuid, err
+	}
+	return b, index, nil
+}
+
+// ContentHasher returns the keyed hash of the plaintext content,
+// see cipher.Indexer.
+type ContentHasher interface {
+	NewHash() (hash.Hash, error)
+}
+
+// NewContentHash returns the content hash of the entry with the name
+// written, the stream of the binary is written to it next, see SumContent.
+func NewContentHash(c ContentHasher, name string) (hash.Hash, error) {
+	h, err := c.NewHash()
+	if err != nil {
+		return nil, err
+	}
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(name))))
+	h.Write([]byte(name))
+	return h, nil
+}
+
+// SumContent writes the entry data to the content hash and returns its hex.
+// The data length is written last, so moving the bytes between the stream
+// and the data changes the hash.
+func SumContent(h hash.Hash, data []byte) string {
+	h.Write(data)
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(data))))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashContent returns the content hash of the entry without the stream.
+func HashContent(c ContentHasher, name string, data []byte) (string, error) {
+	h, err := NewContentHash(c, name)
+	if err != nil {
+		return "", err
+	}
+	return SumContent(h, data), nil
+}
+
+func entryAD(entity, field, record string) []byte {
+	return []byte("gophkeeper\x00" + entity + "\x00" + field + "\x00" + record)
+}
@@ -0,0 +1,143 @@
+package syncservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/sockrpc"
+)
+
+const (
+	methodStatus  = "status"
+	methodPause   = "pause"
+	methodResume  = "resume"
+	methodStop    = "stop"
+	methodSyncNow = "sync"
+	methodToken   = "token"
+
+	StateRunning = "running"
+	StatePaused  = "paused"
+	StateStopped = "stopped"
+
+	// StateExpired is the state of the daemon after the server rejects
+	// the session token, the daemon waits for the new token.
+	StateExpired = "expired"
+)
+
+// DaemonStatus is the state of the running sync daemon. RetryAt is set
+// while the synchronization is delayed on the unavailable server.
+type DaemonStatus struct {
+	State     string     `json:"state"`
+	PID       int        `json:"pid"`
+	StartedAt time.Time  `json:"startedAt"`
+	RetryAt   *time.Time `json:"retryAt,omitempty"`
+}
+
+type tokenParams struct {
+	Token string `json:"token"`
+}
+
+// DaemonClient controls the sync daemon through its unix socket,
+// ErrNoSync is returned if the daemon is not running.
+type DaemonClient struct {
+	logger logger.Logger
+	rpc    *sockrpc.Client
+}
+
+func NewDaemonClient(l logger.Logger, socket string) *DaemonClient {
+	return &DaemonClient{l, sockrpc.NewClient(socket)}
+}
+
+func (c *DaemonClient) Status(ctx context.Context) (DaemonStatus, error) {
+	const op = "DaemonClient.Status"
+	var status DaemonStatus
+	if err := c.call(ctx, methodStatus, nil, &status); err != nil {
+		return DaemonStatus{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return status, nil
+}
+
+func (c *DaemonClient) Pause(ctx context.Context) error {
+	const op = "DaemonClient.Pause"
+	if err := c.call(ctx, methodPause, nil, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Resume resumes the paused daemon, ErrUnauthenticated is returned if
+// the daemon is expired and waits for the new token.
+func (c *DaemonClient) Resume(ctx context.Context) error {
+	const op = "DaemonClient.Resume"
+	status, err := c.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if status.State == StateExpired {
+		return fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if err := c.call(ctx, methodResume, nil, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *DaemonClient) Stop(ctx context.Context) error {
+	const op = "DaemonClient.Stop"
+	if err := c.call(ctx, methodStop, nil, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SyncNow starts the synchronization without waiting for the next tick,
+// the paused daemon synchronizes too. The request is rejected with
+// the time to retry after while the breaker delays the synchronization
+// on the unavailable server.
+func (c *DaemonClient) SyncNow(ctx context.Context) error {
+	const op = "DaemonClient.SyncNow"
+	if err := c.call(ctx, methodSyncNow, nil, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetToken passes the new session token to the expired daemon and
+// resumes the synchronization.
+func (c *DaemonClient) SetToken(ctx context.Context, token string) error {
+	const op = "DaemonClient.SetToken"
+	err := c.call(ctx, methodToken, tokenParams{token}, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *DaemonClient) call(
+	ctx context.Context, method string, params, result any,
+) error {
+	log := c.logger.With().Str("method", method).Logger()
+
+	err := c.rpc.Call(ctx, method, params, result)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to call sync daemon")
+		if errors.Is(err, sockrpc.ErrUnavailable) {
+			return ErrNoSync
+		}
+		return err
+	}
+	return nil
+}
+
+// waitDaemon polls the daemon status until ready reports true.
+func waitDaemon(
+	ctx context.Context, c *DaemonClient, ready func(err error) bool,
+) error {
+	return sockrpc.WaitReady(ctx, func(ctx context.Context) bool {
+		_, err := c.Status(ctx)
+		return ready(err)
+	})
+}
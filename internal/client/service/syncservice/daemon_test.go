@@ -0,0 +1,363 @@
+package syncservice_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/service/syncservice"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/sockrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSyncRepo struct {
+	stopped atomic.Bool
+}
+
+func (r *fakeSyncRepo) Create(
+	_ context.Context, pid int, startedAt time.Time,
+) (dto.Sync, error) {
+	return dto.Sync{ID: 1, PID: pid, StartedAt: startedAt}, nil
+}
+
+func (r *fakeSyncRepo) Update(_ context.Context, obj dto.Sync) error {
+	r.stopped.Store(obj.StoppedAt != nil)
+	return nil
+}
+
+func (r *fakeSyncRepo) SaveSynced(context.Context, string, time.Time) error {
+	return nil
+}
+
+func (r *fakeSyncRepo) SaveError(context.Context, string, string) error {
+	return nil
+}
+
+type fakeSessionRepo struct {
+	expired atomic.Bool
+	deleted atomic.Bool
+}
+
+func (r *fakeSessionRepo) SetExpired(context.Context) error {
+	r.expired.Store(true)
+	return nil
+}
+
+func (r *fakeSessionRepo) Delete(context.Context) error {
+	r.deleted.Store(true)
+	return nil
+}
+
+// fakeWorker returns err from the first failures jobs or from every job
+// if failures is zero.
+type fakeWorker struct {
+	jobs     atomic.Int32
+	err      error
+	failures int32
+}
+
+func (w *fakeWorker) Entity() string {
+	return "passwords"
+}
+
+func (w *fakeWorker) DoJob(context.Context, string) error {
+	if n := w.jobs.Add(1); w.failures != 0 && n > w.failures {
+		return nil
+	}
+	return w.err
+}
+
+func (w *fakeWorker) Sync(ctx context.Context, token string) (dto.SyncPlan, error) {
+	return dto.SyncPlan{}, w.DoJob(ctx, token)
+}
+
+func (w *fakeWorker) Plan(context.Context, string) (dto.SyncPlan, error) {
+	return dto.SyncPlan{}, w.err
+}
+
+// fakeSubscriber records the tokens of the subscriptions and rejects
+// the reject token.
+type fakeSubscriber struct {
+	changes chan string
+	reject  string
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (s *fakeSubscriber) Subscribe(
+	_ context.Context, token string,
+) (<-chan string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, token)
+	if token == s.reject {
+		return nil, syncservice.ErrUnauthenticated
+	}
+	return s.changes, nil
+}
+
+func (s *fakeSubscriber) getTokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.tokens)
+}
+
+type daemonSuite struct {
+	client     *syncservice.DaemonClient
+	repo       *fakeSyncRepo
+	sessions   *fakeSessionRepo
+	subscriber *fakeSubscriber
+	done       chan error
+}
+
+func startDaemon(
+	t *testing.T, w syncservice.SyncWorker, interval syncservice.Interval,
+) *daemonSuite {
+	t.Helper()
+	return startDaemonWith(
+		t, w, interval, &fakeSubscriber{changes: make(chan string)},
+	)
+}
+
+func startDaemonWith(
+	t *testing.T,
+	w syncservice.SyncWorker,
+	interval syncservice.Interval,
+	subscriber *fakeSubscriber,
+) *daemonSuite {
+	t.Helper()
+	log := logger.NewPretty("debug")
+	socket := filepath.Join(t.TempDir(), "sync.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	st := &daemonSuite{
+		client:     syncservice.NewDaemonClient(log, socket),
+		repo:       &fakeSyncRepo{},
+		sessions:   &fakeSessionRepo{},
+		subscriber: subscriber,
+		done:       make(chan error, 1),
+	}
+	pool := syncservice.NewWorkerPool(
+		log, st.repo, st.sessions, st.subscriber,
+		[]syncservice.SyncWorker{w}, interval, socket,
+	)
+	go func() {
+		st.done <- pool.Run(ctx, "token")
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := st.client.Status(ctx)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return st
+}
+
+func (st *daemonSuite) waitDone(t *testing.T) {
+	t.Helper()
+	select {
+	case err := <-st.done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("sync daemon is not stopped")
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	ctx := context.Background()
+	hourly := syncservice.Interval{Min: time.Hour, Max: time.Hour}
+
+	t.Run("Status", func(t *testing.T) {
+		st := startDaemon(t, &fakeWorker{}, hourly)
+
+		status, err := st.client.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, syncservice.StateRunning, status.State)
+		assert.NotZero(t, status.PID)
+	})
+
+	t.Run("PauseResume", func(t *testing.T) {
+		st := startDaemon(t, &fakeWorker{}, hourly)
+
+		require.NoError(t, st.client.Pause(ctx))
+		status, err := st.client.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, syncservice.StatePaused, status.State)
+
+		require.NoError(t, st.client.Resume(ctx))
+		status, err = st.client.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, syncservice.StateRunning, status.State)
+	})
+
+	t.Run("SyncNow", func(t *testing.T) {
+		w := &fakeWorker{}
+		st := startDaemon(t, w, hourly)
+
+		require.NoError(t, st.client.Pause(ctx))
+		require.NoError(t, st.client.SyncNow(ctx))
+		assert.Eventually(t, func() bool {
+			return w.jobs.Load() == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("ServerChange", func(t *testing.T) {
+		w := &fakeWorker{}
+		st := startDaemon(t, w, hourly)
+
+		st.subscriber.changes <- "passwords"
+		assert.Eventually(t, func() bool {
+			return w.jobs.Load() == 1
+		}, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, st.client.Pause(ctx))
+		st.subscriber.changes <- "passwords"
+		assert.Never(t, func() bool {
+			return w.jobs.Load() != 1
+		}, 100*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		st := startDaemon(t, &fakeWorker{}, hourly)
+
+		require.NoError(t, st.client.Stop(ctx))
+		st.waitDone(t)
+		assert.True(t, st.repo.stopped.Load())
+
+		_, err := st.client.Status(ctx)
+		assert.ErrorIs(t, err, syncservice.ErrNoSync)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		w := &fakeWorker{err: syncservice.ErrUnauthenticated, failures: 1}
+		st := startDaemon(t, w, hourly)
+
+		require.NoError(t, st.client.SyncNow(ctx))
+		require.Eventually(t, func() bool {
+			status, err := st.client.Status(ctx)
+			return err == nil && status.State == syncservice.StateExpired
+		}, time.Second, 10*time.Millisecond)
+		assert.True(t, st.sessions.expired.Load())
+
+		err := st.client.Resume(ctx)
+		assert.ErrorIs(t, err, syncservice.ErrUnauthenticated)
+		require.NoError(t, st.client.SyncNow(ctx))
+		assert.Never(t, func() bool {
+			return w.jobs.Load() != 1
+		}, 100*time.Millisecond, 10*time.Millisecond)
+
+		require.NoError(t, st.client.SetToken(ctx, "new token"))
+		assert.Eventually(t, func() bool {
+			return w.jobs.Load() == 2
+		}, time.Second, 10*time.Millisecond)
+		status, err := st.client.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, syncservice.StateRunning, status.State)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		w := &fakeWorker{err: syncservice.ErrServerUnavailable, failures: 2}
+		interval := syncservice.Interval{Min: 100 * time.Millisecond, Max: time.Hour}
+		st := startDaemon(t, w, interval)
+
+		require.NoError(t, st.client.SyncNow(ctx))
+		assert.Eventually(t, func() bool {
+			return w.jobs.Load() == 3
+		}, time.Second, 10*time.Millisecond)
+
+		status, err := st.client.Status(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, status.RetryAt)
+	})
+
+	t.Run("Breaker", func(t *testing.T) {
+		w := &fakeWorker{err: syncservice.ErrServerUnavailable, failures: 9}
+		interval := syncservice.Interval{Min: 50 * time.Millisecond, Max: time.Hour}
+		st := startDaemon(t, w, interval)
+
+		require.Eventually(t, func() bool {
+			status, err := st.client.Status(ctx)
+			return err == nil && status.RetryAt != nil
+		}, 3*time.Second, 10*time.Millisecond)
+		assert.EqualValues(t, 9, w.jobs.Load(),
+			"the breaker opens after three jobs of three attempts")
+
+		assert.Eventually(t, func() bool {
+			status, err := st.client.Status(ctx)
+			return err == nil && status.RetryAt == nil
+		}, 3*time.Second, 10*time.Millisecond)
+		assert.EqualValues(t, 10, w.jobs.Load(), "the probe job closes")
+	})
+
+	t.Run("BreakerEvents", func(t *testing.T) {
+		w := &fakeWorker{err: syncservice.ErrServerUnavailable}
+		interval := syncservice.Interval{Min: 300 * time.Millisecond, Max: time.Hour}
+		st := startDaemon(t, w, interval)
+
+		require.Eventually(t, func() bool {
+			status, err := st.client.Status(ctx)
+			return err == nil && status.RetryAt != nil
+		}, 3*time.Second, 10*time.Millisecond)
+		jobs := w.jobs.Load()
+
+		err := st.client.SyncNow(ctx)
+		assert.ErrorIs(t, err, sockrpc.ErrRemote)
+		assert.ErrorContains(t, err, "retry after",
+			"the open breaker rejects the request")
+		st.subscriber.changes <- "passwords"
+		assert.Never(t, func() bool {
+			return w.jobs.Load() != jobs
+		}, 100*time.Millisecond, 10*time.Millisecond,
+			"the open breaker skips the requests and the changes")
+	})
+
+	t.Run("SubscriptionUnauthenticated", func(t *testing.T) {
+		subscriber := &fakeSubscriber{
+			changes: make(chan string), reject: "token",
+		}
+		interval := syncservice.Interval{Min: 10 * time.Millisecond, Max: time.Hour}
+		st := startDaemonWith(t, &fakeWorker{}, interval, subscriber)
+
+		require.Eventually(t, func() bool {
+			status, err := st.client.Status(ctx)
+			return err == nil && status.State == syncservice.StateExpired
+		}, time.Second, 10*time.Millisecond)
+		assert.True(t, st.sessions.expired.Load())
+		assert.Never(t, func() bool {
+			return len(subscriber.getTokens()) != 1
+		}, 100*time.Millisecond, 10*time.Millisecond,
+			"the rejected token is not used again")
+
+		require.NoError(t, st.client.SetToken(ctx, "new token"))
+		assert.Eventually(t, func() bool {
+			return slices.Equal(
+				[]string{"token", "new token"}, subscriber.getTokens(),
+			)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("NotRunning", func(t *testing.T) {
+		log := logger.NewPretty("debug")
+		c := syncservice.NewDaemonClient(
+			log, filepath.Join(t.TempDir(), "none.sock"),
+		)
+		_, err := c.Status(ctx)
+		assert.ErrorIs(t, err, syncservice.ErrNoSync)
+		assert.ErrorIs(t, c.Stop(ctx), syncservice.ErrNoSync)
+
+		sessions := &fakeSessionRepo{}
+		closer := syncservice.NewSyncCloser(log, c, sessions)
+		err = closer.CloseSynchronization(ctx)
+		assert.ErrorIs(t, err, syncservice.ErrNoSync)
+		assert.True(t, sessions.deleted.Load(),
+			"the session is deleted without the daemon")
+	})
+}
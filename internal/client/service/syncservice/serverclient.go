@@ -2,80 +2,190 @@ package syncservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/pkg/hasher"
+	"github.com/niksmo/gophkeeper/pkg/hlc"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
 	usersdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// streamThreshold is the largest object data sent inline,
+	// the larger data is uploaded in chunks.
+	streamThreshold = 1 << 20
+
+	uploadChunkSize = 1 << 18
+
+	// transferAttempts is the number of the attempts to resume
+	// the interrupted upload or download.
+	transferAttempts = 3
+)
+
+var (
+	ErrTransferIncomplete = errors.New("data transfer is incomplete")
+	ErrTransferHash       = errors.New("transferred data hash mismatch")
 )
 
 type gRPCSyncClient struct {
 	logger logger.Logger
 	client usersdatapb.UsersDataClient
 	entity string
+	origin string
 	token  string
 }
 
 func NewGRPCSyncClientPwd(
-	l logger.Logger, c usersdatapb.UsersDataClient,
+	l logger.Logger, c usersdatapb.UsersDataClient, origin string,
 ) ServerClient {
-	return &gRPCSyncClient{logger: l, client: c, entity: "passwords"}
+	return &gRPCSyncClient{
+		logger: l, client: c, entity: "passwords", origin: origin,
+	}
 }
 
 func NewGRPCSyncClientCard(
-	l logger.Logger, c usersdatapb.UsersDataClient,
+	l logger.Logger, c usersdatapb.UsersDataClient, origin string,
 ) ServerClient {
-	return &gRPCSyncClient{logger: l, client: c, entity: "cards"}
+	return &gRPCSyncClient{
+		logger: l, client: c, entity: "cards", origin: origin,
+	}
 }
 
 func NewGRPCSyncClientBin(
-	l logger.Logger, c usersdatapb.UsersDataClient,
+	l logger.Logger, c usersdatapb.UsersDataClient, origin string,
 ) ServerClient {
-	return &gRPCSyncClient{logger: l, client: c, entity: "binaries"}
+	return &gRPCSyncClient{
+		logger: l, client: c, entity: "binaries", origin: origin,
+	}
 }
 
 func NewGRPCSyncClientText(
-	l logger.Logger, c usersdatapb.UsersDataClient,
+	l logger.Logger, c usersdatapb.UsersDataClient, origin string,
 ) ServerClient {
-	return &gRPCSyncClient{logger: l, client: c, entity: "texts"}
+	return &gRPCSyncClient{
+		logger: l, client: c, entity: "texts", origin: origin,
+	}
+}
+
+func (c *gRPCSyncClient) Entity() string {
+	return c.entity
 }
 
 func (c *gRPCSyncClient) SetToken(token string) {
 	c.token = token
 }
 
+// GetComparable returns the page of the comparable objects after the ID,
+// the cursor of the server changes made after them and the ID to request
+// the next page after, it is zero for the last page.
 func (c *gRPCSyncClient) GetComparable(
-	ctx context.Context,
-) ([]model.SyncComparable, error) {
+	ctx context.Context, after int64, limit int,
+) ([]model.SyncComparable, int64, int64, error) {
 	const op = "gRPCSyncClient.GetComparable"
 	log := c.logger.With().Str("op", op).Str("intity", c.entity).Logger()
 
-	log.Debug().Str("token", c.token).Str("entity", c.entity).Msg("start request")
+	log.Debug().Str("entity", c.entity).Msg("start request")
 
-	req := &usersdatapb.GetComparableRequest{Token: c.token, Entity: c.entity}
+	req := &usersdatapb.GetComparableRequest{
+		Token:  c.token,
+		Entity: c.entity,
+		After:  after,
+		Limit:  int64(limit),
+	}
 	res, err := c.client.GetComparable(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get comparable objects")
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, 0, 0, fmt.Errorf("%s: %w", op, statusErr(err))
 	}
 
-	return c.pbToSyncComprable(res.Data), nil
+	return c.pbToSyncComprable(res.Data), res.Cursor, res.Next, nil
 }
 
+// Reconcile sends the local hashes of the ranges and returns the diverging
+// ranges with the server hashes, the comparable objects of the resolved
+// ranges and the cursor, see merkle.Tree.
+func (c *gRPCSyncClient) Reconcile(
+	ctx context.Context, ranges []merkle.Range,
+) ([]merkle.Range, []model.SyncComparable, int64, error) {
+	const op = "gRPCSyncClient.Reconcile"
+	log := c.logger.With().Str("op", op).Str("intity", c.entity).Logger()
+
+	req := &usersdatapb.ReconcileRequest{
+		Token:  c.token,
+		Entity: c.entity,
+		Ranges: make([]*usersdatapb.Range, 0, len(ranges)),
+	}
+	for _, r := range ranges {
+		req.Ranges = append(req.Ranges, &usersdatapb.Range{
+			Start: r.Start, End: r.End, Hash: r.Hash,
+		})
+	}
+	res, err := c.client.Reconcile(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to reconcile ranges")
+		return nil, nil, 0, fmt.Errorf("%s: %w", op, statusErr(err))
+	}
+
+	diverged := make([]merkle.Range, 0, len(res.Ranges))
+	for _, r := range res.Ranges {
+		diverged = append(diverged, merkle.Range{
+			Start: r.Start, End: r.End, Hash: r.Hash,
+		})
+	}
+	return diverged, c.pbToSyncComprable(res.Data), res.Cursor, nil
+}
+
+// GetChangesSince returns the page of the objects revised after the cursor,
+// the cursor of the page and the cursor to request the next page after,
+// it is zero for the last page.
+func (c *gRPCSyncClient) GetChangesSince(
+	ctx context.Context, cursor int64, limit int,
+) ([]model.SyncPayload, int64, int64, error) {
+	const op = "gRPCSyncClient.GetChangesSince"
+	log := c.logger.With().Str("op", op).Str("intity", c.entity).Logger()
+	req := &usersdatapb.GetChangesSinceRequest{
+		Token:  c.token,
+		Entity: c.entity,
+		Cursor: cursor,
+		Limit:  int64(limit),
+	}
+	res, err := c.client.GetChangesSince(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get changes")
+		return nil, 0, 0, fmt.Errorf("%s: %w", op, statusErr(err))
+	}
+
+	return c.pbToSyncPayload(res.Data), res.Cursor, res.Next, nil
+}
+
+// GetAll returns the page of the objects after the ID and the ID to request
+// the next page after, it is zero for the last page.
 func (c *gRPCSyncClient) GetAll(
-	ctx context.Context,
-) ([]model.SyncPayload, error) {
+	ctx context.Context, after int64, limit int,
+) ([]model.SyncPayload, int64, error) {
 	const op = "gRPCSyncClient.GetAll"
 	log := c.logger.With().Str("op", op).Str("intity", c.entity).Logger()
-	req := &usersdatapb.GetAllRequest{Token: c.token, Entity: c.entity}
+	req := &usersdatapb.GetAllRequest{
+		Token:  c.token,
+		Entity: c.entity,
+		After:  after,
+		Limit:  int64(limit),
+	}
 	res, err := c.client.GetAll(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get all objects")
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, 0, fmt.Errorf("%s: %w", op, statusErr(err))
 	}
 
-	return c.pbToSyncPayload(res.Data), nil
+	return c.pbToSyncPayload(res.Data), res.Next, nil
 }
 
 func (c *gRPCSyncClient) GetSliceByIDs(
@@ -92,46 +202,416 @@ func (c *gRPCSyncClient) GetSliceByIDs(
 	res, err := c.client.GetSlice(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get slice of objects")
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, statusErr(err))
 	}
 
 	return c.pbToSyncPayload(res.Data), nil
 }
 
+// UpdateSliceByIDs returns the revisions of the updated objects, the objects
+// revised since the payload Revision are rejected by the server.
 func (c *gRPCSyncClient) UpdateSliceByIDs(
 	ctx context.Context, data []model.SyncPayload,
-) error {
+) ([]model.SyncRevision, error) {
 	const op = "gRPCSyncClient.UpdateSliceByIDs"
 	log := c.logger.With().Str("op", op).Str("intity", c.entity).Logger()
 	req := &usersdatapb.UpdateSliceRequest{
 		Token:  c.token,
 		Entity: c.entity,
 		Data:   c.syncToPBPayload(data),
+		Origin: c.origin,
 	}
-	_, err := c.client.UpdateSlice(ctx, req)
+	if err := c.offload(ctx, req.Data, data); err != nil {
+		log.Error().Err(err).Msg("failed to upload objects data")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := c.client.UpdateSlice(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to update slice of objects")
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, statusErr(err))
 	}
-	return nil
+	return c.pbToSyncRevision(res.Revisions), nil
 }
 
 func (c *gRPCSyncClient) InsertSlice(
 	ctx context.Context, data []model.LocalPayload,
-) ([]int64, error) {
+) ([]model.SyncRevision, error) {
 	const op = "gRPCSyncClient.InsertSlice"
 	log := c.logger.With().Str("op", op).Str("intity", c.entity).Logger()
 	req := &usersdatapb.InsertSliceRequest{
 		Token:  c.token,
 		Entity: c.entity,
 		Data:   c.localToPBPayload(data),
+		Origin: c.origin,
+	}
+	payloads := make([]model.SyncPayload, 0, len(data))
+	for _, o := range data {
+		payloads = append(payloads, o.SyncPayload)
+	}
+	if err := c.offload(ctx, req.Data, payloads); err != nil {
+		log.Error().Err(err).Msg("failed to upload objects data")
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	res, err := c.client.InsertSlice(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to insert sclice of objects")
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, statusErr(err))
+	}
+	return c.pbToSyncRevision(res.Revisions), nil
+}
+
+// offload uploads the data of the payloads larger than streamThreshold,
+// the payloads refer to the uploads by the data hash. The payloads are
+// sent with the data, the data of the pbData payload is the data of
+// the same payload.
+func (c *gRPCSyncClient) offload(
+	ctx context.Context,
+	pbData []*usersdatapb.Payload,
+	data []model.SyncPayload,
+) error {
+	for i, o := range pbData {
+		src := &data[i]
+		size := src.DataSize()
+		if size <= streamThreshold {
+			if src.Stream == nil {
+				continue
+			}
+			b, err := readData(ctx, src)
+			if err != nil {
+				return err
+			}
+			o.Data = b
+			continue
+		}
+		hash, err := c.upload(ctx, src)
+		if err != nil {
+			return err
+		}
+		o.DataHash, o.DataSize, o.Data = hash, size, nil
 	}
-	return res.IDs, nil
+	return nil
+}
+
+// upload sends the data in chunks and returns its hash. The interrupted
+// upload is resumed from the offset the server received.
+func (c *gRPCSyncClient) upload(
+	ctx context.Context, o *model.SyncPayload,
+) (string, error) {
+	const op = "gRPCSyncClient.upload"
+	log := c.logger.With().Str("op", op).Str("intity", c.entity).Logger()
+
+	hash, err := sumData(ctx, o)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to hash data")
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	for range transferAttempts {
+		if err = c.uploadFrom(ctx, hash, o); err == nil {
+			return hash, nil
+		}
+		if ctx.Err() != nil || !resumable(err) {
+			break
+		}
+		log.Debug().Err(err).Str("hash", hash).Msg("resume upload")
+	}
+	return "", fmt.Errorf("%s: %w", op, statusErr(err))
+}
+
+func (c *gRPCSyncClient) uploadFrom(
+	ctx context.Context, hash string, o *model.SyncPayload,
+) error {
+	size := o.DataSize()
+	res, err := c.client.GetUploadOffset(ctx,
+		&usersdatapb.GetUploadOffsetRequest{Token: c.token, Hash: hash},
+	)
+	if err != nil {
+		return err
+	}
+	if res.Offset == size {
+		return nil
+	}
+
+	r, err := o.OpenData(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if _, err := io.CopyN(io.Discard, r, res.Offset); err != nil {
+		return err
+	}
+
+	// the upload is canceled if the data is not read to the end
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.client.Upload(ctx)
+	if err != nil {
+		return err
+	}
+	for pos := res.Offset; pos < size; {
+		// the sent chunk may be encoded after Send returns,
+		// so its data is not reused
+		data := make([]byte, min(uploadChunkSize, size-pos))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		chunk := &usersdatapb.UploadChunk{
+			Hash:   hash,
+			Size:   size,
+			Offset: pos,
+			Data:   data,
+		}
+		if pos == res.Offset {
+			chunk.Token = c.token
+		}
+		if err := stream.Send(chunk); err != nil {
+			// the stream is finished by the server, its status
+			// is returned by CloseAndRecv
+			break
+		}
+		pos += int64(len(data))
+	}
+
+	closeRes, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	if closeRes.Offset != size {
+		return ErrTransferIncomplete
+	}
+	return nil
+}
+
+// readData returns the object data sent inline.
+func readData(ctx context.Context, o *model.SyncPayload) ([]byte, error) {
+	r, err := o.OpenData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// sumData returns the hash of the object data read chunk by chunk.
+func sumData(ctx context.Context, o *model.SyncPayload) (string, error) {
+	r, err := o.OpenData(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	digest := hasher.NewDigest()
+	if _, err := io.Copy(digest, r); err != nil {
+		return "", err
+	}
+	return digest.Sum(), nil
+}
+
+// downloadStream is the object data the server sent by the hash,
+// it is downloaded in chunks while it is read.
+type downloadStream struct {
+	c    *gRPCSyncClient
+	id   int64
+	hash string
+	size int64
+}
+
+func (s *downloadStream) Size() int64 {
+	return s.size
+}
+
+func (s *downloadStream) Open(ctx context.Context) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &downloadReader{
+		ctx: ctx, cancel: cancel, s: s, digest: hasher.NewDigest(),
+	}, nil
+}
+
+// downloadReader verifies the hash of the data at the end of it.
+// The interrupted download is resumed from the read offset.
+type downloadReader struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	s        *downloadStream
+	stream   grpc.ServerStreamingClient[usersdatapb.DownloadChunk]
+	digest   *hasher.Digest
+	offset   int64
+	attempts int
+	buf      []byte
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	const op = "gRPCSyncClient.download"
+
+	for len(r.buf) == 0 {
+		chunk, err := r.recv()
+		if errors.Is(err, io.EOF) {
+			if r.offset != r.s.size || r.digest.Sum() != r.s.hash {
+				return 0, fmt.Errorf("%s: %w", op, ErrTransferHash)
+			}
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, statusErr(err))
+		}
+		r.digest.Write(chunk.Data)
+		r.offset += int64(len(chunk.Data))
+		r.buf = chunk.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *downloadReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// recv returns the next chunk of the data, the broken download is resumed
+// up to transferAttempts times.
+func (r *downloadReader) recv() (*usersdatapb.DownloadChunk, error) {
+	c := r.s.c
+	for {
+		chunk, err := r.recvFrom()
+		if err == nil || errors.Is(err, io.EOF) {
+			return chunk, err
+		}
+		r.stream = nil
+		r.attempts++
+		if r.ctx.Err() != nil || !resumable(err) ||
+			r.attempts == transferAttempts {
+			return nil, err
+		}
+		c.logger.Debug().Err(err).Str("intity", c.entity).Int64(
+			"id", r.s.id).Msg("resume download")
+	}
+}
+
+func (r *downloadReader) recvFrom() (*usersdatapb.DownloadChunk, error) {
+	c := r.s.c
+	if r.stream == nil {
+		stream, err := c.client.Download(r.ctx, &usersdatapb.DownloadRequest{
+			Token:  c.token,
+			Entity: c.entity,
+			ID:     r.s.id,
+			Hash:   r.s.hash,
+			Offset: r.offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.stream = stream
+	}
+	chunk, err := r.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if chunk.Offset != r.offset {
+		return nil, ErrTransferIncomplete
+	}
+	return chunk, nil
+}
+
+// resumable reports whether the interrupted transfer is continued,
+// the server is unreachable or the upload offset is outdated.
+func resumable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.FailedPrecondition:
+		return true
+	}
+	return false
+}
+
+type gRPCSubscriber struct {
+	logger logger.Logger
+	client usersdatapb.UsersDataClient
+	origin string
+}
+
+// NewGRPCSubscriber returns the subscriber to the changes made by the
+// clients other than origin.
+func NewGRPCSubscriber(
+	l logger.Logger, c usersdatapb.UsersDataClient, origin string,
+) ChangeSubscriber {
+	return &gRPCSubscriber{logger: l, client: c, origin: origin}
+}
+
+// Subscribe returns the entities changed on the server, the channel
+// is closed when the stream drops or the context is done.
+func (s *gRPCSubscriber) Subscribe(
+	ctx context.Context, token string,
+) (<-chan string, error) {
+	const op = "gRPCSubscriber.Subscribe"
+	log := s.logger.WithOp(op)
+
+	req := &usersdatapb.SubscribeRequest{Token: token, Origin: s.origin}
+	stream, err := s.client.Subscribe(ctx, req)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to subscribe")
+		return nil, fmt.Errorf("%s: %w", op, statusErr(err))
+	}
+
+	entities := make(chan string)
+	go func() {
+		defer close(entities)
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				log.Debug().Err(statusErr(err)).Msg("stream closed")
+				return
+			}
+			select {
+			case entities <- ev.Entity:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return entities, nil
+}
+
+type gRPCIndexSaltClient struct {
+	logger logger.Logger
+	client usersdatapb.UsersDataClient
+}
+
+// NewGRPCIndexSaltClient returns the client of the index salt
+// of the account.
+func NewGRPCIndexSaltClient(
+	l logger.Logger, c usersdatapb.UsersDataClient,
+) IndexSaltClient {
+	return &gRPCIndexSaltClient{l, c}
+}
+
+// GetIndexSalt returns the salt of the blind index key of the account,
+// it is nil for the legacy salt.
+func (c *gRPCIndexSaltClient) GetIndexSalt(
+	ctx context.Context, token string,
+) ([]byte, error) {
+	const op = "gRPCIndexSaltClient.GetIndexSalt"
+	log := c.logger.WithOp(op)
+
+	req := &usersdatapb.GetIndexSaltRequest{Token: token}
+	res, err := c.client.GetIndexSalt(ctx, req)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get index salt")
+		return nil, fmt.Errorf("%s: %w", op, statusErr(err))
+	}
+	if len(res.Salt) == 0 {
+		return nil, nil
+	}
+	return res.Salt, nil
+}
+
+// statusErr returns ErrUnauthenticated if the server rejects the token
+// and ErrServerUnavailable if the server is not reachable.
+func statusErr(err error) error {
+	switch status.Code(err) {
+	case codes.Unauthenticated:
+		return ErrUnauthenticated
+	case codes.Unavailable:
+		return fmt.Errorf("%w: %w", ErrServerUnavailable, err)
+	}
+	return err
 }
 
 func (c *gRPCSyncClient) pbToSyncComprable(
@@ -141,14 +621,20 @@ func (c *gRPCSyncClient) pbToSyncComprable(
 	for _, o := range data {
 		cvt := model.SyncComparable{
 			ID:        o.ID,
-			Name:      o.Name,
-			UpdatedAt: time.UnixMilli(o.UpdatedAt),
+			NameIndex: o.NameIndex,
+			Revision:  o.Revision,
+			Clock:     hlc.Timestamp(o.Clock),
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
 		}
 		s = append(s, cvt)
 	}
 	return s
 }
 
+// pbToSyncPayload returns the payloads, the data sent by the hash is
+// downloaded when the payload stream is read.
 func (c *gRPCSyncClient) pbToSyncPayload(
 	data []*usersdatapb.Payload,
 ) []model.SyncPayload {
@@ -156,11 +642,21 @@ func (c *gRPCSyncClient) pbToSyncPayload(
 	for _, o := range data {
 		cvt := model.SyncPayload{
 			ID:        o.ID,
+			NameIndex: o.NameIndex,
 			Name:      o.Name,
 			Data:      o.Data,
 			CreatedAt: time.UnixMilli(o.CreatedAt),
-			UpdatedAt: time.UnixMilli(o.UpdatedAt),
+			Revision:  o.Revision,
+			Clock:     hlc.Timestamp(o.Clock),
 			Deleted:   o.Deleted,
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
+		}
+		if o.DataHash != "" && len(o.Data) == 0 {
+			cvt.Stream = &downloadStream{
+				c: c, id: o.ID, hash: o.DataHash, size: o.DataSize,
+			}
 		}
 		s = append(s, cvt)
 	}
@@ -174,11 +670,16 @@ func (c *gRPCSyncClient) syncToPBPayload(
 	for _, o := range data {
 		cvt := &usersdatapb.Payload{
 			ID:        o.ID,
+			NameIndex: o.NameIndex,
 			Name:      o.Name,
 			Data:      o.Data,
 			CreatedAt: o.CreatedAt.UnixMilli(),
-			UpdatedAt: o.UpdatedAt.UnixMilli(),
+			Revision:  o.Revision,
+			Clock:     int64(o.Clock),
 			Deleted:   o.Deleted,
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
 		}
 		s = append(s, cvt)
 	}
@@ -190,13 +691,30 @@ func (c *gRPCSyncClient) localToPBPayload(data []model.LocalPayload) []*usersdat
 	for _, o := range data {
 		cvt := &usersdatapb.Payload{
 			ID:        o.SyncID,
+			NameIndex: o.NameIndex,
 			Name:      o.Name,
 			Data:      o.Data,
 			CreatedAt: o.CreatedAt.UnixMilli(),
-			UpdatedAt: o.UpdatedAt.UnixMilli(),
+			Revision:  o.Revision,
+			Clock:     int64(o.Clock),
 			Deleted:   o.Deleted,
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
 		}
 		s = append(s, cvt)
 	}
 	return s
 }
+
+func (c *gRPCSyncClient) pbToSyncRevision(
+	data []*usersdatapb.Revision,
+) []model.SyncRevision {
+	s := make([]model.SyncRevision, 0, len(data))
+	for _, o := range data {
+		s = append(s, model.SyncRevision{
+			ID: o.ID, Revision: o.Revision, Clock: hlc.Timestamp(o.Clock),
+		})
+	}
+	return s
+}
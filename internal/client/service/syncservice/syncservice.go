@@ -2,360 +2,920 @@ package syncservice
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/niksmo/gophkeeper/internal/client/dto"
 	"github.com/niksmo/gophkeeper/internal/client/repository"
-
+	"github.com/niksmo/gophkeeper/pkg/backoff"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/sockrpc"
 )
 
 var (
-	ErrNoSync      = errors.New("not sync")
-	ErrPIDConflict = errors.New("PID conflict")
+	ErrNoSync          = errors.New("not sync")
+	ErrAlreadyRunning  = errors.New("synchronization is already running")
+	ErrUnauthenticated = errors.New("sync session is not authenticated")
+
+	ErrServerUnavailable = errors.New("sync server is unavailable")
+	ErrBreakerOpen       = errors.New("sync breaker is open")
 )
 
 type (
 	SyncRepo interface {
 		Create(ctx context.Context,
 			pid int, startedAt time.Time) (dto.Sync, error)
-		ReadLast(context.Context) (dto.Sync, error)
 		Update(context.Context, dto.Sync) error
+		SyncResultRepo
+	}
+
+	SyncResultRepo interface {
+		SaveSynced(ctx context.Context, entity string, syncedAt time.Time) error
+		SaveError(ctx context.Context, entity string, msg string) error
+	}
+
+	TokenLoader interface {
+		Load(ctx context.Context, key string) (string, error)
+	}
+
+	SyncStateRepo interface {
+		ReadStates(context.Context) ([]dto.SyncState, error)
+	}
+
+	SessionReader interface {
+		Read(context.Context) (dto.Session, error)
+	}
+
+	SessionRepo interface {
+		SetExpired(context.Context) error
+		Delete(context.Context) error
 	}
 )
 
 type SyncRunner struct {
 	logger logger.Logger
-	repo   SyncRepo
+	daemon *DaemonClient
 }
 
-func NewSyncExecuter(logger logger.Logger, repo SyncRepo) *SyncRunner {
-	return &SyncRunner{logger, repo}
+func NewSyncExecuter(logger logger.Logger, daemon *DaemonClient) *SyncRunner {
+	return &SyncRunner{logger, daemon}
 }
 
+// ExecSynchronization starts the sync daemon and waits until
+// its control socket is ready. The daemon stopped on the expired session
+// is resumed with the token.
 func (s *SyncRunner) ExecSynchronization(
 	ctx context.Context, token string,
 ) error {
 	const op = "SyncRunner.ExecSynchronization"
 
-	syncEntry, err := s.getEntry(ctx)
-	if err != nil {
-		return s.error(op, err)
-	}
+	log := s.logger.WithOp(op)
 
-	if s.notStopped(syncEntry) {
-		if err = s.verifyAndFix(ctx, syncEntry); err != nil {
-			return s.error(op, err)
+	if status, err := s.daemon.Status(ctx); err == nil {
+		if status.State == StateExpired {
+			log.Debug().Msg("pass new token to expired synchronization")
+			if err := s.daemon.SetToken(ctx, token); err != nil {
+				return s.error(op, err)
+			}
+			return nil
 		}
+		log.Debug().Msg("synchronization already started")
+		return s.error(op, ErrAlreadyRunning)
+	}
+
+	p, err := s.execCommand(token)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to exec command")
+		return s.error(op, err)
 	}
 
-	if err := s.execCommand(ctx, token); err != nil {
+	err = waitDaemon(ctx, s.daemon, func(err error) bool { return err == nil })
+	if err != nil {
+		log.Debug().Err(err).Msg("sync daemon is not ready")
+		p.Kill()
 		return s.error(op, err)
 	}
+	p.Release()
 
+	log.Debug().Int("PID", p.Pid).Msg("synchronization started")
 	return nil
 }
 
-func (s *SyncRunner) getEntry(ctx context.Context) (dto.Sync, error) {
-	const op = "SyncRunner.getEntry"
-	log := s.logger.WithOp(op)
+// execCommand starts the sync process, the token is passed through
+// the stdin pipe, so it is not visible in the process arguments.
+func (s *SyncRunner) execCommand(token string) (*os.Process, error) {
+	cmd := exec.Command(os.Args[0], "sync", "start", "--token-file", "-")
+	cmd.Env = os.Environ()
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
 
-	syncEntry, err := s.repo.ReadLast(ctx)
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		if errors.Is(err, repository.ErrNotExists) {
-			log.Debug().Msg("no sync yet")
-			stoppedAt := time.Now()
-			return dto.Sync{StoppedAt: &stoppedAt}, nil
-		}
-		log.Debug().Err(err).Msg("failed to read sync entry")
-		return dto.Sync{}, err
+		return nil, err
 	}
-	return syncEntry, nil
-}
-
-func (s *SyncRunner) execCommand(ctx context.Context, token string) error {
-	const op = "SyncRunner.execCommand"
-
-	log := s.logger.WithOp(op)
-
-	cmd := exec.Command(os.Args[0], "sync", "start", "-t", token)
-	cmd.Env = os.Environ()
 	if err := cmd.Start(); err != nil {
-		log.Debug().Err(err).Msg("failed to exec command")
-		return err
+		return nil, err
 	}
 
-	log.Debug().Str("token", token).Msg("exec sync start")
-
-	pid := cmd.Process.Pid
-	if _, err := s.repo.Create(ctx, pid, time.Now()); err != nil {
-		log.Debug().Err(err).Msg("failed to create synchronization entry")
-		cmd.Process.Signal(syscall.Signal(0))
-		return err
+	_, err = io.WriteString(stdin, token+"\n")
+	if closeErr := stdin.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, err
 	}
+	return cmd.Process, nil
+}
 
-	log.Debug().Int("PID", pid).Msg("synchronization started")
-	return nil
+func (s *SyncRunner) error(op string, err error) error {
+	return fmt.Errorf("%s: %w", op, err)
 }
 
-func (s *SyncRunner) notStopped(syncDTO dto.Sync) bool {
-	return syncDTO.StoppedAt == nil
+type SyncCloser struct {
+	logger   logger.Logger
+	daemon   *DaemonClient
+	sessions SessionRepo
 }
 
-func (s *SyncRunner) verifyAndFix(
-	ctx context.Context, lastSync dto.Sync,
-) error {
-	const op = "SyncRunner.verifyAndFix"
+func NewSyncCloser(
+	logger logger.Logger, daemon *DaemonClient, sessions SessionRepo,
+) *SyncCloser {
+	return &SyncCloser{logger, daemon, sessions}
+}
 
-	log := s.logger.WithOp(op)
+// CloseSynchronization forgets the session and stops the sync daemon,
+// ErrNoSync is returned after the session is deleted if the daemon is
+// not running.
+func (c *SyncCloser) CloseSynchronization(ctx context.Context) error {
+	const op = "SyncCloser.CloseSync"
 
-	if s.syncProcessWorks(lastSync.PID) {
-		log.Debug().Int("PID", lastSync.PID).Msg(
-			"synchronization already started",
-		)
-		return ErrPIDConflict
+	log := c.logger.WithOp(op)
+
+	if err := c.sessions.Delete(ctx); err != nil {
+		return c.error(op, err)
 	}
 
-	stoppedAt := time.Now()
-	lastSync.StoppedAt = &stoppedAt
-	if err := s.repo.Update(ctx, lastSync); err != nil {
-		log.Debug().Err(err).Msg("failed update last sync entry")
-		return err
+	if err := c.daemon.Stop(ctx); err != nil {
+		log.Debug().Err(err).Msg("failed to stop sync daemon")
+		return c.error(op, err)
 	}
 
-	return nil
-}
+	err := waitDaemon(ctx, c.daemon, func(err error) bool {
+		return errors.Is(err, ErrNoSync)
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("sync daemon is not stopped")
+		return c.error(op, err)
+	}
 
-func (s *SyncRunner) syncProcessWorks(pid int) bool {
-	p, err := os.FindProcess(pid)
-	return err == nil || p.Signal(syscall.Signal(0)) == nil
+	log.Debug().Msg("sync daemon stopped")
+	return nil
 }
 
-func (s *SyncRunner) error(op string, err error) error {
+func (c *SyncCloser) error(op string, err error) error {
 	return fmt.Errorf("%s: %w", op, err)
 }
 
-type SyncCloser struct {
+type SyncPauser struct {
 	logger logger.Logger
-	repo   SyncRepo
+	daemon *DaemonClient
 }
 
-func NewSyncCloser(logger logger.Logger, repo SyncRepo) *SyncCloser {
-	return &SyncCloser{logger, repo}
+func NewSyncPauser(logger logger.Logger, daemon *DaemonClient) *SyncPauser {
+	return &SyncPauser{logger, daemon}
 }
 
-func (c *SyncCloser) CloseSynchronization(ctx context.Context) error {
-	const op = "SyncCloser.CloseSync"
+// PauseSynchronization skips the next ticks until the daemon is resumed.
+func (p *SyncPauser) PauseSynchronization(ctx context.Context) error {
+	const op = "SyncPauser.PauseSynchronization"
 
-	syncEntry, err := c.getSyncEntry(ctx)
-	if err != nil {
-		return c.error(op, err)
+	if err := p.daemon.Pause(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	return nil
+}
 
-	p, ok := c.getSyncProcess(syncEntry.PID)
-	if !ok {
-		c.setStopped(ctx, syncEntry)
-		return c.error(op, ErrNoSync)
-	}
+// SyncStatus is the state of the synchronization.
+type SyncStatus struct {
+	DaemonStatus
+	ServerAddr     string
+	Login          string
+	SessionExpired bool
+	Entities       []dto.SyncState
+}
 
-	if err := c.interruptProcess(p); err != nil {
-		return c.error(op, err)
-	}
+type StatusReader struct {
+	logger     logger.Logger
+	daemon     *DaemonClient
+	repo       SyncStateRepo
+	sessions   SessionReader
+	serverAddr string
+}
 
-	return nil
+func NewStatusReader(
+	logger logger.Logger,
+	daemon *DaemonClient,
+	repo SyncStateRepo,
+	sessions SessionReader,
+	serverAddr string,
+) *StatusReader {
+	return &StatusReader{logger, daemon, repo, sessions, serverAddr}
 }
 
-func (c *SyncCloser) getSyncEntry(ctx context.Context) (dto.Sync, error) {
-	const op = "SyncCloser.getSyncEntry"
+// Status returns the daemon state, the signed in account and the result
+// of the last synchronization of every entity.
+func (r *StatusReader) Status(ctx context.Context) (SyncStatus, error) {
+	const op = "StatusReader.Status"
+	log := r.logger.WithOp(op)
+
+	status := SyncStatus{ServerAddr: r.serverAddr}
+
+	daemonStatus, err := r.daemon.Status(ctx)
+	switch {
+	case errors.Is(err, ErrNoSync):
+		daemonStatus.State = StateStopped
+	case err != nil:
+		log.Debug().Err(err).Msg("failed to get daemon status")
+		return SyncStatus{}, fmt.Errorf("%s: %w", op, err)
+	}
+	status.DaemonStatus = daemonStatus
 
-	log := c.logger.WithOp(op)
+	session, err := r.sessions.Read(ctx)
+	if err != nil && !errors.Is(err, repository.ErrNotExists) {
+		return SyncStatus{}, fmt.Errorf("%s: %w", op, err)
+	}
+	status.Login = session.Login
+	status.SessionExpired = session.Expired
 
-	syncEntry, err := c.repo.ReadLast(ctx)
+	status.Entities, err = r.repo.ReadStates(ctx)
 	if err != nil {
-		if errors.Is(err, repository.ErrNotExists) {
-			log.Debug().Msg("no sync yet")
-			return dto.Sync{}, ErrNoSync
-		}
-		log.Debug().Err(err).Msg("failed to read last sync entry")
-		return dto.Sync{}, err
+		return SyncStatus{}, fmt.Errorf("%s: %w", op, err)
 	}
+	return status, nil
+}
 
-	if c.syncStopped(syncEntry) {
-		log.Debug().Msg("no sync")
-		return dto.Sync{}, ErrNoSync
-	}
+// SyncResult is the result of the foreground synchronization of the entity.
+type SyncResult struct {
+	dto.SyncPlan
+	Err error
+}
 
-	return syncEntry, nil
+type OneShotSyncer struct {
+	logger   logger.Logger
+	sessions TokenLoader
+	daemon   *DaemonClient
+	repo     SyncResultRepo
+	workers  []SyncWorker
 }
 
-func (c *SyncCloser) syncStopped(syncEntry dto.Sync) bool {
-	return syncEntry.StoppedAt != nil
+func NewOneShotSyncer(
+	logger logger.Logger,
+	sessions TokenLoader,
+	daemon *DaemonClient,
+	repo SyncResultRepo,
+	workers []SyncWorker,
+) *OneShotSyncer {
+	return &OneShotSyncer{logger, sessions, daemon, repo, workers}
 }
 
-func (c *SyncCloser) getSyncProcess(pid int) (*os.Process, bool) {
-	const op = "SyncCloser.getSyncProcess"
-	log := c.logger.WithOp(op)
+// SyncNow synchronizes every entity in the foreground with the saved
+// session, the running daemon is paused until the synchronization is done.
+// The dry run returns the plans without changing the data.
+func (s *OneShotSyncer) SyncNow(
+	ctx context.Context, key string, dryRun bool,
+) ([]SyncResult, error) {
+	const op = "OneShotSyncer.SyncNow"
+	log := s.logger.WithOp(op)
 
-	p, err := os.FindProcess(pid)
-	if err != nil || p.Signal(syscall.Signal(0)) != nil {
-		log.Debug().Err(err).Msg("sync process not found")
-		return nil, false
+	token, err := s.sessions.Load(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return p, true
-}
+	if !dryRun {
+		resume, err := s.pauseDaemon(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		defer resume()
+	}
 
-func (c *SyncCloser) setStopped(ctx context.Context, syncEntry dto.Sync) {
-	const op = "SyncCloser.setStopped"
-	log := c.logger.WithOp(op)
-	tn := time.Now()
-	syncEntry.StoppedAt = &tn
-	if err := c.repo.Update(ctx, syncEntry); err != nil {
-		log.Debug().Err(err).Msg("failed to update sync entry")
+	results := make([]SyncResult, 0, len(s.workers))
+	for _, w := range s.workers {
+		var (
+			plan dto.SyncPlan
+			err  error
+		)
+		startedAt := time.Now()
+		if dryRun {
+			plan, err = w.Plan(ctx, token)
+		} else {
+			plan, err = w.Sync(ctx, token)
+			saveResult(ctx, log, s.repo, w.Entity(), startedAt, err)
+		}
+		plan.Entity = w.Entity()
+		results = append(results, SyncResult{plan, err})
 	}
+	return results, nil
 }
 
-func (c *SyncCloser) interruptProcess(p *os.Process) error {
-	const op = "SyncCloser.interruptProcess"
-	log := c.logger.WithOp(op).With().Int("PID", p.Pid).Logger()
-	err := p.Signal(syscall.SIGINT)
+// pauseDaemon pauses the running daemon, so it does not synchronize
+// the same data at the same time. The returned func resumes the daemon.
+func (s *OneShotSyncer) pauseDaemon(ctx context.Context) (func(), error) {
+	log := s.logger.WithOp("OneShotSyncer.pauseDaemon")
+
+	status, err := s.daemon.Status(ctx)
+	if errors.Is(err, ErrNoSync) ||
+		status.State == StatePaused || status.State == StateExpired {
+		return func() {}, nil
+	}
 	if err != nil {
-		log.Debug().Err(err).Msg("failed to interrupt process")
-		return err
+		return nil, err
 	}
-	log.Debug().Msg("sync process interrupted")
-	return nil
-}
 
-func (c *SyncCloser) error(op string, err error) error {
-	return fmt.Errorf("%s: %w", op, err)
+	if err := s.daemon.Pause(ctx); err != nil {
+		return nil, err
+	}
+	log.Debug().Msg("sync daemon paused")
+
+	return func() {
+		if err := s.daemon.Resume(context.Background()); err != nil {
+			log.Error().Err(err).Msg("failed to resume sync daemon")
+		}
+	}, nil
 }
 
 type SyncWorker interface {
-	DoJob(ctx context.Context, token string)
+	Entity() string
+	DoJob(ctx context.Context, token string) error
+	Sync(ctx context.Context, token string) (dto.SyncPlan, error)
+	Plan(ctx context.Context, token string) (dto.SyncPlan, error)
+}
+
+type ChangeSubscriber interface {
+	Subscribe(ctx context.Context, token string) (<-chan string, error)
 }
 
+type IndexSaltClient interface {
+	GetIndexSalt(ctx context.Context, token string) ([]byte, error)
+}
+
+const (
+	// jobTimeout bounds the synchronization of all the entities.
+	jobTimeout = 5 * time.Minute
+
+	// retryAttempts is the number of the attempts to synchronize
+	// the entity while the server is unavailable, the attempts are done
+	// within the minimal period.
+	retryAttempts = 3
+
+	// breakerThreshold is the number of the jobs failed in a row on the
+	// unavailable server after which the ticks are delayed by the breaker.
+	breakerThreshold = 3
+)
+
+// Interval is the range of the period between the synchronization ticks,
+// the period is Min while the data is transferred and doubles up to Max
+// while the data is idle.
+type Interval struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// next returns the period after the job.
+func (i Interval) next(period time.Duration, transferred bool) time.Duration {
+	if transferred {
+		return i.Min
+	}
+	return min(max(period*2, i.Min), max(i.Max, i.Min))
+}
+
+// SyncWorkerPool is the sync daemon, it synchronizes the data on the server
+// change events and every tick, and serves the control socket. The job
+// synchronizes every entity and the next job starts when the workers
+// are done. The circuit breaker delays the jobs of the ticks, the events
+// and the requests while the server is unavailable.
 type SyncWorkerPool struct {
 	logger      logger.Logger
 	repo        SyncRepo
+	sessions    SessionRepo
+	subscriber  ChangeSubscriber
 	wPool       []SyncWorker
-	tick        time.Duration
+	interval    Interval
+	period      time.Duration
+	retry       backoff.Backoff
+	breaker     *backoff.Breaker
+	socket      string
 	cancelJobFn context.CancelFunc
+	expired     chan struct{}
+	syncNow     chan struct{}
+	changed     chan struct{}
+	tokenSet    chan struct{}
+
+	mu     sync.Mutex
+	status DaemonStatus
+	token  string
 }
 
 func NewWorkerPool(
-	l logger.Logger, r SyncRepo, wP []SyncWorker, t time.Duration,
+	l logger.Logger,
+	r SyncRepo,
+	sessions SessionRepo,
+	subscriber ChangeSubscriber,
+	wP []SyncWorker,
+	interval Interval,
+	socket string,
 ) *SyncWorkerPool {
-	return &SyncWorkerPool{logger: l, repo: r, wPool: wP, tick: t}
+	cooldown := backoff.Backoff{Base: 2 * interval.Min, Max: interval.Max}
+	return &SyncWorkerPool{
+		logger:     l,
+		repo:       r,
+		sessions:   sessions,
+		subscriber: subscriber,
+		wPool:      wP,
+		interval:   interval,
+		period:     interval.Min,
+		retry:      backoff.Backoff{Base: interval.Min / 20, Max: interval.Min / 2},
+		breaker:    backoff.NewBreaker(breakerThreshold, cooldown),
+		socket:     socket,
+		expired:    make(chan struct{}, 1),
+		syncNow:    make(chan struct{}, 1),
+		changed:    make(chan struct{}, 1),
+		tokenSet:   make(chan struct{}, 1),
+	}
 }
 
-func (s *SyncWorkerPool) Run(ctx context.Context, token string) {
+// Run synchronizes the data every tick until the context is done or
+// the daemon is stopped. After the server rejects the session token
+// the daemon is expired and waits for the new token.
+func (s *SyncWorkerPool) Run(ctx context.Context, token string) error {
 	const op = "SyncWorkerPool.Run"
 	log := s.logger.WithOp(op)
 
-	log.Debug().Msg("run synchronization worker pool")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	srv := s.newControlServer(cancel)
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx)
+	}()
+
+	syncEntry, err := s.repo.Create(ctx, os.Getpid(), time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create sync entry")
+		cancel()
+		<-served
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.setStatus(DaemonStatus{
+		State:     StateRunning,
+		PID:       syncEntry.PID,
+		StartedAt: syncEntry.StartedAt,
+	})
+	s.setToken(token)
+	defer s.stop(syncEntry)
+
+	log.Debug().Str("socket", s.socket).Msg("run synchronization worker pool")
+
+	go s.watch(ctx)
 
-	ticker := time.NewTicker(s.tick)
-	defer ticker.Stop()
+	timer := time.NewTimer(s.period)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			if state := s.getStatus().State; state != StateRunning {
+				log.Debug().Str("state", state).Msg("skip tick")
+				timer.Reset(s.period)
+				continue
+			}
 			log.Debug().Msg("begin next synchronization tick")
-
-			s.doSync(ctx, token)
-
-		case <-ctx.Done():
+			timer.Reset(s.nextTick(s.doSync(ctx, s.getToken())))
+
+		case <-s.syncNow:
+			if s.getStatus().State == StateExpired {
+				log.Debug().Msg("the sync session is expired, skip sync")
+				continue
+			}
+			if !s.breaker.Allow(time.Now()) {
+				log.Debug().Msg("the breaker is open, skip sync")
+				continue
+			}
+			log.Debug().Msg("begin requested synchronization")
+			timer.Reset(s.nextTick(s.doSync(ctx, s.getToken())))
+
+		case <-s.changed:
+			if state := s.getStatus().State; state != StateRunning {
+				log.Debug().Str("state", state).Msg("skip change")
+				continue
+			}
+			if !s.breaker.Allow(time.Now()) {
+				log.Debug().Msg("the breaker is open, skip change")
+				continue
+			}
+			log.Debug().Msg("begin synchronization of server changes")
+			timer.Reset(s.nextTick(s.doSync(ctx, s.getToken())))
+
+		case <-s.expired:
+			log.Warn().Msg("the sync session is expired, sign in again")
+			s.intPrevJob()
+			s.setState(StateExpired)
+			if err := s.sessions.SetExpired(ctx); err != nil {
+				log.Error().Err(err).Msg("failed to expire session")
+			}
+
+		case err := <-served:
+			if err != nil {
+				log.Error().Err(err).Msg("failed to serve control socket")
+				s.intPrevJob()
+				return fmt.Errorf("%s: %w", op, err)
+			}
 			log.Debug().Str(
 				"ctxErr", ctx.Err().Error()).Msg("receive context done")
+			s.intPrevJob()
+			return nil
+		}
+	}
+}
 
-			s.stop()
+// watch notifies the pool about the server changes until the context
+// is done. The dropped stream is reopened with the current token after
+// the delay growing while the server is unavailable, the ticks keep
+// polling the server meanwhile. The token rejected by the server expires
+// the session and the stream is reopened once the new token is received.
+// The changes missed while the stream was dropped are synced after it
+// is reopened.
+func (s *SyncWorkerPool) watch(ctx context.Context) {
+	log := s.logger.WithOp("SyncWorkerPool.watch")
+
+	reopen := backoff.Backoff{Base: s.interval.Min, Max: s.interval.Max}
+	var (
+		reopened bool
+		failures int
+	)
+	for {
+		token := s.getToken()
+		entities, err := s.subscriber.Subscribe(ctx, token)
+		if errors.Is(err, ErrUnauthenticated) {
+			log.Debug().Msg("the session is rejected, wait for the new token")
+			s.setExpired()
+			if !s.waitToken(ctx, token) {
+				return
+			}
+			reopened, failures = true, 0
+			continue
+		}
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to subscribe to server changes")
+			failures++
+		} else {
+			if reopened {
+				s.notifyChanged()
+			}
+			for entity := range entities {
+				log.Debug().Str("entity", entity).Msg("server change received")
+				s.notifyChanged()
+			}
+			log.Debug().Msg("server changes stream dropped")
+			reopened = true
+			failures = 1
+		}
+
+		if !backoff.Sleep(ctx, reopen.Delay(failures)) {
 			return
 		}
 	}
 }
 
-func (s *SyncWorkerPool) doSync(ctx context.Context, token string) {
-	s.intPrevJob()
-	ctx, cancelJobFn := s.getJobTimeout(ctx)
-	for _, w := range s.wPool {
-		go w.DoJob(ctx, token)
+// waitToken waits until the token other than rejected is received,
+// it reports false if the context is done first.
+func (s *SyncWorkerPool) waitToken(ctx context.Context, rejected string) bool {
+	for s.getToken() == rejected {
+		select {
+		case <-s.tokenSet:
+		case <-ctx.Done():
+			return false
+		}
 	}
-	s.cancelJobFn = cancelJobFn
+	return true
 }
 
-func (s *SyncWorkerPool) getJobTimeout(
-	ctx context.Context,
-) (context.Context, context.CancelFunc) {
-	return context.WithTimeoutCause(
-		ctx, s.tick, errors.New("job timeout expired"),
-	)
+func (s *SyncWorkerPool) notifyChanged() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
 }
 
-func (s *SyncWorkerPool) intPrevJob() {
-	if s.cancelJobFn == nil {
-		return
+func (s *SyncWorkerPool) newControlServer(
+	stop context.CancelFunc,
+) *sockrpc.Server {
+	log := s.logger.WithOp("SyncWorkerPool.control")
+
+	srv := sockrpc.NewServer(s.socket)
+	srv.Handle(methodStatus, func(context.Context, json.RawMessage) (any, error) {
+		return s.getStatus(), nil
+	})
+	srv.Handle(methodPause, func(context.Context, json.RawMessage) (any, error) {
+		log.Debug().Msg("pause requested")
+		if s.swapState(StateRunning, StatePaused) {
+			s.intPrevJob()
+		}
+		return nil, nil
+	})
+	srv.Handle(methodResume, func(context.Context, json.RawMessage) (any, error) {
+		log.Debug().Msg("resume requested")
+		s.swapState(StatePaused, StateRunning)
+		return nil, nil
+	})
+	srv.Handle(methodToken, func(_ context.Context, params json.RawMessage) (any, error) {
+		log.Debug().Msg("new token received")
+		var p tokenParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Token == "" {
+			return nil, errors.New("empty token")
+		}
+		s.setToken(p.Token)
+		s.setState(StateRunning)
+		select {
+		case s.tokenSet <- struct{}{}:
+		default:
+		}
+		select {
+		case s.syncNow <- struct{}{}:
+		default:
+		}
+		return nil, nil
+	})
+	srv.Handle(methodStop, func(context.Context, json.RawMessage) (any, error) {
+		log.Debug().Msg("stop requested")
+		stop()
+		return nil, nil
+	})
+	srv.Handle(methodSyncNow, func(context.Context, json.RawMessage) (any, error) {
+		log.Debug().Msg("sync requested")
+		retryAt := s.getStatus().RetryAt
+		if retryAt != nil && time.Now().Before(*retryAt) {
+			log.Debug().Time("retryAt", *retryAt).Msg(
+				"the breaker is open, reject sync")
+			return nil, fmt.Errorf("%w, retry after %s",
+				ErrBreakerOpen, retryAt.Format(time.RFC3339))
+		}
+		select {
+		case s.syncNow <- struct{}{}:
+		default:
+		}
+		return nil, nil
+	})
+	return srv
+}
+
+func (s *SyncWorkerPool) getStatus() DaemonStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *SyncWorkerPool) setStatus(status DaemonStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *SyncWorkerPool) setState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.State = state
+}
+
+// swapState sets the new state if the current state is old and reports
+// whether the state is changed. The expired daemon is neither paused nor
+// resumed until the new token is received.
+func (s *SyncWorkerPool) swapState(old, state string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.State != old {
+		return false
 	}
-	s.cancelJobFn()
+	s.status.State = state
+	return true
 }
 
-func (s *SyncWorkerPool) stop() {
-	const op = "SyncWorkerPool.stop"
-	log := s.logger.WithOp(op)
-	log.Debug().Msg("start gracefully stop")
+func (s *SyncWorkerPool) getToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
 
-	timeoutCtx, cancel := context.WithTimeout(
-		context.Background(), 10*time.Second,
-	)
-	defer cancel()
+func (s *SyncWorkerPool) setToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
 
-	syncEntry := s.getSyncEntry(timeoutCtx)
-	s.updateSyncEntry(timeoutCtx, syncEntry, time.Now())
-	log.Debug().Msg("synchronization stopped")
+func (s *SyncWorkerPool) setRetryAt(retryAt *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.RetryAt = retryAt
 }
 
-func (s *SyncWorkerPool) getSyncEntry(ctx context.Context) dto.Sync {
-	const op = "SyncWorkerPool.getSyncEntry"
-	log := s.logger.WithOp(op)
+// doSync synchronizes every entity and waits for the workers. The entity
+// is synchronized again after the backoff delay while the server is
+// unavailable, the job failed on the unavailable server is counted by
+// the breaker. It reports whether any data is transferred.
+func (s *SyncWorkerPool) doSync(ctx context.Context, token string) bool {
+	log := s.logger.WithOp("SyncWorkerPool.doSync")
 
-	syncEntry, err := s.repo.ReadLast(ctx)
-	if errors.Is(err, repository.ErrNotExists) {
-		return s.createSyncEntry(ctx, time.Now())
+	jobCtx, cancelJobFn := context.WithTimeoutCause(
+		ctx, jobTimeout, errors.New("job timeout expired"),
+	)
+	defer cancelJobFn()
+	s.setCancelJob(cancelJobFn)
+	defer s.setCancelJob(nil)
+
+	var (
+		wg          sync.WaitGroup
+		transferred atomic.Bool
+		unavailable atomic.Bool
+	)
+	for _, w := range s.wPool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			startedAt := time.Now()
+			plan, err := s.syncEntity(jobCtx, w, token)
+			switch {
+			case errors.Is(err, ErrUnauthenticated):
+				s.setExpired()
+			case errors.Is(err, ErrServerUnavailable):
+				unavailable.Store(true)
+			case err == nil && planTransfers(plan):
+				transferred.Store(true)
+			}
+			if errors.Is(jobCtx.Err(), context.Canceled) {
+				// the job is interrupted by the pause or stop
+				return
+			}
+			s.report(ctx, w.Entity(), startedAt, err)
+		}()
 	}
+	wg.Wait()
+
+	now := time.Now()
+	switch {
+	case errors.Is(jobCtx.Err(), context.Canceled):
+		// the interrupted job is not counted by the breaker
+	case unavailable.Load():
+		s.breaker.Failure(now)
+		if !s.breaker.Allow(now) {
+			retryAt := s.breaker.OpenUntil()
+			s.setRetryAt(&retryAt)
+			log.Warn().Time("retryAt", retryAt).Msg(
+				"the server is unavailable, synchronization is delayed")
+		}
+	default:
+		s.breaker.Success()
+		s.setRetryAt(nil)
+	}
+	return transferred.Load()
+}
 
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed read sync entry")
+// syncEntity synchronizes the entity, the attempt failed on
+// the unavailable server is retried after the backoff delay.
+func (s *SyncWorkerPool) syncEntity(
+	ctx context.Context, w SyncWorker, token string,
+) (dto.SyncPlan, error) {
+	log := s.logger.WithOp("SyncWorkerPool.syncEntity").With().Str(
+		"entity", w.Entity()).Logger()
+
+	var plan dto.SyncPlan
+	err := backoff.Retry(ctx, s.retry, retryAttempts, serverUnavailable,
+		func(ctx context.Context) error {
+			var err error
+			plan, err = w.Sync(ctx, token)
+			if serverUnavailable(err) {
+				log.Debug().Err(err).Msg("server is unavailable")
+			}
+			return err
+		},
+	)
+	return plan, err
+}
+
+// nextTick returns the delay of the next tick after the job, the period
+// is reset to the minimum after the transfer and grows while the data
+// is idle. The open breaker delays the tick until its cooldown is over.
+func (s *SyncWorkerPool) nextTick(transferred bool) time.Duration {
+	s.period = s.interval.next(s.period, transferred)
+	now := time.Now()
+	if !s.breaker.Allow(now) {
+		return max(s.period, s.breaker.OpenUntil().Sub(now))
 	}
+	return s.period
+}
 
-	return syncEntry
+func serverUnavailable(err error) bool {
+	return errors.Is(err, ErrServerUnavailable)
 }
 
-func (s *SyncWorkerPool) createSyncEntry(
-	ctx context.Context, startTime time.Time,
-) dto.Sync {
-	const op = "SyncWorkerPool.createSyncEntry"
-	log := s.logger.WithOp(op)
+// planTransfers reports whether the plan transfers any object.
+func planTransfers(p dto.SyncPlan) bool {
+	return len(p.InsertFromServer)+len(p.UpdateFromServer)+
+		len(p.InsertToServer)+len(p.UpdateToServer)+len(p.Conflicts) != 0
+}
 
-	obj, err := s.repo.Create(ctx, os.Getpid(), startTime)
+// report saves the result of the worker job for the sync status.
+func (s *SyncWorkerPool) report(
+	ctx context.Context, entity string, startedAt time.Time, jobErr error,
+) {
+	l := s.logger.WithOp("SyncWorkerPool.report")
+	saveResult(ctx, l, s.repo, entity, startedAt, jobErr)
+}
+
+func saveResult(
+	ctx context.Context,
+	l logger.Logger,
+	repo SyncResultRepo,
+	entity string,
+	startedAt time.Time,
+	jobErr error,
+) {
+	log := l.With().Str("entity", entity).Logger()
+
+	var err error
+	if jobErr != nil {
+		log.Debug().Err(jobErr).Msg("synchronization failed")
+		err = repo.SaveError(ctx, entity, resultMsg(jobErr))
+	} else {
+		log.Debug().Msg("synchronization succeeded")
+		err = repo.SaveSynced(ctx, entity, startedAt)
+	}
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to create sync entry")
+		log.Error().Err(err).Msg("failed to save sync result")
 	}
-	return obj
 }
 
-func (s *SyncWorkerPool) updateSyncEntry(
-	ctx context.Context, obj dto.Sync, stopTime time.Time,
-) {
-	const op = "SyncWorkerPool.updateSyncEntry"
+// resultMsg returns the short message of the known job error.
+func resultMsg(err error) string {
+	for _, known := range []error{ErrUnauthenticated, ErrServerUnavailable} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "job timeout expired"
+	}
+	return err.Error()
+}
+
+func (s *SyncWorkerPool) setExpired() {
+	select {
+	case s.expired <- struct{}{}:
+	default:
+	}
+}
+
+func (s *SyncWorkerPool) setCancelJob(cancelJobFn context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelJobFn = cancelJobFn
+}
+
+func (s *SyncWorkerPool) intPrevJob() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelJobFn == nil {
+		return
+	}
+	s.cancelJobFn()
+}
+
+func (s *SyncWorkerPool) stop(syncEntry dto.Sync) {
+	const op = "SyncWorkerPool.stop"
 	log := s.logger.WithOp(op)
+	log.Debug().Msg("start gracefully stop")
 
-	obj.StoppedAt = &stopTime
-	if err := s.repo.Update(ctx, obj); err != nil {
-		log.Fatal().Err(err).Msg("failed to update sync entry")
+	timeoutCtx, cancel := context.WithTimeout(
+		context.Background(), 10*time.Second,
+	)
+	defer cancel()
+
+	stoppedAt := time.Now()
+	syncEntry.StoppedAt = &stoppedAt
+	if err := s.repo.Update(timeoutCtx, syncEntry); err != nil {
+		log.Error().Err(err).Msg("failed to update sync entry")
 	}
+	log.Debug().Msg("synchronization stopped")
 }
@@ -2,37 +2,90 @@ package syncservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"sync"
+	"time"
 
+	"github.com/niksmo/gophkeeper/internal/client/dto"
 	"github.com/niksmo/gophkeeper/internal/model"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
 )
 
 type LocalRepo interface {
 	GetComparable(context.Context) ([]model.LocalComparable, error)
-	GetAll(context.Context) ([]model.LocalPayload, error)
+	GetLeaves(context.Context) ([]merkle.Leaf, error)
+	GetAll(
+		ctx context.Context, after int64, limit int,
+	) ([]model.LocalPayload, error)
 	GetSliceByIDs(ctx context.Context, IDs []int64) ([]model.LocalPayload, error)
 	UpdateSliceBySyncIDs(ctx context.Context, data []model.SyncPayload) error
 	InsertSlice(ctx context.Context, data []model.LocalPayload) error
-	InsertSliceSyncID(ctx context.Context, IDSyncIDPairs [][2]int64) error
+	KeepConflicts(ctx context.Context, data []model.SyncConflict) error
+	SetSliceSyncBase(ctx context.Context, data []model.SyncBase) error
+	GetOutbox(context.Context) ([]model.OutboxEntry, error)
+	ReadCursor(context.Context) (int64, error)
+	SaveCursor(ctx context.Context, cursor int64) error
+	ReadReconciledAt(context.Context) (time.Time, error)
+	SaveReconciled(ctx context.Context, cursor int64) error
 }
 
 type ServerClient interface {
+	Entity() string
 	SetToken(string)
-	GetComparable(context.Context) ([]model.SyncComparable, error)
-	GetAll(context.Context) ([]model.SyncPayload, error)
+	GetComparable(
+		ctx context.Context, after int64, limit int,
+	) ([]model.SyncComparable, int64, int64, error)
+	GetChangesSince(
+		ctx context.Context, cursor int64, limit int,
+	) ([]model.SyncPayload, int64, int64, error)
+	Reconcile(
+		ctx context.Context, ranges []merkle.Range,
+	) ([]merkle.Range, []model.SyncComparable, int64, error)
+	GetAll(
+		ctx context.Context, after int64, limit int,
+	) ([]model.SyncPayload, int64, error)
 	GetSliceByIDs(ctx context.Context, IDs []int64) ([]model.SyncPayload, error)
-	UpdateSliceByIDs(ctx context.Context, data []model.SyncPayload) error
-	InsertSlice(ctx context.Context, data []model.LocalPayload) ([]int64, error)
+	UpdateSliceByIDs(
+		ctx context.Context, data []model.SyncPayload,
+	) ([]model.SyncRevision, error)
+	InsertSlice(
+		ctx context.Context, data []model.LocalPayload,
+	) ([]model.SyncRevision, error)
 }
 
+const (
+	// comparablePageSize is the number of the comparable objects
+	// requested at once.
+	comparablePageSize = 1000
+
+	// pageSize is the number of the objects read or requested at once.
+	pageSize = 100
+
+	// maxBatchBytes bounds the data of the objects sent at once,
+	// the larger object is sent alone.
+	maxBatchBytes = 2 << 20
+
+	// maxReconcileRanges is the number of the ranges reconciled at once.
+	maxReconcileRanges = 1024
+
+	// reconcileInterval is the period the changes since the cursor are
+	// synchronized for, then all the objects are reconciled again, so
+	// the change missing in the feed is not missing forever.
+	reconcileInterval = time.Hour
+)
+
 type lists struct {
 	insert []int64
 	update []int64
 }
 
+// conflicts maps the server ID to the local ID of the object changed
+// on the both sides or taking the same name.
+type conflicts map[int64]int64
+
 type Worker struct {
 	logger logger.Logger
 	local  LocalRepo
@@ -43,43 +96,105 @@ func NewWorker(l logger.Logger, clR LocalRepo, srvR ServerClient) *Worker {
 	return &Worker{l, clR, srvR}
 }
 
-func (w *Worker) DoJob(ctx context.Context, token string) {
-	const op = "Worker.DoJob"
-	log := w.logger.WithOp(op)
+// Entity returns the name of the synchronized entity.
+func (w *Worker) Entity() string {
+	return w.server.Entity()
+}
 
-	sync.OnceFunc(func() {
-		log.Debug().Str("token", token).Msg("set token to server client")
-		w.server.SetToken(token)
-	})()
+// DoJob synchronizes the local and the server data and waits until
+// the both directions are done.
+func (w *Worker) DoJob(ctx context.Context, token string) error {
+	_, err := w.Sync(ctx, token)
+	return err
+}
+
+// Sync synchronizes the local and the server data and returns the plan
+// of the transferred objects. The first synchronization compares all
+// the objects, then only the server changes since the saved cursor
+// are compared with the local data until the reconcileInterval passes.
+// The cursor is saved when the both directions are done.
+func (w *Worker) Sync(ctx context.Context, token string) (dto.SyncPlan, error) {
+	w.setToken(token)
 
-	srvComp, err := w.getServerComparable(ctx)
+	cursor, err := w.getLocalCursor(ctx)
+	if err != nil {
+		return dto.SyncPlan{Entity: w.Entity()}, err
+	}
+	due, err := w.reconcileDue(ctx, cursor)
 	if err != nil {
-		return
+		return dto.SyncPlan{Entity: w.Entity()}, err
 	}
 
-	if w.serverNoData(srvComp) {
-		log.Debug().Msg("server no data")
-		locData, err := w.getLocalAll(ctx)
+	if due {
+		plan, next, err := w.syncAll(ctx)
 		if err != nil {
-			return
+			return plan, err
 		}
-		w.insertToServer(ctx, locData)
-		return
+		return plan, w.saveReconciled(ctx, next)
+	}
+	plan, next, err := w.syncChanges(ctx, cursor)
+	if err != nil {
+		return plan, err
+	}
+	return plan, w.saveLocalCursor(ctx, next)
+}
+
+// reconcileDue reports whether all the objects are compared, the entity
+// without the cursor or reconciled before the reconcileInterval.
+func (w *Worker) reconcileDue(ctx context.Context, cursor int64) (bool, error) {
+	const op = "Worker.reconcileDue"
+	log := w.logger.WithOp(op)
+
+	if cursor == 0 {
+		return true, nil
+	}
+	reconciledAt, err := w.local.ReadReconciledAt(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to read reconciled at")
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	due := time.Since(reconciledAt) >= reconcileInterval
+	if due {
+		log.Debug().Time("reconciledAt", reconciledAt).Msg("reconcile is due")
+	}
+	return due, nil
+}
+
+// syncAll compares all the local and the server objects and returns
+// the server cursor read before the comparison.
+func (w *Worker) syncAll(ctx context.Context) (dto.SyncPlan, int64, error) {
+	const op = "Worker.syncAll"
+	log := w.logger.WithOp(op)
+
+	plan := dto.SyncPlan{Entity: w.Entity()}
+
+	srvComp, cursor, partial, err := w.getServerState(ctx)
+	if err != nil {
+		return plan, 0, err
+	}
+
+	if !partial && w.serverNoData(srvComp) {
+		log.Debug().Msg("server no data")
+		plan.InsertToServer, err = w.insertAllToServer(ctx)
+		return plan, cursor, err
 	}
 
 	locComp, err := w.getLocalComparable(ctx)
 	if err != nil {
-		return
+		return plan, 0, err
 	}
 
 	if w.localNoData(locComp) {
 		log.Debug().Msg("no local data")
-		srvData, err := w.getServerAll(ctx)
-		if err != nil {
-			return
-		}
-		w.insertToLocal(ctx, srvData)
-		return
+		plan.InsertFromServer, err = w.insertAllToLocal(ctx)
+		return plan, cursor, err
+	}
+
+	if err := w.adoptOwnWrites(ctx, locComp, srvComp, true); err != nil {
+		return plan, 0, err
+	}
+	if err := w.settleSameContent(ctx, locComp, srvComp, true); err != nil {
+		return plan, 0, err
 	}
 
 	log.Debug().Int(
@@ -87,16 +202,206 @@ func (w *Worker) DoJob(ctx context.Context, token string) {
 		"srvCompLen", len(srvComp)).Msg(
 		"start compare between local and server")
 
-	srvIDs, locIDs := w.compare(locComp, srvComp)
+	compare := w.compare
+	if partial {
+		compare = w.compareRevised
+	}
+	srvIDs, locIDs, conflicts := compare(locComp, srvComp)
+	plan = w.makePlan(srvIDs, locIDs, conflicts)
 
 	log.Debug().Ints64(
 		"insertFromServer", srvIDs.insert).Ints64(
 		"updateFromServer", srvIDs.update).Ints64(
 		"insertFromLocal", locIDs.insert).Ints64(
-		"updateFromLocal", locIDs.update).Msg("compare result")
+		"updateFromLocal", locIDs.update).Ints64(
+		"conflicts", plan.Conflicts).Msg("compare result")
 
-	go w.handleServerData(ctx, srvIDs)
-	go w.handleLocalData(ctx, locIDs)
+	err = w.exchange(ctx, func(ctx context.Context) error {
+		return w.handleServerData(ctx, srvIDs, conflicts)
+	}, locIDs)
+	return plan, cursor, err
+}
+
+// syncChanges compares the server objects revised since the cursor with
+// the local data and returns the next cursor.
+func (w *Worker) syncChanges(
+	ctx context.Context, cursor int64,
+) (dto.SyncPlan, int64, error) {
+	const op = "Worker.syncChanges"
+	log := w.logger.WithOp(op)
+
+	plan := dto.SyncPlan{Entity: w.Entity()}
+
+	srvData, next, err := w.getServerChanges(ctx, cursor)
+	if err != nil {
+		return plan, 0, err
+	}
+
+	locComp, err := w.getLocalComparable(ctx)
+	if err != nil {
+		return plan, 0, err
+	}
+
+	srvComp := w.payloadComparable(srvData)
+	if err := w.adoptOwnWrites(ctx, locComp, srvComp, true); err != nil {
+		return plan, 0, err
+	}
+	if err := w.settleSameContent(ctx, locComp, srvComp, true); err != nil {
+		return plan, 0, err
+	}
+
+	srvIDs, locIDs, conflicts := w.compareRevised(locComp, srvComp)
+	plan = w.makePlan(srvIDs, locIDs, conflicts)
+
+	log.Debug().Ints64(
+		"insertFromServer", srvIDs.insert).Ints64(
+		"updateFromServer", srvIDs.update).Ints64(
+		"insertFromLocal", locIDs.insert).Ints64(
+		"updateFromLocal", locIDs.update).Ints64(
+		"conflicts", plan.Conflicts).Msg("compare result")
+
+	err = w.exchange(ctx, func(ctx context.Context) error {
+		return w.applyServerData(ctx, srvData, srvIDs, conflicts)
+	}, locIDs)
+	return plan, next, err
+}
+
+// exchange applies the server data and sends the local data concurrently
+// and waits until the both directions are done.
+func (w *Worker) exchange(
+	ctx context.Context,
+	handleServer func(context.Context) error,
+	locIDs lists,
+) error {
+	var (
+		wg             sync.WaitGroup
+		srvErr, locErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		srvErr = handleServer(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		locErr = w.handleLocalData(ctx, locIDs)
+	}()
+	wg.Wait()
+	return errors.Join(srvErr, locErr)
+}
+
+// Plan compares the local and the server data without changing them,
+// the server lists hold the server IDs, the local lists and the conflicts
+// hold the local IDs. The cursor, the adopted own writes and the settled
+// versions are not saved.
+func (w *Worker) Plan(ctx context.Context, token string) (dto.SyncPlan, error) {
+	w.setToken(token)
+
+	cursor, err := w.getLocalCursor(ctx)
+	if err != nil {
+		return dto.SyncPlan{}, err
+	}
+	due, err := w.reconcileDue(ctx, cursor)
+	if err != nil {
+		return dto.SyncPlan{}, err
+	}
+
+	if !due {
+		srvData, _, err := w.getServerChanges(ctx, cursor)
+		if err != nil {
+			return dto.SyncPlan{}, err
+		}
+		locComp, err := w.getLocalComparable(ctx)
+		if err != nil {
+			return dto.SyncPlan{}, err
+		}
+		srvComp := w.payloadComparable(srvData)
+		err = w.adoptOwnWrites(ctx, locComp, srvComp, false)
+		if err != nil {
+			return dto.SyncPlan{}, err
+		}
+		err = w.settleSameContent(ctx, locComp, srvComp, false)
+		if err != nil {
+			return dto.SyncPlan{}, err
+		}
+		return w.makePlan(w.compareRevised(locComp, srvComp)), nil
+	}
+
+	srvComp, _, partial, err := w.getServerState(ctx)
+	if err != nil {
+		return dto.SyncPlan{}, err
+	}
+
+	locComp, err := w.getLocalComparable(ctx)
+	if err != nil {
+		return dto.SyncPlan{}, err
+	}
+
+	switch {
+	case !partial && w.serverNoData(srvComp):
+		plan := dto.SyncPlan{Entity: w.Entity()}
+		for _, o := range locComp {
+			plan.InsertToServer = append(plan.InsertToServer, o.ID)
+		}
+		return plan, nil
+	case w.localNoData(locComp):
+		plan := dto.SyncPlan{Entity: w.Entity()}
+		for _, o := range srvComp {
+			plan.InsertFromServer = append(plan.InsertFromServer, o.ID)
+		}
+		return plan, nil
+	}
+
+	if err := w.adoptOwnWrites(ctx, locComp, srvComp, false); err != nil {
+		return dto.SyncPlan{}, err
+	}
+	if err := w.settleSameContent(ctx, locComp, srvComp, false); err != nil {
+		return dto.SyncPlan{}, err
+	}
+	if partial {
+		return w.makePlan(w.compareRevised(locComp, srvComp)), nil
+	}
+	return w.makePlan(w.compare(locComp, srvComp)), nil
+}
+
+func (w *Worker) setToken(token string) {
+	sync.OnceFunc(func() {
+		w.logger.Debug().Msg("set token to server client")
+		w.server.SetToken(token)
+	})()
+}
+
+func (w *Worker) makePlan(
+	srvIDs, locIDs lists, conflicts conflicts,
+) dto.SyncPlan {
+	plan := dto.SyncPlan{
+		Entity:           w.Entity(),
+		InsertFromServer: srvIDs.insert,
+		UpdateFromServer: srvIDs.update,
+		InsertToServer:   locIDs.insert,
+		UpdateToServer:   locIDs.update,
+	}
+	for _, locID := range conflicts {
+		plan.Conflicts = append(plan.Conflicts, locID)
+	}
+	slices.Sort(plan.Conflicts)
+	return plan
+}
+
+func (w *Worker) localPayloadIDs(data []model.LocalPayload) []int64 {
+	IDs := make([]int64, 0, len(data))
+	for _, o := range data {
+		IDs = append(IDs, o.ID)
+	}
+	return IDs
+}
+
+func (w *Worker) syncPayloadIDs(data []model.SyncPayload) []int64 {
+	IDs := make([]int64, 0, len(data))
+	for _, o := range data {
+		IDs = append(IDs, o.ID)
+	}
+	return IDs
 }
 
 func (w *Worker) serverNoData(srvComp []model.SyncComparable) bool {
@@ -107,57 +412,156 @@ func (w *Worker) localNoData(locComp []model.LocalComparable) bool {
 	return len(locComp) == 0
 }
 
+// insertAllToServer walks the pages of the local objects and inserts them
+// to the server, the IDs of the walked objects are returned.
+func (w *Worker) insertAllToServer(ctx context.Context) ([]int64, error) {
+	var (
+		IDs   []int64
+		after int64
+	)
+	for {
+		locData, err := w.getLocalPage(ctx, after)
+		if err != nil || len(locData) == 0 {
+			return IDs, err
+		}
+		IDs = append(IDs, w.localPayloadIDs(locData)...)
+		if err := w.insertToServer(ctx, locData); err != nil {
+			return IDs, err
+		}
+		after = locData[len(locData)-1].ID
+	}
+}
+
+// insertAllToLocal walks the pages of the server objects and inserts them
+// to the local data, the IDs of the walked objects are returned.
+func (w *Worker) insertAllToLocal(ctx context.Context) ([]int64, error) {
+	var (
+		IDs   []int64
+		after int64
+	)
+	for {
+		srvData, next, err := w.getServerPage(ctx, after)
+		if err != nil {
+			return IDs, err
+		}
+		IDs = append(IDs, w.syncPayloadIDs(srvData)...)
+		if err := w.insertToLocal(ctx, srvData); err != nil {
+			return IDs, err
+		}
+		if next == 0 {
+			return IDs, nil
+		}
+		after = next
+	}
+}
+
+// batches splits the objects into the batches of pageSize objects at most
+// with the data not larger than maxBatchBytes, the larger object makes
+// the batch alone.
+func (w *Worker) batches(data []model.LocalPayload) [][]model.LocalPayload {
+	var (
+		s           [][]model.LocalPayload
+		start, size int
+	)
+	for i, o := range data {
+		n := len(o.Name) + int(o.DataSize())
+		if i > start && (size+n > maxBatchBytes || i-start == pageSize) {
+			s = append(s, data[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	if start < len(data) {
+		s = append(s, data[start:])
+	}
+	return s
+}
+
 func (w *Worker) insertToServer(
 	ctx context.Context, locData []model.LocalPayload,
-) {
+) error {
 	const op = "Worker.insertToServer"
 	log := w.logger.WithOp(op)
 
 	if len(locData) == 0 {
 		log.Debug().Msg("no local data to send")
-		return
+		return nil
 	}
 
-	log.Debug().Msg("start insert local data to the server")
+	for _, batch := range w.batches(locData) {
+		if err := w.insertBatchToServer(ctx, batch); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) insertBatchToServer(
+	ctx context.Context, locData []model.LocalPayload,
+) error {
+	const op = "Worker.insertBatchToServer"
+	log := w.logger.WithOp(op)
+
+	log.Debug().Int("batchLen", len(locData)).Msg(
+		"start insert local data to the server")
 
-	syncIDs, err := w.server.InsertSlice(ctx, locData)
+	revisions, err := w.server.InsertSlice(ctx, locData)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to send local data ot server")
-		return
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Debug().Ints64("syncIDs", syncIDs).Msg(
+	log.Debug().Int("revisionsLen", len(revisions)).Msg(
 		"insert local data to the server successfully")
 
-	if len(syncIDs) != len(locData) {
-		log.Error().Err(err).Int(
+	if len(revisions) != len(locData) {
+		log.Error().Int(
 			"locDataLen", len(locData)).Int(
-			"syncIDsLen", len(syncIDs)).Msg(
-			"unexpected syncIDs returned length")
-		return
+			"revisionsLen", len(revisions)).Msg(
+			"unexpected revisions returned length")
+		return fmt.Errorf("%s: unexpected revisions length %d, expected %d",
+			op, len(revisions), len(locData))
 	}
 
-	IDSyncIDPairs := w.makeIDSyncIDPairs(locData, syncIDs)
-
-	log.Debug().Any(
-		"idSyncIDPairs", IDSyncIDPairs).Msg(
-		"start insert syncID to local")
+	for i, rev := range revisions {
+		locData[i].SyncID = rev.ID
+	}
 
-	err = w.local.InsertSliceSyncID(ctx, IDSyncIDPairs)
-	if err != nil {
+	log.Debug().Msg("start insert syncID to local")
+	if err := w.setSyncBase(ctx, locData, revisions); err != nil {
 		log.Error().Err(err).Msg("failed to insert syncIDs to local data")
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	log.Debug().Msg("insert syncID successfully")
+	return nil
 }
 
-func (w *Worker) makeIDSyncIDPairs(
-	locData []model.LocalPayload, syncIDs []int64,
-) [][2]int64 {
-	var s [][2]int64
-	for i, syncID := range syncIDs {
-		s = append(s, [2]int64{locData[i].ID, syncID})
+// setSyncBase makes the versions written by the server the base revisions
+// of the local objects. The rejected objects stay changed and become
+// the conflicts on the next synchronization. The repeated write returns
+// the version of the first one, the local changes made since then stay
+// pending.
+func (w *Worker) setSyncBase(
+	ctx context.Context,
+	locData []model.LocalPayload,
+	revisions []model.SyncRevision,
+) error {
+	bySyncID := make(map[int64]model.SyncRevision, len(revisions))
+	for _, rev := range revisions {
+		bySyncID[rev.ID] = rev
+	}
+
+	s := make([]model.SyncBase, 0, len(revisions))
+	for _, o := range locData {
+		rev, ok := bySyncID[o.SyncID]
+		if !ok {
+			continue
+		}
+		s = append(s, model.SyncBase{
+			ID: o.ID, SyncID: o.SyncID, Revision: rev.Revision, Clock: rev.Clock,
+		})
 	}
-	return s
+	return w.local.SetSliceSyncBase(ctx, s)
 }
 
 func (w *Worker) insertToLocal(
@@ -206,21 +610,33 @@ func (w *Worker) makeLocalComparableMaps(
 	map[string]model.LocalComparable,
 ) {
 	syncIDModelMap := make(map[int64]model.LocalComparable)
-	nameModelMap := make(map[string]model.LocalComparable)
+	nameIndexModelMap := make(map[string]model.LocalComparable)
 
 	for _, o := range locComp {
 		if o.SyncID != 0 {
 			syncIDModelMap[o.SyncID] = o
 		} else {
-			nameModelMap[o.Name] = o
+			nameIndexModelMap[o.NameIndex] = o
 		}
 	}
-	return syncIDModelMap, nameModelMap
+	return syncIDModelMap, nameIndexModelMap
 }
 
+// sameContent reports whether the local and the server object have
+// the same content hash, the objects are in sync whatever their revisions
+// and clocks are.
+func (w *Worker) sameContent(
+	locObj model.LocalComparable, srvObj model.SyncComparable,
+) bool {
+	return locObj.Hash != "" && locObj.Hash == srvObj.Hash
+}
+
+// compareForUpdate compares the synchronized objects with their base
+// revisions, the objects changed on the both sides are the conflicts.
 func (w *Worker) compareForUpdate(
 	srvComp []model.SyncComparable,
 	syncLocalCompMap map[int64]model.LocalComparable,
+	conflicts conflicts,
 ) (
 	fromSrv []int64,
 	fromLoc []int64,
@@ -228,10 +644,16 @@ func (w *Worker) compareForUpdate(
 ) {
 	for _, srvObj := range srvComp {
 		if locObj, ok := syncLocalCompMap[srvObj.ID]; ok {
-			switch locObj.UpdatedAt.Compare(srvObj.UpdatedAt) {
-			case -1:
+			if w.sameContent(locObj, srvObj) {
+				continue
+			}
+			locChanged, srvChanged := w.changes(locObj, srvObj)
+			switch {
+			case locChanged && srvChanged:
+				conflicts[srvObj.ID] = locObj.ID
+			case srvChanged:
 				fromSrv = append(fromSrv, srvObj.ID)
-			case 1:
+			case locChanged:
 				fromLoc = append(fromLoc, locObj.ID)
 			}
 			continue
@@ -242,19 +664,177 @@ func (w *Worker) compareForUpdate(
 	return
 }
 
+// changes reports whether the local and the server object are changed
+// since the base revision, the local object is changed if it has pending
+// outbox records. The object synchronized before the revisions has no base
+// revision and is ordered by the clocks, the later local object is
+// the conflict if the server object is revised since then.
+func (w *Worker) changes(
+	locObj model.LocalComparable, srvObj model.SyncComparable,
+) (local, server bool) {
+	if locObj.Revision == 0 {
+		local = locObj.Pending && locObj.Clock > srvObj.Clock
+		return local, !local || srvObj.Revision != 0
+	}
+	return locObj.Pending, srvObj.Revision != locObj.Revision
+}
+
+// adoptOwnWrites makes the server versions written with the keys of
+// the pending local changes the base versions of the local objects, so
+// the write the device did not get the response to is not taken for
+// a concurrent one. The changes made since the adopted version stay
+// pending. The adopted versions are saved if save is set.
+func (w *Worker) adoptOwnWrites(
+	ctx context.Context,
+	locComp []model.LocalComparable,
+	srvComp []model.SyncComparable,
+	save bool,
+) error {
+	const op = "Worker.adoptOwnWrites"
+	log := w.logger.WithOp(op)
+
+	outbox, err := w.local.GetOutbox(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get local outbox")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(outbox) == 0 {
+		return nil
+	}
+
+	byKey := make(map[string]model.OutboxEntry, len(outbox))
+	for _, o := range outbox {
+		byKey[o.Key] = o
+	}
+	byID := make(map[int64]int, len(locComp))
+	for i, o := range locComp {
+		byID[o.ID] = i
+	}
+
+	var s []model.SyncBase
+	for _, srvObj := range srvComp {
+		rec, ok := byKey[srvObj.Key]
+		if srvObj.Key == "" || !ok {
+			continue
+		}
+		i, ok := byID[rec.EntryID]
+		if !ok {
+			continue
+		}
+		locObj := &locComp[i]
+		if locObj.SyncID != 0 && locObj.SyncID != srvObj.ID {
+			continue
+		}
+		locObj.SyncID = srvObj.ID
+		locObj.Revision = srvObj.Revision
+		locObj.SyncClock = srvObj.Clock
+		locObj.Pending = slices.ContainsFunc(outbox,
+			func(o model.OutboxEntry) bool {
+				return o.EntryID == locObj.ID && o.Clock > srvObj.Clock
+			})
+		s = append(s, model.SyncBase{
+			ID:       locObj.ID,
+			SyncID:   srvObj.ID,
+			Revision: srvObj.Revision,
+			Clock:    srvObj.Clock,
+		})
+	}
+
+	log.Debug().Int("adopted", len(s)).Msg("adopt own writes")
+	if !save || len(s) == 0 {
+		return nil
+	}
+	if err := w.local.SetSliceSyncBase(ctx, s); err != nil {
+		log.Error().Err(err).Msg("failed to set sync base")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// settleSameContent makes the server versions with the same content as
+// the synchronized local objects their base versions, so the object
+// revised without changing the content, e.g. the same content written by
+// the other device, is not transferred. The base clock is the later one of
+// the versions, so the pending records of the local version are
+// acknowledged. The settled versions are saved if save is set.
+func (w *Worker) settleSameContent(
+	ctx context.Context,
+	locComp []model.LocalComparable,
+	srvComp []model.SyncComparable,
+	save bool,
+) error {
+	const op = "Worker.settleSameContent"
+	log := w.logger.WithOp(op)
+
+	bySyncID := make(map[int64]int, len(locComp))
+	for i, o := range locComp {
+		if o.SyncID != 0 {
+			bySyncID[o.SyncID] = i
+		}
+	}
+
+	var s []model.SyncBase
+	for _, srvObj := range srvComp {
+		i, ok := bySyncID[srvObj.ID]
+		if !ok {
+			continue
+		}
+		locObj := &locComp[i]
+		if !w.sameContent(*locObj, srvObj) {
+			continue
+		}
+		if !locObj.Pending && locObj.Revision == srvObj.Revision {
+			continue
+		}
+		clock := max(locObj.Clock, srvObj.Clock)
+		locObj.Revision = srvObj.Revision
+		locObj.SyncClock = clock
+		locObj.Pending = false
+		s = append(s, model.SyncBase{
+			ID:       locObj.ID,
+			SyncID:   srvObj.ID,
+			Revision: srvObj.Revision,
+			Clock:    clock,
+		})
+	}
+
+	log.Debug().Int("settled", len(s)).Msg("settle same content")
+	if !save || len(s) == 0 {
+		return nil
+	}
+	if err := w.local.SetSliceSyncBase(ctx, s); err != nil {
+		log.Error().Err(err).Msg("failed to set sync base")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// compareForInsert matches the not synchronized objects by the name blind
+// index without revealing the name to the server. The objects with the same
+// name from the different devices are the conflicts, the server object takes
+// the name and the local one is kept as the conflict copy. The server object
+// with the UUID of the local object is its insert changed by the other
+// device since then, it is the conflict whatever the names are.
 func (w *Worker) compareForInsert(
 	notSyncYet []model.SyncComparable,
 	newLocalCompMap map[string]model.LocalComparable,
+	conflicts conflicts,
 ) (fromSrv []int64, fromLoc []int64) {
+	indexByUUID := make(map[string]string, len(newLocalCompMap))
+	for nameIndex, o := range newLocalCompMap {
+		if o.UUID != "" {
+			indexByUUID[o.UUID] = nameIndex
+		}
+	}
+
 	for _, srvObj := range notSyncYet {
-		if locObj, ok := newLocalCompMap[srvObj.Name]; ok {
-			switch locObj.UpdatedAt.Compare(srvObj.UpdatedAt) {
-			case -1:
-				fromSrv = append(fromSrv, srvObj.ID)
-			case 1:
-				fromLoc = append(fromLoc, locObj.ID)
-			}
-			delete(newLocalCompMap, srvObj.Name)
+		nameIndex, ok := indexByUUID[srvObj.UUID]
+		if !ok || srvObj.UUID == "" {
+			nameIndex = srvObj.NameIndex
+		}
+		if locObj, ok := newLocalCompMap[nameIndex]; ok {
+			conflicts[srvObj.ID] = locObj.ID
+			delete(newLocalCompMap, nameIndex)
 			continue
 		}
 		fromSrv = append(fromSrv, srvObj.ID)
@@ -298,14 +878,37 @@ func (w *Worker) updateServer(
 		return nil
 	}
 
+	for _, batch := range w.batches(locData) {
+		if err := w.updateBatchOnServer(ctx, batch); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) updateBatchOnServer(
+	ctx context.Context, locData []model.LocalPayload,
+) error {
+	const op = "Worker.updateBatchOnServer"
+	log := w.logger.WithOp(op)
+
 	updateData := w.convertLocToSrv(locData)
 
 	log.Debug().Msg("start update server data")
-	err := w.server.UpdateSliceByIDs(ctx, updateData)
+	revisions, err := w.server.UpdateSliceByIDs(ctx, updateData)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to update server by IDs")
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if n := len(updateData) - len(revisions); n != 0 {
+		log.Debug().Int("rejected", n).Msg(
+			"objects are revised on the server since the base revision")
+	}
+
+	if err := w.setSyncBase(ctx, locData, revisions); err != nil {
+		log.Error().Err(err).Msg("failed to set sync base")
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	log.Debug().Msg("update server successfully")
 	return nil
 }
@@ -322,42 +925,43 @@ func (w *Worker) convertLocToSrv(
 	return s
 }
 
-func (w *Worker) getLocalAll(
-	ctx context.Context,
+func (w *Worker) getLocalPage(
+	ctx context.Context, after int64,
 ) ([]model.LocalPayload, error) {
-	const op = "Worker.getLocalAll"
+	const op = "Worker.getLocalPage"
 	log := w.logger.WithOp(op)
 
-	log.Debug().Msg("start get all local data")
+	log.Debug().Int64("after", after).Msg("start get local page")
 
-	locData, err := w.local.GetAll(ctx)
+	locData, err := w.local.GetAll(ctx, after, pageSize)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to get all local data")
+		log.Error().Err(err).Msg("failed to get local page")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	log.Debug().Int("locDataLen", len(locData)).Msg("get all local data")
+	log.Debug().Int("locDataLen", len(locData)).Msg("get local page")
 	return locData, nil
 }
 
-func (w *Worker) getServerAll(
-	ctx context.Context,
-) ([]model.SyncPayload, error) {
-	const op = "Worker.getServerAll"
+func (w *Worker) getServerPage(
+	ctx context.Context, after int64,
+) ([]model.SyncPayload, int64, error) {
+	const op = "Worker.getServerPage"
 	log := w.logger.WithOp(op)
 
-	log.Debug().Msg("start get all from server")
+	log.Debug().Int64("after", after).Msg("start get page from server")
 
-	srvData, err := w.server.GetAll(ctx)
+	srvData, next, err := w.server.GetAll(ctx, after, pageSize)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to get all server data")
-		return nil, fmt.Errorf("%s: %w", op, err)
+		log.Error().Err(err).Msg("failed to get server page")
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Debug().Int(
-		"srvDataLen", len(srvData)).Msg(
-		"receive data from server")
+		"srvDataLen", len(srvData)).Int64(
+		"next", next).Msg(
+		"receive page from server")
 
-	return srvData, nil
+	return srvData, next, nil
 }
 
 func (w *Worker) getLocalComparable(
@@ -377,23 +981,174 @@ func (w *Worker) getLocalComparable(
 	return locComp, nil
 }
 
+// getServerState returns the server comparable objects and the cursor.
+// The synchronized local data is reconciled with the server, then only
+// the objects of the diverging ranges are returned and partial is set.
+func (w *Worker) getServerState(
+	ctx context.Context,
+) (srvComp []model.SyncComparable, cursor int64, partial bool, err error) {
+	const op = "Worker.getServerState"
+	log := w.logger.WithOp(op)
+
+	leaves, err := w.local.GetLeaves(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get local leaves")
+		return nil, 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(leaves) == 0 {
+		srvComp, cursor, err = w.getServerComparable(ctx)
+		return srvComp, cursor, false, err
+	}
+	srvComp, cursor, err = w.reconcile(ctx, leaves)
+	return srvComp, cursor, true, err
+}
+
+// reconcile returns the server objects of the ranges diverging from
+// the local leaves and the cursor of the first round, see merkle.Tree.
+// The ranges are sent by maxReconcileRanges at most.
+func (w *Worker) reconcile(
+	ctx context.Context, leaves []merkle.Leaf,
+) ([]model.SyncComparable, int64, error) {
+	const op = "Worker.reconcile"
+	log := w.logger.WithOp(op)
+
+	tree := merkle.New(leaves)
+	var (
+		srvComp []model.SyncComparable
+		cursor  int64
+		rounds  int
+	)
+	for ranges := []merkle.Range{tree.Root()}; len(ranges) != 0; rounds++ {
+		n := min(len(ranges), maxReconcileRanges)
+		diverged, comp, roundCursor, err := w.server.Reconcile(ctx, ranges[:n])
+		if err != nil {
+			log.Error().Err(err).Msg("failed to reconcile ranges")
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if rounds == 0 {
+			cursor = roundCursor
+		}
+		srvComp = append(srvComp, comp...)
+
+		ranges = ranges[n:]
+		for _, r := range diverged {
+			if local := tree.Range(r.Start, r.End); local.Hash != r.Hash {
+				ranges = append(ranges, local)
+			}
+		}
+	}
+
+	log.Debug().Int(
+		"leaves", len(leaves)).Int(
+		"rounds", rounds).Int(
+		"srvCompLen", len(srvComp)).Msg(
+		"reconcile with server")
+	return srvComp, cursor, nil
+}
+
 func (w *Worker) getServerComparable(
 	ctx context.Context,
-) ([]model.SyncComparable, error) {
+) ([]model.SyncComparable, int64, error) {
 	const op = "Worker.getServerComparable"
 	log := w.logger.WithOp(op)
 
 	log.Debug().Msg("start get comparable from server")
 
-	srvComp, err := w.server.GetComparable(ctx)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to get server comparable objects")
-		return nil, fmt.Errorf("%s: %w", op, err)
+	// the cursor of the first page is returned, the objects revised while
+	// the pages are walked are returned again by the changes since it
+	var (
+		srvComp []model.SyncComparable
+		cursor  int64
+	)
+	for after, first := int64(0), true; ; first = false {
+		page, pageCursor, next, err := w.server.GetComparable(
+			ctx, after, comparablePageSize,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get server comparable objects")
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if first {
+			cursor = pageCursor
+		}
+		srvComp = append(srvComp, page...)
+		if next == 0 {
+			break
+		}
+		after = next
 	}
 	log.Debug().Int(
-		"srvComLen", len(srvComp)).Msg(
+		"srvComLen", len(srvComp)).Int64(
+		"cursor", cursor).Msg(
 		"receive comparable from server")
-	return srvComp, nil
+	return srvComp, cursor, nil
+}
+
+// getServerChanges walks the pages of the changes after the cursor and
+// returns them with the cursor of the last page.
+func (w *Worker) getServerChanges(
+	ctx context.Context, cursor int64,
+) ([]model.SyncPayload, int64, error) {
+	const op = "Worker.getServerChanges"
+	log := w.logger.WithOp(op)
+
+	log.Debug().Int64("cursor", cursor).Msg("start get changes from server")
+
+	var srvData []model.SyncPayload
+	for after := cursor; ; {
+		page, pageCursor, next, err := w.server.GetChangesSince(
+			ctx, after, pageSize,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get server changes")
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		srvData = append(srvData, page...)
+		cursor = pageCursor
+		if next == 0 {
+			break
+		}
+		after = next
+	}
+	log.Debug().Int(
+		"srvDataLen", len(srvData)).Int64(
+		"cursor", cursor).Msg(
+		"receive changes from server")
+	return srvData, cursor, nil
+}
+
+func (w *Worker) getLocalCursor(ctx context.Context) (int64, error) {
+	const op = "Worker.getLocalCursor"
+	log := w.logger.WithOp(op)
+
+	cursor, err := w.local.ReadCursor(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to read local cursor")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return cursor, nil
+}
+
+func (w *Worker) saveReconciled(ctx context.Context, cursor int64) error {
+	const op = "Worker.saveReconciled"
+	log := w.logger.WithOp(op)
+
+	if err := w.local.SaveReconciled(ctx, cursor); err != nil {
+		log.Error().Err(err).Msg("failed to save reconciled cursor")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (w *Worker) saveLocalCursor(ctx context.Context, cursor int64) error {
+	const op = "Worker.saveLocalCursor"
+	log := w.logger.WithOp(op)
+
+	if err := w.local.SaveCursor(ctx, cursor); err != nil {
+		log.Error().Err(err).Msg("failed to save local cursor")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
 }
 
 func (w *Worker) getLocalSlice(
@@ -445,72 +1200,201 @@ func (w *Worker) getServerSlice(
 }
 
 func (w *Worker) handleServerData(
-	ctx context.Context, srvIDs lists,
-) {
+	ctx context.Context, srvIDs lists, conflicts conflicts,
+) error {
 	const op = "Worker.handleServerData"
 	log := w.logger.WithOp(op)
 	log.Debug().Msg("start op")
 
-	srvData, err := w.getServerSlice(
-		ctx, append(srvIDs.update, srvIDs.insert...))
-	if err != nil {
-		return
+	IDs := slices.Concat(srvIDs.update, srvIDs.insert)
+	for srvID := range conflicts {
+		IDs = append(IDs, srvID)
+	}
+	for page := range slices.Chunk(IDs, pageSize) {
+		srvData, err := w.getServerSlice(ctx, page)
+		if err != nil {
+			return err
+		}
+		err = w.applyServerData(ctx, srvData, srvIDs, conflicts)
+		if err != nil {
+			return err
+		}
 	}
+	log.Debug().Msg("end op")
+	return nil
+}
 
-	updFromSrvSize := len(srvIDs.update)
+// applyServerData writes the server objects listed for the update,
+// the insert and the conflicts to the local data, the others are skipped.
+func (w *Worker) applyServerData(
+	ctx context.Context,
+	srvData []model.SyncPayload,
+	srvIDs lists,
+	conflicts conflicts,
+) error {
+	var (
+		updFromSrvData []model.SyncPayload
+		insFromSrvData []model.SyncPayload
+		conflictData   []model.SyncConflict
+	)
+	for _, o := range srvData {
+		switch {
+		case slices.Contains(srvIDs.update, o.ID):
+			updFromSrvData = append(updFromSrvData, o)
+		case slices.Contains(srvIDs.insert, o.ID):
+			insFromSrvData = append(insFromSrvData, o)
+		default:
+			if locID, ok := conflicts[o.ID]; ok {
+				conflictData = append(conflictData, model.SyncConflict{
+					SyncPayload: o, LocalID: locID,
+				})
+			}
+		}
+	}
 
-	updFromSrvData := srvData[:updFromSrvSize]
-	insFromSrvData := srvData[updFromSrvSize:]
+	if err := w.updateLocal(ctx, updFromSrvData); err != nil {
+		return err
+	}
 
-	err = w.updateLocal(ctx, updFromSrvData)
-	if err != nil {
-		return
+	if err := w.keepConflicts(ctx, conflictData); err != nil {
+		return err
 	}
 
-	w.insertToLocal(ctx, insFromSrvData)
-	log.Debug().Msg("end op")
+	return w.insertToLocal(ctx, insFromSrvData)
+}
+
+func (w *Worker) keepConflicts(
+	ctx context.Context, data []model.SyncConflict,
+) error {
+	const op = "Worker.keepConflicts"
+	log := w.logger.WithOp(op)
+
+	if len(data) == 0 {
+		log.Debug().Msg("no conflicts")
+		return nil
+	}
+
+	log.Debug().Int("conflicts", len(data)).Msg("start keep conflicts")
+	if err := w.local.KeepConflicts(ctx, data); err != nil {
+		log.Error().Err(err).Msg("failed to keep conflicts")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Debug().Msg("keep conflicts successfully")
+	return nil
 }
 
 func (w *Worker) handleLocalData(
 	ctx context.Context, locIDs lists,
-) {
+) error {
 	const op = "Worker.handleLocalData"
 
 	log := w.logger.WithOp(op)
 
 	log.Debug().Msg("start op")
 
-	locData, err := w.getLocalSlice(
-		ctx, append(locIDs.update, locIDs.insert...))
-	if err != nil {
-		return
-	}
-
-	updFromLocSize := len(locIDs.update)
+	IDs := slices.Concat(locIDs.update, locIDs.insert)
+	slices.Sort(IDs)
+	for len(IDs) != 0 {
+		page := IDs[:min(len(IDs), pageSize)]
+		locData, err := w.getLocalSlice(ctx, page)
+		if err != nil {
+			return err
+		}
+		IDs = IDs[w.slicedLen(page, locData):]
 
-	updFromLocData := locData[:updFromLocSize]
-	insFromLocData := locData[updFromLocSize:]
+		var updFromLocData, insFromLocData []model.LocalPayload
+		for _, o := range locData {
+			if slices.Contains(locIDs.update, o.ID) {
+				updFromLocData = append(updFromLocData, o)
+				continue
+			}
+			insFromLocData = append(insFromLocData, o)
+		}
 
-	err = w.updateServer(ctx, updFromLocData)
-	if err != nil {
-		return
+		if err := w.updateServer(ctx, updFromLocData); err != nil {
+			return err
+		}
+		if err := w.insertToServer(ctx, insFromLocData); err != nil {
+			return err
+		}
 	}
-
-	w.insertToServer(ctx, insFromLocData)
 	log.Debug().Msg("end op")
+	return nil
+}
+
+// slicedLen returns the number of the page IDs the local slice is read
+// for, the slice is cut short after its last object if the data is large.
+func (w *Worker) slicedLen(page []int64, locData []model.LocalPayload) int {
+	if len(locData) == 0 {
+		return len(page)
+	}
+	n, _ := slices.BinarySearch(page, locData[len(locData)-1].ID+1)
+	return n
 }
 
 func (w *Worker) compare(
 	locComp []model.LocalComparable, srvComp []model.SyncComparable,
-) (fromSrvLists, fromLocLists lists) {
+) (fromSrvLists, fromLocLists lists, conflicts conflicts) {
 	var notSyncYet []model.SyncComparable
 	syncLocCompMap, newLocCompMap := w.makeLocalComparableMaps(locComp)
+	conflicts = make(map[int64]int64)
 
 	fromSrvLists.update, fromLocLists.update, notSyncYet =
-		w.compareForUpdate(srvComp, syncLocCompMap)
+		w.compareForUpdate(srvComp, syncLocCompMap, conflicts)
 
 	fromSrvLists.insert, fromLocLists.insert =
-		w.compareForInsert(notSyncYet, newLocCompMap)
+		w.compareForInsert(notSyncYet, newLocCompMap, conflicts)
 
 	return
 }
+
+// compareRevised compares the server objects revised since the local data
+// is synchronized, the changes since the cursor or the objects of
+// the diverging ranges, with the local data. The synchronized local objects
+// missing in them are not revised on the server, they are sent if changed
+// since the base version.
+func (w *Worker) compareRevised(
+	locComp []model.LocalComparable, srvComp []model.SyncComparable,
+) (fromSrvLists, fromLocLists lists, conflicts conflicts) {
+	revised := make(map[int64]struct{}, len(srvComp))
+	for _, o := range srvComp {
+		revised[o.ID] = struct{}{}
+	}
+
+	fromSrvLists, fromLocLists, conflicts = w.compare(locComp, srvComp)
+
+	for _, o := range locComp {
+		if o.SyncID == 0 {
+			continue
+		}
+		if _, ok := revised[o.SyncID]; ok {
+			continue
+		}
+		if o.Pending {
+			fromLocLists.update = append(fromLocLists.update, o.ID)
+		}
+	}
+	slices.Sort(fromLocLists.update)
+	return
+}
+
+// payloadComparable returns the comparable server objects with the content
+// hashes written by the clients.
+func (w *Worker) payloadComparable(
+	srvData []model.SyncPayload,
+) []model.SyncComparable {
+	s := make([]model.SyncComparable, 0, len(srvData))
+	for _, o := range srvData {
+		cmp := model.SyncComparable{
+			ID:        o.ID,
+			NameIndex: o.NameIndex,
+			Revision:  o.Revision,
+			Clock:     o.Clock,
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
+		}
+		s = append(s, cmp)
+	}
+	return s
+}
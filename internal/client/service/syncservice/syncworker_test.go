@@ -0,0 +1,650 @@
+package syncservice_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/service/syncservice"
+	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/pkg/hlc"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errReadOnly = errors.New("read only")
+
+// readOnlyLocal fails on every change, the plan must not change the data.
+type readOnlyLocal struct {
+	comp         []model.LocalComparable
+	outbox       []model.OutboxEntry
+	cursor       int64
+	reconciledAt time.Time
+}
+
+func (r *readOnlyLocal) GetComparable(
+	context.Context,
+) ([]model.LocalComparable, error) {
+	return r.comp, nil
+}
+
+func (r *readOnlyLocal) GetLeaves(context.Context) ([]merkle.Leaf, error) {
+	var s []merkle.Leaf
+	for _, o := range r.comp {
+		if o.SyncID != 0 {
+			s = append(s, merkle.Leaf{ID: o.SyncID, Revision: o.Revision})
+		}
+	}
+	return s, nil
+}
+
+func (r *readOnlyLocal) GetAll(
+	context.Context, int64, int,
+) ([]model.LocalPayload, error) {
+	return nil, errReadOnly
+}
+
+func (r *readOnlyLocal) GetSliceByIDs(
+	context.Context, []int64,
+) ([]model.LocalPayload, error) {
+	return nil, errReadOnly
+}
+
+func (r *readOnlyLocal) UpdateSliceBySyncIDs(
+	context.Context, []model.SyncPayload,
+) error {
+	return errReadOnly
+}
+
+func (r *readOnlyLocal) InsertSlice(context.Context, []model.LocalPayload) error {
+	return errReadOnly
+}
+
+func (r *readOnlyLocal) KeepConflicts(
+	context.Context, []model.SyncConflict,
+) error {
+	return errReadOnly
+}
+
+func (r *readOnlyLocal) SetSliceSyncBase(
+	context.Context, []model.SyncBase,
+) error {
+	return errReadOnly
+}
+
+func (r *readOnlyLocal) GetOutbox(
+	context.Context,
+) ([]model.OutboxEntry, error) {
+	return r.outbox, nil
+}
+
+func (r *readOnlyLocal) ReadCursor(context.Context) (int64, error) {
+	return r.cursor, nil
+}
+
+func (r *readOnlyLocal) SaveCursor(context.Context, int64) error {
+	return errReadOnly
+}
+
+func (r *readOnlyLocal) ReadReconciledAt(context.Context) (time.Time, error) {
+	return r.reconciledAt, nil
+}
+
+func (r *readOnlyLocal) SaveReconciled(context.Context, int64) error {
+	return errReadOnly
+}
+
+type readOnlyServer struct {
+	comp    []model.SyncComparable
+	changes []model.SyncPayload
+	token   string
+	rounds  int
+}
+
+func (c *readOnlyServer) Entity() string {
+	return "passwords"
+}
+
+func (c *readOnlyServer) SetToken(token string) {
+	c.token = token
+}
+
+func (c *readOnlyServer) GetComparable(
+	_ context.Context, after int64, limit int,
+) ([]model.SyncComparable, int64, int64, error) {
+	var page []model.SyncComparable
+	for _, o := range c.comp {
+		if o.ID > after && len(page) < limit {
+			page = append(page, o)
+		}
+	}
+	var next int64
+	if len(page) == limit {
+		next = page[len(page)-1].ID
+	}
+	return page, int64(len(c.comp)), next, nil
+}
+
+// GetChangesSince returns the pages of two changes at most.
+func (c *readOnlyServer) GetChangesSince(
+	_ context.Context, cursor int64, limit int,
+) ([]model.SyncPayload, int64, int64, error) {
+	limit = min(limit, 2)
+	var page []model.SyncPayload
+	for _, o := range c.changes {
+		if o.Revision > cursor && len(page) < limit {
+			page = append(page, o)
+		}
+	}
+	var next int64
+	if len(page) != 0 {
+		cursor = page[len(page)-1].Revision
+		if len(page) == limit {
+			next = cursor
+		}
+	}
+	return page, cursor, next, nil
+}
+
+// Reconcile resolves the diverging ranges of two objects at most
+// and splits the larger ones in two.
+func (c *readOnlyServer) Reconcile(
+	_ context.Context, ranges []merkle.Range,
+) ([]merkle.Range, []model.SyncComparable, int64, error) {
+	leaves := make([]merkle.Leaf, 0, len(c.comp))
+	for _, o := range c.comp {
+		leaves = append(leaves, merkle.Leaf{ID: o.ID, Revision: o.Revision})
+	}
+	tree := merkle.New(leaves)
+
+	var (
+		diverged []merkle.Range
+		comp     []model.SyncComparable
+	)
+	for _, r := range ranges {
+		if tree.Range(r.Start, r.End).Hash == r.Hash {
+			continue
+		}
+		if tree.Len(r.Start, r.End) > 2 {
+			diverged = append(diverged, tree.Split(r.Start, r.End, 2)...)
+			continue
+		}
+		for _, o := range c.comp {
+			if r.Start <= o.ID && o.ID < r.End {
+				comp = append(comp, o)
+			}
+		}
+	}
+	c.rounds++
+	return diverged, comp, int64(len(c.comp)), nil
+}
+
+func (c *readOnlyServer) GetAll(
+	context.Context, int64, int,
+) ([]model.SyncPayload, int64, error) {
+	return nil, 0, errReadOnly
+}
+
+func (c *readOnlyServer) GetSliceByIDs(
+	context.Context, []int64,
+) ([]model.SyncPayload, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyServer) UpdateSliceByIDs(
+	context.Context, []model.SyncPayload,
+) ([]model.SyncRevision, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyServer) InsertSlice(
+	context.Context, []model.LocalPayload,
+) ([]model.SyncRevision, error) {
+	return nil, errReadOnly
+}
+
+func localComp(
+	id int64, nameIndex string, clock hlc.Timestamp, syncID int64,
+) model.LocalComparable {
+	return model.LocalComparable{
+		SyncComparable: model.SyncComparable{
+			ID: id, NameIndex: nameIndex, Clock: clock,
+		},
+		SyncID:  syncID,
+		Pending: true,
+	}
+}
+
+func TestWorkerPlan(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewPretty("debug")
+	now := hlc.FromTime(time.Now())
+	before := hlc.FromTime(time.Now().Add(-time.Hour))
+
+	t.Run("ServerNoData", func(t *testing.T) {
+		local := &readOnlyLocal{comp: []model.LocalComparable{
+			localComp(1, "a", now, 0),
+			localComp(2, "b", now, 0),
+		}}
+		server := &readOnlyServer{}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:         "passwords",
+			InsertToServer: []int64{1, 2},
+		}
+		assert.Equal(t, expected, plan)
+		assert.Equal(t, "token", server.token)
+	})
+
+	t.Run("LocalNoData", func(t *testing.T) {
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "a", Revision: 1, Clock: now},
+		}}
+		w := syncservice.NewWorker(log, &readOnlyLocal{}, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:           "passwords",
+			InsertFromServer: []int64{10},
+		}
+		assert.Equal(t, expected, plan)
+	})
+
+	t.Run("CompareWithoutRevisions", func(t *testing.T) {
+		local := &readOnlyLocal{comp: []model.LocalComparable{
+			localComp(1, "a", now, 10),
+			localComp(2, "b", before, 11),
+			localComp(3, "c", now, 0),
+		}}
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "a", Clock: before},
+			{ID: 11, NameIndex: "b", Clock: now},
+			{ID: 12, NameIndex: "d", Revision: 1, Clock: now},
+		}}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:           "passwords",
+			InsertFromServer: []int64{12},
+			UpdateFromServer: []int64{11},
+			InsertToServer:   []int64{3},
+			UpdateToServer:   []int64{1},
+		}
+		assert.Equal(t, expected, plan)
+	})
+
+	t.Run("CompareRevisions", func(t *testing.T) {
+		const baseRev = 5
+		synced := func(
+			id int64, nameIndex string, clock hlc.Timestamp, syncID int64,
+		) model.LocalComparable {
+			o := localComp(id, nameIndex, clock, syncID)
+			o.Revision, o.SyncClock = baseRev, before
+			o.Pending = clock != before
+			return o
+		}
+		skewed := hlc.FromTime(time.Now().Add(24 * time.Hour))
+		local := &readOnlyLocal{comp: []model.LocalComparable{
+			synced(1, "a", now, 10),
+			synced(2, "b", before, 11),
+			synced(3, "c", now, 12),
+			localComp(4, "d", now, 0),
+			synced(5, "e", before, 14),
+			localComp(6, "f", now, 15),
+		}}
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "a", Revision: baseRev + 1, Clock: before},
+			{ID: 11, NameIndex: "b", Revision: baseRev + 1, Clock: before},
+			{ID: 12, NameIndex: "c", Revision: baseRev, Clock: skewed},
+			{ID: 13, NameIndex: "d", Revision: 1, Clock: before},
+			{ID: 14, NameIndex: "e", Revision: baseRev, Clock: skewed},
+			{ID: 15, NameIndex: "f", Revision: 1, Clock: before},
+		}}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:           "passwords",
+			UpdateFromServer: []int64{11},
+			UpdateToServer:   []int64{3},
+			Conflicts:        []int64{1, 4, 6},
+		}
+		assert.Equal(t, expected, plan)
+	})
+
+	t.Run("CompareChanges", func(t *testing.T) {
+		const cursor = 20
+		synced := func(
+			id int64, nameIndex string, clock hlc.Timestamp, syncID int64,
+		) model.LocalComparable {
+			o := localComp(id, nameIndex, clock, syncID)
+			o.Revision, o.SyncClock = 5, before
+			o.Pending = clock != before
+			return o
+		}
+		local := &readOnlyLocal{
+			cursor: cursor, reconciledAt: time.Now(),
+			comp: []model.LocalComparable{
+				synced(1, "a", now, 10),
+				synced(2, "b", before, 11),
+				synced(3, "c", now, 12),
+				synced(4, "d", before, 13),
+				localComp(5, "e", now, 0),
+			},
+		}
+		server := &readOnlyServer{
+			comp: []model.SyncComparable{
+				{ID: 10, NameIndex: "a", Revision: 5, Clock: before},
+			},
+			changes: []model.SyncPayload{
+				{ID: 11, NameIndex: "b", Revision: cursor + 1, Clock: now},
+				{ID: 12, NameIndex: "c", Revision: cursor + 2, Clock: now},
+				{ID: 14, NameIndex: "f", Revision: cursor + 3, Clock: now},
+			},
+		}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:           "passwords",
+			InsertFromServer: []int64{14},
+			UpdateFromServer: []int64{11},
+			InsertToServer:   []int64{5},
+			UpdateToServer:   []int64{1},
+			Conflicts:        []int64{3},
+		}
+		assert.Equal(t, expected, plan,
+			"the objects missing in the changes are not revised on the server")
+		assert.Zero(t, server.rounds)
+	})
+
+	t.Run("ReconcileDue", func(t *testing.T) {
+		o := localComp(1, "a", before, 10)
+		o.Revision, o.SyncClock, o.Pending = 5, before, false
+		local := &readOnlyLocal{
+			cursor: 20, reconciledAt: time.Now().Add(-2 * time.Hour),
+			comp: []model.LocalComparable{o},
+		}
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "a", Revision: 5, Clock: before},
+			{ID: 11, NameIndex: "b", Revision: 6, Clock: now},
+		}}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:           "passwords",
+			InsertFromServer: []int64{11},
+		}
+		assert.Equal(t, expected, plan,
+			"the object missing in the changes is found by the reconcile")
+		assert.NotZero(t, server.rounds)
+	})
+
+	t.Run("AdoptOwnWrites", func(t *testing.T) {
+		const baseRev = 5
+		between := hlc.FromTime(time.Now().Add(-time.Minute))
+		synced := localComp(2, "b", now, 11)
+		synced.Revision, synced.SyncClock = baseRev, before
+		edited := localComp(3, "c", now, 12)
+		edited.Revision, edited.SyncClock = baseRev, before
+		local := &readOnlyLocal{
+			comp: []model.LocalComparable{
+				localComp(1, "a", now, 0), synced, edited,
+			},
+			outbox: []model.OutboxEntry{
+				{ID: 1, EntryID: 1, Op: "create", Key: "k1", Clock: now},
+				{ID: 2, EntryID: 2, Op: "update", Key: "k2", Clock: now},
+				{ID: 3, EntryID: 3, Op: "update", Key: "k3", Clock: between},
+				{ID: 4, EntryID: 3, Op: "update", Key: "k4", Clock: now},
+			},
+		}
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "a", Revision: baseRev + 1, Clock: now, Key: "k1"},
+			{ID: 11, NameIndex: "b", Revision: baseRev + 2, Clock: now, Key: "k2"},
+			{ID: 12, NameIndex: "c", Revision: baseRev + 3, Clock: between,
+				Key: "k3"},
+		}}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:         "passwords",
+			UpdateToServer: []int64{3},
+		}
+		assert.Equal(t, expected, plan,
+			"the server versions written with the pending keys are not conflicts")
+	})
+
+	t.Run("InsertChangedElsewhere", func(t *testing.T) {
+		inserted := localComp(1, "a", now, 0)
+		inserted.UUID = "0191e7a0-7c8a-7b4e-9f3a-1c2d3e4f5a6b"
+		local := &readOnlyLocal{
+			comp: []model.LocalComparable{inserted, localComp(2, "c", now, 0)},
+			outbox: []model.OutboxEntry{
+				{ID: 1, EntryID: 1, Op: "create", Key: "k1", Clock: before},
+				{ID: 2, EntryID: 2, Op: "create", Key: "k2", Clock: now},
+			},
+		}
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "b", Revision: 2, Clock: now, Key: "k9",
+				UUID: inserted.UUID},
+		}}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:         "passwords",
+			InsertToServer: []int64{2},
+			Conflicts:      []int64{1},
+		}
+		assert.Equal(t, expected, plan,
+			"the server object with the local UUID is not inserted again")
+	})
+
+	t.Run("SameContent", func(t *testing.T) {
+		const baseRev = 5
+		synced := func(
+			id int64, nameIndex string, clock hlc.Timestamp, syncID int64,
+			hash string,
+		) model.LocalComparable {
+			o := localComp(id, nameIndex, clock, syncID)
+			o.Revision, o.SyncClock, o.Hash = baseRev, before, hash
+			o.Pending = clock != before
+			return o
+		}
+		legacy := localComp(2, "b", before, 11)
+		legacy.Hash, legacy.Pending = "h2", false
+		local := &readOnlyLocal{comp: []model.LocalComparable{
+			synced(1, "a", now, 10, "h1"),
+			legacy,
+			synced(3, "c", before, 12, "h3"),
+			synced(4, "d", before, 13, ""),
+		}}
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "a", Revision: baseRev + 1, Clock: now,
+				Hash: "h1"},
+			{ID: 11, NameIndex: "b", Clock: now, Hash: "h2"},
+			{ID: 12, NameIndex: "c", Revision: baseRev + 1, Clock: now,
+				Hash: "h4"},
+			{ID: 13, NameIndex: "d", Revision: baseRev + 1, Clock: now},
+		}}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:           "passwords",
+			UpdateFromServer: []int64{12, 13},
+		}
+		assert.Equal(t, expected, plan,
+			"the objects with the same content hash are in sync")
+	})
+
+	t.Run("Reconcile", func(t *testing.T) {
+		const baseRev = 5
+		local := &readOnlyLocal{}
+		server := &readOnlyServer{}
+		for i := range int64(100) {
+			o := localComp(i+1, fmt.Sprint(i), before, 100+i)
+			o.Revision, o.SyncClock, o.Pending = baseRev, before, false
+			local.comp = append(local.comp, o)
+			server.comp = append(server.comp, model.SyncComparable{
+				ID: 100 + i, NameIndex: o.NameIndex, Revision: baseRev,
+				Clock: before,
+			})
+		}
+		server.comp[50].Revision, server.comp[50].Clock = baseRev+1, now
+		server.comp = append(server.comp, model.SyncComparable{
+			ID: 500, NameIndex: "new", Revision: baseRev + 2, Clock: now,
+		})
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Plan(ctx, "token")
+		require.NoError(t, err)
+		expected := dto.SyncPlan{
+			Entity:           "passwords",
+			InsertFromServer: []int64{500},
+			UpdateFromServer: []int64{150},
+		}
+		assert.Equal(t, expected, plan)
+		assert.Less(t, server.rounds, 10,
+			"the diverging ranges are found in the few round trips")
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		local := &readOnlyLocal{comp: []model.LocalComparable{
+			localComp(1, "a", now, 0),
+		}}
+		server := &readOnlyServer{comp: []model.SyncComparable{
+			{ID: 10, NameIndex: "b", Revision: 1, Clock: now},
+		}}
+		w := syncservice.NewWorker(log, local, server)
+
+		plan, err := w.Sync(ctx, "token")
+		assert.ErrorIs(t, err, errReadOnly)
+		assert.Equal(t, []int64{1}, plan.InsertToServer)
+		assert.Equal(t, []int64{10}, plan.InsertFromServer)
+	})
+}
+
+// pagedLocal serves the objects by pages and accepts the sync base,
+// the pages are cut short to maxLen objects if it is set as the pages
+// of the large data.
+type pagedLocal struct {
+	readOnlyLocal
+	data   []model.LocalPayload
+	maxLen int
+}
+
+func (r *pagedLocal) GetAll(
+	_ context.Context, after int64, limit int,
+) ([]model.LocalPayload, error) {
+	if r.maxLen != 0 {
+		limit = min(limit, r.maxLen)
+	}
+	var page []model.LocalPayload
+	for _, o := range r.data {
+		if o.ID > after && len(page) < limit {
+			page = append(page, o)
+		}
+	}
+	return page, nil
+}
+
+func (r *pagedLocal) SetSliceSyncBase(context.Context, []model.SyncBase) error {
+	return nil
+}
+
+func (r *pagedLocal) SaveCursor(context.Context, int64) error {
+	return nil
+}
+
+func (r *pagedLocal) SaveReconciled(context.Context, int64) error {
+	return nil
+}
+
+// batchServer records the lengths of the inserted batches.
+type batchServer struct {
+	readOnlyServer
+	batches []int
+	nextID  int64
+}
+
+func (c *batchServer) InsertSlice(
+	_ context.Context, data []model.LocalPayload,
+) ([]model.SyncRevision, error) {
+	c.batches = append(c.batches, len(data))
+	revisions := make([]model.SyncRevision, len(data))
+	for i := range data {
+		c.nextID++
+		revisions[i] = model.SyncRevision{ID: c.nextID, Revision: c.nextID}
+	}
+	return revisions, nil
+}
+
+func TestWorkerBatches(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewPretty("debug")
+	now := hlc.FromTime(time.Now())
+
+	local := &pagedLocal{}
+	for i := range 250 {
+		id := int64(i + 1)
+		local.comp = append(local.comp, localComp(id, "a", now, 0))
+		o := model.LocalPayload{SyncPayload: model.SyncPayload{ID: id}}
+		switch id {
+		case 10, 11:
+			o.Data = make([]byte, 1<<20+1)
+		case 20:
+			o.Data = make([]byte, 3<<20)
+		}
+		local.data = append(local.data, o)
+	}
+	server := &batchServer{}
+	w := syncservice.NewWorker(log, local, server)
+
+	plan, err := w.Sync(ctx, "token")
+	require.NoError(t, err)
+	assert.Len(t, plan.InsertToServer, 250)
+	assert.Equal(t, []int{10, 9, 1, 80, 100, 50}, server.batches,
+		"the batches are bounded by the count and the data size")
+}
+
+func TestWorkerShortPages(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewPretty("debug")
+	now := hlc.FromTime(time.Now())
+
+	local := &pagedLocal{maxLen: 7}
+	for i := range 30 {
+		id := int64(i + 1)
+		local.comp = append(local.comp, localComp(id, "a", now, 0))
+		local.data = append(local.data,
+			model.LocalPayload{SyncPayload: model.SyncPayload{ID: id}})
+	}
+	server := &batchServer{}
+	w := syncservice.NewWorker(log, local, server)
+
+	plan, err := w.Sync(ctx, "token")
+	require.NoError(t, err)
+	assert.Len(t, plan.InsertToServer, 30,
+		"the short page is not the last one")
+	assert.Equal(t, []int{7, 7, 7, 7, 2}, server.batches)
+}
@@ -0,0 +1,654 @@
+package vaultservice
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/internal/client/service/agentservice"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+)
+
+type (
+	VaultRepo interface {
+		ReadParams(context.Context) ([]byte, error)
+		CreateParams(ctx context.Context, params []byte) error
+		ReadVerifier(context.Context) ([]byte, error)
+		SaveVerifier(ctx context.Context, verifier []byte) error
+		ReadSample(context.Context) (repository.SealedEntry, error)
+		ReadIndexSalt(context.Context) ([]byte, error)
+		Reencrypt(
+			ctx context.Context,
+			params, indexSalt []byte,
+			fn func(data []byte) ([]byte, error),
+			entryFn func(e *repository.SealedEntry) (bool, error),
+		) (int, error)
+	}
+
+	Encrypter interface {
+		SetKey(string)
+		SetParams(cipher.Params)
+		Params() cipher.Params
+		Algorithm() cipher.Algorithm
+		Encrypt([]byte) ([]byte, error)
+		EncryptAD(data, ad []byte) ([]byte, error)
+		EncryptStream(w io.Writer, ad []byte) (io.WriteCloser, error)
+	}
+
+	Decrypter interface {
+		SetKey(string)
+		Decrypt([]byte) ([]byte, error)
+		DecryptAD(data, ad []byte) ([]byte, error)
+		DecryptStream(r io.Reader, ad []byte) (io.Reader, error)
+	}
+
+	Indexer interface {
+		service.ContentHasher
+		SetKey(string)
+		SetSalt([]byte)
+		Salt() []byte
+		Index(string) (string, error)
+	}
+
+	Verifier interface {
+		Verify(ctx context.Context, key string) error
+	}
+
+	AgentLocker interface {
+		Lock(ctx context.Context) error
+	}
+
+	SaltSource interface {
+		GetIndexSalt(ctx context.Context, token string) ([]byte, error)
+	}
+)
+
+var canary = []byte("gophkeeper vault verifier")
+
+type ParamsLoader struct {
+	logger logger.Logger
+	repo   VaultRepo
+	time   uint32
+	memory uint32
+}
+
+func NewParamsLoader(
+	l logger.Logger, r VaultRepo, time, memory uint32,
+) *ParamsLoader {
+	return &ParamsLoader{l, r, time, memory}
+}
+
+// Load returns the key derivation params of the vault. The params
+// with a random salt are created on the first call.
+func (l *ParamsLoader) Load(ctx context.Context) (cipher.Params, error) {
+	const op = "ParamsLoader.Load"
+	log := l.logger.WithOp(op)
+
+	b, err := l.repo.ReadParams(ctx)
+	if err == nil {
+		var p cipher.Params
+		if err := p.UnmarshalBinary(b); err != nil {
+			log.Error().Err(err).Msg("failed to parse vault params")
+			return cipher.Params{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return p, nil
+	}
+
+	if !errors.Is(err, repository.ErrNotExists) {
+		log.Debug().Err(err).Msg("failed to read vault params")
+		return cipher.Params{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p := cipher.NewParams(l.time, l.memory)
+	b, err = p.MarshalBinary()
+	if err != nil {
+		return cipher.Params{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := l.repo.CreateParams(ctx, b); err != nil {
+		log.Debug().Err(err).Msg("failed to create vault params")
+		return cipher.Params{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Debug().Msg("vault params created")
+	return p, nil
+}
+
+type KeyVerifier struct {
+	logger    logger.Logger
+	repo      VaultRepo
+	encrypter Encrypter
+	decrypter Decrypter
+}
+
+func NewKeyVerifier(
+	l logger.Logger, r VaultRepo, e Encrypter, d Decrypter,
+) *KeyVerifier {
+	return &KeyVerifier{l, r, e, d}
+}
+
+// Verify checks the key against the vault verifier. The verifier is created
+// on the first use, if the vault already has entries the key is checked
+// against one of them beforehand.
+func (v *KeyVerifier) Verify(ctx context.Context, key string) error {
+	const op = "KeyVerifier.Verify"
+	log := v.logger.WithOp(op)
+
+	verifier, err := v.repo.ReadVerifier(ctx)
+	if err == nil {
+		return v.compare(key, verifier)
+	}
+
+	if !errors.Is(err, repository.ErrNotExists) {
+		log.Debug().Err(err).Msg("failed to read verifier")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := v.verifySample(ctx, key); err != nil {
+		return err
+	}
+
+	if err := v.createVerifier(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to create verifier")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Debug().Msg("verifier created")
+	return nil
+}
+
+func (v *KeyVerifier) compare(key string, verifier []byte) error {
+	v.decrypter.SetKey(key)
+	b, err := v.decrypter.Decrypt(verifier)
+	if errors.Is(err, cipher.ErrNoKey) {
+		return service.ErrVaultLocked
+	}
+	if err != nil || !bytes.Equal(b, canary) {
+		return service.ErrInvalidKey
+	}
+	return nil
+}
+
+func (v *KeyVerifier) verifySample(ctx context.Context, key string) error {
+	const op = "KeyVerifier.verifySample"
+
+	sample, err := v.repo.ReadSample(ctx)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotExists) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	v.decrypter.SetKey(key)
+	if _, _, err := openData(v.decrypter, &sample); err != nil {
+		if errors.Is(err, cipher.ErrNoKey) {
+			return service.ErrVaultLocked
+		}
+		return service.ErrInvalidKey
+	}
+	return nil
+}
+
+func (v *KeyVerifier) createVerifier(ctx context.Context, key string) error {
+	v.encrypter.SetKey(key)
+	verifier, err := v.encrypter.Encrypt(canary)
+	if err != nil {
+		if errors.Is(err, cipher.ErrNoKey) {
+			return service.ErrVaultLocked
+		}
+		return err
+	}
+	return v.repo.SaveVerifier(ctx, verifier)
+}
+
+type Upgrader struct {
+	logger    logger.Logger
+	repo      VaultRepo
+	verifier  Verifier
+	encrypter Encrypter
+	decrypter Decrypter
+	indexer   Indexer
+	time      uint32
+	memory    uint32
+}
+
+func NewUpgrader(
+	l logger.Logger,
+	r VaultRepo,
+	v Verifier,
+	e Encrypter,
+	d Decrypter,
+	i Indexer,
+	time, memory uint32,
+) *Upgrader {
+	return &Upgrader{l, r, v, e, d, i, time, memory}
+}
+
+// Upgrade reencrypts in place every entry encrypted with the params
+// other than the current vault params, with the other algorithm, without
+// the associated data or with the data bound to the name index instead of
+// the record UUID and encrypts the plaintext names. The vault params
+// weaker than the configured time and memory are replaced by the new ones
+// with the fresh salt, so every entry is reencrypted. The content hashes
+// of the entries written before them are computed, the entries are not
+// counted.
+func (u *Upgrader) Upgrade(ctx context.Context, key string) (int, error) {
+	const op = "Upgrader.Upgrade"
+	log := u.logger.WithOp(op)
+
+	if err := u.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return 0, err
+	}
+
+	params := u.encrypter.Params()
+	if params.Weaker(u.time, u.memory) {
+		params = u.strongerParams(params)
+		u.encrypter.SetParams(params)
+		log.Debug().Uint32("time", params.Time).Uint32(
+			"memory", params.Memory).Msg("raise vault params")
+	}
+	b, err := params.MarshalBinary()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.encrypter.SetKey(key)
+	u.decrypter.SetKey(key)
+	u.indexer.SetKey(key)
+
+	alg := u.encrypter.Algorithm()
+	upgrade := func(data []byte) ([]byte, error) {
+		if isCurrent(data, params, alg) {
+			return nil, nil
+		}
+		return reencrypt(data, u.decrypter, u.encrypter)
+	}
+
+	n, err := u.repo.Reencrypt(ctx, b, u.indexer.Salt(), upgrade,
+		func(e *repository.SealedEntry) (bool, error) {
+			if isSealed(e, params, alg, u.decrypter) {
+				if e.Hashed {
+					return false, nil
+				}
+				return false, hashEntry(e, u.decrypter, u.indexer)
+			}
+			name, data, stream, err := openEntry(e, u.decrypter)
+			if err != nil {
+				return false, err
+			}
+			return true, sealEntry(
+				e, name, data, stream, u.indexer, u.encrypter,
+			)
+		})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidKey) {
+			log.Debug().Err(err).Msg("invalid key")
+			return 0, service.ErrInvalidKey
+		}
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			log.Debug().Err(err).Msg("names are not unique")
+			return 0, service.ErrAlreadyExists
+		}
+		log.Debug().Err(err).Msg("failed to upgrade vault")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Debug().Int("entries", n).Msg("vault upgraded")
+	return n, nil
+}
+
+// strongerParams returns the Argon2id params with the configured costs,
+// the higher Argon2id costs of the current params are kept.
+func (u *Upgrader) strongerParams(cur cipher.Params) cipher.Params {
+	time, memory := u.time, u.memory
+	if cur.KDF == cipher.Argon2id {
+		time, memory = max(time, cur.Time), max(memory, cur.Memory)
+	}
+	return cipher.NewParams(time, memory)
+}
+
+type Rekeyer struct {
+	logger    logger.Logger
+	repo      VaultRepo
+	verifier  Verifier
+	encrypter Encrypter
+	decrypter Decrypter
+	indexer   Indexer
+	agent     AgentLocker
+}
+
+func NewRekeyer(
+	l logger.Logger,
+	r VaultRepo,
+	v Verifier,
+	e Encrypter,
+	d Decrypter,
+	i Indexer,
+	a AgentLocker,
+) *Rekeyer {
+	return &Rekeyer{l, r, v, e, d, i, a}
+}
+
+// Rekey reencrypts every entry and the verifier with the new key
+// and the fresh salt, the name indexes are computed with the new key.
+// Updated entries are pushed by the sync workers. The unlocked agent
+// holds the old key, so it is locked after the rekey.
+func (r *Rekeyer) Rekey(
+	ctx context.Context, key, newKey string,
+) (int, error) {
+	const op = "Rekeyer.Rekey"
+	log := r.logger.WithOp(op)
+
+	if err := r.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return 0, err
+	}
+
+	cur := r.encrypter.Params()
+	params := cipher.NewParams(cur.Time, cur.Memory)
+	b, err := params.MarshalBinary()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	r.decrypter.SetKey(key)
+	r.encrypter.SetKey(newKey)
+	r.encrypter.SetParams(params)
+	r.indexer.SetKey(newKey)
+
+	n, err := r.repo.Reencrypt(ctx, b, r.indexer.Salt(),
+		func(data []byte) ([]byte, error) {
+			return reencrypt(data, r.decrypter, r.encrypter)
+		},
+		func(e *repository.SealedEntry) (bool, error) {
+			name, data, stream, err := openEntry(e, r.decrypter)
+			if err != nil {
+				return false, err
+			}
+			return true, sealEntry(
+				e, name, data, stream, r.indexer, r.encrypter,
+			)
+		})
+	if err != nil {
+		r.encrypter.SetParams(cur)
+		if errors.Is(err, service.ErrInvalidKey) {
+			log.Debug().Err(err).Msg("invalid key")
+			return 0, service.ErrInvalidKey
+		}
+		log.Debug().Err(err).Msg("failed to rekey vault")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Debug().Int("entries", n).Msg("vault rekeyed")
+
+	err = r.agent.Lock(ctx)
+	if err != nil && !errors.Is(err, agentservice.ErrNotUnlocked) {
+		log.Error().Err(err).Msg("failed to lock agent")
+	}
+	return n, nil
+}
+
+type Reindexer struct {
+	logger    logger.Logger
+	repo      VaultRepo
+	verifier  Verifier
+	encrypter Encrypter
+	decrypter Decrypter
+	indexer   Indexer
+	salts     SaltSource
+}
+
+func NewReindexer(
+	l logger.Logger,
+	r VaultRepo,
+	v Verifier,
+	e Encrypter,
+	d Decrypter,
+	i Indexer,
+	s SaltSource,
+) *Reindexer {
+	return &Reindexer{l, r, v, e, d, i, s}
+}
+
+// Reindex gets the index salt of the account and computes the name indexes
+// and the content hashes of every entry with it, if the vault has the other
+// salt. The names are encrypted again, they are bound to the indexes.
+// Updated entries are pushed by the sync workers.
+func (r *Reindexer) Reindex(ctx context.Context, key, token string) (int, error) {
+	const op = "Reindexer.Reindex"
+	log := r.logger.WithOp(op)
+
+	if err := r.verifier.Verify(ctx, key); err != nil {
+		log.Debug().Err(err).Msg("failed to verify key")
+		return 0, err
+	}
+
+	salt, err := r.salts.GetIndexSalt(ctx, token)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get account index salt")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cur := r.indexer.Salt()
+	if bytes.Equal(salt, cur) {
+		return 0, nil
+	}
+
+	b, err := r.encrypter.Params().MarshalBinary()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	r.encrypter.SetKey(key)
+	r.decrypter.SetKey(key)
+	r.indexer.SetKey(key)
+	r.indexer.SetSalt(salt)
+
+	n, err := r.repo.Reencrypt(ctx, b, salt,
+		func(data []byte) ([]byte, error) {
+			return nil, nil
+		},
+		func(e *repository.SealedEntry) (bool, error) {
+			name, data, stream, err := openEntry(e, r.decrypter)
+			if err != nil {
+				return false, err
+			}
+			return true, sealEntry(
+				e, name, data, stream, r.indexer, r.encrypter,
+			)
+		})
+	if err != nil {
+		r.indexer.SetSalt(cur)
+		if errors.Is(err, service.ErrInvalidKey) {
+			log.Debug().Err(err).Msg("invalid key")
+			return 0, service.ErrInvalidKey
+		}
+		log.Debug().Err(err).Msg("failed to reindex vault")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Debug().Int("entries", n).Msg("vault reindexed")
+	return n, nil
+}
+
+func reencrypt(data []byte, d Decrypter, e Encrypter) ([]byte, error) {
+	b, err := d.Decrypt(data)
+	if err != nil {
+		return nil, service.ErrInvalidKey
+	}
+	return e.Encrypt(b)
+}
+
+// isCurrent reports whether the data is encrypted with the params
+// and the algorithm.
+func isCurrent(data []byte, params cipher.Params, alg cipher.Algorithm) bool {
+	return cipher.ReadParams(data).Equal(params) &&
+		cipher.ReadAlgorithm(data) == alg
+}
+
+// isSealed reports whether the entry name, data and stream are bound
+// to the record and encrypted with the params and the algorithm.
+func isSealed(
+	e *repository.SealedEntry,
+	params cipher.Params,
+	alg cipher.Algorithm,
+	d Decrypter,
+) bool {
+	if e.Index == "" {
+		return false
+	}
+	for _, b := range [][]byte{e.Name, e.Data} {
+		if !cipher.IsBound(b) || !isCurrent(b, params, alg) {
+			return false
+		}
+	}
+	ad := service.DataAD(e.Entity, e.UUID)
+	if _, err := d.DecryptAD(e.Data, ad); err != nil {
+		return false
+	}
+	if e.Stream != nil {
+		r := bufio.NewReader(e.Stream)
+		e.Stream = r
+		header, _ := r.Peek(cipher.MaxHeaderSize)
+		return cipher.IsBound(header) && isCurrent(header, params, alg)
+	}
+	return true
+}
+
+// open decrypts the data with the associated data, the data encrypted
+// without the associated data is decrypted as is.
+func open(d Decrypter, data, ad []byte) ([]byte, error) {
+	if cipher.IsBound(data) {
+		return d.DecryptAD(data, ad)
+	}
+	return d.Decrypt(data)
+}
+
+// openData decrypts the entry data, see service.OpenData. The data
+// encrypted without the associated data is decrypted as is. Returns
+// the data and the UUID or the index the stream of the entry is bound to.
+func openData(d Decrypter, e *repository.SealedEntry) ([]byte, string, error) {
+	if !cipher.IsBound(e.Data) {
+		b, err := d.Decrypt(e.Data)
+		return b, e.Index, err
+	}
+	return service.OpenData(d, e.Data, e.Entity, e.UUID, e.Index)
+}
+
+// openEntry returns the plaintext name, data and stream of the entry,
+// the name with the empty index is plaintext.
+func openEntry(
+	e *repository.SealedEntry, d Decrypter,
+) (string, []byte, io.Reader, error) {
+	name := e.Name
+	if e.Index != "" {
+		b, err := open(d, e.Name, service.NameAD(e.Entity, e.Index))
+		if err != nil {
+			return "", nil, nil, service.ErrInvalidKey
+		}
+		name = b
+	}
+	data, bound, err := openData(d, e)
+	if err != nil {
+		return "", nil, nil, service.ErrInvalidKey
+	}
+	if e.Stream == nil {
+		return string(name), data, nil, nil
+	}
+	stream, err := d.DecryptStream(
+		e.Stream, service.StreamAD(e.Entity, bound),
+	)
+	if err != nil {
+		return "", nil, nil, service.ErrInvalidKey
+	}
+	return string(name), data, stream, nil
+}
+
+// sealEntry computes the blind index of the name and encrypts the name
+// bound to the entity type and the index, the data and the stream bound
+// to the entity type and the record UUID. The content hash is summed
+// from the plaintext, so it stays the same.
+func sealEntry(
+	e *repository.SealedEntry,
+	name string,
+	data []byte,
+	stream io.Reader,
+	i Indexer,
+	enc Encrypter,
+) error {
+	index, err := i.Index(name)
+	if err != nil {
+		return err
+	}
+	sealedName, err := enc.EncryptAD(
+		[]byte(name), service.NameAD(e.Entity, index),
+	)
+	if err != nil {
+		return err
+	}
+	sealedData, err := enc.EncryptAD(data, service.DataAD(e.Entity, e.UUID))
+	if err != nil {
+		return err
+	}
+	h, err := service.NewContentHash(i, name)
+	if err != nil {
+		return err
+	}
+	e.Index, e.Name, e.Data = index, sealedName, sealedData
+	if stream == nil {
+		e.Hash = service.SumContent(h, data)
+		return nil
+	}
+	e.SealStream = func(w io.Writer) error {
+		ad := service.StreamAD(e.Entity, e.UUID)
+		err := sealStream(w, io.TeeReader(stream, h), ad, enc)
+		if err != nil {
+			return err
+		}
+		e.Hash = service.SumContent(h, data)
+		return nil
+	}
+	return nil
+}
+
+// hashEntry sets the content hash of the entry, see sealEntry.
+func hashEntry(e *repository.SealedEntry, d Decrypter, i Indexer) error {
+	name, data, stream, err := openEntry(e, d)
+	if err != nil {
+		return err
+	}
+	h, err := service.NewContentHash(i, name)
+	if err != nil {
+		return err
+	}
+	if stream != nil {
+		if _, err := io.Copy(h, stream); err != nil {
+			return streamErr(err)
+		}
+	}
+	e.Hash = service.SumContent(h, data)
+	return nil
+}
+
+func sealStream(w io.Writer, r io.Reader, ad []byte, enc Encrypter) error {
+	sw, err := enc.EncryptStream(w, ad)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(sw, r); err != nil {
+		return streamErr(err)
+	}
+	return sw.Close()
+}
+
+// streamErr reports the stream failed to decrypt as the invalid key.
+func streamErr(err error) error {
+	if errors.Is(err, cipher.ErrTruncated) ||
+		errors.Is(err, cipher.ErrChunkNotValid) {
+		return service.ErrInvalidKey
+	}
+	return err
+}
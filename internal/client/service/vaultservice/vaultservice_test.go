@@ -0,0 +1,581 @@
+package vaultservice_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	stdcipher "crypto/cipher"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/client/dto"
+	"github.com/niksmo/gophkeeper/internal/client/repository"
+	"github.com/niksmo/gophkeeper/internal/client/service"
+	"github.com/niksmo/gophkeeper/internal/client/service/binservice"
+	"github.com/niksmo/gophkeeper/internal/client/service/vaultservice"
+	"github.com/niksmo/gophkeeper/internal/client/storage"
+	"github.com/niksmo/gophkeeper/pkg/cipher"
+	"github.com/niksmo/gophkeeper/pkg/encode"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type suite struct {
+	ctx       context.Context
+	log       logger.Logger
+	s         *storage.Storage
+	vault     *repository.VaultRepository
+	pwd       *repository.Repository
+	encrypter *cipher.Encrypter
+	decrypter *cipher.Decrypter
+	indexer   *cipher.Indexer
+	verifier  *vaultservice.KeyVerifier
+}
+
+func newSuite(t *testing.T) *suite {
+	log := logger.NewPretty("debug")
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		os.Remove(dsn)
+	})
+
+	s := storage.New(log, dsn)
+	s.MustRun(ctx)
+
+	vault := repository.NewVault(log, s)
+	loader := vaultservice.NewParamsLoader(log, vault, 1, 8*1024)
+	params, err := loader.Load(ctx)
+	require.NoError(t, err)
+
+	e := cipher.NewEncrypter()
+	e.SetParams(params)
+	d := cipher.NewDecrypter()
+	v := vaultservice.NewKeyVerifier(log, vault, e, d)
+
+	return &suite{
+		ctx, log, s, vault, repository.NewPwd(log, s), e, d, cipher.NewIndexer(), v,
+	}
+}
+
+func (st *suite) add(t *testing.T, key, name string, data []byte) int {
+	t.Helper()
+	entryUUID := uuid.New()
+	return st.addBound(t, key, name, data, entryUUID, entryUUID)
+}
+
+// addBound adds the entry with the data bound to the record, the record
+// is the UUID or the name index the entries were bound to before.
+func (st *suite) addBound(
+	t *testing.T, key, name string, data []byte, entryUUID, record string,
+) int {
+	t.Helper()
+	st.encrypter.SetKey(key)
+	st.indexer.SetKey(key)
+	index, err := st.indexer.Index(name)
+	require.NoError(t, err)
+	if record == "" {
+		record = index
+	}
+	b, err := st.encrypter.EncryptAD(data, service.DataAD("passwords", record))
+	require.NoError(t, err)
+	sealedName, err := st.encrypter.EncryptAD(
+		[]byte(name), service.NameAD("passwords", index),
+	)
+	require.NoError(t, err)
+	id, err := st.pwd.Create(st.ctx, entryUUID, index, sealedName, b, "")
+	require.NoError(t, err)
+	return id
+}
+
+// sealUnbound returns the data encrypted by the first envelope version
+// with AES-GCM and without the associated data.
+func (st *suite) sealUnbound(t *testing.T, key string, data []byte) []byte {
+	t.Helper()
+	p := st.encrypter.Params()
+	header, err := p.MarshalBinary()
+	require.NoError(t, err)
+	k, err := cipher.DeriveKey(key, p)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(k)
+	require.NoError(t, err)
+	aead, err := stdcipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(append(header, nonce...), nonce, data, nil)
+}
+
+// addUnbound adds the entry encrypted without the associated data.
+func (st *suite) addUnbound(t *testing.T, key, name string, data []byte) int {
+	t.Helper()
+	st.indexer.SetKey(key)
+	b := st.sealUnbound(t, key, data)
+	sealedName := st.sealUnbound(t, key, []byte(name))
+	index, err := st.indexer.Index(name)
+	require.NoError(t, err)
+	id, err := st.pwd.Create(st.ctx, uuid.New(), index, sealedName, b, "")
+	require.NoError(t, err)
+	return id
+}
+
+// names returns the decrypted names by their blind index.
+func (st *suite) names(t *testing.T, key string) map[string]string {
+	t.Helper()
+	sealedNames, err := st.pwd.ListNames(st.ctx)
+	require.NoError(t, err)
+
+	st.decrypter.SetKey(key)
+	names := make(map[string]string)
+	for _, sn := range sealedNames {
+		name, err := st.decrypter.DecryptAD(
+			sn.Name, service.NameAD("passwords", sn.Index),
+		)
+		require.NoError(t, err)
+		names[sn.Index] = string(name)
+	}
+	return names
+}
+
+func index(t *testing.T, key, name string) string {
+	t.Helper()
+	i := cipher.NewIndexer()
+	i.SetKey(key)
+	index, err := i.Index(name)
+	require.NoError(t, err)
+	return index
+}
+
+func (st *suite) read(t *testing.T, key string, id int) ([]byte, error) {
+	t.Helper()
+	e, err := st.pwd.ReadByID(st.ctx, id)
+	require.NoError(t, err)
+	st.decrypter.SetKey(key)
+	return st.decrypter.DecryptAD(e.Data, service.DataAD("passwords", e.UUID))
+}
+
+// saltStub returns the index salt of the account.
+type saltStub struct {
+	salt []byte
+}
+
+func (s saltStub) GetIndexSalt(context.Context, string) ([]byte, error) {
+	return s.salt, nil
+}
+
+// agentStub counts the locks of the agent.
+type agentStub struct {
+	locks int
+}
+
+func (a *agentStub) Lock(context.Context) error {
+	a.locks++
+	return nil
+}
+
+func TestKeyVerifier(t *testing.T) {
+	t.Run("EmptyVault", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+
+		err := st.verifier.Verify(st.ctx, "wrongKey")
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+	})
+
+	t.Run("NotEmptyVault", func(t *testing.T) {
+		st := newSuite(t)
+		st.add(t, "key", "A", []byte("a"))
+
+		err := st.verifier.Verify(st.ctx, "wrongKey")
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+		_, err = st.vault.ReadVerifier(st.ctx)
+		require.ErrorIs(t, err, repository.ErrNotExists)
+
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		_, err = st.vault.ReadVerifier(st.ctx)
+		require.NoError(t, err)
+	})
+}
+
+func TestRekeyer(t *testing.T) {
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		idA := st.add(t, "key", "A", []byte("a"))
+		idB := st.add(t, "key", "B", []byte("b"))
+		oldParams := st.encrypter.Params()
+
+		agent := &agentStub{}
+		r := vaultservice.NewRekeyer(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, agent,
+		)
+		n, err := r.Rekey(st.ctx, "key", "newKey")
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.False(t, oldParams.Equal(st.encrypter.Params()))
+		assert.Equal(t, 1, agent.locks, "the agent with the old key is locked")
+
+		data, err := st.read(t, "newKey", idA)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), data)
+		data, err = st.read(t, "newKey", idB)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("b"), data)
+
+		_, err = st.read(t, "key", idA)
+		require.Error(t, err)
+
+		expectedNames := map[string]string{
+			index(t, "newKey", "A"): "A",
+			index(t, "newKey", "B"): "B",
+		}
+		assert.Equal(t, expectedNames, st.names(t, "newKey"))
+
+		require.NoError(t, st.verifier.Verify(st.ctx, "newKey"))
+		err = st.verifier.Verify(st.ctx, "key")
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+	})
+
+	t.Run("Streams", func(t *testing.T) {
+		st := newSuite(t)
+		bin := repository.NewBinStream(st.log, st.s)
+		bs := binservice.New(
+			st.log, bin, st.verifier, encode.NewEncoder(), encode.NewDecoder(),
+			st.encrypter, st.decrypter, st.indexer,
+		)
+		data := bytes.Repeat([]byte("content"), cipher.StreamChunkSize/3)
+		id, err := bs.Add(
+			st.ctx, "key", "A", dto.BIN{Name: "A"}, bytes.NewReader(data),
+		)
+		require.NoError(t, err)
+
+		r := vaultservice.NewRekeyer(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, &agentStub{},
+		)
+		n, err := r.Rekey(st.ctx, "key", "newKey")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		var buf bytes.Buffer
+		obj, err := bs.Read(st.ctx, "newKey", id, &buf)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), obj.Size)
+		assert.Equal(t, data, buf.Bytes())
+
+		var nStreams int
+		err = st.s.QueryRowContext(st.ctx,
+			`SELECT COUNT(DISTINCT stream_id) FROM binary_chunks;`,
+		).Scan(&nStreams)
+		require.NoError(t, err)
+		assert.Equal(t, 1, nStreams)
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 1, 8*1024,
+		)
+		n, err = u.Upgrade(st.ctx, "newKey")
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		id := st.add(t, "key", "A", []byte("a"))
+
+		agent := &agentStub{}
+		r := vaultservice.NewRekeyer(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, agent,
+		)
+		_, err := r.Rekey(st.ctx, "wrongKey", "newKey")
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+		assert.Zero(t, agent.locks)
+
+		data, err := st.read(t, "key", id)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), data)
+	})
+}
+
+func TestReindexer(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+
+	t.Run("Ordinary", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		st.add(t, "key", "A", []byte("a"))
+		st.add(t, "key", "B", []byte("b"))
+
+		r := vaultservice.NewReindexer(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, saltStub{salt},
+		)
+		n, err := r.Reindex(st.ctx, "key", "token")
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		saved, err := st.vault.ReadIndexSalt(st.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, salt, saved)
+
+		i := cipher.NewIndexer()
+		i.SetKey("key")
+		i.SetSalt(salt)
+		indexA, err := i.Index("A")
+		require.NoError(t, err)
+		indexB, err := i.Index("B")
+		require.NoError(t, err)
+		expectedNames := map[string]string{indexA: "A", indexB: "B"}
+		assert.Equal(t, expectedNames, st.names(t, "key"))
+
+		hash, err := service.HashContent(i, "A", []byte("a"))
+		require.NoError(t, err)
+		comp, err := repository.NewPwdSync(st.log, st.s).GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 2)
+		assert.Contains(t, []string{comp[0].Hash, comp[1].Hash}, hash)
+
+		n, err = r.Reindex(st.ctx, "key", "token")
+		require.NoError(t, err)
+		assert.Zero(t, n, "the vault has the salt of the account")
+	})
+
+	t.Run("LegacyAccount", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		st.add(t, "key", "A", []byte("a"))
+
+		r := vaultservice.NewReindexer(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, saltStub{},
+		)
+		n, err := r.Reindex(st.ctx, "key", "token")
+		require.NoError(t, err)
+		assert.Zero(t, n, "the vault keeps the legacy salt")
+		assert.Equal(t,
+			map[string]string{index(t, "key", "A"): "A"}, st.names(t, "key"))
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		st.add(t, "key", "A", []byte("a"))
+
+		r := vaultservice.NewReindexer(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, saltStub{salt},
+		)
+		_, err := r.Reindex(st.ctx, "wrongKey", "token")
+		require.ErrorIs(t, err, service.ErrInvalidKey)
+		assert.Nil(t, st.indexer.Salt())
+	})
+}
+
+func TestUpgrader(t *testing.T) {
+	t.Run("SealNames", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		st.add(t, "key", "A", []byte("a"))
+
+		b := st.sealUnbound(t, "key", []byte("b"))
+		_, err := st.pwd.Create(st.ctx, uuid.New(), "", []byte("B"), b, "")
+		require.NoError(t, err)
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 1, 8*1024,
+		)
+		n, err := u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		expectedNames := map[string]string{
+			index(t, "key", "A"): "A",
+			index(t, "key", "B"): "B",
+		}
+		assert.Equal(t, expectedNames, st.names(t, "key"))
+	})
+	t.Run("BindEntries", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		idA := st.addUnbound(t, "key", "A", []byte("a"))
+		idB := st.add(t, "key", "B", []byte("b"))
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 1, 8*1024,
+		)
+		n, err := u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		adRequired, err := st.vault.ReadADRequired(st.ctx)
+		require.NoError(t, err)
+		assert.True(t, adRequired)
+
+		entryA, err := st.pwd.ReadByID(st.ctx, idA)
+		require.NoError(t, err)
+		assert.True(t, cipher.IsBound(entryA.Data))
+
+		data, err := st.read(t, "key", idA)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), data)
+
+		entryB, err := st.pwd.ReadByID(st.ctx, idB)
+		require.NoError(t, err)
+		sealedNames, err := st.pwd.ListNames(st.ctx)
+		require.NoError(t, err)
+		nameA := sealedNames[0]
+		require.Equal(t, idA, nameA.ID)
+		err = st.pwd.Update(st.ctx, idA, nameA.Index, nameA.Name, entryB.Data, "")
+		require.NoError(t, err)
+
+		_, err = st.read(t, "key", idA)
+		require.Error(t, err)
+	})
+	t.Run("BindUUID", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		id := st.addBound(t, "key", "A", []byte("a"), uuid.New(), "")
+		st.add(t, "key", "B", []byte("b"))
+
+		_, err := st.read(t, "key", id)
+		require.Error(t, err, "the data is bound to the name index")
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 1, 8*1024,
+		)
+		n, err := u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		data, err := st.read(t, "key", id)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), data)
+	})
+	t.Run("ChangeAlgorithm", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		st.encrypter.SetAlgorithm(cipher.AESGCM)
+		id := st.add(t, "key", "A", []byte("a"))
+		st.encrypter.SetAlgorithm(cipher.XChaCha20Poly1305)
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 1, 8*1024,
+		)
+		n, err := u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		e, err := st.pwd.ReadByID(st.ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, cipher.XChaCha20Poly1305, cipher.ReadAlgorithm(e.Data))
+
+		data, err := st.read(t, "key", id)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), data)
+	})
+	t.Run("KeepContentHash", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		bs := binservice.New(
+			st.log, repository.NewBinStream(st.log, st.s), st.verifier,
+			encode.NewEncoder(), encode.NewDecoder(),
+			st.encrypter, st.decrypter, st.indexer,
+		)
+		st.encrypter.SetAlgorithm(cipher.AESGCM)
+		data := bytes.Repeat([]byte("content"), cipher.StreamChunkSize/3)
+		_, err := bs.Add(
+			st.ctx, "key", "A", dto.BIN{Name: "A"}, bytes.NewReader(data),
+		)
+		require.NoError(t, err)
+		st.encrypter.SetAlgorithm(cipher.XChaCha20Poly1305)
+
+		binSync := repository.NewBinSync(st.log, st.s)
+		comp, err := binSync.GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 1)
+		hash := comp[0].Hash
+		require.NotEmpty(t, hash)
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 1, 8*1024,
+		)
+		n, err := u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		comp, err = binSync.GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 1)
+		assert.Equal(t, hash, comp[0].Hash,
+			"the hash of the encrypted again content is the same")
+	})
+	t.Run("HashEntries", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		st.add(t, "key", "A", []byte("a"))
+
+		pwdSync := repository.NewPwdSync(st.log, st.s)
+		comp, err := pwdSync.GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 1)
+		require.Empty(t, comp[0].Hash)
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 1, 8*1024,
+		)
+		n, err := u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Zero(t, n, "the entry is not encrypted again")
+
+		hash, err := service.HashContent(st.indexer, "A", []byte("a"))
+		require.NoError(t, err)
+		comp, err = pwdSync.GetComparable(st.ctx)
+		require.NoError(t, err)
+		require.Len(t, comp, 1)
+		assert.Equal(t, hash, comp[0].Hash)
+	})
+
+	t.Run("RaiseParams", func(t *testing.T) {
+		st := newSuite(t)
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+		id := st.add(t, "key", "A", []byte("a"))
+		weak := st.encrypter.Params()
+
+		u := vaultservice.NewUpgrader(
+			st.log, st.vault, st.verifier,
+			st.encrypter, st.decrypter, st.indexer, 2, 8*1024,
+		)
+		n, err := u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		b, err := st.vault.ReadParams(st.ctx)
+		require.NoError(t, err)
+		var params cipher.Params
+		require.NoError(t, params.UnmarshalBinary(b))
+		assert.EqualValues(t, 2, params.Time)
+		assert.NotEqual(t, weak.Salt, params.Salt)
+
+		e, err := st.pwd.ReadByID(st.ctx, id)
+		require.NoError(t, err)
+		assert.True(t, params.Equal(cipher.ReadParams(e.Data)))
+		require.NoError(t, st.verifier.Verify(st.ctx, "key"))
+
+		n, err = u.Upgrade(st.ctx, "key")
+		require.NoError(t, err)
+		assert.Zero(t, n, "the params are not raised again")
+	})
+}
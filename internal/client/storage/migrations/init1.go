@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+func init1(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	CREATE TABLE IF NOT EXISTS vault (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	kdf_params BLOB NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+	);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init1", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init10 adds the server change cursor of every entity, the zero cursor
+// makes the full comparison with the server.
+func init10(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE sync_states ADD COLUMN cursor INTEGER NOT NULL DEFAULT 0;
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init10", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init11 adds the journal of the local changes. The entries changed since
+// the last synchronization get the records of their current versions.
+func init11(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	CREATE TABLE IF NOT EXISTS outbox (
+	id INTEGER PRIMARY KEY,
+	entity TEXT NOT NULL,
+	entry_id INTEGER NOT NULL,
+	op TEXT NOT NULL,
+	key TEXT NOT NULL UNIQUE,
+	clock INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	acked_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (entity, entry_id)
+	WHERE acked_at IS NULL;
+
+	INSERT INTO outbox (entity, entry_id, op, key, clock, created_at)
+	SELECT 'passwords', id,
+	  CASE WHEN deleted THEN 'delete' WHEN sync_id IS NULL THEN 'create'
+	  ELSE 'update' END,
+	  lower(hex(randomblob(16))), clock, updated_at
+	FROM passwords
+	WHERE (name_index IS NOT NULL OR sync_id IS NOT NULL)
+	  AND (sync_id IS NULL OR sync_rev IS NULL OR clock<>sync_clock);
+
+	INSERT INTO outbox (entity, entry_id, op, key, clock, created_at)
+	SELECT 'cards', id,
+	  CASE WHEN deleted THEN 'delete' WHEN sync_id IS NULL THEN 'create'
+	  ELSE 'update' END,
+	  lower(hex(randomblob(16))), clock, updated_at
+	FROM cards
+	WHERE (name_index IS NOT NULL OR sync_id IS NOT NULL)
+	  AND (sync_id IS NULL OR sync_rev IS NULL OR clock<>sync_clock);
+
+	INSERT INTO outbox (entity, entry_id, op, key, clock, created_at)
+	SELECT 'texts', id,
+	  CASE WHEN deleted THEN 'delete' WHEN sync_id IS NULL THEN 'create'
+	  ELSE 'update' END,
+	  lower(hex(randomblob(16))), clock, updated_at
+	FROM texts
+	WHERE (name_index IS NOT NULL OR sync_id IS NOT NULL)
+	  AND (sync_id IS NULL OR sync_rev IS NULL OR clock<>sync_clock);
+
+	INSERT INTO outbox (entity, entry_id, op, key, clock, created_at)
+	SELECT 'binaries', id,
+	  CASE WHEN deleted THEN 'delete' WHEN sync_id IS NULL THEN 'create'
+	  ELSE 'update' END,
+	  lower(hex(randomblob(16))), clock, updated_at
+	FROM binaries
+	WHERE (name_index IS NOT NULL OR sync_id IS NOT NULL)
+	  AND (sync_id IS NULL OR sync_rev IS NULL OR clock<>sync_clock);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init11", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
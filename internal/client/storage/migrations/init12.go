@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init12 adds the global identity of the entries. The entries not
+// synchronized yet get the version 7 UUIDs of their created_at time,
+// the synchronized entries get the UUID of their server object with
+// the next server version.
+func init12(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE passwords ADD COLUMN uuid TEXT;
+	ALTER TABLE cards ADD COLUMN uuid TEXT;
+	ALTER TABLE texts ADD COLUMN uuid TEXT;
+	ALTER TABLE binaries ADD COLUMN uuid TEXT;
+	ALTER TABLE conflicts ADD COLUMN uuid TEXT;
+
+	UPDATE passwords
+	SET uuid = (
+	  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+	    ms >> 16, ms & 65535, abs(random()) % 4096,
+	    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+	  FROM (
+	    SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+	)
+	WHERE sync_id IS NULL;
+	UPDATE cards
+	SET uuid = (
+	  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+	    ms >> 16, ms & 65535, abs(random()) % 4096,
+	    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+	  FROM (
+	    SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+	)
+	WHERE sync_id IS NULL;
+	UPDATE texts
+	SET uuid = (
+	  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+	    ms >> 16, ms & 65535, abs(random()) % 4096,
+	    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+	  FROM (
+	    SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+	)
+	WHERE sync_id IS NULL;
+	UPDATE binaries
+	SET uuid = (
+	  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+	    ms >> 16, ms & 65535, abs(random()) % 4096,
+	    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+	  FROM (
+	    SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+	)
+	WHERE sync_id IS NULL;
+
+	CREATE UNIQUE INDEX IF NOT EXISTS passwords_uuid ON passwords (uuid);
+	CREATE UNIQUE INDEX IF NOT EXISTS cards_uuid ON cards (uuid);
+	CREATE UNIQUE INDEX IF NOT EXISTS texts_uuid ON texts (uuid);
+	CREATE UNIQUE INDEX IF NOT EXISTS binaries_uuid ON binaries (uuid);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init12", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init13 adds the keyed hash of the plaintext content of the entries.
+// The hash is written with the entry and is valid for its clock, the vault
+// upgrade computes the hashes of the entries written before.
+func init13(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE passwords ADD COLUMN hash TEXT;
+	ALTER TABLE passwords ADD COLUMN hash_clock INTEGER;
+	ALTER TABLE cards ADD COLUMN hash TEXT;
+	ALTER TABLE cards ADD COLUMN hash_clock INTEGER;
+	ALTER TABLE texts ADD COLUMN hash TEXT;
+	ALTER TABLE texts ADD COLUMN hash_clock INTEGER;
+	ALTER TABLE binaries ADD COLUMN hash TEXT;
+	ALTER TABLE binaries ADD COLUMN hash_clock INTEGER;
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init13", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
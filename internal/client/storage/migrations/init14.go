@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init14 adds the salt of the blind index key of the account, the vault
+// without the salt keeps the legacy one.
+func init14(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE vault ADD COLUMN index_salt BLOB;
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init14", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init15 adds the time of the last reconciliation of the entity with
+// the server.
+func init15(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE sync_states ADD COLUMN reconciled_at TIMESTAMP;
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init15", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
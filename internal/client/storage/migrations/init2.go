@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+func init2(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE vault ADD COLUMN verifier BLOB;
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init2", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
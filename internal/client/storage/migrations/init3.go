@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init3 adds the blind index of the entry name. The names stored before
+// are kept in plaintext with the empty index until the vault upgrade.
+func init3(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE passwords ADD COLUMN name_index TEXT;
+	ALTER TABLE cards ADD COLUMN name_index TEXT;
+	ALTER TABLE texts ADD COLUMN name_index TEXT;
+	ALTER TABLE binaries ADD COLUMN name_index TEXT;
+
+	CREATE UNIQUE INDEX IF NOT EXISTS passwords_name_index
+	ON passwords (name_index);
+	CREATE UNIQUE INDEX IF NOT EXISTS cards_name_index
+	ON cards (name_index);
+	CREATE UNIQUE INDEX IF NOT EXISTS texts_name_index
+	ON texts (name_index);
+	CREATE UNIQUE INDEX IF NOT EXISTS binaries_name_index
+	ON binaries (name_index);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init3", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
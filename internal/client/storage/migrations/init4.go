@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init4 adds the flag of the vault where every entry is bound to its record
+// with the associated data, such vault rejects the unbound entries.
+func init4(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE vault ADD COLUMN ad_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init4", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
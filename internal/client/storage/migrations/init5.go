@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init5 adds the chunked content of the binaries. The binaries saved
+// before have no stream and keep the content in the data.
+func init5(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE binaries ADD COLUMN stream_id TEXT;
+
+	CREATE TABLE IF NOT EXISTS binary_chunks (
+	stream_id TEXT NOT NULL,
+	seq INTEGER NOT NULL,
+	data BLOB NOT NULL,
+	PRIMARY KEY (stream_id, seq)
+	);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init5", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
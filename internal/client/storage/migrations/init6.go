@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init6 adds the sync session, the token is encrypted with the vault key.
+func init6(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	login TEXT NOT NULL,
+	token BLOB NOT NULL,
+	expired BOOLEAN NOT NULL DEFAULT FALSE,
+	updated_at TIMESTAMP NOT NULL
+	);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init6", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
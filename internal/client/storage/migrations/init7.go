@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init7 adds the result of the last synchronization of every entity.
+func init7(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	CREATE TABLE IF NOT EXISTS sync_states (
+	entity TEXT PRIMARY KEY,
+	synced_at TIMESTAMP,
+	error TEXT,
+	updated_at TIMESTAMP NOT NULL
+	);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init7", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init8 adds the base revision of the synchronized entries, it is
+// the server version the entry is changed from, and the conflict copies
+// of the entries changed on the both sides.
+func init8(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	ALTER TABLE passwords ADD COLUMN sync_base TIMESTAMP;
+	ALTER TABLE cards ADD COLUMN sync_base TIMESTAMP;
+	ALTER TABLE texts ADD COLUMN sync_base TIMESTAMP;
+	ALTER TABLE binaries ADD COLUMN sync_base TIMESTAMP;
+
+	CREATE TABLE IF NOT EXISTS conflicts (
+	id INTEGER PRIMARY KEY,
+	entity TEXT NOT NULL,
+	entry_id INTEGER NOT NULL,
+	reason TEXT NOT NULL,
+	sync_id INTEGER,
+	sync_base TIMESTAMP,
+	name_index TEXT,
+	name BLOB,
+	data BLOB,
+	stream_id TEXT,
+	deleted BOOLEAN NOT NULL DEFAULT FALSE,
+	updated_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL
+	);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init8", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
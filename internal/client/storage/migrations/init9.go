@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"context"
+	"time"
+)
+
+// init9 replaces the base revision timestamps with the server revisions
+// and the hybrid logical clock of the device. The clock of the entries
+// saved before is the first timestamp of the updated_at millisecond. The
+// entries synchronized before have no base revision until the next
+// synchronization.
+func init9(ctx context.Context, s Storage) error {
+	stmt := `
+	BEGIN;
+	CREATE TABLE IF NOT EXISTS clock (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	last INTEGER NOT NULL
+	);
+
+	ALTER TABLE passwords ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE passwords ADD COLUMN sync_rev INTEGER;
+	ALTER TABLE passwords ADD COLUMN sync_clock INTEGER;
+	ALTER TABLE passwords DROP COLUMN sync_base;
+	UPDATE passwords
+	SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+
+	ALTER TABLE cards ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE cards ADD COLUMN sync_rev INTEGER;
+	ALTER TABLE cards ADD COLUMN sync_clock INTEGER;
+	ALTER TABLE cards DROP COLUMN sync_base;
+	UPDATE cards
+	SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+
+	ALTER TABLE texts ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE texts ADD COLUMN sync_rev INTEGER;
+	ALTER TABLE texts ADD COLUMN sync_clock INTEGER;
+	ALTER TABLE texts DROP COLUMN sync_base;
+	UPDATE texts
+	SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+
+	ALTER TABLE binaries ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE binaries ADD COLUMN sync_rev INTEGER;
+	ALTER TABLE binaries ADD COLUMN sync_clock INTEGER;
+	ALTER TABLE binaries DROP COLUMN sync_base;
+	UPDATE binaries
+	SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+
+	ALTER TABLE conflicts ADD COLUMN sync_rev INTEGER;
+	ALTER TABLE conflicts ADD COLUMN sync_clock INTEGER;
+	ALTER TABLE conflicts DROP COLUMN sync_base;
+
+	INSERT INTO clock (id, last)
+	SELECT 1, COALESCE(MAX(clock), 0) FROM (
+	  SELECT clock FROM passwords UNION ALL
+	  SELECT clock FROM cards UNION ALL
+	  SELECT clock FROM texts UNION ALL
+	  SELECT clock FROM binaries
+	);
+
+	INSERT INTO migrations (name, created_at) VALUES (?, ?);
+	COMMIT;
+	`
+	_, err := s.ExecContext(ctx, stmt, "init9", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
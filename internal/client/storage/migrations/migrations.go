@@ -8,6 +8,21 @@ import (
 
 var Seq = []func(context.Context, Storage) error{
 	init0,
+	init1,
+	init2,
+	init3,
+	init4,
+	init5,
+	init6,
+	init7,
+	init8,
+	init9,
+	init10,
+	init11,
+	init12,
+	init13,
+	init14,
+	init15,
 }
 
 type Storage interface {
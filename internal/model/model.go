@@ -1,64 +1,220 @@
 package model
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"io"
 	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/hlc"
 )
 
 type Row interface {
 	Scan(dest ...any) error
 }
 
+// SyncComparable is matched by the name blind index, the name itself
+// is encrypted and never leaves the client in plaintext. The Revision is
+// assigned by the server on every write of the object, the Clock is the
+// hybrid logical clock of the device that wrote the version. The Key is
+// the idempotency key of the write, the device recognizes its own write
+// by the key of the pending change. The UUID is the identity of the object
+// generated by the device that created it. The Hash is the keyed hash of
+// the plaintext name and content, it is empty for the object written
+// without the hash.
 type SyncComparable struct {
 	ID        int64
-	Name      string
-	UpdatedAt time.Time
+	NameIndex string
+	Revision  int64
+	Clock     hlc.Timestamp
+	Key       string
+	UUID      string
+	Hash      string
 }
 
 func (sc *SyncComparable) ScanRow(row Row) error {
-	return row.Scan(&sc.ID, &sc.Name, &sc.UpdatedAt)
+	var nameIndex, key, uuid, hash sql.NullString
+	err := row.Scan(
+		&sc.ID, &nameIndex, &sc.Revision, &sc.Clock, &key, &uuid, &hash,
+	)
+	if err != nil {
+		return err
+	}
+	sc.NameIndex = nameIndex.String
+	sc.Key = key.String
+	sc.UUID = uuid.String
+	sc.Hash = hash.String
+	return nil
+}
+
+// Stream is the object data kept out of memory, e.g. in the chunk store
+// of the client or on the server. Every Open reads the data from the start.
+type Stream interface {
+	Open(ctx context.Context) (io.ReadCloser, error)
+	Size() int64
 }
 
+// SyncPayload is the object version. The DataHash is the SHA-256 hex of
+// the Data the server keeps, it is empty for the object written before
+// the data hashes. The object data of the client is the Data followed by
+// the Stream if it is set. The Hash is the keyed hash of the plaintext
+// content computed by the client writing it, it is empty for the object
+// written without the hash.
 type SyncPayload struct {
 	ID        int64
-	Name      string
+	NameIndex string
+	Name      []byte
 	Data      []byte
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	Revision  int64
+	Clock     hlc.Timestamp
 	Deleted   bool
+	Key       string
+	UUID      string
+	DataHash  string
+	Stream    Stream
+	Hash      string
 }
 
 func (sp *SyncPayload) ScanRow(row Row) error {
-	return row.Scan(&sp.ID, &sp.Name, &sp.Data,
-		&sp.CreatedAt, &sp.UpdatedAt, &sp.Deleted)
+	var nameIndex, key, uuid, dataHash, hash sql.NullString
+	err := row.Scan(&sp.ID, &nameIndex, &sp.Name, &sp.Data, &sp.CreatedAt,
+		&sp.Revision, &sp.Clock, &sp.Deleted, &key, &uuid, &dataHash, &hash)
+	if err != nil {
+		return err
+	}
+	sp.NameIndex = nameIndex.String
+	sp.Key = key.String
+	sp.UUID = uuid.String
+	sp.DataHash = dataHash.String
+	sp.Hash = hash.String
+	return nil
+}
+
+// DataSize returns the size of the object data.
+func (sp *SyncPayload) DataSize() int64 {
+	n := int64(len(sp.Data))
+	if sp.Stream != nil {
+		n += sp.Stream.Size()
+	}
+	return n
+}
+
+// OpenData returns the reader of the object data, the Data followed by
+// the Stream.
+func (sp *SyncPayload) OpenData(ctx context.Context) (io.ReadCloser, error) {
+	head := bytes.NewReader(sp.Data)
+	if sp.Stream == nil {
+		return io.NopCloser(head), nil
+	}
+	rc, err := sp.Stream.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return readCloser{io.MultiReader(head, rc), rc}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
 
+// LocalComparable holds the base revision of the synchronized object in
+// the Revision, it is the server revision the object is changed from, and
+// the SyncClock of the base version. The object is changed locally if it
+// has the Pending outbox records. The zero Revision of the synchronized
+// object means it is synchronized before the revisions are kept.
 type LocalComparable struct {
 	SyncComparable
-	SyncID int64
+	SyncID    int64
+	SyncClock hlc.Timestamp
+	Pending   bool
 }
 
 func (lc *LocalComparable) ScanRow(row Row) error {
-	var syncID sql.NullInt64
-	if err := row.Scan(&lc.ID, &lc.Name, &lc.UpdatedAt, &syncID); err != nil {
+	var (
+		nameIndex sql.NullString
+		revision  sql.NullInt64
+		syncID    sql.NullInt64
+		syncClock sql.NullInt64
+		uuid      sql.NullString
+		hash      sql.NullString
+	)
+	err := row.Scan(&lc.ID, &nameIndex, &revision, &lc.Clock, &syncID,
+		&syncClock, &lc.Pending, &uuid, &hash)
+	if err != nil {
 		return err
 	}
+	lc.NameIndex = nameIndex.String
+	lc.UUID = uuid.String
+	lc.Hash = hash.String
+	lc.Revision = revision.Int64
 	lc.SyncID = syncID.Int64
+	lc.SyncClock = hlc.Timestamp(syncClock.Int64)
 	return nil
 }
 
+// LocalPayload holds the base revision in the Revision, see LocalComparable,
+// and the idempotency key of the pending change in the Key.
 type LocalPayload struct {
 	SyncPayload
 	SyncID int64
 }
 
 func (lp *LocalPayload) ScanRow(row Row) error {
-	var syncID sql.NullInt64
-	err := row.Scan(&lp.ID, &lp.Name, &lp.Data, &lp.CreatedAt,
-		&lp.UpdatedAt, &lp.Deleted, &syncID)
+	var (
+		nameIndex sql.NullString
+		revision  sql.NullInt64
+		syncID    sql.NullInt64
+		key       sql.NullString
+		uuid      sql.NullString
+		hash      sql.NullString
+	)
+	err := row.Scan(&lp.ID, &nameIndex, &lp.Name, &lp.Data, &lp.CreatedAt,
+		&revision, &lp.Clock, &lp.Deleted, &syncID, &key, &uuid, &hash)
 	if err != nil {
 		return err
 	}
+	lp.UUID = uuid.String
+	lp.Hash = hash.String
+	lp.NameIndex = nameIndex.String
+	lp.Revision = revision.Int64
 	lp.SyncID = syncID.Int64
+	lp.Key = key.String
 	return nil
 }
+
+// SyncRevision is the revision the server assigns to the written object
+// and the clock of the version the server keeps. The repeated request
+// returns the version written by the first one.
+type SyncRevision struct {
+	ID       int64
+	Revision int64
+	Clock    hlc.Timestamp
+}
+
+// OutboxEntry is the pending local change of the entry EntryID.
+type OutboxEntry struct {
+	ID      int64
+	EntryID int64
+	Op      string
+	Key     string
+	Clock   hlc.Timestamp
+}
+
+// SyncBase is the server version the local object is based on
+// after it is sent to the server.
+type SyncBase struct {
+	ID       int64
+	SyncID   int64
+	Revision int64
+	Clock    hlc.Timestamp
+}
+
+// SyncConflict is the server version of the object that is changed
+// concurrently with the local object LocalID or takes its name.
+type SyncConflict struct {
+	SyncPayload
+	LocalID int64
+}
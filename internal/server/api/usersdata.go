@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/niksmo/gophkeeper/internal/server/interceptors"
 	"github.com/niksmo/gophkeeper/internal/server/service/usersdataservice"
@@ -14,23 +15,70 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-var ErrInvalidEntity = status.Error(codes.InvalidArgument, "invalid entity")
+var (
+	ErrInvalidEntity = status.Error(codes.InvalidArgument, "invalid entity")
+	ErrInvalidUUID   = status.Error(codes.InvalidArgument, "invalid uuid")
+
+	ErrInvalidHash = status.Error(codes.InvalidArgument, "invalid hash")
+	ErrUploadSize  = status.Error(
+		codes.InvalidArgument, "unexpected upload size",
+	)
+	ErrUploadOffset = status.Error(
+		codes.FailedPrecondition, "unexpected upload offset",
+	)
+	ErrUploadTooLarge = status.Error(
+		codes.ResourceExhausted, "upload is too large",
+	)
+	ErrUploadHash     = status.Error(codes.DataLoss, "upload hash mismatch")
+	ErrUploadNotFound = status.Error(
+		codes.FailedPrecondition, "upload not found",
+	)
+	ErrNotFound      = status.Error(codes.NotFound, "object not found")
+	ErrDataChanged   = status.Error(codes.Aborted, "object data changed")
+	ErrInvalidOffset = status.Error(codes.OutOfRange, "invalid offset")
+
+	ErrInvalidRange  = status.Error(codes.InvalidArgument, "invalid range")
+	ErrTooManyRanges = status.Error(
+		codes.InvalidArgument, "too many ranges",
+	)
+)
 
 type UsersDataService interface {
-	GetComparable(ctx context.Context,
-		userID int, entity string) ([]*usrdatapb.Comparable, error)
+	GetComparable(ctx context.Context, userID int, entity string,
+		after, limit int64) ([]*usrdatapb.Comparable, int64, int64, error)
 
-	GetAll(ctx context.Context,
-		userID int, entity string) ([]*usrdatapb.Payload, error)
+	GetAll(ctx context.Context, userID int, entity string,
+		after, limit int64) ([]*usrdatapb.Payload, int64, error)
 
 	GetSliceByIDs(ctx context.Context,
 		userID int, entity string, IDs []int64) ([]*usrdatapb.Payload, error)
 
-	UpdateSliceByIDs(ctx context.Context,
-		entity string, data []*usrdatapb.Payload) error
+	UpdateSliceByIDs(ctx context.Context, userID int, entity, origin string,
+		data []*usrdatapb.Payload) ([]*usrdatapb.Revision, error)
+
+	InsertSlice(ctx context.Context, userID int, entity, origin string,
+		data []*usrdatapb.Payload) ([]*usrdatapb.Revision, error)
 
-	InsertSlice(ctx context.Context,
-		userID int, entity string, data []*usrdatapb.Payload) ([]int64, error)
+	GetChangesSince(ctx context.Context, userID int, entity string,
+		cursor, limit int64) ([]*usrdatapb.Payload, int64, int64, error)
+
+	Subscribe(userID int,
+		origin string) (<-chan *usrdatapb.ChangeEvent, func())
+
+	GetUploadOffset(ctx context.Context, userID int, hash string) (int64, error)
+
+	UploadChunk(ctx context.Context, userID int,
+		hash string, size, offset int64, data []byte) (int64, error)
+
+	Download(ctx context.Context, userID int,
+		entity string, id int64, hash string, offset int64,
+		send func(offset int64, data []byte) error) error
+
+	Reconcile(ctx context.Context, userID int, entity string,
+		ranges []*usrdatapb.Range,
+	) ([]*usrdatapb.Range, []*usrdatapb.Comparable, int64, error)
+
+	GetIndexSalt(ctx context.Context, userID int) ([]byte, error)
 }
 
 type usersDataSyncHandler struct {
@@ -59,7 +107,9 @@ func (h *usersDataSyncHandler) GetComparable(
 		return nil, ErrInternal
 	}
 
-	data, err := h.service.GetComparable(ctx, userID, in.Entity)
+	data, cursor, next, err := h.service.GetComparable(
+		ctx, userID, in.Entity, in.After, in.Limit,
+	)
 	if err != nil {
 		if errors.Is(err, usersdataservice.ErrInvalidEntity) {
 			log.Warn().Str("entity", in.Entity).Msg("invalid entity")
@@ -69,7 +119,9 @@ func (h *usersDataSyncHandler) GetComparable(
 		return nil, ErrInternal
 	}
 
-	return &usrdatapb.GetComparableResponse{Data: data}, nil
+	return &usrdatapb.GetComparableResponse{
+		Data: data, Cursor: cursor, Next: next,
+	}, nil
 }
 
 func (h *usersDataSyncHandler) GetAll(
@@ -84,7 +136,9 @@ func (h *usersDataSyncHandler) GetAll(
 		return nil, ErrInternal
 	}
 
-	data, err := h.service.GetAll(ctx, userID, in.Entity)
+	data, next, err := h.service.GetAll(
+		ctx, userID, in.Entity, in.After, in.Limit,
+	)
 	if err != nil {
 		if errors.Is(err, usersdataservice.ErrInvalidEntity) {
 			return nil, ErrInvalidEntity
@@ -93,7 +147,7 @@ func (h *usersDataSyncHandler) GetAll(
 		return nil, ErrInternal
 	}
 
-	return &usrdatapb.GetAllResponse{Data: data}, nil
+	return &usrdatapb.GetAllResponse{Data: data, Next: next}, nil
 }
 
 func (h *usersDataSyncHandler) GetSlice(
@@ -108,7 +162,7 @@ func (h *usersDataSyncHandler) GetSlice(
 		return nil, ErrInternal
 	}
 
-	data, err := h.service.GetAll(ctx, userID, in.Entity)
+	data, err := h.service.GetSliceByIDs(ctx, userID, in.Entity, in.IDs)
 	if err != nil {
 		if errors.Is(err, usersdataservice.ErrInvalidEntity) {
 			log.Warn().Str("entity", in.Entity).Msg("invalid entity")
@@ -127,17 +181,35 @@ func (h *usersDataSyncHandler) UpdateSlice(
 	const op = "usersDataSyncHandler.UpdateSlice"
 	log := h.logger.WithOp(op)
 
-	err := h.service.UpdateSliceByIDs(ctx, in.Entity, in.Data)
+	userID, err := h.getUserID(ctx)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, ErrInternal
+	}
+
+	revisions, err := h.service.UpdateSliceByIDs(
+		ctx, userID, in.Entity, in.Origin, in.Data,
+	)
 	if err != nil {
 		if errors.Is(err, usersdataservice.ErrInvalidEntity) {
 			log.Warn().Str("entity", in.Entity).Msg("invalid entity")
 			return nil, ErrInvalidEntity
 		}
+		if errors.Is(err, usersdataservice.ErrInvalidUUID) {
+			log.Warn().Msg("invalid uuid")
+			return nil, ErrInvalidUUID
+		}
+		if errors.Is(err, usersdataservice.ErrUploadNotFound) {
+			log.Debug().Msg("upload not found")
+			return nil, ErrUploadNotFound
+		}
 		log.Error().Err(err).Msg("internal error")
 		return nil, ErrInternal
 	}
 
-	return &usrdatapb.UpdateSliceResponse{Ok: true}, nil
+	return &usrdatapb.UpdateSliceResponse{
+		Ok: true, Revisions: revisions,
+	}, nil
 }
 
 func (h *usersDataSyncHandler) InsertSlice(
@@ -152,17 +224,263 @@ func (h *usersDataSyncHandler) InsertSlice(
 		return nil, ErrInternal
 	}
 
-	IDs, err := h.service.InsertSlice(ctx, userID, in.Entity, in.Data)
+	revisions, err := h.service.InsertSlice(
+		ctx, userID, in.Entity, in.Origin, in.Data,
+	)
 	if err != nil {
 		if errors.Is(err, usersdataservice.ErrInvalidEntity) {
 			log.Warn().Str("entity", in.Entity).Msg("invalid entity")
 			return nil, ErrInvalidEntity
 		}
+		if errors.Is(err, usersdataservice.ErrInvalidUUID) {
+			log.Warn().Msg("invalid uuid")
+			return nil, ErrInvalidUUID
+		}
+		if errors.Is(err, usersdataservice.ErrUploadNotFound) {
+			log.Debug().Msg("upload not found")
+			return nil, ErrUploadNotFound
+		}
 		log.Error().Err(err).Msg("internal error")
 		return nil, ErrInternal
 	}
 
-	return &usrdatapb.InsertSliceResponse{IDs: IDs}, nil
+	return &usrdatapb.InsertSliceResponse{Revisions: revisions}, nil
+}
+
+func (h *usersDataSyncHandler) GetChangesSince(
+	ctx context.Context, in *usrdatapb.GetChangesSinceRequest,
+) (*usrdatapb.GetChangesSinceResponse, error) {
+	const op = "usersDataSyncHandler.GetChangesSince"
+	log := h.logger.WithOp(op)
+
+	userID, err := h.getUserID(ctx)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, ErrInternal
+	}
+
+	data, cursor, next, err := h.service.GetChangesSince(
+		ctx, userID, in.Entity, in.Cursor, in.Limit,
+	)
+	if err != nil {
+		if errors.Is(err, usersdataservice.ErrInvalidEntity) {
+			log.Warn().Str("entity", in.Entity).Msg("invalid entity")
+			return nil, ErrInvalidEntity
+		}
+		log.Error().Err(err).Msg("internal error")
+		return nil, ErrInternal
+	}
+
+	return &usrdatapb.GetChangesSinceResponse{
+		Data: data, Cursor: cursor, Next: next,
+	}, nil
+}
+
+// Subscribe streams the change events of the user until the client
+// disconnects or the server stops.
+func (h *usersDataSyncHandler) Subscribe(
+	in *usrdatapb.SubscribeRequest,
+	stream grpc.ServerStreamingServer[usrdatapb.ChangeEvent],
+) error {
+	const op = "usersDataSyncHandler.Subscribe"
+	log := h.logger.WithOp(op)
+
+	ctx := stream.Context()
+	userID, err := h.getUserID(ctx)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return ErrInternal
+	}
+
+	events, cancel := h.service.Subscribe(userID, in.Origin)
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(ev); err != nil {
+				log.Debug().Err(err).Msg("failed to send event")
+				return err
+			}
+		}
+	}
+}
+
+func (h *usersDataSyncHandler) Reconcile(
+	ctx context.Context, in *usrdatapb.ReconcileRequest,
+) (*usrdatapb.ReconcileResponse, error) {
+	const op = "usersDataSyncHandler.Reconcile"
+	log := h.logger.WithOp(op)
+
+	userID, err := h.getUserID(ctx)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, ErrInternal
+	}
+
+	ranges, data, cursor, err := h.service.Reconcile(
+		ctx, userID, in.Entity, in.Ranges,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, usersdataservice.ErrInvalidEntity):
+			log.Warn().Str("entity", in.Entity).Msg("invalid entity")
+			return nil, ErrInvalidEntity
+		case errors.Is(err, usersdataservice.ErrInvalidRange):
+			return nil, ErrInvalidRange
+		case errors.Is(err, usersdataservice.ErrTooManyRanges):
+			return nil, ErrTooManyRanges
+		}
+		log.Error().Err(err).Msg("internal error")
+		return nil, ErrInternal
+	}
+
+	return &usrdatapb.ReconcileResponse{
+		Ranges: ranges, Data: data, Cursor: cursor,
+	}, nil
+}
+
+func (h *usersDataSyncHandler) GetIndexSalt(
+	ctx context.Context, in *usrdatapb.GetIndexSaltRequest,
+) (*usrdatapb.GetIndexSaltResponse, error) {
+	const op = "usersDataSyncHandler.GetIndexSalt"
+	log := h.logger.WithOp(op)
+
+	userID, err := h.getUserID(ctx)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, ErrInternal
+	}
+
+	salt, err := h.service.GetIndexSalt(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("internal error")
+		return nil, ErrInternal
+	}
+
+	return &usrdatapb.GetIndexSaltResponse{Salt: salt}, nil
+}
+
+func (h *usersDataSyncHandler) GetUploadOffset(
+	ctx context.Context, in *usrdatapb.GetUploadOffsetRequest,
+) (*usrdatapb.GetUploadOffsetResponse, error) {
+	const op = "usersDataSyncHandler.GetUploadOffset"
+	log := h.logger.WithOp(op)
+
+	userID, err := h.getUserID(ctx)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, ErrInternal
+	}
+
+	offset, err := h.service.GetUploadOffset(ctx, userID, in.Hash)
+	if err != nil {
+		return nil, h.uploadErr(log, err)
+	}
+	return &usrdatapb.GetUploadOffsetResponse{Offset: offset}, nil
+}
+
+// Upload saves the received chunks until the client closes the stream,
+// the chunks saved before the stream is broken are kept for the resume.
+func (h *usersDataSyncHandler) Upload(
+	stream grpc.ClientStreamingServer[usrdatapb.UploadChunk, usrdatapb.UploadResponse],
+) error {
+	const op = "usersDataSyncHandler.Upload"
+	log := h.logger.WithOp(op)
+
+	var offset int64
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(
+				&usrdatapb.UploadResponse{Offset: offset},
+			)
+		}
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to receive chunk")
+			return err
+		}
+
+		ctx := stream.Context()
+		userID, err := h.getUserID(ctx)
+		if err != nil {
+			log.Error().Err(err).Send()
+			return ErrInternal
+		}
+
+		offset, err = h.service.UploadChunk(ctx, userID,
+			chunk.Hash, chunk.Size, chunk.Offset, chunk.Data)
+		if err != nil {
+			return h.uploadErr(log, err)
+		}
+	}
+}
+
+// Download streams the object data starting at the requested offset.
+func (h *usersDataSyncHandler) Download(
+	in *usrdatapb.DownloadRequest,
+	stream grpc.ServerStreamingServer[usrdatapb.DownloadChunk],
+) error {
+	const op = "usersDataSyncHandler.Download"
+	log := h.logger.WithOp(op)
+
+	ctx := stream.Context()
+	userID, err := h.getUserID(ctx)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return ErrInternal
+	}
+
+	var sendErr error
+	err = h.service.Download(ctx, userID, in.Entity, in.ID, in.Hash, in.Offset,
+		func(offset int64, data []byte) error {
+			chunk := &usrdatapb.DownloadChunk{Offset: offset, Data: data}
+			sendErr = stream.Send(chunk)
+			return sendErr
+		},
+	)
+	if sendErr != nil {
+		log.Debug().Err(sendErr).Msg("failed to send chunk")
+		return sendErr
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, usersdataservice.ErrInvalidEntity):
+			log.Warn().Str("entity", in.Entity).Msg("invalid entity")
+			return ErrInvalidEntity
+		case errors.Is(err, usersdataservice.ErrNotFound):
+			return ErrNotFound
+		case errors.Is(err, usersdataservice.ErrDataChanged):
+			return ErrDataChanged
+		case errors.Is(err, usersdataservice.ErrInvalidOffset):
+			return ErrInvalidOffset
+		}
+		log.Error().Err(err).Msg("internal error")
+		return ErrInternal
+	}
+	return nil
+}
+
+func (h *usersDataSyncHandler) uploadErr(log logger.Logger, err error) error {
+	switch {
+	case errors.Is(err, usersdataservice.ErrInvalidHash):
+		return ErrInvalidHash
+	case errors.Is(err, usersdataservice.ErrUploadSize):
+		return ErrUploadSize
+	case errors.Is(err, usersdataservice.ErrUploadTooLarge):
+		return ErrUploadTooLarge
+	case errors.Is(err, usersdataservice.ErrUploadOffset):
+		return ErrUploadOffset
+	case errors.Is(err, usersdataservice.ErrUploadHash):
+		return ErrUploadHash
+	}
+	log.Error().Err(err).Msg("internal error")
+	return ErrInternal
 }
 
 func (h *usersDataSyncHandler) getUserID(ctx context.Context) (int, error) {
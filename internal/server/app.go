@@ -21,6 +21,7 @@ type App struct {
 	config     *config.Config
 	gRPCServer *grpc.Server
 	storage    *storage.Storage
+	broker     *usersdataservice.Broker
 }
 
 func New(config *config.Config) *App {
@@ -54,11 +55,18 @@ func (a *App) initGRPCServer() {
 	userIDInterceptor := interceptors.NewUseIDInterceptor(
 		a.logger, tokenVerifier,
 	)
-	a.gRPCServer = grpc.NewServer(grpc.ChainUnaryInterceptor(
-		interceptors.WithRecovery(a.logger),
-		interceptors.WithLog(a.logger),
-		interceptors.WithUser(userIDInterceptor),
-	))
+	a.gRPCServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			interceptors.WithRecovery(a.logger),
+			interceptors.WithLog(a.logger),
+			interceptors.WithUser(userIDInterceptor),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptors.WithStreamRecovery(a.logger),
+			interceptors.WithStreamLog(a.logger),
+			interceptors.WithStreamUser(userIDInterceptor),
+		),
+	)
 	a.logger.Info().Str("init", "gRPCServer").Str(
 		"addr", a.config.TCPAddr.String(),
 	).Send()
@@ -85,7 +93,10 @@ func (a *App) registerAuthService() {
 
 func (a *App) registerUsersDataService() {
 	usersDataR := repository.NewUsersDataRepository(a.logger, a.storage)
-	usersDataS := usersdataservice.New(a.logger, usersDataR)
+	a.broker = usersdataservice.NewBroker()
+	usersDataS := usersdataservice.New(
+		a.logger, usersDataR, a.broker, a.config.MaxUploadSize,
+	)
 
 	api.RegisterUsersDataSyncAPI(a.logger, a.gRPCServer, usersDataS)
 	a.logger.Info().Str("register", "UsersDataSynchronizationService").Send()
@@ -118,6 +129,7 @@ func (a *App) Stop() {
 	log.Info().Msg("stopping application")
 
 	log.Info().Msg("gRPC server closing")
+	a.broker.Close()
 	a.gRPCServer.GracefulStop()
 	log.Info().Msg("gRPC server closed successfully")
 
@@ -10,13 +10,17 @@ import (
 	"github.com/spf13/viper"
 )
 
+// defaultMaxUploadSize is the upload limit in MiB if the config has none.
+const defaultMaxUploadSize = 64
+
 type Config struct {
-	LogLevel    string
-	DSN         string
-	HashCost    int
-	TokenSecret []byte
-	TokenTTL    time.Duration
-	TCPAddr     *net.TCPAddr
+	LogLevel      string
+	DSN           string
+	HashCost      int
+	TokenSecret   []byte
+	TokenTTL      time.Duration
+	TCPAddr       *net.TCPAddr
+	MaxUploadSize int64
 }
 
 func MustLoad() *Config {
@@ -29,13 +33,16 @@ func MustLoad() *Config {
 		os.Exit(1)
 	}
 
+	viper.SetDefault("MaxUploadSize", defaultMaxUploadSize)
+
 	c := &Config{
-		LogLevel:    viper.GetString("LogLevel"),
-		DSN:         viper.GetString("DSN"),
-		HashCost:    viper.GetInt("HashCost"),
-		TokenSecret: []byte(viper.GetString("TokenSecret")),
-		TokenTTL:    time.Duration(viper.GetInt("TokenTTL")) * time.Hour,
-		TCPAddr:     mustResolveTCPAddr(viper.GetString("TCPAddr")),
+		LogLevel:      viper.GetString("LogLevel"),
+		DSN:           viper.GetString("DSN"),
+		HashCost:      viper.GetInt("HashCost"),
+		TokenSecret:   []byte(viper.GetString("TokenSecret")),
+		TokenTTL:      time.Duration(viper.GetInt("TokenTTL")) * time.Hour,
+		TCPAddr:       mustResolveTCPAddr(viper.GetString("TCPAddr")),
+		MaxUploadSize: mustPositive("MaxUploadSize") << 20,
 	}
 
 	return c
@@ -70,3 +77,12 @@ func mustResolveTCPAddr(addr string) *net.TCPAddr {
 	}
 	return a
 }
+
+func mustPositive(key string) int64 {
+	v := viper.GetInt64(key)
+	if v <= 0 {
+		fmt.Printf("incorrect %q config: must be positive\n", key)
+		os.Exit(1)
+	}
+	return v
+}
@@ -15,6 +15,13 @@ func WithLog(logger logger.Logger) grpc.UnaryServerInterceptor {
 	)
 }
 
+func WithStreamLog(logger logger.Logger) grpc.StreamServerInterceptor {
+	return logging.StreamServerInterceptor(
+		interceptorLogger(logger),
+		logging.WithLogOnEvents(logging.StartCall, logging.FinishCall),
+	)
+}
+
 func interceptorLogger(logger logger.Logger) logging.Logger {
 	return logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
 		log := logger.With().Fields(fields).Logger()
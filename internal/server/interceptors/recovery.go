@@ -9,8 +9,16 @@ import (
 )
 
 func WithRecovery(log logger.Logger) grpc.UnaryServerInterceptor {
-	return recovery.UnaryServerInterceptor(recovery.WithRecoveryHandler(func(p any) (err error) {
+	return recovery.UnaryServerInterceptor(recoveryHandler(log))
+}
+
+func WithStreamRecovery(log logger.Logger) grpc.StreamServerInterceptor {
+	return recovery.StreamServerInterceptor(recoveryHandler(log))
+}
+
+func recoveryHandler(log logger.Logger) recovery.Option {
+	return recovery.WithRecoveryHandler(func(p any) (err error) {
 		log.Error().Any("panic", p).Msg("panic recovered")
 		return status.Errorf(codes.Internal, "internal error")
-	}))
+	})
 }
@@ -21,6 +21,15 @@ func WithUser(i Interceptor) grpc.UnaryServerInterceptor {
 	return i.Intercept
 }
 
+type StreamInterceptor interface {
+	InterceptStream(srv any, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error
+}
+
+func WithStreamUser(i StreamInterceptor) grpc.StreamServerInterceptor {
+	return i.InterceptStream
+}
+
 type key int8
 
 const UserIDKey key = 0
@@ -49,20 +58,9 @@ func (e UserIDInterceptor) Intercept(ctx context.Context,
 	const op = "UserIDInterceptor.Intercept"
 	log := e.log.With().Str("op", op).Str("method", info.FullMethod).Logger()
 
-	var token string
-	switch r := req.(type) {
-	default:
+	token, ok := requestToken(req)
+	if !ok {
 		return handler(ctx, req)
-	case *pb.GetComparableRequest:
-		token = r.Token
-	case *pb.GetAllRequest:
-		token = r.Token
-	case *pb.GetSliceRequest:
-		token = r.Token
-	case *pb.UpdateSliceRequest:
-		token = r.Token
-	case *pb.InsertSliceRequest:
-		token = r.Token
 	}
 
 	userID, err := e.getUserID(token)
@@ -77,6 +75,30 @@ func (e UserIDInterceptor) Intercept(ctx context.Context,
 	return handler(ctx, req)
 }
 
+// InterceptStream verifies the token of the first stream message, the
+// stream context of the handler holds the userID after it is received.
+func (e UserIDInterceptor) InterceptStream(srv any, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	const op = "UserIDInterceptor.InterceptStream"
+	log := e.log.With().Str("op", op).Str("method", info.FullMethod).Logger()
+
+	return handler(srv, &userStream{ServerStream: ss, ctx: ss.Context(),
+		verify: func(ctx context.Context, m any) (context.Context, error) {
+			token, ok := requestToken(m)
+			if !ok {
+				return ctx, nil
+			}
+			userID, err := e.getUserID(token)
+			if err != nil {
+				log.Warn().Err(err).Msg("invalid token")
+				return ctx, ErrInvalidToken
+			}
+			log.Debug().Int("userID", userID).Send()
+			return e.updateContext(ctx, userID), nil
+		},
+	})
+}
+
 func (e UserIDInterceptor) getUserID(token string) (int, error) {
 	return e.verifier.Verify(token)
 }
@@ -84,3 +106,62 @@ func (e UserIDInterceptor) getUserID(token string) (int, error) {
 func (e UserIDInterceptor) updateContext(ctx context.Context, userID int) context.Context {
 	return context.WithValue(ctx, UserIDKey, UserID(userID))
 }
+
+func requestToken(req any) (string, bool) {
+	switch r := req.(type) {
+	case *pb.GetComparableRequest:
+		return r.Token, true
+	case *pb.GetAllRequest:
+		return r.Token, true
+	case *pb.GetSliceRequest:
+		return r.Token, true
+	case *pb.UpdateSliceRequest:
+		return r.Token, true
+	case *pb.InsertSliceRequest:
+		return r.Token, true
+	case *pb.GetChangesSinceRequest:
+		return r.Token, true
+	case *pb.SubscribeRequest:
+		return r.Token, true
+	case *pb.GetUploadOffsetRequest:
+		return r.Token, true
+	case *pb.UploadChunk:
+		return r.Token, true
+	case *pb.DownloadRequest:
+		return r.Token, true
+	case *pb.ReconcileRequest:
+		return r.Token, true
+	case *pb.GetIndexSaltRequest:
+		return r.Token, true
+	}
+	return "", false
+}
+
+// userStream verifies the first received message and keeps the context
+// updated by it, the next messages of the stream carry no token.
+type userStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	verify   func(ctx context.Context, m any) (context.Context, error)
+	verified bool
+}
+
+func (s *userStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *userStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if s.verified {
+		return nil
+	}
+	ctx, err := s.verify(s.ctx, m)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	s.verified = true
+	return nil
+}
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE passwords ADD COLUMN name_index TEXT;
+ALTER TABLE cards ADD COLUMN name_index TEXT;
+ALTER TABLE texts ADD COLUMN name_index TEXT;
+ALTER TABLE binaries ADD COLUMN name_index TEXT;
+
+COMMIT;
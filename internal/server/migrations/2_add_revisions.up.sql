@@ -0,0 +1,25 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE passwords ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE passwords ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cards ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cards ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE texts ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE texts ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE binaries ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE binaries ADD COLUMN clock INTEGER NOT NULL DEFAULT 0;
+
+-- The objects written before keep the zero revision, their clock is
+-- the first hybrid logical clock timestamp of the updated_at millisecond.
+UPDATE passwords
+SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+UPDATE cards
+SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+UPDATE texts
+SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+UPDATE binaries
+SET clock = CAST(unixepoch(updated_at, 'subsec') * 1000 AS INTEGER) << 16;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- The idempotency key of the last write, the repeated request with the key
+-- returns the written version.
+ALTER TABLE passwords ADD COLUMN op_key TEXT;
+ALTER TABLE cards ADD COLUMN op_key TEXT;
+ALTER TABLE texts ADD COLUMN op_key TEXT;
+ALTER TABLE binaries ADD COLUMN op_key TEXT;
+
+CREATE INDEX IF NOT EXISTS passwords_op_key_idx ON passwords (user_id, op_key);
+CREATE INDEX IF NOT EXISTS cards_op_key_idx ON cards (user_id, op_key);
+CREATE INDEX IF NOT EXISTS texts_op_key_idx ON texts (user_id, op_key);
+CREATE INDEX IF NOT EXISTS binaries_op_key_idx ON binaries (user_id, op_key);
+
+COMMIT;
@@ -0,0 +1,44 @@
+BEGIN;
+
+-- The global identity of the object generated by the client. The objects
+-- written before get the version 7 UUIDs of their created_at time.
+ALTER TABLE passwords ADD COLUMN uuid TEXT;
+ALTER TABLE cards ADD COLUMN uuid TEXT;
+ALTER TABLE texts ADD COLUMN uuid TEXT;
+ALTER TABLE binaries ADD COLUMN uuid TEXT;
+
+UPDATE passwords
+SET uuid = (
+  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+    ms >> 16, ms & 65535, abs(random()) % 4096,
+    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+  FROM (SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+);
+UPDATE cards
+SET uuid = (
+  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+    ms >> 16, ms & 65535, abs(random()) % 4096,
+    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+  FROM (SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+);
+UPDATE texts
+SET uuid = (
+  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+    ms >> 16, ms & 65535, abs(random()) % 4096,
+    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+  FROM (SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+);
+UPDATE binaries
+SET uuid = (
+  SELECT printf('%08x-%04x-7%03x-%04x-%012x',
+    ms >> 16, ms & 65535, abs(random()) % 4096,
+    32768 + abs(random()) % 16384, abs(random()) % 281474976710656)
+  FROM (SELECT CAST(unixepoch(created_at, 'subsec') * 1000 AS INTEGER) AS ms)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS passwords_uuid_idx ON passwords (user_id, uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS cards_uuid_idx ON cards (user_id, uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS texts_uuid_idx ON texts (user_id, uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS binaries_uuid_idx ON binaries (user_id, uuid);
+
+COMMIT;
@@ -0,0 +1,35 @@
+BEGIN;
+
+-- The uploads of the large object data in progress keyed by the SHA-256
+-- hex of the data, the chunks received are kept until the upload
+-- is written to the object, so the interrupted upload is resumed.
+-- The SHA-256 state of the data received is kept with the upload,
+-- the upload is hashed chunk by chunk.
+CREATE TABLE IF NOT EXISTS uploads (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    hash_state BLOB,
+    PRIMARY KEY (user_id, hash)
+);
+
+CREATE TABLE IF NOT EXISTS upload_chunks (
+    user_id INTEGER NOT NULL,
+    hash TEXT NOT NULL,
+    pos INTEGER NOT NULL,
+    data BLOB NOT NULL,
+    PRIMARY KEY (user_id, hash, pos),
+    FOREIGN KEY (user_id, hash) REFERENCES uploads (user_id, hash)
+        ON DELETE CASCADE
+);
+
+-- The SHA-256 hex of the object data is set on every write, so the data
+-- downloaded by the hash is not hashed again. The objects written before
+-- get the hash on the first download.
+ALTER TABLE passwords ADD COLUMN data_hash TEXT;
+ALTER TABLE cards ADD COLUMN data_hash TEXT;
+ALTER TABLE texts ADD COLUMN data_hash TEXT;
+ALTER TABLE binaries ADD COLUMN data_hash TEXT;
+
+COMMIT;
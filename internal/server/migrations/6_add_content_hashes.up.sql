@@ -0,0 +1,11 @@
+BEGIN;
+
+-- The keyed hash of the plaintext content sent by the client is set on
+-- every write, the objects written before have no hash until they are
+-- written again.
+ALTER TABLE passwords ADD COLUMN hash TEXT;
+ALTER TABLE cards ADD COLUMN hash TEXT;
+ALTER TABLE texts ADD COLUMN hash TEXT;
+ALTER TABLE binaries ADD COLUMN hash TEXT;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- The salt of the blind index key of the user vault is generated at the
+-- registration, so the indexes of the same names differ between the users.
+-- The users registered before have no salt, their clients keep the salt
+-- the indexes are computed with.
+ALTER TABLE users ADD COLUMN index_salt BLOB;
+
+COMMIT;
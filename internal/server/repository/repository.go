@@ -9,6 +9,7 @@ import (
 var (
 	ErrNotExists     = errors.New("not exist")
 	ErrAlreadyExists = errors.New("already exists")
+	ErrUUIDMismatch  = errors.New("uuid mismatch")
 )
 
 type Storage interface {
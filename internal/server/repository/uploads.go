@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/hasher"
+)
+
+var (
+	ErrUploadOffset    = errors.New("unexpected upload offset")
+	ErrUploadSize      = errors.New("unexpected upload size")
+	ErrUploadNotExists = errors.New("upload not exist")
+)
+
+// GetUploadOffset returns the length of the upload data received,
+// the zero offset is returned for the unknown upload.
+func (r *UsersDataRepository) GetUploadOffset(
+	ctx context.Context, userID int, hash string,
+) (int64, error) {
+	const op = "UsersDataRepository.GetUploadOffset"
+	log := r.logger.WithOp(op)
+
+	offset, err := r.uploadOffset(ctx, r.db, userID, hash)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select upload offset")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return offset, nil
+}
+
+// SaveUploadChunk appends the chunk to the upload of the size and returns
+// the next offset and the SHA-256 hex of the data received. The digest
+// state is saved with the chunk, so the upload is hashed chunk by chunk.
+// The chunk not starting at the received length is not saved and
+// ErrUploadOffset is returned.
+func (r *UsersDataRepository) SaveUploadChunk(ctx context.Context,
+	userID int, hash string, size, offset int64,
+	data []byte) (int64, string, error) {
+	const op = "UsersDataRepository.SaveUploadChunk"
+	log := r.logger.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO uploads (user_id, hash, size, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, hash) DO UPDATE SET updated_at=excluded.updated_at;`,
+		userID, hash, size, time.Now(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to upsert upload")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		uploadSize int64
+		state      []byte
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT size, hash_state FROM uploads
+		WHERE user_id=? AND hash=?;`, userID, hash,
+	).Scan(&uploadSize, &state)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select upload size")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	if uploadSize != size {
+		return 0, "", fmt.Errorf("%s: %w", op, ErrUploadSize)
+	}
+
+	received, err := r.uploadOffset(ctx, tx, userID, hash)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select upload offset")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	next := offset + int64(len(data))
+	if offset != received || next > size {
+		return received, "", fmt.Errorf("%s: %w", op, ErrUploadOffset)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO upload_chunks (user_id, hash, pos, data)
+		VALUES (?, ?, ?, ?);`,
+		userID, hash, offset, data,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to insert upload chunk")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	digest, err := hasher.RestoreDigest(state)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to restore upload digest")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	digest.Write(data)
+	if state, err = digest.State(); err != nil {
+		log.Error().Err(err).Msg("failed to save upload digest")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE uploads SET hash_state=? WHERE user_id=? AND hash=?;`,
+		state, userID, hash,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to update upload digest")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	return next, digest.Sum(), nil
+}
+
+// writeUpload copies the completed upload to the data of the object
+// chunk by chunk, so the upload is never read into memory as a whole.
+// The concatenation is text in SQLite, so it is cast back to the blob.
+// ErrUploadNotExists is returned for the unknown or incomplete upload.
+func (r *UsersDataRepository) writeUpload(ctx context.Context, tx *sql.Tx,
+	t Table, userID int, id int64, hash string) error {
+	var size int64
+	err := tx.QueryRowContext(ctx,
+		`SELECT size FROM uploads WHERE user_id=? AND hash=?;`, userID, hash,
+	).Scan(&size)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUploadNotExists
+	}
+	if err != nil {
+		return err
+	}
+	received, err := r.uploadOffset(ctx, tx, userID, hash)
+	if err != nil {
+		return err
+	}
+	if received != size {
+		return ErrUploadNotExists
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT pos FROM upload_chunks
+		WHERE user_id=? AND hash=?
+		ORDER BY pos;`, userID, hash,
+	)
+	if err != nil {
+		return err
+	}
+	var positions []int64
+	for rows.Next() {
+		var pos int64
+		if err := rows.Scan(&pos); err != nil {
+			rows.Close()
+			return err
+		}
+		positions = append(positions, pos)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET data=CAST(COALESCE(data, X'') || (
+		  SELECT data FROM upload_chunks WHERE user_id=? AND hash=? AND pos=?
+		) AS BLOB)
+		WHERE id=?;`, t),
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, pos := range positions {
+		_, err := stmt.ExecContext(ctx, userID, hash, pos, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUpload deletes the upload and its chunks.
+func (r *UsersDataRepository) DeleteUpload(
+	ctx context.Context, userID int, hash string,
+) error {
+	const op = "UsersDataRepository.DeleteUpload"
+	log := r.logger.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		`DELETE FROM upload_chunks WHERE user_id=? AND hash=?;`,
+		`DELETE FROM uploads WHERE user_id=? AND hash=?;`,
+	} {
+		if _, err := tx.ExecContext(ctx, q, userID, hash); err != nil {
+			log.Error().Err(err).Msg("failed to delete upload")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteUploadsBefore deletes the uploads of the user not updated since
+// the time.
+func (r *UsersDataRepository) DeleteUploadsBefore(
+	ctx context.Context, userID int, before time.Time,
+) error {
+	const op = "UsersDataRepository.DeleteUploadsBefore"
+	log := r.logger.WithOp(op)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM upload_chunks
+		WHERE user_id=? AND hash IN (
+		  SELECT hash FROM uploads WHERE user_id=? AND updated_at<?
+		);`, userID, userID, before,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete upload chunks")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM uploads WHERE user_id=? AND updated_at<?;`,
+		userID, before,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete uploads")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetDataHash returns the data hash and the data length of the object,
+// ErrNotExists is returned for the unknown object. The hash of the object
+// written before the data hashes is set once here.
+func (r *UsersDataRepository) GetDataHash(
+	ctx context.Context, t Table, userID int, id int64,
+) (string, int64, error) {
+	const op = "UsersDataRepository.GetDataHash"
+	log := r.logger.WithOp(op)
+
+	var (
+		hash sql.NullString
+		size int64
+	)
+	err := r.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT data_hash, COALESCE(length(data), 0) FROM %s
+		WHERE user_id=? AND id=?;`, t), userID, id,
+	).Scan(&hash, &size)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, fmt.Errorf("%s: %w", op, ErrNotExists)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select data hash")
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if hash.Valid {
+		return hash.String, size, nil
+	}
+
+	var data []byte
+	err = r.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT data FROM %s WHERE user_id=? AND id=?;`, t), userID, id,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, fmt.Errorf("%s: %w", op, ErrNotExists)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select data")
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+	sum := hasher.Sum(data)
+	_, err = r.db.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET data_hash=?
+		WHERE user_id=? AND id=? AND data_hash IS NULL AND data=?;`, t),
+		sum, userID, id, data,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to update data hash")
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return sum, int64(len(data)), nil
+}
+
+// ReadData returns up to n bytes of the object data starting at
+// the offset. ErrNotExists is returned if the object data has the other
+// hash, so the data changed between the reads is not mixed.
+func (r *UsersDataRepository) ReadData(ctx context.Context, t Table,
+	userID int, id int64, hash string, offset, n int64) ([]byte, error) {
+	const op = "UsersDataRepository.ReadData"
+	log := r.logger.WithOp(op)
+
+	var data []byte
+	err := r.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT substr(data, ?, ?) FROM %s
+		WHERE user_id=? AND id=? AND data_hash=?;`, t),
+		offset+1, n, userID, id, hash,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotExists)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select data")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return data, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *UsersDataRepository) uploadOffset(
+	ctx context.Context, db queryRower, userID int, hash string,
+) (int64, error) {
+	var offset int64
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(length(data)), 0) FROM upload_chunks
+		WHERE user_id=? AND hash=?;`, userID, hash,
+	).Scan(&offset)
+	return offset, err
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/internal/server/dto"
+	"github.com/niksmo/gophkeeper/internal/server/storage"
+	"github.com/niksmo/gophkeeper/pkg/hasher"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type uploadsSuite struct {
+	t       *testing.T
+	storage Storage
+	repo    *UsersDataRepository
+}
+
+// Before runnint tests see newUsersSuite.
+func newUploadsSuite(t *testing.T) *uploadsSuite {
+	t.Helper()
+
+	dsn := os.Getenv("GOPHKEEPER_TEST_DB")
+	if dsn == "" {
+		t.Skip("Env GOPHKEEPER_TEST_DB not set")
+	}
+	logger := logger.NewPretty("debug")
+	storage := storage.New(logger, dsn)
+	repo := NewUsersDataRepository(logger, storage)
+	st := &uploadsSuite{t, storage, repo}
+
+	t.Cleanup(st.cleanup)
+	return st
+}
+
+func (st *uploadsSuite) cleanup() {
+	st.t.Helper()
+	_, err := st.storage.ExecContext(
+		context.Background(),
+		"DELETE FROM upload_chunks; DELETE FROM uploads;",
+	)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+}
+
+// createUser returns the user deleted with its binaries on cleanup.
+func (st *uploadsSuite) createUser(t *testing.T, login string) dto.User {
+	t.Helper()
+	ctx := context.Background()
+	users := NewUsersRepository(st.repo.logger, st.storage)
+	user, err := users.Create(ctx, login, []byte("hash"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		st.storage.ExecContext(ctx, `
+			DELETE FROM binaries WHERE user_id=?;
+			DELETE FROM users WHERE id=?;`, user.ID, user.ID,
+		)
+	})
+	return user
+}
+
+func TestUploads(t *testing.T) {
+	const (
+		userID = 1
+		hash   = "hash"
+	)
+	ctx := context.Background()
+	data := []byte("0123456789")
+	size := int64(len(data))
+
+	t.Run("Resume", func(t *testing.T) {
+		st := newUploadsSuite(t)
+
+		offset, err := st.repo.GetUploadOffset(ctx, userID, hash)
+		require.NoError(t, err)
+		assert.Zero(t, offset)
+
+		offset, sum, err := st.repo.SaveUploadChunk(
+			ctx, userID, hash, size, 0, data[:4],
+		)
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, offset)
+		assert.Equal(t, hasher.Sum(data[:4]), sum)
+
+		offset, err = st.repo.GetUploadOffset(ctx, userID, hash)
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, offset)
+
+		offset, sum, err = st.repo.SaveUploadChunk(
+			ctx, userID, hash, size, 4, data[4:],
+		)
+		require.NoError(t, err)
+		assert.Equal(t, size, offset)
+		assert.Equal(t, hasher.Sum(data), sum, "the data is hashed by chunks")
+
+		require.NoError(t, st.repo.DeleteUpload(ctx, userID, hash))
+		offset, err = st.repo.GetUploadOffset(ctx, userID, hash)
+		require.NoError(t, err)
+		assert.Zero(t, offset)
+	})
+
+	t.Run("UnexpectedOffset", func(t *testing.T) {
+		st := newUploadsSuite(t)
+
+		_, _, err := st.repo.SaveUploadChunk(
+			ctx, userID, hash, size, 0, data[:4],
+		)
+		require.NoError(t, err)
+
+		offset, _, err := st.repo.SaveUploadChunk(
+			ctx, userID, hash, size, 2, data[2:],
+		)
+		require.ErrorIs(t, err, ErrUploadOffset)
+		assert.EqualValues(t, 4, offset)
+
+		_, _, err = st.repo.SaveUploadChunk(
+			ctx, userID, hash, size+1, 4, data[4:],
+		)
+		require.ErrorIs(t, err, ErrUploadSize)
+	})
+
+	t.Run("DeleteBefore", func(t *testing.T) {
+		st := newUploadsSuite(t)
+
+		_, _, err := st.repo.SaveUploadChunk(
+			ctx, userID, hash, size, 0, data[:4],
+		)
+		require.NoError(t, err)
+
+		err = st.repo.DeleteUploadsBefore(
+			ctx, userID, time.Now().Add(-time.Hour),
+		)
+		require.NoError(t, err)
+		offset, err := st.repo.GetUploadOffset(ctx, userID, hash)
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, offset)
+
+		err = st.repo.DeleteUploadsBefore(
+			ctx, userID, time.Now().Add(time.Hour),
+		)
+		require.NoError(t, err)
+		offset, err = st.repo.GetUploadOffset(ctx, userID, hash)
+		require.NoError(t, err)
+		assert.Zero(t, offset)
+	})
+
+	t.Run("ReadData", func(t *testing.T) {
+		st := newUploadsSuite(t)
+
+		user := st.createUser(t, "uploads")
+
+		revisions, err := st.repo.InsertSlice(ctx, Binaries, user.ID,
+			[]model.SyncPayload{{
+				UUID: uuid.New(), Name: []byte("name"), Data: data,
+				CreatedAt: time.Now(),
+			}},
+		)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		id := revisions[0].ID
+
+		dataHash, n, err := st.repo.GetDataHash(ctx, Binaries, user.ID, id)
+		require.NoError(t, err)
+		assert.Equal(t, hasher.Sum(data), dataHash)
+		assert.Equal(t, size, n)
+
+		chunk, err := st.repo.ReadData(ctx, Binaries, user.ID, id, dataHash, 4, 3)
+		require.NoError(t, err)
+		assert.Equal(t, data[4:7], chunk)
+
+		_, err = st.repo.ReadData(ctx, Binaries, user.ID, id, hash, 4, 3)
+		assert.ErrorIs(t, err, ErrNotExists, "the data changed")
+
+		_, _, err = st.repo.GetDataHash(ctx, Binaries, user.ID, id+1)
+		assert.ErrorIs(t, err, ErrNotExists)
+	})
+
+	t.Run("WriteUpload", func(t *testing.T) {
+		st := newUploadsSuite(t)
+		user := st.createUser(t, "uploads")
+
+		big := make([]byte, InlineDataLimit+10)
+		for i := range big {
+			big[i] = byte(i)
+		}
+		bigSize := int64(len(big))
+		bigHash := hasher.Sum(big)
+		_, _, err := st.repo.SaveUploadChunk(
+			ctx, user.ID, bigHash, bigSize, 0, big[:InlineDataLimit],
+		)
+		require.NoError(t, err)
+
+		payload := []model.SyncPayload{{
+			UUID: uuid.New(), Name: []byte("name"), DataHash: bigHash,
+			CreatedAt: time.Now(),
+		}}
+		_, err = st.repo.InsertSlice(ctx, Binaries, user.ID, payload)
+		require.ErrorIs(t, err, ErrUploadNotExists, "the upload is incomplete")
+
+		_, _, err = st.repo.SaveUploadChunk(ctx, user.ID, bigHash, bigSize,
+			InlineDataLimit, big[InlineDataLimit:])
+		require.NoError(t, err)
+		revisions, err := st.repo.InsertSlice(ctx, Binaries, user.ID, payload)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+
+		objects, err := st.repo.GetAll(ctx, Binaries, user.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+		o := objects[0]
+		assert.Empty(t, o.Data, "the large data is not selected")
+		assert.Equal(t, bigHash, o.DataHash)
+		require.NotNil(t, o.Stream)
+		assert.Equal(t, bigSize, o.DataSize())
+
+		rc, err := o.Stream.Open(ctx)
+		require.NoError(t, err)
+		defer rc.Close()
+		read, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, big, read)
+	})
+}
@@ -21,6 +21,8 @@ func NewUsersRepository(logger logger.Logger, storage Storage) *UsersRepository
 	return &UsersRepository{logger, storage}
 }
 
+// Create creates the user with the random salt of the blind index key
+// of the user vault.
 func (r *UsersRepository) Create(
 	ctx context.Context, login string, pwdHash []byte,
 ) (dto.User, error) {
@@ -29,8 +31,8 @@ func (r *UsersRepository) Create(
 	log := r.logger.WithOp(op)
 
 	stmt := `
-	INSERT INTO users (login, password, created_at)
-	VALUES (?, ?, ?)
+	INSERT INTO users (login, password, created_at, index_salt)
+	VALUES (?, ?, ?, randomblob(16))
 	RETURNING id, login, password, created_at, disabled;
 	`
 
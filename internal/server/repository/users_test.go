@@ -86,6 +86,24 @@ func TestUsers(t *testing.T) {
 			_, err = st.repo.Create(t.Context(), login, password)
 			require.ErrorIs(t, err, ErrAlreadyExists)
 		})
+
+		t.Run("IndexSalt", func(t *testing.T) {
+			st := newUsersSuite(t)
+			data := NewUsersDataRepository(logger.NewPretty("debug"), st.storage)
+
+			salts := make([][]byte, 0, 2)
+			for _, login := range []string{"loginA", "loginB"} {
+				user, err := st.repo.Create(
+					t.Context(), login, []byte("testPassword"),
+				)
+				require.NoError(t, err)
+				salt, err := data.GetIndexSalt(t.Context(), user.ID)
+				require.NoError(t, err)
+				assert.Len(t, salt, 16)
+				salts = append(salts, salt)
+			}
+			assert.NotEqual(t, salts[0], salts[1])
+		})
 	})
 
 	t.Run("Read", func(t *testing.T) {
@@ -2,12 +2,18 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/pkg/hasher"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
 )
 
 type Table int8
@@ -33,6 +39,17 @@ func (t Table) String() string {
 	return ""
 }
 
+// InlineDataLimit is the largest object data read with the payload,
+// the larger data is read by the hash, see ReadData.
+const InlineDataLimit = 1 << 20
+
+var (
+	inlineDataCol = fmt.Sprintf(
+		"CASE WHEN length(data)>%d THEN NULL ELSE data END", InlineDataLimit,
+	)
+	dataSizeCol = "COALESCE(length(data), 0)"
+)
+
 type UsersDataRepository struct {
 	logger logger.Logger
 	db     Storage
@@ -42,56 +59,94 @@ func NewUsersDataRepository(l logger.Logger, s Storage) *UsersDataRepository {
 	return &UsersDataRepository{l, s}
 }
 
+// GetComparable returns the page of the objects with the ID greater than
+// after ordered by ID.
 func (r *UsersDataRepository) GetComparable(
-	ctx context.Context, t Table, userID int,
+	ctx context.Context, t Table, userID int, after, limit int64,
 ) ([]model.SyncComparable, error) {
 	const op = "UsersDataRepository.GetComparable"
 	log := r.logger.WithOp(op)
 
 	stmt := fmt.Sprintf(
-		`SELECT id, name, updated_at FROM %s WHERE user_id=?;`, t,
+		`SELECT id, name_index, revision, clock, op_key, uuid, hash FROM %s
+		WHERE user_id=? AND id>?
+		ORDER BY id
+		LIMIT ?;`, t,
+	)
+
+	return r.queryComparable(ctx, log, op, stmt, userID, after, limit)
+}
+
+// GetComparableByIDs returns the comparable objects with the IDs.
+func (r *UsersDataRepository) GetComparableByIDs(
+	ctx context.Context, t Table, userID int, IDs []int64,
+) ([]model.SyncComparable, error) {
+	const op = "UsersDataRepository.GetComparableByIDs"
+	log := r.logger.WithOp(op)
+
+	stmt := fmt.Sprintf(
+		`SELECT id, name_index, revision, clock, op_key, uuid, hash FROM %s
+		WHERE user_id=? AND id IN (%s)
+		ORDER BY id;`, t, r.makeStrIDList(IDs),
 	)
 
-	rows, err := r.db.QueryContext(ctx, stmt, userID)
+	return r.queryComparable(ctx, log, op, stmt, userID)
+}
+
+// GetLeaves returns the IDs and the revisions of all objects of the user,
+// see merkle.Tree.
+func (r *UsersDataRepository) GetLeaves(
+	ctx context.Context, t Table, userID int,
+) ([]merkle.Leaf, error) {
+	const op = "UsersDataRepository.GetLeaves"
+	log := r.logger.WithOp(op)
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, revision FROM %s WHERE user_id=? ORDER BY id;`, t,
+	), userID)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to select rows")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	var s []model.SyncComparable
+	var s []merkle.Leaf
 	for rows.Next() {
-		var o model.SyncComparable
-		if err := o.ScanRow(rows); err != nil {
+		var l merkle.Leaf
+		if err := rows.Scan(&l.ID, &l.Revision); err != nil {
 			log.Error().Err(err).Msg("failed to scan row")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		s = append(s, o)
+		s = append(s, l)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Error().Err(err).Msg("failed get comparable data")
+		log.Error().Err(err).Msg("failed to select leaves")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-
 	return s, nil
 }
 
+// GetAll returns the page of the objects with the ID greater than after
+// ordered by ID.
 func (r *UsersDataRepository) GetAll(
-	ctx context.Context, t Table, userID int,
+	ctx context.Context, t Table, userID int, after, limit int64,
 ) ([]model.SyncPayload, error) {
 	const op = "UsersDataRepository.GetAll"
 	log := r.logger.WithOp(op)
 
 	stmt := fmt.Sprintf(`
 		SELECT
-			id, name, data, created_at, updated_at, deleted
+			id, name_index, name, %s, created_at, revision, clock, deleted,
+			op_key, uuid, data_hash, hash, %s
 		FROM %s
-		WHERE user_id=?;`,
-		t,
+		WHERE user_id=? AND id>?
+		ORDER BY id
+		LIMIT ?;`,
+		inlineDataCol, dataSizeCol, t,
 	)
 
-	return r.querySlice(ctx, log, op, stmt, userID)
+	return r.querySlice(ctx, log, op, t, userID, stmt, userID, after, limit)
 }
 
 func (r *UsersDataRepository) GetSliceByIDs(
@@ -101,62 +156,193 @@ func (r *UsersDataRepository) GetSliceByIDs(
 	log := r.logger.WithOp(op)
 
 	stmt := fmt.Sprintf(`
-		SELECT id, name, data, created_at, updated_at, deleted, sync_id
+		SELECT
+			id, name_index, name, %s, created_at, revision, clock, deleted,
+			op_key, uuid, data_hash, hash, %s
 		FROM %s
 		WHERE user_id=? AND id IN (%s);`,
-		t, r.makeStrIDList(IDs),
+		inlineDataCol, dataSizeCol, t, r.makeStrIDList(IDs),
 	)
 
-	return r.querySlice(ctx, log, op, stmt, userID)
+	return r.querySlice(ctx, log, op, t, userID, stmt, userID)
 }
 
+// GetChangesSince returns up to limit objects revised after the cursor
+// revision in the revisions order.
+func (r *UsersDataRepository) GetChangesSince(
+	ctx context.Context, t Table, userID int, cursor, limit int64,
+) ([]model.SyncPayload, error) {
+	const op = "UsersDataRepository.GetChangesSince"
+	log := r.logger.WithOp(op)
+
+	stmt := fmt.Sprintf(`
+		SELECT
+			id, name_index, name, %s, created_at, revision, clock, deleted,
+			op_key, uuid, data_hash, hash, %s
+		FROM %s
+		WHERE user_id=? AND revision>?
+		ORDER BY revision
+		LIMIT ?;`,
+		inlineDataCol, dataSizeCol, t,
+	)
+
+	return r.querySlice(ctx, log, op, t, userID, stmt, userID, cursor, limit)
+}
+
+// GetRevision returns the last revision assigned to the objects of the user.
+func (r *UsersDataRepository) GetRevision(
+	ctx context.Context, userID int,
+) (int64, error) {
+	const op = "UsersDataRepository.GetRevision"
+	log := r.logger.WithOp(op)
+
+	var revision int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT revision FROM users WHERE id=?;`, userID,
+	).Scan(&revision)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select user revision")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return revision, nil
+}
+
+// GetIndexSalt returns the salt of the blind index key of the user vault,
+// it is nil for the user registered before the salts.
+func (r *UsersDataRepository) GetIndexSalt(
+	ctx context.Context, userID int,
+) ([]byte, error) {
+	const op = "UsersDataRepository.GetIndexSalt"
+	log := r.logger.WithOp(op)
+
+	var salt []byte
+	err := r.db.QueryRowContext(ctx,
+		`SELECT index_salt FROM users WHERE id=?;`, userID,
+	).Scan(&salt)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select user index salt")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return salt, nil
+}
+
+// UpdateSliceByIDs writes the objects changed from their current revision
+// and assigns them the next revisions of the user. The objects revised
+// since the payload Revision are not written and missing in the result,
+// unless the object is written with the payload Key already. The content
+// and data hashes of the object are set with the write. The UUID of the
+// object is immutable, ErrUUIDMismatch is returned for the not empty
+// payload UUID other than the UUID of the object.
+// The payload without the Data gets the upload of its DataHash, see
+// writeUpload.
 func (r *UsersDataRepository) UpdateSliceByIDs(
-	ctx context.Context, t Table, data []model.SyncPayload,
-) error {
+	ctx context.Context, t Table, userID int, data []model.SyncPayload,
+) ([]model.SyncRevision, error) {
 	const op = "UsersDataRepository.UpdateSliceByIDs"
 	log := r.logger.WithOp(op)
 
 	q := fmt.Sprintf(`
 		UPDATE %s
-		SET name=?, data=?, created_at=?, updated_at=?, deleted=?
-		WHERE id=?;
+		SET name_index=?, name=?, data=?, created_at=?, updated_at=?,
+		  deleted=?, revision=?, clock=?, op_key=?, hash=?, data_hash=?
+		WHERE id=? AND user_id=? AND revision=?
+		  AND (?='' OR uuid IS NULL OR uuid=?);
 		`, t,
 	)
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to begin transaction")
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
 	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to prepare stmt")
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
+	s := make([]model.SyncRevision, 0, len(data))
 	for i, o := range data {
-		_, execErr := stmt.ExecContext(ctx, o.Name, o.Data,
-			o.CreatedAt, o.UpdatedAt, o.Deleted, o.ID)
-		if execErr != nil {
+		revision, err := r.nextRevision(ctx, tx, userID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get next revision")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		res, err := stmt.ExecContext(ctx, o.NameIndex, o.Name, o.Data,
+			o.CreatedAt, time.Now(), o.Deleted, revision, o.Clock,
+			nullKey(o.Key), nullHash(o.Hash), dataHash(o),
+			o.ID, userID, o.Revision, o.UUID, o.UUID)
+		if err != nil {
+			log.Error().Err(err).Int("index", i).Msg("failed to exec update")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
 			log.Error().Err(err).Int("index", i).Msg("failed to exec update")
-			return tx.Rollback()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if n == 0 {
+			written, err := r.selectWritten(ctx, tx, t, userID, o.ID, o.Key)
+			if err == nil {
+				log.Debug().Int64("id", o.ID).Msg("object is written already")
+				s = append(s, written)
+				continue
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Error().Err(err).Int("index", i).Msg(
+					"failed to select written object")
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			if err := r.checkUUID(ctx, tx, t, userID, o); err != nil {
+				log.Debug().Err(err).Int("index", i).Msg(
+					"failed to check object uuid")
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			log.Debug().Int64("id", o.ID).Int64("revision", o.Revision).Msg(
+				"object is revised or not exists")
+			continue
+		}
+		if isUpload(o) {
+			err := r.writeUpload(ctx, tx, t, userID, o.ID, o.DataHash)
+			if err != nil {
+				log.Debug().Err(err).Int("index", i).Msg(
+					"failed to write upload")
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
 		}
+		s = append(s, model.SyncRevision{
+			ID: o.ID, Revision: revision, Clock: o.Clock,
+		})
 	}
-	return tx.Commit()
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return s, nil
 }
 
+// InsertSlice inserts the objects and assigns them the next revisions
+// of the user. The insert is the upsert keyed by the UUID of the object,
+// the object inserted already is not written again and the version
+// the server keeps is returned, so the repeated insert makes no duplicate.
+// The payload without the Data gets the upload of its DataHash.
 func (r *UsersDataRepository) InsertSlice(
 	ctx context.Context, t Table, userID int, data []model.SyncPayload,
-) ([]int64, error) {
+) ([]model.SyncRevision, error) {
 	const op = "UsersDataRepository.InsertSlice"
 	log := r.logger.WithOp(op)
 
 	q := fmt.Sprintf(`
 		INSERT INTO %s
-		  (user_id, name, data, created_at, updated_at, deleted)
-		VALUES (?, ?, ?, ?, ?, ?)
+		  (user_id, uuid, name_index, name, data, created_at, updated_at,
+		  deleted, revision, clock, op_key, hash, data_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, uuid) DO NOTHING
 		RETURNING id;`, t,
 	)
 
@@ -165,6 +351,7 @@ func (r *UsersDataRepository) InsertSlice(
 		log.Error().Err(err).Msg("failed to begin transaction")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
 	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
@@ -173,26 +360,155 @@ func (r *UsersDataRepository) InsertSlice(
 	}
 	defer stmt.Close()
 
-	var s []int64
-
+	s := make([]model.SyncRevision, 0, len(data))
 	for i, o := range data {
+		revision, err := r.nextRevision(ctx, tx, userID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get next revision")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
 		var id int64
-		err := stmt.QueryRowContext(ctx, userID, o.Name, o.Data,
-			o.CreatedAt, o.UpdatedAt, o.Deleted).Scan(&id)
+		err = stmt.QueryRowContext(ctx, userID, o.UUID, o.NameIndex, o.Name,
+			o.Data, o.CreatedAt, time.Now(), o.Deleted, revision, o.Clock,
+			nullKey(o.Key), nullHash(o.Hash), dataHash(o),
+		).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			kept, err := r.selectByUUID(ctx, tx, t, userID, o.UUID)
+			if err != nil {
+				log.Error().Err(err).Int("index", i).Msg(
+					"failed to select inserted object")
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			log.Debug().Int64("id", kept.ID).Msg("object is inserted already")
+			s = append(s, kept)
+			continue
+		}
 		if err != nil {
 			log.Error().Err(err).Int("index", i).Msg(
 				"failed to insert row while iterate")
-			return nil, tx.Rollback()
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		s = append(s, id)
+		if isUpload(o) {
+			err := r.writeUpload(ctx, tx, t, userID, id, o.DataHash)
+			if err != nil {
+				log.Debug().Err(err).Int("index", i).Msg(
+					"failed to write upload")
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		s = append(s, model.SyncRevision{
+			ID: id, Revision: revision, Clock: o.Clock,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return s, nil
+}
+
+// selectWritten returns the version of the object written with the key.
+// The sql.ErrNoRows is returned for the empty key.
+func (r *UsersDataRepository) selectWritten(
+	ctx context.Context, tx *sql.Tx, t Table, userID int, id int64, key string,
+) (model.SyncRevision, error) {
+	if key == "" {
+		return model.SyncRevision{}, sql.ErrNoRows
+	}
+	var o model.SyncRevision
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT id, revision, clock FROM %s
+		WHERE id=? AND user_id=? AND op_key=?;`, t,
+	), id, userID, key).Scan(&o.ID, &o.Revision, &o.Clock)
+	return o, err
+}
+
+// checkUUID returns ErrUUIDMismatch for the not empty payload UUID
+// other than the UUID of the object the server keeps.
+func (r *UsersDataRepository) checkUUID(
+	ctx context.Context, tx *sql.Tx, t Table, userID int, o model.SyncPayload,
+) error {
+	if o.UUID == "" {
+		return nil
+	}
+	var uuid sql.NullString
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT uuid FROM %s WHERE id=? AND user_id=?;`, t,
+	), o.ID, userID).Scan(&uuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if uuid.Valid && uuid.String != o.UUID {
+		return ErrUUIDMismatch
+	}
+	return nil
+}
+
+// selectByUUID returns the version of the object the server keeps.
+func (r *UsersDataRepository) selectByUUID(
+	ctx context.Context, tx *sql.Tx, t Table, userID int, uuid string,
+) (model.SyncRevision, error) {
+	var o model.SyncRevision
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT id, revision, clock FROM %s WHERE user_id=? AND uuid=?;`, t,
+	), userID, uuid).Scan(&o.ID, &o.Revision, &o.Clock)
+	return o, err
+}
+
+// nextRevision increments the revision of the user, so the revisions
+// of the user objects only grow whatever the clocks of the devices are.
+func (r *UsersDataRepository) nextRevision(
+	ctx context.Context, tx *sql.Tx, userID int,
+) (int64, error) {
+	var revision int64
+	err := tx.QueryRowContext(ctx,
+		`UPDATE users SET revision=revision+1 WHERE id=? RETURNING revision;`,
+		userID,
+	).Scan(&revision)
+	return revision, err
+}
+
+func (r *UsersDataRepository) queryComparable(
+	ctx context.Context, log logger.Logger, op string, stmt string, args ...any,
+) ([]model.SyncComparable, error) {
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select rows")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var s []model.SyncComparable
+	for rows.Next() {
+		var o model.SyncComparable
+		if err := o.ScanRow(rows); err != nil {
+			log.Error().Err(err).Msg("failed to scan row")
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s = append(s, o)
 	}
-	return s, tx.Commit()
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed get comparable data")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
 }
 
-func (r *UsersDataRepository) querySlice(
-	ctx context.Context, log logger.Logger, op string, stmt string, userID int,
+// querySlice returns the payloads selected with the inlineDataCol and
+// the dataSizeCol. The data larger than InlineDataLimit is not selected,
+// the payload gets the stream reading it by the hash.
+func (r *UsersDataRepository) querySlice(ctx context.Context,
+	log logger.Logger, op string, t Table, userID int, stmt string,
+	args ...any,
 ) ([]model.SyncPayload, error) {
-	rows, err := r.db.QueryContext(ctx, stmt, userID)
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to select rows")
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -200,26 +516,123 @@ func (r *UsersDataRepository) querySlice(
 	defer rows.Close()
 
 	data := make([]model.SyncPayload, 0)
+	var sizes []int64
 
 	for rows.Next() {
-		var m model.SyncPayload
+		var (
+			m    model.SyncPayload
+			size int64
+		)
 
-		if err := m.ScanRow(rows); err != nil {
+		if err := m.ScanRow(sizedRow{rows, &size}); err != nil {
 			log.Error().Err(err).Msg("failed to scan row")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
 		data = append(data, m)
+		sizes = append(sizes, size)
 	}
 
 	if err := rows.Err(); err != nil {
 		log.Error().Err(err).Msg("failed to get users data rows")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	rows.Close()
+
+	for i := range data {
+		m := &data[i]
+		if sizes[i] <= int64(len(m.Data)) {
+			continue
+		}
+		if m.DataHash == "" {
+			m.DataHash, _, err = r.GetDataHash(ctx, t, userID, m.ID)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to get data hash")
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		m.Stream = &dataStream{r, t, userID, m.ID, m.DataHash, sizes[i]}
+	}
 
 	return data, nil
 }
 
+// sizedRow scans the data size selected after the payload columns.
+type sizedRow struct {
+	model.Row
+	size *int64
+}
+
+func (r sizedRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.size)...)
+}
+
+// dataStream reads the object data larger than InlineDataLimit by
+// the hash, see ReadData.
+type dataStream struct {
+	r      *UsersDataRepository
+	t      Table
+	userID int
+	id     int64
+	hash   string
+	size   int64
+}
+
+func (s *dataStream) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(&dataReader{ctx: ctx, s: s}), nil
+}
+
+func (s *dataStream) Size() int64 {
+	return s.size
+}
+
+type dataReader struct {
+	ctx    context.Context
+	s      *dataStream
+	offset int64
+}
+
+func (d *dataReader) Read(p []byte) (int, error) {
+	if d.offset >= d.s.size {
+		return 0, io.EOF
+	}
+	b, err := d.s.r.ReadData(d.ctx, d.s.t, d.s.userID, d.s.id, d.s.hash,
+		d.offset, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, b)
+	d.offset += int64(n)
+	return n, nil
+}
+
+// isUpload reports whether the payload data is the completed upload
+// referred by the hash.
+func isUpload(o model.SyncPayload) bool {
+	return o.DataHash != "" && len(o.Data) == 0
+}
+
+// dataHash returns the SHA-256 hex of the payload data.
+func dataHash(o model.SyncPayload) string {
+	if isUpload(o) {
+		return o.DataHash
+	}
+	return hasher.Sum(o.Data)
+}
+
+func nullKey(key string) sql.NullString {
+	return sql.NullString{String: key, Valid: key != ""}
+}
+
+// nullHash stores the empty content hash of the object written without
+// the hash as NULL.
+func nullHash(hash string) sql.NullString {
+	return sql.NullString{String: hash, Valid: hash != ""}
+}
+
 func (r *UsersDataRepository) makeStrIDList(sID []int64) string {
 	var b strings.Builder
 	lastIdx := len(sID) - 1
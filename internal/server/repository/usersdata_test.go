@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/internal/server/dto"
+	"github.com/niksmo/gophkeeper/internal/server/storage"
+	"github.com/niksmo/gophkeeper/pkg/hlc"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type usersDataSuite struct {
+	t       *testing.T
+	storage Storage
+	repo    *UsersDataRepository
+}
+
+// Before runnint tests see newUsersSuite.
+func newUsersDataSuite(t *testing.T) *usersDataSuite {
+	t.Helper()
+
+	dsn := os.Getenv("GOPHKEEPER_TEST_DB")
+	if dsn == "" {
+		t.Skip("Env GOPHKEEPER_TEST_DB not set")
+	}
+	logger := logger.NewPretty("debug")
+	storage := storage.New(logger, dsn)
+	repo := NewUsersDataRepository(logger, storage)
+	return &usersDataSuite{t, storage, repo}
+}
+
+// createUser returns the user deleted with its objects on cleanup.
+func (st *usersDataSuite) createUser(t *testing.T, login string) dto.User {
+	t.Helper()
+	ctx := context.Background()
+	users := NewUsersRepository(st.repo.logger, st.storage)
+	user, err := users.Create(ctx, login, []byte("hash"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		st.storage.ExecContext(ctx, `
+			DELETE FROM passwords WHERE user_id=?;
+			DELETE FROM cards WHERE user_id=?;
+			DELETE FROM users WHERE id=?;`, user.ID, user.ID, user.ID,
+		)
+	})
+	return user
+}
+
+func newPayload(name string) model.SyncPayload {
+	return model.SyncPayload{
+		UUID: uuid.New(), NameIndex: name, Name: []byte(name),
+		Data: []byte("data"), CreatedAt: time.Now(),
+		Clock: hlc.FromTime(time.Now()),
+	}
+}
+
+func TestUsersData(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Revisions", func(t *testing.T) {
+		st := newUsersDataSuite(t)
+		user := st.createUser(t, "revisions")
+
+		revision, err := st.repo.GetRevision(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Zero(t, revision)
+
+		payload := []model.SyncPayload{newPayload("a"), newPayload("b")}
+		pwds, err := st.repo.InsertSlice(ctx, Passwords, user.ID, payload)
+		require.NoError(t, err)
+		cards, err := st.repo.InsertSlice(ctx, Cards, user.ID,
+			[]model.SyncPayload{newPayload("c")},
+		)
+		require.NoError(t, err)
+		require.Len(t, pwds, 2)
+		require.Len(t, cards, 1)
+		assert.Equal(t, int64(1), pwds[0].Revision)
+		assert.Equal(t, int64(2), pwds[1].Revision)
+		assert.Equal(t, int64(3), cards[0].Revision,
+			"the revisions are assigned across the tables of the user")
+
+		o := payload[0]
+		o.ID, o.Revision = pwds[0].ID, pwds[0].Revision
+		updated, err := st.repo.UpdateSliceByIDs(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+		require.Len(t, updated, 1)
+		assert.Equal(t, int64(4), updated[0].Revision)
+
+		revision, err = st.repo.GetRevision(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), revision)
+
+		updated, err = st.repo.UpdateSliceByIDs(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+		assert.Empty(t, updated, "the object is revised since the revision")
+
+		other := st.createUser(t, "revisions-other")
+		revisions, err := st.repo.InsertSlice(ctx, Passwords, other.ID,
+			[]model.SyncPayload{newPayload("a")},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), revisions[0].Revision,
+			"the revisions are kept per user")
+	})
+
+	t.Run("WrittenWithKey", func(t *testing.T) {
+		st := newUsersDataSuite(t)
+		user := st.createUser(t, "written")
+
+		o := newPayload("a")
+		inserted, err := st.repo.InsertSlice(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+
+		o.ID, o.Revision, o.Key = inserted[0].ID, inserted[0].Revision, "key"
+		first, err := st.repo.UpdateSliceByIDs(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+		again, err := st.repo.UpdateSliceByIDs(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, first, again,
+			"the repeated write returns the revision written with the key")
+	})
+
+	t.Run("ChangesSince", func(t *testing.T) {
+		st := newUsersDataSuite(t)
+		user := st.createUser(t, "changes")
+
+		payload := []model.SyncPayload{
+			newPayload("a"), newPayload("b"), newPayload("c"),
+		}
+		pwds, err := st.repo.InsertSlice(ctx, Passwords, user.ID, payload)
+		require.NoError(t, err)
+		_, err = st.repo.InsertSlice(ctx, Cards, user.ID,
+			[]model.SyncPayload{newPayload("d")},
+		)
+		require.NoError(t, err)
+		o := payload[0]
+		o.ID, o.Revision = pwds[0].ID, pwds[0].Revision
+		_, err = st.repo.UpdateSliceByIDs(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+
+		revisions := func(data []model.SyncPayload) []int64 {
+			s := make([]int64, 0, len(data))
+			for _, o := range data {
+				s = append(s, o.Revision)
+			}
+			return s
+		}
+
+		changes, err := st.repo.GetChangesSince(ctx, Passwords, user.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2, 3, 5}, revisions(changes),
+			"the changes are ordered by the revisions")
+		assert.Equal(t, pwds[0].ID, changes[2].ID)
+
+		changes, err = st.repo.GetChangesSince(ctx, Passwords, user.ID, 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3}, revisions(changes))
+
+		changes, err = st.repo.GetChangesSince(ctx, Passwords, user.ID, 5, 10)
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+
+		changes, err = st.repo.GetChangesSince(ctx, Cards, user.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{4}, revisions(changes))
+	})
+
+	t.Run("UUIDs", func(t *testing.T) {
+		st := newUsersDataSuite(t)
+		user := st.createUser(t, "uuids")
+
+		o := newPayload("a")
+		first, err := st.repo.InsertSlice(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+		again, err := st.repo.InsertSlice(
+			ctx, Passwords, user.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, first, again,
+			"the repeated insert returns the version the server keeps")
+
+		objects, err := st.repo.GetAll(ctx, Passwords, user.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, objects, 1, "the repeated insert makes no duplicate")
+		assert.Equal(t, o.UUID, objects[0].UUID)
+
+		other := st.createUser(t, "uuids-other")
+		revisions, err := st.repo.InsertSlice(
+			ctx, Passwords, other.ID, []model.SyncPayload{o},
+		)
+		require.NoError(t, err)
+		assert.NotEqual(t, first[0].ID, revisions[0].ID,
+			"the UUIDs are unique per user")
+
+		changed := o
+		changed.ID, changed.Revision = first[0].ID, first[0].Revision
+		changed.UUID = uuid.New()
+		_, err = st.repo.UpdateSliceByIDs(
+			ctx, Passwords, user.ID, []model.SyncPayload{changed},
+		)
+		require.ErrorIs(t, err, ErrUUIDMismatch)
+
+		changed.UUID = ""
+		updated, err := st.repo.UpdateSliceByIDs(
+			ctx, Passwords, user.ID, []model.SyncPayload{changed},
+		)
+		require.NoError(t, err)
+		require.Len(t, updated, 1)
+
+		objects, err = st.repo.GetAll(ctx, Passwords, user.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+		assert.Equal(t, o.UUID, objects[0].UUID,
+			"the update without the UUID keeps it")
+		assert.Equal(t, updated[0].Revision, objects[0].Revision)
+	})
+
+	t.Run("Leaves", func(t *testing.T) {
+		st := newUsersDataSuite(t)
+		user := st.createUser(t, "leaves")
+
+		revisions, err := st.repo.InsertSlice(ctx, Passwords, user.ID,
+			[]model.SyncPayload{newPayload("a"), newPayload("b")},
+		)
+		require.NoError(t, err)
+		_, err = st.repo.InsertSlice(ctx, Cards, user.ID,
+			[]model.SyncPayload{newPayload("c")},
+		)
+		require.NoError(t, err)
+
+		leaves, err := st.repo.GetLeaves(ctx, Passwords, user.ID)
+		require.NoError(t, err)
+		expected := []merkle.Leaf{
+			{ID: revisions[0].ID, Revision: revisions[0].Revision},
+			{ID: revisions[1].ID, Revision: revisions[1].Revision},
+		}
+		assert.Equal(t, expected, leaves)
+
+		comp, err := st.repo.GetComparableByIDs(
+			ctx, Passwords, user.ID, []int64{revisions[1].ID},
+		)
+		require.NoError(t, err)
+		require.Len(t, comp, 1)
+		assert.Equal(t, "b", comp[0].NameIndex)
+		assert.Equal(t, revisions[1].Revision, comp[0].Revision)
+	})
+}
@@ -0,0 +1,102 @@
+package usersdataservice
+
+import (
+	"sync"
+
+	usrdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
+)
+
+// subscriptionBuffer is the number of the events queued for the slow
+// subscriber, the events beyond it are dropped.
+const subscriptionBuffer = 16
+
+type subscription struct {
+	origin string
+	events chan *usrdatapb.ChangeEvent
+}
+
+// Broker delivers the change events to the subscribed clients of the user.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[int]map[*subscription]struct{}
+	closed bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[int]map[*subscription]struct{})}
+}
+
+// Subscribe returns the events of the user written by the clients other
+// than origin and the func to cancel the subscription. The events channel
+// is closed on cancel or when the broker is closed.
+func (b *Broker) Subscribe(
+	userID int, origin string,
+) (<-chan *usrdatapb.ChangeEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &subscription{
+		origin: origin,
+		events: make(chan *usrdatapb.ChangeEvent, subscriptionBuffer),
+	}
+	if b.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[*subscription]struct{})
+	}
+	b.subs[userID][sub] = struct{}{}
+
+	return sub.events, func() { b.unsubscribe(userID, sub) }
+}
+
+// Publish sends the event to the subscribers of the user except origin.
+// The event is dropped for the subscriber with the full queue, the queued
+// events already make it sync.
+func (b *Broker) Publish(
+	userID int, origin string, ev *usrdatapb.ChangeEvent,
+) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[userID] {
+		if origin != "" && sub.origin == origin {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+		}
+	}
+}
+
+// Close closes the events channels of all subscribers, so the streams
+// are finished before the server stops.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for userID, subs := range b.subs {
+		for sub := range subs {
+			close(sub.events)
+		}
+		delete(b.subs, userID)
+	}
+	b.closed = true
+}
+
+func (b *Broker) unsubscribe(userID int, sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[userID][sub]; !ok {
+		return
+	}
+	delete(b.subs[userID], sub)
+	if len(b.subs[userID]) == 0 {
+		delete(b.subs, userID)
+	}
+	close(sub.events)
+}
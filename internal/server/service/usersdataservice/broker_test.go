@@ -0,0 +1,91 @@
+package usersdataservice_test
+
+import (
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/server/service/usersdataservice"
+	usrdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receive returns the queued events without waiting for more.
+func receive(events <-chan *usrdatapb.ChangeEvent) []*usrdatapb.ChangeEvent {
+	var s []*usrdatapb.ChangeEvent
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return s
+			}
+			s = append(s, ev)
+		default:
+			return s
+		}
+	}
+}
+
+func TestBroker(t *testing.T) {
+	ev := &usrdatapb.ChangeEvent{Entity: "passwords", Revision: 1}
+
+	t.Run("OtherOrigins", func(t *testing.T) {
+		b := usersdataservice.NewBroker()
+		defer b.Close()
+		laptop, cancelLaptop := b.Subscribe(1, "laptop")
+		defer cancelLaptop()
+		phone, cancelPhone := b.Subscribe(1, "phone")
+		defer cancelPhone()
+		otherUser, cancelOther := b.Subscribe(2, "phone")
+		defer cancelOther()
+
+		b.Publish(1, "laptop", ev)
+		assert.Empty(t, receive(laptop), "the origin is not notified")
+		assert.Equal(t, []*usrdatapb.ChangeEvent{ev}, receive(phone))
+		assert.Empty(t, receive(otherUser), "the events are kept per user")
+
+		b.Publish(1, "", ev)
+		assert.Len(t, receive(laptop), 1, "the unknown origin notifies all")
+		assert.Len(t, receive(phone), 1)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		b := usersdataservice.NewBroker()
+		defer b.Close()
+		events, cancel := b.Subscribe(1, "laptop")
+
+		cancel()
+		_, ok := <-events
+		assert.False(t, ok, "the events are closed on cancel")
+		require.NotPanics(t, cancel, "the cancel is repeatable")
+		require.NotPanics(t, func() { b.Publish(1, "", ev) })
+	})
+
+	t.Run("FullQueue", func(t *testing.T) {
+		b := usersdataservice.NewBroker()
+		defer b.Close()
+		events, cancel := b.Subscribe(1, "laptop")
+		defer cancel()
+
+		for range 100 {
+			b.Publish(1, "", ev)
+		}
+		got := receive(events)
+		assert.NotEmpty(t, got)
+		assert.Less(t, len(got), 100,
+			"the events beyond the queue are dropped without blocking")
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		b := usersdataservice.NewBroker()
+		events, cancel := b.Subscribe(1, "laptop")
+
+		b.Close()
+		_, ok := <-events
+		assert.False(t, ok, "the events are closed with the broker")
+		require.NotPanics(t, cancel)
+
+		events, _ = b.Subscribe(1, "laptop")
+		_, ok = <-events
+		assert.False(t, ok, "the closed broker takes no subscribers")
+	})
+}
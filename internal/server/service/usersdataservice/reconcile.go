@@ -0,0 +1,134 @@
+package usersdataservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/niksmo/gophkeeper/internal/model"
+	"github.com/niksmo/gophkeeper/internal/server/repository"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
+	usrdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
+)
+
+const (
+	// reconcileFanout is the number of the subranges the diverging range
+	// is split into.
+	reconcileFanout = 16
+
+	// reconcileLeafSize is the largest diverging range the objects of which
+	// are returned instead of splitting it.
+	reconcileLeafSize = 64
+
+	// maxReconcileRanges bounds the ranges of the request.
+	maxReconcileRanges = 4096
+)
+
+var (
+	ErrInvalidRange  = errors.New("invalid range")
+	ErrTooManyRanges = errors.New("too many ranges")
+)
+
+type ReconcileProvider interface {
+	GetLeaves(
+		ctx context.Context, t repository.Table, userID int,
+	) ([]merkle.Leaf, error)
+
+	GetComparableByIDs(
+		ctx context.Context, t repository.Table, userID int, IDs []int64,
+	) ([]model.SyncComparable, error)
+}
+
+// Reconcile compares the client hashes of the ranges with the hashes of
+// the server objects. The diverging range of the few objects is resolved
+// by returning its objects, the larger one is split into the subranges
+// with the server hashes for the next request. The objects are returned
+// up to the comparable page size, the diverging ranges beyond it are
+// returned unsplit. The cursor is read first, see GetComparable.
+func (s *UsersDataService) Reconcile(ctx context.Context, userID int,
+	entity string, ranges []*usrdatapb.Range,
+) ([]*usrdatapb.Range, []*usrdatapb.Comparable, int64, error) {
+	const op = "UsersDataService.Reconcile"
+	log := s.logger.WithOp(op)
+
+	table, err := s.parseEntity(entity)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		return nil, nil, 0, err
+	}
+	if err := s.validateRanges(ranges); err != nil {
+		log.Warn().Err(err).Int("rangesLen", len(ranges)).Send()
+		return nil, nil, 0, err
+	}
+
+	cursor, err := s.dataProvider.GetRevision(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get revision")
+		return nil, nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	leaves, err := s.dataProvider.GetLeaves(ctx, table, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get leaves")
+		return nil, nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	tree := merkle.New(leaves)
+
+	var (
+		diverged []*usrdatapb.Range
+		IDs      []int64
+		resolved int
+	)
+	for _, r := range ranges {
+		srvRange := tree.Range(r.Start, r.End)
+		if srvRange.Hash == r.Hash {
+			continue
+		}
+		n := tree.Len(r.Start, r.End)
+		switch {
+		case n > reconcileLeafSize:
+			for _, sub := range tree.Split(r.Start, r.End, reconcileFanout) {
+				diverged = append(diverged, s.rangeToPB(sub))
+			}
+		case resolved == 0 || len(IDs)+n <= comparablePageSize:
+			IDs = append(IDs, tree.IDs(r.Start, r.End)...)
+			resolved++
+		default:
+			diverged = append(diverged, s.rangeToPB(srvRange))
+		}
+	}
+
+	log.Debug().Int("ranges", len(ranges)).Int(
+		"diverged", len(diverged)).Int(
+		"resolved", resolved).Int(
+		"objects", len(IDs)).Msg("reconcile ranges")
+
+	if len(IDs) == 0 {
+		return diverged, nil, cursor, nil
+	}
+	compData, err := s.dataProvider.GetComparableByIDs(ctx, table, userID, IDs)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get comparable")
+		return nil, nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return diverged, s.comparableToPB(compData), cursor, nil
+}
+
+func (s *UsersDataService) validateRanges(ranges []*usrdatapb.Range) error {
+	if len(ranges) == 0 {
+		return ErrInvalidRange
+	}
+	if len(ranges) > maxReconcileRanges {
+		return ErrTooManyRanges
+	}
+	for _, r := range ranges {
+		if !(merkle.Range{Start: r.Start, End: r.End}).Valid() {
+			return ErrInvalidRange
+		}
+	}
+	return nil
+}
+
+func (s *UsersDataService) rangeToPB(r merkle.Range) *usrdatapb.Range {
+	return &usrdatapb.Range{Start: r.Start, End: r.End, Hash: r.Hash}
+}
@@ -0,0 +1,112 @@
+package usersdataservice_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/niksmo/gophkeeper/internal/server/service/usersdataservice"
+	"github.com/niksmo/gophkeeper/pkg/merkle"
+	usrdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rangeToPB(r merkle.Range) *usrdatapb.Range {
+	return &usrdatapb.Range{Start: r.Start, End: r.End, Hash: r.Hash}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	st := newServiceSuite(t)
+	userID := st.createUser(t, "reconcile")
+
+	payload := make([]*usrdatapb.Payload, 0, 200)
+	for i := range 200 {
+		payload = append(payload, newPayload(fmt.Sprint(i)))
+	}
+	revisions, err := st.service.InsertSlice(
+		ctx, userID, "passwords", "", payload,
+	)
+	require.NoError(t, err)
+	leaves := make([]merkle.Leaf, 0, len(revisions))
+	for _, r := range revisions {
+		leaves = append(leaves, merkle.Leaf{ID: r.ID, Revision: r.Revision})
+	}
+
+	t.Run("InSync", func(t *testing.T) {
+		tree := merkle.New(leaves)
+		diverged, comp, cursor, err := st.service.Reconcile(ctx, userID,
+			"passwords", []*usrdatapb.Range{rangeToPB(tree.Root())},
+		)
+		require.NoError(t, err)
+		assert.Empty(t, diverged)
+		assert.Empty(t, comp)
+		assert.Equal(t, int64(len(payload)), cursor)
+	})
+
+	t.Run("Diverged", func(t *testing.T) {
+		stale := make([]merkle.Leaf, len(leaves))
+		copy(stale, leaves)
+		stale[120].Revision--
+		tree := merkle.New(stale)
+
+		var (
+			comp   []*usrdatapb.Comparable
+			rounds int
+		)
+		for ranges := []merkle.Range{tree.Root()}; len(ranges) != 0; rounds++ {
+			pb := make([]*usrdatapb.Range, 0, len(ranges))
+			for _, r := range ranges {
+				pb = append(pb, rangeToPB(r))
+			}
+			diverged, roundComp, _, err := st.service.Reconcile(
+				ctx, userID, "passwords", pb,
+			)
+			require.NoError(t, err)
+			comp = append(comp, roundComp...)
+
+			ranges = ranges[:0]
+			for _, r := range diverged {
+				if local := tree.Range(r.Start, r.End); local.Hash != r.Hash {
+					ranges = append(ranges, local)
+				}
+			}
+		}
+
+		require.NotEmpty(t, comp)
+		assert.Less(t, len(comp), len(payload),
+			"only the objects of the diverging range are returned")
+		var found bool
+		for _, o := range comp {
+			if o.ID == leaves[120].ID {
+				found = true
+				assert.Equal(t, leaves[120].Revision, o.Revision)
+			}
+		}
+		assert.True(t, found, "the diverging object is returned")
+		assert.Equal(t, 2, rounds, "the large range is split first")
+	})
+
+	t.Run("InvalidRanges", func(t *testing.T) {
+		_, _, _, err := st.service.Reconcile(ctx, userID, "passwords", nil)
+		assert.ErrorIs(t, err, usersdataservice.ErrInvalidRange)
+
+		_, _, _, err = st.service.Reconcile(ctx, userID, "passwords",
+			[]*usrdatapb.Range{{Start: 10, End: 10}},
+		)
+		assert.ErrorIs(t, err, usersdataservice.ErrInvalidRange)
+
+		ranges := make([]*usrdatapb.Range, 5000)
+		for i := range ranges {
+			ranges[i] = &usrdatapb.Range{Start: int64(i), End: int64(i + 1)}
+		}
+		_, _, _, err = st.service.Reconcile(ctx, userID, "passwords", ranges)
+		assert.ErrorIs(t, err, usersdataservice.ErrTooManyRanges)
+
+		_, _, _, err = st.service.Reconcile(ctx, userID, "unknown",
+			[]*usrdatapb.Range{{Start: 0, End: 10}},
+		)
+		assert.ErrorIs(t, err, usersdataservice.ErrInvalidEntity)
+	})
+}
@@ -0,0 +1,208 @@
+package usersdataservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/server/repository"
+	"github.com/niksmo/gophkeeper/pkg/hasher"
+	usrdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
+)
+
+const (
+	// uploadTTL is the time the upload not written to the object is kept.
+	uploadTTL = 7 * 24 * time.Hour
+
+	// downloadChunkSize is the largest data of the download chunk.
+	downloadChunkSize = 1 << 18
+)
+
+var (
+	ErrInvalidHash    = errors.New("invalid hash")
+	ErrUploadSize     = errors.New("unexpected upload size")
+	ErrUploadTooLarge = errors.New("upload is too large")
+	ErrUploadOffset   = errors.New("unexpected upload offset")
+	ErrUploadHash     = errors.New("upload hash mismatch")
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrNotFound       = errors.New("object not found")
+	ErrDataChanged    = errors.New("object data changed")
+	ErrInvalidOffset  = errors.New("invalid offset")
+)
+
+type UploadProvider interface {
+	GetUploadOffset(ctx context.Context, userID int, hash string) (int64, error)
+
+	SaveUploadChunk(ctx context.Context, userID int,
+		hash string, size, offset int64, data []byte) (int64, string, error)
+
+	DeleteUpload(ctx context.Context, userID int, hash string) error
+
+	DeleteUploadsBefore(
+		ctx context.Context, userID int, before time.Time,
+	) error
+
+	GetDataHash(ctx context.Context, t repository.Table,
+		userID int, id int64) (string, int64, error)
+
+	ReadData(ctx context.Context, t repository.Table, userID int,
+		id int64, hash string, offset, n int64) ([]byte, error)
+}
+
+// GetUploadOffset returns the length of the upload data received,
+// the upload is resumed from it.
+func (s *UsersDataService) GetUploadOffset(
+	ctx context.Context, userID int, hash string,
+) (int64, error) {
+	const op = "UsersDataService.GetUploadOffset"
+	log := s.logger.WithOp(op)
+
+	if !hasher.ValidSum(hash) {
+		log.Warn().Str("hash", hash).Msg("invalid hash")
+		return 0, ErrInvalidHash
+	}
+
+	offset, err := s.dataProvider.GetUploadOffset(ctx, userID, hash)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get upload offset")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return offset, nil
+}
+
+// UploadChunk saves the chunk of the upload and returns the next offset.
+// The received offset is returned with ErrUploadOffset for the chunk not
+// starting at it. ErrUploadTooLarge is returned for the upload larger than
+// the maxUploadSize. The upload is hashed chunk by chunk, the completed
+// upload is deleted if its data has the other hash. The uploads of the user
+// expired by uploadTTL are deleted when the new upload is started.
+func (s *UsersDataService) UploadChunk(ctx context.Context, userID int,
+	hash string, size, offset int64, data []byte) (int64, error) {
+	const op = "UsersDataService.UploadChunk"
+	log := s.logger.WithOp(op)
+
+	if !hasher.ValidSum(hash) {
+		log.Warn().Str("hash", hash).Msg("invalid hash")
+		return 0, ErrInvalidHash
+	}
+	if size <= 0 {
+		log.Warn().Int64("size", size).Msg("invalid upload size")
+		return 0, ErrUploadSize
+	}
+	if size > s.maxUploadSize {
+		log.Warn().Int64("size", size).Msg("upload is too large")
+		return 0, ErrUploadTooLarge
+	}
+
+	if offset == 0 {
+		err := s.dataProvider.DeleteUploadsBefore(
+			ctx, userID, time.Now().Add(-uploadTTL),
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to delete expired uploads")
+		}
+	}
+
+	next, sum, err := s.dataProvider.SaveUploadChunk(
+		ctx, userID, hash, size, offset, data,
+	)
+	if err != nil {
+		if errors.Is(err, repository.ErrUploadOffset) {
+			log.Debug().Int64("offset", offset).Int64(
+				"received", next).Msg("unexpected upload offset")
+			return next, ErrUploadOffset
+		}
+		if errors.Is(err, repository.ErrUploadSize) {
+			log.Warn().Int64("size", size).Msg("unexpected upload size")
+			return 0, ErrUploadSize
+		}
+		log.Error().Err(err).Msg("failed to save upload chunk")
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if next == size && sum != hash {
+		log.Warn().Str("hash", hash).Msg("upload hash mismatch")
+		s.deleteUploads(ctx, userID, []string{hash})
+		return 0, ErrUploadHash
+	}
+	return next, nil
+}
+
+// Download sends the data of the object starting at the offset in
+// the chunks of downloadChunkSize, the data is read chunk by chunk.
+// ErrDataChanged is returned if the object data has the other hash.
+func (s *UsersDataService) Download(ctx context.Context, userID int,
+	entity string, id int64, hash string, offset int64,
+	send func(offset int64, data []byte) error) error {
+	const op = "UsersDataService.Download"
+	log := s.logger.WithOp(op)
+
+	table, err := s.parseEntity(entity)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		return err
+	}
+
+	dataHash, size, err := s.dataProvider.GetDataHash(ctx, table, userID, id)
+	if errors.Is(err, repository.ErrNotExists) {
+		log.Debug().Int64("id", id).Msg("object not found")
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get data hash")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if dataHash != hash {
+		log.Debug().Int64("id", id).Msg("object data changed")
+		return ErrDataChanged
+	}
+	if offset < 0 || offset > size {
+		log.Warn().Int64("offset", offset).Msg("invalid offset")
+		return ErrInvalidOffset
+	}
+
+	for pos := offset; pos < size; pos += downloadChunkSize {
+		data, err := s.dataProvider.ReadData(
+			ctx, table, userID, id, hash, pos, downloadChunkSize,
+		)
+		if errors.Is(err, repository.ErrNotExists) {
+			log.Debug().Int64("id", id).Msg("object data changed")
+			return ErrDataChanged
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to read data")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := send(pos, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadHashes returns the hashes of the completed uploads the payloads
+// refer to, the repository writes the uploads to the objects.
+func (s *UsersDataService) uploadHashes(data []*usrdatapb.Payload) []string {
+	var hashes []string
+	for _, o := range data {
+		if o.DataHash != "" && len(o.Data) == 0 {
+			hashes = append(hashes, o.DataHash)
+		}
+	}
+	return hashes
+}
+
+// deleteUploads deletes the uploads written to the objects, the upload
+// failed to delete is kept until the same data is written again.
+func (s *UsersDataService) deleteUploads(
+	ctx context.Context, userID int, hashes []string,
+) {
+	log := s.logger.WithOp("UsersDataService.deleteUploads")
+	for _, hash := range hashes {
+		err := s.dataProvider.DeleteUpload(ctx, userID, hash)
+		if err != nil {
+			log.Error().Err(err).Str("hash", hash).Msg(
+				"failed to delete upload")
+		}
+	}
+}
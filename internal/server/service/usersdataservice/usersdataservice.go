@@ -9,80 +9,199 @@ import (
 
 	"github.com/niksmo/gophkeeper/internal/model"
 	"github.com/niksmo/gophkeeper/internal/server/repository"
+	"github.com/niksmo/gophkeeper/pkg/hasher"
+	"github.com/niksmo/gophkeeper/pkg/hlc"
 	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
 	usrdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
 )
 
-var ErrInvalidEntity = errors.New("invalid entity")
+var (
+	ErrInvalidEntity = errors.New("invalid entity")
+	ErrInvalidUUID   = errors.New("invalid uuid")
+)
+
+const (
+	// comparablePageSize and payloadPageSize are the largest pages of
+	// the comparable and the payload objects.
+	comparablePageSize = 1000
+	payloadPageSize    = 100
+
+	// maxInlineBytes bounds the object data sent inline in the response,
+	// the data beyond it is downloaded by the hash.
+	maxInlineBytes = 2 << 20
+)
 
 type DataProvider interface {
-	GetComparable(
-		ctx context.Context, t repository.Table, userID int,
-	) ([]model.SyncComparable, error)
+	GetComparable(ctx context.Context, t repository.Table,
+		userID int, after, limit int64) ([]model.SyncComparable, error)
 
-	GetAll(
-		ctx context.Context, t repository.Table, userID int,
-	) ([]model.SyncPayload, error)
+	GetAll(ctx context.Context, t repository.Table,
+		userID int, after, limit int64) ([]model.SyncPayload, error)
 
 	GetSliceByIDs(
 		ctx context.Context, t repository.Table, userID int, IDs []int64,
 	) ([]model.SyncPayload, error)
 
 	UpdateSliceByIDs(
-		ctx context.Context, t repository.Table, data []model.SyncPayload,
-	) error
+		ctx context.Context, t repository.Table,
+		userID int, data []model.SyncPayload,
+	) ([]model.SyncRevision, error)
 
 	InsertSlice(
 		ctx context.Context, t repository.Table,
 		userID int, data []model.SyncPayload,
-	) ([]int64, error)
+	) ([]model.SyncRevision, error)
+
+	GetChangesSince(
+		ctx context.Context, t repository.Table,
+		userID int, cursor, limit int64,
+	) ([]model.SyncPayload, error)
+
+	GetRevision(ctx context.Context, userID int) (int64, error)
+
+	GetIndexSalt(ctx context.Context, userID int) ([]byte, error)
+
+	UploadProvider
+	ReconcileProvider
 }
 
 type UsersDataService struct {
-	logger       logger.Logger
-	dataProvider DataProvider
+	logger        logger.Logger
+	dataProvider  DataProvider
+	broker        *Broker
+	maxUploadSize int64
 }
 
-func New(l logger.Logger, p DataProvider) *UsersDataService {
-	return &UsersDataService{l, p}
+// New returns the service accepting the uploads up to maxUploadSize bytes.
+func New(
+	l logger.Logger, p DataProvider, b *Broker, maxUploadSize int64,
+) *UsersDataService {
+	return &UsersDataService{l, p, b, maxUploadSize}
 }
 
+// GetComparable returns the page of the comparable objects after the ID,
+// the cursor of the changes made after them and the ID to request the next
+// page after. The cursor is read first, so the objects revised concurrently
+// are returned again by GetChangesSince.
 func (s *UsersDataService) GetComparable(ctx context.Context,
-	userID int, entity string) ([]*usrdatapb.Comparable, error) {
+	userID int, entity string,
+	after, limit int64) ([]*usrdatapb.Comparable, int64, int64, error) {
 	const op = "UsersDataService.GetComparable"
 	log := s.logger.WithOp(op)
 
 	table, err := s.parseEntity(entity)
 	if err != nil {
 		log.Warn().Err(err).Send()
-		return nil, err
+		return nil, 0, 0, err
 	}
 
-	compData, err := s.dataProvider.GetComparable(ctx, table, userID)
+	cursor, err := s.dataProvider.GetRevision(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get revision")
+		return nil, 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	limit = s.pageLimit(limit, comparablePageSize)
+	compData, err := s.dataProvider.GetComparable(
+		ctx, table, userID, after, limit,
+	)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get comparable")
+		return nil, 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var next int64
+	if int64(len(compData)) == limit {
+		next = compData[len(compData)-1].ID
+	}
+	return s.comparableToPB(compData), cursor, next, nil
+}
+
+// GetChangesSince returns the page of the objects revised after the cursor,
+// the revision of the last object of the page and the cursor to request the
+// next page after. The returned cursor is the request cursor for the empty
+// page.
+func (s *UsersDataService) GetChangesSince(ctx context.Context,
+	userID int, entity string, cursor, limit int64,
+) ([]*usrdatapb.Payload, int64, int64, error) {
+	const op = "UsersDataService.GetChangesSince"
+	log := s.logger.WithOp(op)
+
+	table, err := s.parseEntity(entity)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		return nil, 0, 0, err
+	}
+
+	limit = s.pageLimit(limit, payloadPageSize)
+	payloadData, err := s.dataProvider.GetChangesSince(
+		ctx, table, userID, cursor, limit,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get changes")
+		return nil, 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var next int64
+	if len(payloadData) != 0 {
+		cursor = payloadData[len(payloadData)-1].Revision
+		if int64(len(payloadData)) == limit {
+			next = cursor
+		}
+	}
+	return s.payloadToPB(payloadData), cursor, next, nil
+}
+
+// GetIndexSalt returns the salt of the blind index key of the user vault.
+func (s *UsersDataService) GetIndexSalt(
+	ctx context.Context, userID int,
+) ([]byte, error) {
+	const op = "UsersDataService.GetIndexSalt"
+	log := s.logger.WithOp(op)
+
+	salt, err := s.dataProvider.GetIndexSalt(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get index salt")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return s.comparableToPB(compData), nil
+	return salt, nil
 }
 
-func (s *UsersDataService) GetAll(ctx context.Context,
-	userID int, entity string) ([]*usrdatapb.Payload, error) {
+// GetAll returns the page of the objects after the ID and the ID to request
+// the next page after.
+func (s *UsersDataService) GetAll(ctx context.Context, userID int,
+	entity string, after, limit int64) ([]*usrdatapb.Payload, int64, error) {
 	const op = "UsersDataService.GetAll"
 	log := s.logger.WithOp(op)
 
 	table, err := s.parseEntity(entity)
 	if err != nil {
 		log.Warn().Err(err).Send()
-		return nil, err
+		return nil, 0, err
 	}
 
-	payloadData, err := s.dataProvider.GetAll(ctx, table, userID)
+	limit = s.pageLimit(limit, payloadPageSize)
+	payloadData, err := s.dataProvider.GetAll(ctx, table, userID, after, limit)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get all")
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
 	}
-	return s.payloadToPB(payloadData), nil
+
+	var next int64
+	if int64(len(payloadData)) == limit {
+		next = payloadData[len(payloadData)-1].ID
+	}
+	return s.payloadToPB(payloadData), next, nil
+}
+
+// pageLimit returns the requested limit bounded by the page size,
+// the page size is returned for the zero limit.
+func (s *UsersDataService) pageLimit(limit, pageSize int64) int64 {
+	if limit <= 0 || limit > pageSize {
+		return pageSize
+	}
+	return limit
 }
 
 func (s *UsersDataService) GetSliceByIDs(ctx context.Context,
@@ -104,27 +223,58 @@ func (s *UsersDataService) GetSliceByIDs(ctx context.Context,
 	return s.payloadToPB(payloadData), nil
 }
 
-func (s *UsersDataService) UpdateSliceByIDs(ctx context.Context,
-	entity string, data []*usrdatapb.Payload) error {
+// UpdateSliceByIDs returns the revisions of the written objects, the objects
+// revised since the payload Revision are not written. The object takes
+// the payload UUID the client bound its data to. The subscribers other
+// than origin are notified about the written objects.
+func (s *UsersDataService) UpdateSliceByIDs(ctx context.Context, userID int,
+	entity, origin string,
+	data []*usrdatapb.Payload) ([]*usrdatapb.Revision, error) {
 	const op = "UsersDataService.UpdateSliceByIDs"
 	log := s.logger.WithOp(op)
 
 	table, err := s.parseEntity(entity)
 	if err != nil {
 		log.Warn().Err(err).Send()
-		return err
+		return nil, err
 	}
 
-	err = s.dataProvider.UpdateSliceByIDs(ctx, table, s.pbToPayload(data))
+	uploads := s.uploadHashes(data)
+	payloadData := s.pbToPayload(data)
+	for _, o := range payloadData {
+		if o.UUID != "" && !uuid.Valid(o.UUID) {
+			log.Warn().Str("uuid", o.UUID).Msg("invalid uuid")
+			return nil, ErrInvalidUUID
+		}
+	}
+
+	revisions, err := s.dataProvider.UpdateSliceByIDs(
+		ctx, table, userID, payloadData,
+	)
+	if errors.Is(err, repository.ErrUUIDMismatch) {
+		log.Warn().Err(err).Msg("object uuid is changed")
+		return nil, ErrInvalidUUID
+	}
+	if errors.Is(err, repository.ErrUploadNotExists) {
+		log.Warn().Err(err).Msg("upload not found")
+		return nil, ErrUploadNotFound
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get update slice by IDs")
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return nil
+	s.deleteUploads(ctx, userID, uploads)
+	s.publish(userID, table, origin, revisions)
+	return s.revisionToPB(revisions), nil
 }
 
-func (s *UsersDataService) InsertSlice(ctx context.Context,
-	userID int, entity string, data []*usrdatapb.Payload) ([]int64, error) {
+// InsertSlice returns the revisions of the inserted objects, the object
+// with the UUID inserted before is not inserted again. The object without
+// the UUID gets the new one. The subscribers other than origin are notified
+// about the inserted objects.
+func (s *UsersDataService) InsertSlice(ctx context.Context, userID int,
+	entity, origin string,
+	data []*usrdatapb.Payload) ([]*usrdatapb.Revision, error) {
 	const op = "UsersDataService.InsertSlice"
 	log := s.logger.WithOp(op)
 
@@ -134,15 +284,55 @@ func (s *UsersDataService) InsertSlice(ctx context.Context,
 		return nil, err
 	}
 
-	IDs, err := s.dataProvider.InsertSlice(
-		ctx, table, userID, s.pbToPayload(data),
+	uploads := s.uploadHashes(data)
+	payloadData := s.pbToPayload(data)
+	for i, o := range payloadData {
+		switch {
+		case o.UUID == "":
+			payloadData[i].UUID = uuid.New()
+		case !uuid.Valid(o.UUID):
+			log.Warn().Str("uuid", o.UUID).Msg("invalid uuid")
+			return nil, ErrInvalidUUID
+		}
+	}
+
+	revisions, err := s.dataProvider.InsertSlice(
+		ctx, table, userID, payloadData,
 	)
+	if errors.Is(err, repository.ErrUploadNotExists) {
+		log.Warn().Err(err).Msg("upload not found")
+		return nil, ErrUploadNotFound
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("failed to insert slice")
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	s.deleteUploads(ctx, userID, uploads)
+	s.publish(userID, table, origin, revisions)
+
+	return s.revisionToPB(revisions), nil
+}
+
+// Subscribe returns the change events of the objects written by the clients
+// other than origin and the func to cancel the subscription.
+func (s *UsersDataService) Subscribe(
+	userID int, origin string,
+) (<-chan *usrdatapb.ChangeEvent, func()) {
+	return s.broker.Subscribe(userID, origin)
+}
 
-	return IDs, nil
+func (s *UsersDataService) publish(userID int,
+	table repository.Table, origin string, revisions []model.SyncRevision) {
+	if len(revisions) == 0 {
+		return
+	}
+	var last int64
+	for _, r := range revisions {
+		last = max(last, r.Revision)
+	}
+	s.broker.Publish(userID, origin, &usrdatapb.ChangeEvent{
+		Entity: table.String(), Revision: last,
+	})
 }
 
 func (s *UsersDataService) parseEntity(
@@ -168,26 +358,50 @@ func (s *UsersDataService) comparableToPB(
 	for _, o := range compData {
 		pb := &usrdatapb.Comparable{
 			ID:        o.ID,
-			Name:      o.Name,
-			UpdatedAt: o.UpdatedAt.UnixMilli(),
+			NameIndex: o.NameIndex,
+			Revision:  o.Revision,
+			Clock:     int64(o.Clock),
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
 		}
 		data = append(data, pb)
 	}
 	return data
 }
 
+// payloadToPB sends the data inline until the response holds maxInlineBytes,
+// the data read as the stream and the rest are sent by the hash.
 func (s *UsersDataService) payloadToPB(
 	payloadData []model.SyncPayload,
 ) []*usrdatapb.Payload {
 	data := make([]*usrdatapb.Payload, 0, len(payloadData))
+	var inline int
 	for _, o := range payloadData {
 		pb := &usrdatapb.Payload{
 			ID:        o.ID,
+			NameIndex: o.NameIndex,
 			Name:      o.Name,
 			Data:      o.Data,
 			CreatedAt: o.CreatedAt.UnixMilli(),
-			UpdatedAt: o.UpdatedAt.UnixMilli(),
+			Revision:  o.Revision,
+			Clock:     int64(o.Clock),
 			Deleted:   o.Deleted,
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
+		}
+		switch {
+		case o.Stream != nil,
+			len(o.Data) > 0 && inline+len(o.Data) > maxInlineBytes:
+			pb.Data = nil
+			pb.DataHash = o.DataHash
+			if pb.DataHash == "" {
+				pb.DataHash = hasher.Sum(o.Data)
+			}
+			pb.DataSize = o.DataSize()
+		default:
+			inline += len(o.Data)
 		}
 		data = append(data, pb)
 	}
@@ -201,13 +415,33 @@ func (s *UsersDataService) pbToPayload(
 	for _, o := range pbData {
 		pb := model.SyncPayload{
 			ID:        o.ID,
+			NameIndex: o.NameIndex,
 			Name:      o.Name,
 			Data:      o.Data,
 			CreatedAt: time.UnixMilli(o.CreatedAt),
-			UpdatedAt: time.UnixMilli(o.UpdatedAt),
+			Revision:  o.Revision,
+			Clock:     hlc.Timestamp(o.Clock),
 			Deleted:   o.Deleted,
+			Key:       o.Key,
+			UUID:      o.UUID,
+			Hash:      o.Hash,
+		}
+		if len(o.Data) == 0 {
+			pb.DataHash = o.DataHash
 		}
 		data = append(data, pb)
 	}
 	return data
 }
+
+func (s *UsersDataService) revisionToPB(
+	revisions []model.SyncRevision,
+) []*usrdatapb.Revision {
+	data := make([]*usrdatapb.Revision, 0, len(revisions))
+	for _, o := range revisions {
+		data = append(data, &usrdatapb.Revision{
+			ID: o.ID, Revision: o.Revision, Clock: int64(o.Clock),
+		})
+	}
+	return data
+}
@@ -0,0 +1,196 @@
+package usersdataservice_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/internal/server/repository"
+	"github.com/niksmo/gophkeeper/internal/server/service/usersdataservice"
+	"github.com/niksmo/gophkeeper/internal/server/storage"
+	"github.com/niksmo/gophkeeper/pkg/logger"
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+	usrdatapb "github.com/niksmo/gophkeeper/proto/usersdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type serviceSuite struct {
+	storage repository.Storage
+	users   *repository.UsersRepository
+	service *usersdataservice.UsersDataService
+	broker  *usersdataservice.Broker
+}
+
+// Before runnint tests:
+//
+//  1. create test database
+//  2. run migrations
+//  3. set testing DSN to the GOPHKEEPER_TEST_DB env
+func newServiceSuite(t *testing.T) *serviceSuite {
+	t.Helper()
+
+	dsn := os.Getenv("GOPHKEEPER_TEST_DB")
+	if dsn == "" {
+		t.Skip("Env GOPHKEEPER_TEST_DB not set")
+	}
+	log := logger.NewPretty("debug")
+	storage := storage.New(log, dsn)
+	broker := usersdataservice.NewBroker()
+	t.Cleanup(broker.Close)
+	return &serviceSuite{
+		storage: storage,
+		users:   repository.NewUsersRepository(log, storage),
+		service: usersdataservice.New(
+			log, repository.NewUsersDataRepository(log, storage), broker, 1<<20,
+		),
+		broker: broker,
+	}
+}
+
+// createUser returns the ID of the user deleted with its objects on cleanup.
+func (st *serviceSuite) createUser(t *testing.T, login string) int {
+	t.Helper()
+	ctx := context.Background()
+	user, err := st.users.Create(ctx, login, []byte("hash"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		st.storage.ExecContext(ctx, `
+			DELETE FROM passwords WHERE user_id=?;
+			DELETE FROM users WHERE id=?;`, user.ID, user.ID,
+		)
+	})
+	return user.ID
+}
+
+func newPayload(name string) *usrdatapb.Payload {
+	return &usrdatapb.Payload{
+		UUID: uuid.New(), NameIndex: name, Name: []byte(name),
+		Data: []byte("data"), CreatedAt: time.Now().UnixMilli(),
+	}
+}
+
+func TestGetChangesSince(t *testing.T) {
+	ctx := context.Background()
+	st := newServiceSuite(t)
+	userID := st.createUser(t, "changes")
+
+	_, err := st.service.InsertSlice(ctx, userID, "passwords", "",
+		[]*usrdatapb.Payload{newPayload("a"), newPayload("b"), newPayload("c")},
+	)
+	require.NoError(t, err)
+
+	data, cursor, next, err := st.service.GetChangesSince(
+		ctx, userID, "passwords", 0, 2,
+	)
+	require.NoError(t, err)
+	assert.Len(t, data, 2)
+	assert.Equal(t, int64(2), cursor)
+	assert.Equal(t, cursor, next, "the full page has the next page")
+
+	data, cursor, next, err = st.service.GetChangesSince(
+		ctx, userID, "passwords", next, 2,
+	)
+	require.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Equal(t, int64(3), cursor)
+	assert.Zero(t, next)
+
+	data, cursor, next, err = st.service.GetChangesSince(
+		ctx, userID, "passwords", cursor, 2,
+	)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+	assert.Equal(t, int64(3), cursor, "the cursor is kept for the empty page")
+	assert.Zero(t, next)
+
+	_, _, _, err = st.service.GetChangesSince(ctx, userID, "unknown", 0, 2)
+	assert.ErrorIs(t, err, usersdataservice.ErrInvalidEntity)
+}
+
+func TestUUIDs(t *testing.T) {
+	ctx := context.Background()
+	st := newServiceSuite(t)
+	userID := st.createUser(t, "uuids")
+
+	t.Run("InvalidUUID", func(t *testing.T) {
+		o := newPayload("a")
+		o.UUID = "invalid"
+		_, err := st.service.InsertSlice(
+			ctx, userID, "passwords", "", []*usrdatapb.Payload{o},
+		)
+		assert.ErrorIs(t, err, usersdataservice.ErrInvalidUUID)
+	})
+
+	t.Run("WithoutUUID", func(t *testing.T) {
+		o := newPayload("b")
+		o.UUID = ""
+		revisions, err := st.service.InsertSlice(
+			ctx, userID, "passwords", "", []*usrdatapb.Payload{o},
+		)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+
+		data, err := st.service.GetSliceByIDs(
+			ctx, userID, "passwords", []int64{revisions[0].ID},
+		)
+		require.NoError(t, err)
+		require.Len(t, data, 1)
+		assert.True(t, uuid.Valid(data[0].UUID), "the object gets the UUID")
+	})
+
+	t.Run("UUIDMismatch", func(t *testing.T) {
+		o := newPayload("c")
+		revisions, err := st.service.InsertSlice(
+			ctx, userID, "passwords", "", []*usrdatapb.Payload{o},
+		)
+		require.NoError(t, err)
+
+		o.ID, o.Revision = revisions[0].ID, revisions[0].Revision
+		o.UUID = uuid.New()
+		_, err = st.service.UpdateSliceByIDs(
+			ctx, userID, "passwords", "", []*usrdatapb.Payload{o},
+		)
+		assert.ErrorIs(t, err, usersdataservice.ErrInvalidUUID)
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	st := newServiceSuite(t)
+	userID := st.createUser(t, "subscribe")
+
+	laptop, cancelLaptop := st.service.Subscribe(userID, "laptop")
+	defer cancelLaptop()
+	phone, cancelPhone := st.service.Subscribe(userID, "phone")
+	defer cancelPhone()
+
+	payload := []*usrdatapb.Payload{newPayload("a"), newPayload("b")}
+	revisions, err := st.service.InsertSlice(
+		ctx, userID, "passwords", "laptop", payload,
+	)
+	require.NoError(t, err)
+
+	expected := &usrdatapb.ChangeEvent{Entity: "passwords", Revision: 2}
+	assert.Equal(t, []*usrdatapb.ChangeEvent{expected}, receive(phone),
+		"the event holds the last revision of the write")
+	assert.Empty(t, receive(laptop), "the writer is not notified")
+
+	o := payload[0]
+	o.ID, o.Revision = revisions[0].ID, revisions[0].Revision
+	_, err = st.service.UpdateSliceByIDs(
+		ctx, userID, "passwords", "phone", []*usrdatapb.Payload{o},
+	)
+	require.NoError(t, err)
+	expected = &usrdatapb.ChangeEvent{Entity: "passwords", Revision: 3}
+	assert.Equal(t, []*usrdatapb.ChangeEvent{expected}, receive(laptop))
+	assert.Empty(t, receive(phone))
+
+	revisions, err = st.service.UpdateSliceByIDs(
+		ctx, userID, "passwords", "phone", []*usrdatapb.Payload{o},
+	)
+	require.NoError(t, err)
+	assert.Empty(t, revisions, "the object is revised since the revision")
+	assert.Empty(t, receive(laptop), "the write of no objects is not notified")
+}
@@ -0,0 +1,68 @@
+// Package backoff implements the exponential delays of the retries with
+// the jitter, so the clients failed at once do not retry at once, and
+// the circuit breaker which stops calling the failing service for the
+// growing cooldown.
+package backoff
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff is the exponential delay, the delay doubles after every failed
+// attempt from Base up to Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the delay after the failed attempts, the attempts start
+// from one. The delay is random from half to the full exponential delay.
+func (b Backoff) Delay(attempts int) time.Duration {
+	d := b.Base
+	for i := 1; i < attempts && d < b.Max; i++ {
+		d *= 2
+	}
+	d = min(d, b.Max)
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// Retry calls fn until it succeeds, the error is not retryable, the
+// attempts are over or the context is done. The last error of fn is
+// returned.
+func Retry(
+	ctx context.Context,
+	b Backoff,
+	attempts int,
+	retryable func(error) bool,
+	fn func(context.Context) error,
+) error {
+	var err error
+	for i := 1; ; i++ {
+		err = fn(ctx)
+		if err == nil || !retryable(err) || i >= attempts {
+			return err
+		}
+		if !Sleep(ctx, b.Delay(i)) {
+			return err
+		}
+	}
+}
+
+// Sleep waits for the duration and reports false if the context is done
+// first.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,121 @@
+package backoff_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/backoff"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errTemporary = errors.New("temporary")
+	errPermanent = errors.New("permanent")
+)
+
+func isTemporary(err error) bool {
+	return errors.Is(err, errTemporary)
+}
+
+func TestDelay(t *testing.T) {
+	b := backoff.Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+	for range 100 {
+		d := b.Delay(1)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 100*time.Millisecond)
+
+		d = b.Delay(3)
+		assert.GreaterOrEqual(t, d, 200*time.Millisecond)
+		assert.LessOrEqual(t, d, 400*time.Millisecond)
+
+		d = b.Delay(100)
+		assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+		assert.LessOrEqual(t, d, time.Second)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	b := backoff.Backoff{Base: time.Millisecond, Max: time.Millisecond}
+
+	t.Run("Succeeded", func(t *testing.T) {
+		var calls int
+		err := backoff.Retry(ctx, b, 5, isTemporary, func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errTemporary
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("AttemptsOver", func(t *testing.T) {
+		var calls int
+		err := backoff.Retry(ctx, b, 3, isTemporary, func(context.Context) error {
+			calls++
+			return errTemporary
+		})
+		assert.ErrorIs(t, err, errTemporary)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		var calls int
+		err := backoff.Retry(ctx, b, 3, isTemporary, func(context.Context) error {
+			calls++
+			return errPermanent
+		})
+		assert.ErrorIs(t, err, errPermanent)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ContextDone", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		var calls int
+		long := backoff.Backoff{Base: time.Hour, Max: time.Hour}
+		err := backoff.Retry(ctx, long, 3, isTemporary, func(context.Context) error {
+			calls++
+			cancel()
+			return errTemporary
+		})
+		assert.ErrorIs(t, err, errTemporary)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := backoff.NewBreaker(3, backoff.Backoff{Base: time.Minute, Max: time.Hour})
+
+	b.Failure(now)
+	b.Failure(now)
+	assert.Equal(t, backoff.Closed, b.State(now))
+	b.Success()
+	b.Failure(now)
+	b.Failure(now)
+	assert.True(t, b.Allow(now), "the failures in a row are counted")
+
+	b.Failure(now)
+	assert.Equal(t, backoff.Open, b.State(now))
+	assert.False(t, b.Allow(now))
+	cooldown := b.OpenUntil().Sub(now)
+	assert.GreaterOrEqual(t, cooldown, 30*time.Second)
+	assert.LessOrEqual(t, cooldown, time.Minute)
+
+	now = b.OpenUntil()
+	assert.Equal(t, backoff.HalfOpen, b.State(now))
+	assert.True(t, b.Allow(now))
+
+	b.Failure(now)
+	assert.Equal(t, backoff.Open, b.State(now), "the failed probe opens")
+	cooldown = b.OpenUntil().Sub(now)
+	assert.GreaterOrEqual(t, cooldown, time.Minute)
+	assert.LessOrEqual(t, cooldown, 2*time.Minute)
+
+	b.Success()
+	assert.Equal(t, backoff.Closed, b.State(now))
+}
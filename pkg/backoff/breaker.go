@@ -0,0 +1,82 @@
+package backoff
+
+import "time"
+
+// State is the state of the circuit breaker.
+type State int
+
+const (
+	// Closed allows the calls and counts the failures in a row.
+	Closed State = iota
+
+	// Open rejects the calls until the cooldown is over.
+	Open
+
+	// HalfOpen allows the probe call after the cooldown, the failed probe
+	// opens the breaker for the longer cooldown.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// Breaker is the circuit breaker, it opens after the threshold of the
+// failures in a row for the cooldown of the backoff delay, the delay grows
+// with every failed probe. The Breaker is not safe for concurrent use.
+type Breaker struct {
+	threshold int
+	backoff   Backoff
+	failures  int
+	trips     int
+	openUntil time.Time
+}
+
+func NewBreaker(threshold int, b Backoff) *Breaker {
+	return &Breaker{threshold: max(threshold, 1), backoff: b}
+}
+
+// State returns the state of the breaker at the time.
+func (b *Breaker) State(now time.Time) State {
+	switch {
+	case b.trips == 0:
+		return Closed
+	case now.Before(b.openUntil):
+		return Open
+	}
+	return HalfOpen
+}
+
+// Allow reports whether the call is allowed at the time.
+func (b *Breaker) Allow(now time.Time) bool {
+	return b.State(now) != Open
+}
+
+// OpenUntil returns the end of the cooldown of the open breaker.
+func (b *Breaker) OpenUntil() time.Time {
+	return b.openUntil
+}
+
+// Success closes the breaker.
+func (b *Breaker) Success() {
+	b.failures = 0
+	b.trips = 0
+	b.openUntil = time.Time{}
+}
+
+// Failure counts the failure at the time and opens the breaker after
+// the threshold of the failures in a row or the failed probe.
+func (b *Breaker) Failure(now time.Time) {
+	b.failures++
+	if b.trips == 0 && b.failures < b.threshold {
+		return
+	}
+	b.trips++
+	b.openUntil = now.Add(b.backoff.Delay(b.trips))
+}
@@ -1,43 +1,93 @@
 package cipher
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/pbkdf2"
 	"crypto/rand"
-	"crypto/sha256"
+	"errors"
 	"fmt"
 )
 
-const (
-	keySize = 32
-	iter    = 4096
+const keySize = 32
+
+var (
+	ErrShortData = errors.New("data is too short")
+	ErrNoKey     = errors.New("key is not provided")
+	ErrNotBound  = errors.New("data is not bound to associated data")
 )
 
+// KeyDeriver provides the derived key when the master key is not set,
+// e.g. from the unlock agent.
+type KeyDeriver interface {
+	DeriveKey(Params) ([]byte, error)
+}
+
 type Encrypter struct {
 	keySetter
+	alg    Algorithm
+	params Params
+	header []byte
 }
 
 func NewEncrypter() *Encrypter {
-	return &Encrypter{}
+	e := &Encrypter{alg: DefaultAlgorithm}
+	e.SetParams(DefaultParams())
+	return e
+}
+
+func (e *Encrypter) SetParams(p Params) {
+	e.params = p
+	e.header, _ = marshalEnvelope(e.alg, p)
+}
+
+func (e *Encrypter) Params() Params {
+	return e.params
+}
+
+func (e *Encrypter) SetAlgorithm(alg Algorithm) {
+	e.alg = alg
+	e.header, _ = marshalEnvelope(alg, e.params)
+}
+
+func (e *Encrypter) Algorithm() Algorithm {
+	return e.alg
 }
 
 func (e *Encrypter) Encrypt(data []byte) ([]byte, error) {
 	const op = "Encrypter.Encrypt"
-
-	key, err := makeKey(e.Key)
+	b, err := e.seal(data, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return b, nil
+}
 
-	aead, err := makeAEAD(key)
+// EncryptAD encrypts the data bound to the associated data, such data
+// is decrypted only with the same associated data.
+func (e *Encrypter) EncryptAD(data, ad []byte) ([]byte, error) {
+	const op = "Encrypter.EncryptAD"
+	b, err := e.seal(data, ad)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return b, nil
+}
+
+func (e *Encrypter) seal(data, ad []byte) ([]byte, error) {
+	key, err := e.getKey(e.params)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := makeAEAD(e.alg, key)
+	if err != nil {
+		return nil, err
+	}
 
 	nonce := e.getNonce(aead.NonceSize())
 
-	return aead.Seal(nonce, nonce, data, nil), nil
+	dst := make([]byte, 0, len(e.header)+len(nonce)+len(data)+aead.Overhead())
+	dst = append(dst, e.header...)
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, data, ad), nil
 }
 
 func (e *Encrypter) getNonce(size int) []byte {
@@ -46,69 +96,141 @@ func (e *Encrypter) getNonce(size int) []byte {
 
 type Decrypter struct {
 	keySetter
+	requireAD bool
 }
 
 func NewDecrypter() *Decrypter {
 	return &Decrypter{}
 }
 
+// SetRequireAD makes DecryptAD reject the data encrypted without
+// the associated data.
+func (d *Decrypter) SetRequireAD(require bool) {
+	d.requireAD = require
+}
+
 func (d *Decrypter) Decrypt(data []byte) ([]byte, error) {
 	const op = "Decrypter.Decrypt"
-
-	key, err := makeKey(d.Key)
+	b, err := d.decrypt(data, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return b, nil
+}
 
-	aead, err := makeAEAD(key)
+// DecryptAD decrypts the data bound to the associated data. The data
+// encrypted before the binding is decrypted without the associated data
+// unless it is required.
+func (d *Decrypter) DecryptAD(data, ad []byte) ([]byte, error) {
+	const op = "Decrypter.DecryptAD"
+	b, err := d.decrypt(data, ad)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return b, nil
+}
 
-	nonce, payload := data[:aead.NonceSize()], data[aead.NonceSize():]
+func (d *Decrypter) decrypt(data, ad []byte) ([]byte, error) {
+	e, n, err := readEnvelope(data)
+	if errors.Is(err, ErrParamsLimit) {
+		return nil, err
+	}
+	if err != nil {
+		return d.openUnbound(legacyEnvelope(), data, ad)
+	}
+
+	if !e.bound() {
+		decData, err := d.openUnbound(e, data[n:], ad)
+		if err != nil {
+			if legacyData, legacyErr := d.openUnbound(
+				legacyEnvelope(), data, ad,
+			); legacyErr == nil {
+				return legacyData, nil
+			}
+			return nil, err
+		}
+		return decData, nil
+	}
+
+	return d.open(e, data[n:], ad)
+}
+
+func (d *Decrypter) openUnbound(e envelope, data, ad []byte) ([]byte, error) {
+	if ad != nil && d.requireAD {
+		return nil, ErrNotBound
+	}
+	return d.open(e, data, nil)
+}
 
-	decData, err := aead.Open(nil, nonce, payload, nil)
+func (d *Decrypter) open(e envelope, data, ad []byte) ([]byte, error) {
+	key, err := d.getKey(e.params)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
+	}
+
+	aead, err := makeAEAD(e.alg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, ErrShortData
 	}
-	return decData, nil
+	nonce, payload := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, payload, ad)
 }
 
 type keySetter struct {
-	Key string
+	Key     string
+	deriver KeyDeriver
+	keys    map[string][]byte
 }
 
 func (s *keySetter) SetKey(key string) {
+	if s.Key != key {
+		clear(s.keys)
+	}
 	s.Key = key
 }
 
-func makeAEAD(key []byte) (cipher.AEAD, error) {
-	const op = "cipher.makeAEAD"
-	aesBlock, err := aes.NewCipher(key)
+func (s *keySetter) SetDeriver(d KeyDeriver) {
+	clear(s.keys)
+	s.deriver = d
+}
+
+// getKey derives the key once for every distinct parameters set,
+// because memory-hard derivation is expensive.
+func (s *keySetter) getKey(p Params) ([]byte, error) {
+	id, err := p.MarshalBinary()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
 
-	aesGCM, err := cipher.NewGCM(aesBlock)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	if key, ok := s.keys[string(id)]; ok {
+		return key, nil
 	}
-	return aesGCM, nil
-}
 
-func makeKey(k string) ([]byte, error) {
-	const op = "cipher.makeKey"
-	key, err := pbkdf2.Key(sha256.New, k, makeSalt(k), iter, keySize)
+	key, err := s.deriveKey(p)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
+
+	if s.keys == nil {
+		s.keys = make(map[string][]byte)
+	}
+	s.keys[string(id)] = key
 	return key, nil
 }
 
-func makeSalt(k string) []byte {
-	h := sha256.New()
-	h.Write([]byte(k))
-	return h.Sum(nil)
+func (s *keySetter) deriveKey(p Params) ([]byte, error) {
+	if s.Key != "" {
+		return DeriveKey(s.Key, p)
+	}
+	if s.deriver == nil {
+		return nil, ErrNoKey
+	}
+	return s.deriver.DeriveKey(p)
 }
 
 func generateRandom(size int) []byte {
@@ -1,6 +1,14 @@
 package cipher_test
 
 import (
+	"bytes"
+	"crypto/aes"
+	stdcipher "crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
 	"math/rand/v2"
 	"strings"
 	"testing"
@@ -66,3 +74,445 @@ func TestDecrypter(t *testing.T) {
 		assert.NotEqual(t, data, decryptedData)
 	})
 }
+
+func TestDecrypterLegacy(t *testing.T) {
+	password := getRandPwd(100)
+	data := []byte("hello_world")
+
+	h := sha256.Sum256([]byte(password))
+	key, err := pbkdf2.Key(sha256.New, password, h[:], 4096, 32)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := stdcipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, aead.NonceSize())
+	legacyData := aead.Seal(nonce, nonce, data, nil)
+
+	d := cipher.NewDecrypter()
+	d.SetKey(password)
+	decryptedData, err := d.Decrypt(legacyData)
+	require.NoError(t, err)
+	assert.Equal(t, data, decryptedData)
+	assert.True(t, cipher.ReadParams(legacyData).IsLegacy())
+}
+
+// sealAESGCM returns the data encrypted by the previous envelope versions.
+func sealAESGCM(
+	t *testing.T,
+	password string,
+	p cipher.Params,
+	version byte,
+	data, ad []byte,
+) []byte {
+	t.Helper()
+	header, err := p.MarshalBinary()
+	require.NoError(t, err)
+	header[3] = version
+
+	key, err := cipher.DeriveKey(password, p)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := stdcipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(append(header, nonce...), nonce, data, ad)
+}
+
+func TestDecrypterAD(t *testing.T) {
+	password := getRandPwd(100)
+	data := []byte("hello_world")
+	ad := []byte("passwords\x00data\x00index")
+
+	e := cipher.NewEncrypter()
+	e.SetKey(password)
+	d := cipher.NewDecrypter()
+	d.SetKey(password)
+
+	t.Run("Ordinary", func(t *testing.T) {
+		b, err := e.EncryptAD(data, ad)
+		require.NoError(t, err)
+		assert.True(t, cipher.IsBound(b))
+
+		decryptedData, err := d.DecryptAD(b, ad)
+		require.NoError(t, err)
+		assert.Equal(t, data, decryptedData)
+	})
+
+	t.Run("OtherAD", func(t *testing.T) {
+		b, err := e.EncryptAD(data, ad)
+		require.NoError(t, err)
+
+		_, err = d.DecryptAD(b, []byte("cards\x00data\x00index"))
+		require.Error(t, err)
+		_, err = d.Decrypt(b)
+		require.Error(t, err)
+	})
+
+	t.Run("Unbound", func(t *testing.T) {
+		b := sealAESGCM(t, password, e.Params(), 1, data, nil)
+		assert.False(t, cipher.IsBound(b))
+
+		decryptedData, err := d.DecryptAD(b, ad)
+		require.NoError(t, err)
+		assert.Equal(t, data, decryptedData)
+
+		strict := cipher.NewDecrypter()
+		strict.SetKey(password)
+		strict.SetRequireAD(true)
+		_, err = strict.DecryptAD(b, ad)
+		require.ErrorIs(t, err, cipher.ErrNotBound)
+	})
+}
+
+func TestEnvelope(t *testing.T) {
+	password := getRandPwd(100)
+	data := []byte("hello_world")
+	ad := []byte("ad")
+	params := cipher.NewParams(1, 1024)
+
+	d := cipher.NewDecrypter()
+	d.SetKey(password)
+
+	t.Run("DefaultAlgorithm", func(t *testing.T) {
+		e := cipher.NewEncrypter()
+		e.SetParams(params)
+		e.SetKey(password)
+		b, err := e.Encrypt(data)
+		require.NoError(t, err)
+		assert.Equal(t, cipher.XChaCha20Poly1305, cipher.ReadAlgorithm(b))
+		assert.True(t, params.Equal(cipher.ReadParams(b)))
+
+		decryptedData, err := d.Decrypt(b)
+		require.NoError(t, err)
+		assert.Equal(t, data, decryptedData)
+	})
+
+	t.Run("AESGCM", func(t *testing.T) {
+		e := cipher.NewEncrypter()
+		e.SetAlgorithm(cipher.AESGCM)
+		e.SetParams(params)
+		e.SetKey(password)
+		b, err := e.EncryptAD(data, ad)
+		require.NoError(t, err)
+		assert.Equal(t, cipher.AESGCM, cipher.ReadAlgorithm(b))
+
+		decryptedData, err := d.DecryptAD(b, ad)
+		require.NoError(t, err)
+		assert.Equal(t, data, decryptedData)
+	})
+
+	t.Run("PreviousVersions", func(t *testing.T) {
+		v1 := sealAESGCM(t, password, params, 1, data, nil)
+		v2 := sealAESGCM(t, password, params, 2, data, ad)
+		for _, b := range [][]byte{v1, v2} {
+			assert.Equal(t, cipher.AESGCM, cipher.ReadAlgorithm(b))
+			decryptedData, err := d.DecryptAD(b, ad)
+			require.NoError(t, err)
+			assert.Equal(t, data, decryptedData)
+		}
+	})
+
+	t.Run("UnknownAlgorithm", func(t *testing.T) {
+		e := cipher.NewEncrypter()
+		e.SetParams(params)
+		e.SetKey(password)
+		b, err := e.Encrypt(data)
+		require.NoError(t, err)
+		b[4] = 0xff
+
+		_, err = d.Decrypt(b)
+		require.Error(t, err)
+	})
+}
+
+func TestStream(t *testing.T) {
+	password := getRandPwd(100)
+	ad := []byte("binaries\x00stream\x00index")
+
+	e := cipher.NewEncrypter()
+	e.SetParams(cipher.NewParams(1, 1024))
+	e.SetKey(password)
+	d := cipher.NewDecrypter()
+	d.SetKey(password)
+
+	encrypt := func(t *testing.T, data []byte) []byte {
+		t.Helper()
+		var b bytes.Buffer
+		w, err := e.EncryptStream(&b, ad)
+		require.NoError(t, err)
+		_, err = io.Copy(w, bytes.NewReader(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return b.Bytes()
+	}
+
+	decrypt := func(b, ad []byte) ([]byte, error) {
+		r, err := d.DecryptStream(bytes.NewReader(b), ad)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	t.Run("Sizes", func(t *testing.T) {
+		for _, size := range []int{
+			0, 1, cipher.StreamChunkSize, cipher.StreamChunkSize + 1,
+			3*cipher.StreamChunkSize + 5,
+		} {
+			data := make([]byte, size)
+			for i := range data {
+				data[i] = byte(rand.IntN(256))
+			}
+			actual, err := decrypt(encrypt(t, data), ad)
+			require.NoError(t, err, size)
+			assert.Equal(t, len(data), len(actual), size)
+			assert.True(t, bytes.Equal(data, actual), size)
+		}
+	})
+
+	t.Run("OtherAD", func(t *testing.T) {
+		_, err := decrypt(encrypt(t, []byte("hello_world")), []byte("other"))
+		require.Error(t, err)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		data := make([]byte, 2*cipher.StreamChunkSize)
+		b := encrypt(t, data)
+		for _, n := range []int{len(b) - 1, len(b) / 2, 40} {
+			_, err := decrypt(b[:n], ad)
+			require.Error(t, err, n)
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		b := encrypt(t, []byte("hello_world"))
+		b[len(b)-1] ^= 1
+		_, err := decrypt(b, ad)
+		require.ErrorIs(t, err, cipher.ErrChunkNotValid)
+	})
+}
+
+func TestParams(t *testing.T) {
+	t.Run("RandomSalt", func(t *testing.T) {
+		p1 := cipher.NewParams(1, 1024)
+		p2 := cipher.NewParams(1, 1024)
+		assert.NotEqual(t, p1.Salt, p2.Salt)
+		assert.False(t, p1.Equal(p2))
+	})
+
+	t.Run("MarshalUnmarshal", func(t *testing.T) {
+		expected := cipher.NewParams(2, 2048)
+		b, err := expected.MarshalBinary()
+		require.NoError(t, err)
+
+		var actual cipher.Params
+		err = actual.UnmarshalBinary(b)
+		require.NoError(t, err)
+		assert.True(t, expected.Equal(actual))
+	})
+
+	t.Run("InvalidHeader", func(t *testing.T) {
+		var p cipher.Params
+		err := p.UnmarshalBinary([]byte("invalid header"))
+		require.ErrorIs(t, err, cipher.ErrInvalidHeader)
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		password := getRandPwd(100)
+		e := cipher.NewEncrypter()
+		e.SetParams(cipher.NewParams(1, 1024))
+		e.SetKey(password)
+		data, err := e.Encrypt([]byte("hello_world"))
+		require.NoError(t, err)
+
+		// the memory cost follows the magic, version, algorithm, kdf and time
+		costly := bytes.Clone(data)
+		binary.BigEndian.PutUint32(costly[10:14], cipher.MaxMemory+1)
+		d := cipher.NewDecrypter()
+		d.SetKey(password)
+		_, err = d.Decrypt(costly)
+		require.ErrorIs(t, err, cipher.ErrParamsLimit)
+
+		p := cipher.NewParams(cipher.MaxTime+1, 1024)
+		_, err = cipher.DeriveKey(password, p)
+		require.ErrorIs(t, err, cipher.ErrParamsLimit)
+	})
+
+	t.Run("ReadFromEncrypted", func(t *testing.T) {
+		password := getRandPwd(100)
+		expected := cipher.NewParams(1, 1024)
+
+		e := cipher.NewEncrypter()
+		e.SetParams(expected)
+		e.SetKey(password)
+		encryptedData, err := e.Encrypt([]byte("hello_world"))
+		require.NoError(t, err)
+		assert.True(t, expected.Equal(cipher.ReadParams(encryptedData)))
+
+		d := cipher.NewDecrypter()
+		d.SetKey(password)
+		_, err = d.Decrypt(encryptedData)
+		require.NoError(t, err)
+	})
+
+	t.Run("PreviousParamsStayReadable", func(t *testing.T) {
+		password := getRandPwd(100)
+		data := []byte("hello_world")
+
+		e := cipher.NewEncrypter()
+		e.SetParams(cipher.NewParams(1, 1024))
+		e.SetKey(password)
+		oldData, err := e.Encrypt(data)
+		require.NoError(t, err)
+
+		e.SetParams(cipher.NewParams(2, 2048))
+		newData, err := e.Encrypt(data)
+		require.NoError(t, err)
+
+		d := cipher.NewDecrypter()
+		d.SetKey(password)
+		for _, encryptedData := range [][]byte{oldData, newData} {
+			decryptedData, err := d.Decrypt(encryptedData)
+			require.NoError(t, err)
+			assert.Equal(t, data, decryptedData)
+		}
+	})
+}
+
+type deriver struct {
+	key string
+}
+
+func (d deriver) DeriveKey(p cipher.Params) ([]byte, error) {
+	return cipher.DeriveKey(d.key, p)
+}
+
+func TestKeyDeriver(t *testing.T) {
+	password := getRandPwd(100)
+	data := []byte(getRandPwd(1000))
+
+	t.Run("NoKey", func(t *testing.T) {
+		e := cipher.NewEncrypter()
+		_, err := e.Encrypt(data)
+		require.ErrorIs(t, err, cipher.ErrNoKey)
+	})
+
+	t.Run("Ordinary", func(t *testing.T) {
+		e := cipher.NewEncrypter()
+		e.SetDeriver(deriver{password})
+		encData, err := e.Encrypt(data)
+		require.NoError(t, err)
+
+		d := cipher.NewDecrypter()
+		d.SetKey(password)
+		decData, err := d.Decrypt(encData)
+		require.NoError(t, err)
+		assert.Equal(t, data, decData)
+	})
+
+	t.Run("KeyOverridesDeriver", func(t *testing.T) {
+		e := cipher.NewEncrypter()
+		e.SetKey(password)
+		encData, err := e.Encrypt(data)
+		require.NoError(t, err)
+
+		d := cipher.NewDecrypter()
+		d.SetDeriver(deriver{getRandPwd(100)})
+		_, err = d.Decrypt(encData)
+		require.Error(t, err)
+
+		d.SetKey(password)
+		decData, err := d.Decrypt(encData)
+		require.NoError(t, err)
+		assert.Equal(t, data, decData)
+	})
+}
+
+func TestIndexer(t *testing.T) {
+	password := getRandPwd(100)
+	const name = "Sberbank card"
+
+	i := cipher.NewIndexer()
+	i.SetKey(password)
+	index, err := i.Index(name)
+	require.NoError(t, err)
+	assert.NotContains(t, index, name)
+
+	t.Run("SameOnOtherDevice", func(t *testing.T) {
+		other := cipher.NewIndexer()
+		other.SetDeriver(deriver{password})
+		otherIndex, err := other.Index(name)
+		require.NoError(t, err)
+		assert.Equal(t, index, otherIndex)
+	})
+
+	t.Run("OtherName", func(t *testing.T) {
+		otherIndex, err := i.Index(name + " ")
+		require.NoError(t, err)
+		assert.NotEqual(t, index, otherIndex)
+	})
+
+	t.Run("OtherKey", func(t *testing.T) {
+		other := cipher.NewIndexer()
+		other.SetKey(getRandPwd(100))
+		otherIndex, err := other.Index(name)
+		require.NoError(t, err)
+		assert.NotEqual(t, index, otherIndex)
+	})
+
+	t.Run("OtherSalt", func(t *testing.T) {
+		salt := []byte("0123456789abcdef")
+		other := cipher.NewIndexer()
+		other.SetKey(password)
+		other.SetSalt(salt)
+		assert.Equal(t, salt, other.Salt())
+		otherIndex, err := other.Index(name)
+		require.NoError(t, err)
+		assert.NotEqual(t, index, otherIndex)
+
+		other.SetSalt(nil)
+		assert.Nil(t, other.Salt())
+		otherIndex, err = other.Index(name)
+		require.NoError(t, err)
+		assert.Equal(t, index, otherIndex, "the empty salt is the legacy one")
+	})
+
+	t.Run("NoKey", func(t *testing.T) {
+		_, err := cipher.NewIndexer().Index(name)
+		require.ErrorIs(t, err, cipher.ErrNoKey)
+	})
+}
+
+func TestIndexerHash(t *testing.T) {
+	password := getRandPwd(100)
+	content := []byte("content")
+
+	i := cipher.NewIndexer()
+	i.SetKey(password)
+	h, err := i.NewHash()
+	require.NoError(t, err)
+	h.Write(content)
+	sum := h.Sum(nil)
+
+	t.Run("SameOnOtherDevice", func(t *testing.T) {
+		other := cipher.NewIndexer()
+		other.SetDeriver(deriver{password})
+		h, err := other.NewHash()
+		require.NoError(t, err)
+		h.Write(content)
+		assert.Equal(t, sum, h.Sum(nil))
+	})
+
+	t.Run("NotIndex", func(t *testing.T) {
+		index, err := i.Index(string(content))
+		require.NoError(t, err)
+		assert.NotEqual(t, index, hex.EncodeToString(sum))
+	})
+
+	t.Run("NoKey", func(t *testing.T) {
+		_, err := cipher.NewIndexer().NewHash()
+		require.ErrorIs(t, err, cipher.ErrNoKey)
+	})
+}
@@ -0,0 +1,177 @@
+package cipher
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithm is the AEAD the data is encrypted with.
+type Algorithm uint8
+
+const (
+	AESGCM Algorithm = iota + 1
+	XChaCha20Poly1305
+)
+
+// DefaultAlgorithm is used for the new data.
+const DefaultAlgorithm = XChaCha20Poly1305
+
+// The envelope is the header followed by the nonce and the ciphertext:
+//
+//	v1, v2: magic | version | kdf params
+//	v3:     magic | version | algorithm | kdf params
+//
+// The first version data is encrypted with AES-GCM without the associated
+// data, the second version binds the AES-GCM data to the associated data,
+// the third one names the algorithm and always authenticates
+// the associated data, the empty one too. The data without the header
+// is legacy AES-GCM data with the key derived by PBKDF2.
+const (
+	headerMagic = "GKE"
+	headerV1    = 1
+	headerV2    = 2
+	headerV3    = 3
+)
+
+// MaxHeaderSize is the length of the longest envelope header.
+const MaxHeaderSize = len(headerMagic) + 2 + paramsFixLen + 0xff
+
+type envelope struct {
+	version byte
+	alg     Algorithm
+	params  Params
+}
+
+func legacyEnvelope() envelope {
+	return envelope{alg: AESGCM, params: LegacyParams()}
+}
+
+// bound reports whether the data is authenticated with the associated data.
+func (e envelope) bound() bool {
+	return e.version >= headerV2
+}
+
+func marshalEnvelope(alg Algorithm, p Params) ([]byte, error) {
+	const op = "cipher.marshalEnvelope"
+	if len(p.Salt) > 0xff {
+		return nil, fmt.Errorf("%s: salt is too long", op)
+	}
+	b := make([]byte, 0, len(headerMagic)+2+paramsFixLen+len(p.Salt))
+	b = append(b, headerMagic...)
+	b = append(b, headerV3, byte(alg))
+	return appendParams(b, p), nil
+}
+
+func readEnvelope(data []byte) (envelope, int, error) {
+	const op = "cipher.readEnvelope"
+	n := len(headerMagic) + 1
+	if len(data) < n || string(data[:len(headerMagic)]) != headerMagic {
+		return envelope{}, 0, fmt.Errorf("%s: %w", op, ErrInvalidHeader)
+	}
+
+	e := envelope{version: data[n-1], alg: AESGCM}
+	switch e.version {
+	case headerV1, headerV2:
+	case headerV3:
+		if len(data) <= n {
+			return envelope{}, 0, fmt.Errorf("%s: %w", op, ErrInvalidHeader)
+		}
+		e.alg = Algorithm(data[n])
+		if e.alg != AESGCM && e.alg != XChaCha20Poly1305 {
+			return envelope{}, 0, fmt.Errorf(
+				"%s: unknown algorithm: %w", op, ErrInvalidHeader)
+		}
+		n++
+	default:
+		return envelope{}, 0, fmt.Errorf(
+			"%s: unknown version: %w", op, ErrInvalidHeader)
+	}
+
+	p, pn, err := readParams(data[n:])
+	if err != nil {
+		return envelope{}, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	e.params = p
+	return e, n + pn, nil
+}
+
+// IsBound reports whether the data is encrypted with the associated data.
+func IsBound(data []byte) bool {
+	e, _, err := readEnvelope(data)
+	return err == nil && e.bound()
+}
+
+// ReadAlgorithm returns the algorithm from the data header.
+// Data without header is treated as legacy.
+func ReadAlgorithm(data []byte) Algorithm {
+	e, _, err := readEnvelope(data)
+	if err != nil {
+		return legacyEnvelope().alg
+	}
+	return e.alg
+}
+
+func makeAEAD(alg Algorithm, key []byte) (cipher.AEAD, error) {
+	const op = "cipher.makeAEAD"
+	switch alg {
+	case AESGCM:
+		aesBlock, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		aesGCM, err := cipher.NewGCM(aesBlock)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return aesGCM, nil
+	case XChaCha20Poly1305:
+		aead, err := chacha20poly1305.NewX(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return aead, nil
+	}
+	return nil, fmt.Errorf("%s: unknown algorithm %d", op, alg)
+}
+
+func appendParams(b []byte, p Params) []byte {
+	b = append(b, byte(p.KDF))
+	b = binary.BigEndian.AppendUint32(b, p.Time)
+	b = binary.BigEndian.AppendUint32(b, p.Memory)
+	b = append(b, p.Threads, byte(len(p.Salt)))
+	return append(b, p.Salt...)
+}
+
+func readParams(data []byte) (Params, int, error) {
+	if len(data) < paramsFixLen {
+		return Params{}, 0, ErrInvalidHeader
+	}
+
+	kdf := KDF(data[0])
+	if kdf != PBKDF2 && kdf != Argon2id {
+		return Params{}, 0, fmt.Errorf("unknown kdf: %w", ErrInvalidHeader)
+	}
+
+	n := paramsFixLen + int(data[paramsFixLen-1])
+	if len(data) < n {
+		return Params{}, 0, fmt.Errorf("short salt: %w", ErrInvalidHeader)
+	}
+
+	p := Params{
+		KDF:     kdf,
+		Time:    binary.BigEndian.Uint32(data[1:5]),
+		Memory:  binary.BigEndian.Uint32(data[5:9]),
+		Threads: data[9],
+		Salt:    bytes.Clone(data[paramsFixLen:n]),
+	}
+	if err := p.Validate(); err != nil {
+		return Params{}, 0, err
+	}
+	return p, n, nil
+}
@@ -0,0 +1,88 @@
+package cipher
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// legacyIndexSalt is the salt of the indexes computed before the salts
+// of the accounts, the vault without the salt keeps it.
+const legacyIndexSalt = "gophkeeper blind index"
+
+// hashLabel separates the content hash key from the index key.
+const hashLabel = "gophkeeper content hash"
+
+// IndexParams returns parameters of the blind index key derivation
+// with the salt, the empty salt is the legacy one.
+func IndexParams(salt []byte) Params {
+	if len(salt) == 0 {
+		salt = []byte(legacyIndexSalt)
+	}
+	return Params{
+		KDF:     Argon2id,
+		Time:    DefaultTime,
+		Memory:  DefaultMemory,
+		Threads: DefaultThreads,
+		Salt:    salt,
+	}
+}
+
+// Indexer computes the keyed blind index of the value, the index allows
+// to compare the values without decrypting them. The salt of the index
+// key is the same on every device of the account, so the indexes are
+// comparable between them.
+type Indexer struct {
+	keySetter
+	params Params
+}
+
+func NewIndexer() *Indexer {
+	return &Indexer{params: IndexParams(nil)}
+}
+
+// SetSalt sets the salt of the index key, the empty salt is the legacy one.
+func (i *Indexer) SetSalt(salt []byte) {
+	i.params = IndexParams(salt)
+}
+
+// Salt returns the salt of the index key, it is nil for the legacy salt.
+func (i *Indexer) Salt() []byte {
+	if bytes.Equal(i.params.Salt, []byte(legacyIndexSalt)) {
+		return nil
+	}
+	return i.params.Salt
+}
+
+func (i *Indexer) Index(value string) (string, error) {
+	const op = "Indexer.Index"
+
+	key, err := i.getKey(i.params)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// NewHash returns the keyed hash of the plaintext content written to it,
+// the hash allows to compare the contents without decrypting them and
+// stays the same when the content is encrypted again. Its key is derived
+// from the index key, so it is the same on every device of the account.
+func (i *Indexer) NewHash() (hash.Hash, error) {
+	const op = "Indexer.NewHash"
+
+	key, err := i.getKey(i.params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(hashLabel))
+	return hmac.New(sha256.New, mac.Sum(nil)), nil
+}
@@ -0,0 +1,165 @@
+package cipher
+
+import (
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+type KDF uint8
+
+const (
+	PBKDF2 KDF = iota + 1
+	Argon2id
+)
+
+const (
+	DefaultTime    = 3
+	DefaultMemory  = 64 * 1024
+	DefaultThreads = 4
+
+	// MaxTime, MaxMemory and MaxIterations bound the params read from
+	// the data header, so the tampered header does not exhaust the memory
+	// or the processor.
+	MaxTime       = 64
+	MaxMemory     = 1024 * 1024
+	MaxIterations = 10_000_000
+
+	saltSize     = 16
+	legacyIter   = 4096
+	paramsFixLen = 1 + 4 + 4 + 1 + 1
+)
+
+var (
+	ErrInvalidHeader = errors.New("invalid header")
+	ErrParamsLimit   = errors.New("params exceed the limit")
+)
+
+// Params describes how the encryption key is derived from the master key.
+// Time is the number of iterations for PBKDF2 and the number of passes
+// for Argon2id, Memory is the Argon2id memory cost in KiB.
+type Params struct {
+	KDF     KDF
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	Salt    []byte
+}
+
+func NewParams(time, memory uint32) Params {
+	return Params{
+		KDF:     Argon2id,
+		Time:    time,
+		Memory:  memory,
+		Threads: DefaultThreads,
+		Salt:    generateRandom(saltSize),
+	}
+}
+
+func DefaultParams() Params {
+	return NewParams(DefaultTime, DefaultMemory)
+}
+
+// LegacyParams returns parameters of the data encrypted before the header
+// was introduced, the salt of such data is derived from the master key.
+func LegacyParams() Params {
+	return Params{KDF: PBKDF2, Time: legacyIter}
+}
+
+func (p Params) IsLegacy() bool {
+	return p.KDF == PBKDF2 && len(p.Salt) == 0
+}
+
+func (p Params) Equal(o Params) bool {
+	return p.KDF == o.KDF && p.Time == o.Time && p.Memory == o.Memory &&
+		p.Threads == o.Threads && bytes.Equal(p.Salt, o.Salt)
+}
+
+// Validate checks the costs of the params against the limits.
+func (p Params) Validate() error {
+	switch p.KDF {
+	case PBKDF2:
+		if p.Time == 0 || p.Time > MaxIterations {
+			return ErrParamsLimit
+		}
+	case Argon2id:
+		if p.Time == 0 || p.Time > MaxTime || p.Memory > MaxMemory ||
+			p.Threads == 0 {
+			return ErrParamsLimit
+		}
+	}
+	return nil
+}
+
+// Weaker reports whether the params cost less than Argon2id with
+// the time and memory.
+func (p Params) Weaker(time, memory uint32) bool {
+	return p.KDF != Argon2id || p.Time < time || p.Memory < memory
+}
+
+// MarshalBinary encodes the params as the first version header.
+func (p Params) MarshalBinary() ([]byte, error) {
+	const op = "Params.MarshalBinary"
+	if len(p.Salt) > 0xff {
+		return nil, fmt.Errorf("%s: salt is too long", op)
+	}
+	b := make([]byte, 0, len(headerMagic)+1+paramsFixLen+len(p.Salt))
+	b = append(b, headerMagic...)
+	b = append(b, headerV1)
+	return appendParams(b, p), nil
+}
+
+func (p *Params) UnmarshalBinary(data []byte) error {
+	const op = "Params.UnmarshalBinary"
+	e, _, err := readEnvelope(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	*p = e.params
+	return nil
+}
+
+// ReadParams returns the key derivation parameters from the data header.
+// Data without header is treated as legacy.
+func ReadParams(data []byte) Params {
+	e, _, err := readEnvelope(data)
+	if err != nil {
+		return legacyEnvelope().params
+	}
+	return e.params
+}
+
+// DeriveKey derives the key, the params above the limits are rejected.
+func DeriveKey(k string, p Params) ([]byte, error) {
+	const op = "cipher.DeriveKey"
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	switch p.KDF {
+	case PBKDF2:
+		salt := p.Salt
+		if p.IsLegacy() {
+			salt = makeLegacySalt(k)
+		}
+		key, err := pbkdf2.Key(sha256.New, k, salt, int(p.Time), keySize)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return key, nil
+	case Argon2id:
+		return argon2.IDKey(
+			[]byte(k), p.Salt, p.Time, p.Memory, p.Threads, keySize,
+		), nil
+	}
+	return nil, fmt.Errorf("%s: unknown kdf %d", op, p.KDF)
+}
+
+func makeLegacySalt(k string) []byte {
+	h := sha256.New()
+	h.Write([]byte(k))
+	return h.Sum(nil)
+}
@@ -0,0 +1,233 @@
+package cipher
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamChunkSize is the plaintext size of every stream chunk
+// except the last one.
+const StreamChunkSize = 64 * 1024
+
+// nonceSuffixSize is the size of the chunk counter and the last chunk flag,
+// the rest of the chunk nonce is random for every stream.
+const nonceSuffixSize = 4 + 1
+
+var (
+	ErrTruncated     = errors.New("stream is truncated")
+	ErrChunkNotValid = errors.New("stream chunk is not authentic")
+)
+
+// streamWriter writes the envelope header followed by the nonce prefix and
+// the chunks sealed with the prefix | counter | last flag nonce, so the
+// chunks can not be reordered, dropped or appended.
+type streamWriter struct {
+	w           io.Writer
+	aead        cipher.AEAD
+	ad          []byte
+	noncePrefix []byte
+	counter     uint32
+	buf         []byte
+	closed      bool
+}
+
+// EncryptStream returns the writer that encrypts the data by chunks
+// bound to the associated data and writes them to the w. The last chunk
+// is written on Close.
+func (e *Encrypter) EncryptStream(
+	w io.Writer, ad []byte,
+) (io.WriteCloser, error) {
+	const op = "Encrypter.EncryptStream"
+
+	key, err := e.getKey(e.params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	aead, err := makeAEAD(e.alg, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	noncePrefix := e.getNonce(aead.NonceSize() - nonceSuffixSize)
+	if _, err := w.Write(e.header); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := w.Write(noncePrefix); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &streamWriter{
+		w:           w,
+		aead:        aead,
+		ad:          ad,
+		noncePrefix: noncePrefix,
+		buf:         make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+// Write keeps the full chunk until the next data, because the last chunk
+// is sealed with the last flag.
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed stream")
+	}
+
+	var n int
+	for len(p) > 0 {
+		if len(s.buf) == StreamChunkSize {
+			if err := s.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(s.buf[len(s.buf):StreamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.seal(true)
+}
+
+func (s *streamWriter) seal(last bool) error {
+	nonce := streamNonce(s.noncePrefix, s.counter, last)
+	if _, err := s.w.Write(s.aead.Seal(nil, nonce, s.buf, s.ad)); err != nil {
+		return err
+	}
+	s.counter++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+type streamReader struct {
+	r           *bufio.Reader
+	aead        cipher.AEAD
+	ad          []byte
+	noncePrefix []byte
+	chunkSize   int
+	counter     uint32
+	buf         []byte
+	done        bool
+}
+
+// DecryptStream returns the reader of the stream encrypted
+// by the EncryptStream with the same associated data.
+func (d *Decrypter) DecryptStream(r io.Reader, ad []byte) (io.Reader, error) {
+	const op = "Decrypter.DecryptStream"
+
+	br := bufio.NewReaderSize(r, StreamChunkSize)
+	e, err := readStreamEnvelope(br)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := d.getKey(e.params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	aead, err := makeAEAD(e.alg, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	noncePrefix := make([]byte, aead.NonceSize()-nonceSuffixSize)
+	if _, err := io.ReadFull(br, noncePrefix); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrTruncated)
+	}
+
+	return &streamReader{
+		r:           br,
+		aead:        aead,
+		ad:          ad,
+		noncePrefix: noncePrefix,
+		chunkSize:   StreamChunkSize + aead.Overhead(),
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// next opens the next chunk, the chunk is the last one
+// if nothing follows it.
+func (s *streamReader) next() error {
+	chunk := make([]byte, s.chunkSize)
+	n, err := io.ReadFull(s.r, chunk)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return ErrTruncated
+		}
+		return err
+	}
+
+	last := n < s.chunkSize
+	if !last {
+		if _, err := s.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		}
+	}
+
+	nonce := streamNonce(s.noncePrefix, s.counter, last)
+	b, err := s.aead.Open(chunk[:0], nonce, chunk[:n], s.ad)
+	if err != nil {
+		return ErrChunkNotValid
+	}
+	s.counter++
+	s.buf = b
+	s.done = last
+	return nil
+}
+
+func readStreamEnvelope(r *bufio.Reader) (envelope, error) {
+	fixLen := len(headerMagic) + 2 + paramsFixLen
+	b, err := r.Peek(fixLen)
+	if err != nil {
+		return envelope{}, ErrInvalidHeader
+	}
+	b, err = r.Peek(fixLen + int(b[fixLen-1]))
+	if err != nil {
+		return envelope{}, ErrInvalidHeader
+	}
+
+	e, n, err := readEnvelope(b)
+	if err != nil {
+		return envelope{}, err
+	}
+	if e.version != headerV3 {
+		return envelope{}, fmt.Errorf("not a stream: %w", ErrInvalidHeader)
+	}
+	_, err = r.Discard(n)
+	return e, err
+}
+
+func streamNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, len(prefix)+nonceSuffixSize)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
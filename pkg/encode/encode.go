@@ -6,21 +6,20 @@ import (
 	"fmt"
 )
 
+// Encoder encodes every object with the type description,
+// so the encoded bytes are decoded independently.
 type Encoder struct {
-	buf  *bytes.Buffer
-	gobE *gob.Encoder
+	buf *bytes.Buffer
 }
 
 func NewEncoder() *Encoder {
-	buf := new(bytes.Buffer)
-	gobE := gob.NewEncoder(buf)
-	return &Encoder{buf, gobE}
+	return &Encoder{new(bytes.Buffer)}
 }
 
 func (e *Encoder) Encode(src any) ([]byte, error) {
 	const op = "encoder.Encode"
 	defer e.buf.Reset()
-	if err := e.gobE.Encode(src); err != nil {
+	if err := gob.NewEncoder(e.buf).Encode(src); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	dst := make([]byte, e.buf.Len())
@@ -29,21 +28,18 @@ func (e *Encoder) Encode(src any) ([]byte, error) {
 }
 
 type Decoder struct {
-	buf  *bytes.Buffer
-	godD *gob.Decoder
+	buf *bytes.Buffer
 }
 
 func NewDecoder() *Decoder {
-	buf := new(bytes.Buffer)
-	gobD := gob.NewDecoder(buf)
-	return &Decoder{buf, gobD}
+	return &Decoder{new(bytes.Buffer)}
 }
 
 func (d *Decoder) Decode(dst any, src []byte) error {
 	const op = "decoder.Decode"
 	d.buf.Write(src)
 	defer d.buf.Reset()
-	if err := d.godD.Decode(dst); err != nil {
+	if err := gob.NewDecoder(d.buf).Decode(dst); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
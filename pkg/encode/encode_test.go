@@ -45,4 +45,18 @@ func TestEncode(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, encObj, decObj)
 	})
+
+	t.Run("SeveralObjects", func(t *testing.T) {
+		encoder := encode.NewEncoder()
+		decoder := encode.NewDecoder()
+		for _, encObj := range []obj{{Foo: "a"}, {Foo: "b", Bar: 1}} {
+			oBytes, err := encoder.Encode(encObj)
+			require.NoError(t, err)
+
+			var decObj obj
+			err = decoder.Decode(&decObj, oBytes)
+			require.NoError(t, err)
+			assert.Equal(t, encObj, decObj)
+		}
+	})
 }
@@ -1,7 +1,11 @@
 package hasher
 
 import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
 	"errors"
+	"hash"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -29,3 +33,62 @@ func (h *CryptoHasher) Generate(src []byte) ([]byte, error) {
 func (h *CryptoHasher) Compare(hash, src []byte) error {
 	return bcrypt.CompareHashAndPassword(hash, src)
 }
+
+// Sum returns the SHA-256 hex of the content.
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Digest is the SHA-256 of the content written in parts, e.g. the chunks
+// of the resumed upload. Its state is saved between the parts, so the
+// content is never held in memory at once.
+type Digest struct {
+	h hash.Hash
+}
+
+func NewDigest() *Digest {
+	return &Digest{sha256.New()}
+}
+
+// RestoreDigest returns the digest of the state returned by State,
+// the new digest is returned for the empty state.
+func RestoreDigest(state []byte) (*Digest, error) {
+	d := NewDigest()
+	if len(state) == 0 {
+		return d, nil
+	}
+	err := d.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Digest) Write(p []byte) (int, error) {
+	return d.h.Write(p)
+}
+
+// State returns the state of the digest of the content written so far.
+func (d *Digest) State() ([]byte, error) {
+	return d.h.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+// Sum returns the SHA-256 hex of the content written so far, it is
+// the same as Sum of the whole content.
+func (d *Digest) Sum() string {
+	return hex.EncodeToString(d.h.Sum(nil))
+}
+
+// ValidSum reports whether s is the SHA-256 hex returned by Sum.
+func ValidSum(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
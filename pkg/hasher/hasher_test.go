@@ -2,6 +2,7 @@ package hasher_test
 
 import (
 	"crypto/rand"
+	"strings"
 	"testing"
 
 	"github.com/niksmo/gophkeeper/pkg/hasher"
@@ -64,3 +65,32 @@ func TestCryptoHasher(t *testing.T) {
 	})
 
 }
+
+func TestSum(t *testing.T) {
+	sum := hasher.Sum([]byte("data"))
+	assert.Equal(t,
+		"3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", sum,
+	)
+	assert.True(t, hasher.ValidSum(sum))
+	assert.False(t, hasher.ValidSum(sum[1:]))
+	assert.False(t, hasher.ValidSum(strings.ToUpper(sum)))
+}
+
+func TestDigest(t *testing.T) {
+	d := hasher.NewDigest()
+	d.Write([]byte("da"))
+	state, err := d.State()
+	require.NoError(t, err)
+
+	d, err = hasher.RestoreDigest(state)
+	require.NoError(t, err)
+	d.Write([]byte("ta"))
+	assert.Equal(t, hasher.Sum([]byte("data")), d.Sum())
+
+	d, err = hasher.RestoreDigest(nil)
+	require.NoError(t, err)
+	assert.Equal(t, hasher.Sum(nil), d.Sum())
+
+	_, err = hasher.RestoreDigest([]byte("state"))
+	assert.Error(t, err)
+}
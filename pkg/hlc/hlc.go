@@ -0,0 +1,27 @@
+// Package hlc implements the hybrid logical clock timestamps. The timestamp
+// keeps the wall clock milliseconds in the high bits and the counter of the
+// events within the millisecond in the low bits.
+//
+// The next local event gets max(last+1, FromTime(now)), so the clock stays
+// close to the wall clock and still grows when the wall clock stalls or goes
+// back. The received remote event moves the last timestamp to
+// max(last, remote), so the following local events are ordered after it
+// whatever the wall clock of the devices is.
+package hlc
+
+import "time"
+
+// logicalBits is the size of the event counter.
+const logicalBits = 16
+
+type Timestamp int64
+
+// FromTime returns the first timestamp of the wall clock millisecond.
+func FromTime(t time.Time) Timestamp {
+	return Timestamp(t.UnixMilli() << logicalBits)
+}
+
+// Time returns the wall clock time of the timestamp in milliseconds.
+func (ts Timestamp) Time() time.Time {
+	return time.UnixMilli(int64(ts) >> logicalBits)
+}
@@ -0,0 +1,20 @@
+package hlc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/hlc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestamp(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+
+	ts := hlc.FromTime(now)
+	assert.True(t, now.Equal(ts.Time()))
+	assert.True(t, now.Equal((ts + 1).Time()),
+		"the event counter keeps the wall clock time")
+	assert.Less(t, ts+1, hlc.FromTime(now.Add(time.Millisecond)))
+	assert.True(t, time.UnixMilli(0).Equal(hlc.Timestamp(0).Time()))
+}
@@ -0,0 +1,134 @@
+// Package merkle implements the hash tree over the versions of the records
+// for the range reconciliation. The leaf is the ID and the revision of
+// the record, the node is the half-open range of the IDs and the hash of
+// its leaves. The sides compare the root range first and split only
+// the diverging ranges, so the few changed records of the large set are
+// found in the logarithmic number of round trips.
+//
+// The hash of the range is the XOR of the leaf hashes, so the hash of any
+// range is computed from the prefix hashes and both sides get the same
+// hash for the same range whatever ranges they split it into.
+package merkle
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"slices"
+)
+
+// Leaf is the version of the record.
+type Leaf struct {
+	ID       int64
+	Revision int64
+}
+
+// Range is the IDs from Start up to End excluded and the hash of
+// the leaves in it, the empty range has the empty hash.
+type Range struct {
+	Start int64
+	End   int64
+	Hash  string
+}
+
+// Valid reports whether the range holds any ID.
+func (r Range) Valid() bool {
+	return r.Start < r.End
+}
+
+type digest [sha256.Size]byte
+
+// Tree holds the leaves ordered by ID and the prefix hashes of them.
+type Tree struct {
+	ids    []int64
+	prefix []digest
+}
+
+// New returns the tree of the leaves, the leaves have the unique IDs.
+func New(leaves []Leaf) *Tree {
+	leaves = slices.Clone(leaves)
+	slices.SortFunc(leaves, func(a, b Leaf) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	t := &Tree{
+		ids:    make([]int64, len(leaves)),
+		prefix: make([]digest, len(leaves)+1),
+	}
+	for i, l := range leaves {
+		t.ids[i] = l.ID
+		t.prefix[i+1] = xor(t.prefix[i], leafHash(l))
+	}
+	return t
+}
+
+// Root returns the range of all IDs.
+func (t *Tree) Root() Range {
+	return t.Range(0, math.MaxInt64)
+}
+
+// Range returns the range with the hash of the leaves in it.
+func (t *Tree) Range(start, end int64) Range {
+	i, j := t.bounds(start, end)
+	r := Range{Start: start, End: end}
+	if h := xor(t.prefix[i], t.prefix[j]); h != (digest{}) {
+		r.Hash = hex.EncodeToString(h[:])
+	}
+	return r
+}
+
+// Len returns the number of the leaves in the range.
+func (t *Tree) Len(start, end int64) int {
+	i, j := t.bounds(start, end)
+	return j - i
+}
+
+// IDs returns the IDs of the leaves in the range.
+func (t *Tree) IDs(start, end int64) []int64 {
+	i, j := t.bounds(start, end)
+	return slices.Clone(t.ids[i:j])
+}
+
+// Split splits the range into n subranges at most with the same number
+// of the leaves, every subrange holds the leaves. The range with less than
+// two leaves is not split.
+func (t *Tree) Split(start, end int64, n int) []Range {
+	i, j := t.bounds(start, end)
+	count := j - i
+	n = min(n, count)
+	if n < 2 {
+		return []Range{t.Range(start, end)}
+	}
+
+	s := make([]Range, 0, n)
+	from := start
+	for k := 1; k < n; k++ {
+		to := t.ids[i+k*count/n]
+		s = append(s, t.Range(from, to))
+		from = to
+	}
+	return append(s, t.Range(from, end))
+}
+
+// bounds returns the indexes of the first leaf in the range and the first
+// leaf after it.
+func (t *Tree) bounds(start, end int64) (int, int) {
+	i, _ := slices.BinarySearch(t.ids, start)
+	j, _ := slices.BinarySearch(t.ids, end)
+	return i, max(i, j)
+}
+
+func leafHash(l Leaf) digest {
+	b := binary.BigEndian.AppendUint64(nil, uint64(l.ID))
+	b = binary.BigEndian.AppendUint64(b, uint64(l.Revision))
+	return sha256.Sum256(b)
+}
+
+func xor(a, b digest) digest {
+	for i := range a {
+		a[i] ^= b[i]
+	}
+	return a
+}
@@ -0,0 +1,86 @@
+package merkle_test
+
+import (
+	"testing"
+
+	"github.com/niksmo/gophkeeper/pkg/merkle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func leaves(n int) []merkle.Leaf {
+	s := make([]merkle.Leaf, 0, n)
+	for i := range n {
+		s = append(s, merkle.Leaf{ID: int64(i*3 + 1), Revision: int64(i)})
+	}
+	return s
+}
+
+func TestTree(t *testing.T) {
+	t.Run("Root", func(t *testing.T) {
+		a := merkle.New(leaves(100))
+		s := leaves(100)
+		s[0], s[99] = s[99], s[0]
+		b := merkle.New(s)
+		assert.Equal(t, a.Root(), b.Root(), "the order of leaves is ignored")
+		assert.Len(t, a.Root().Hash, 64)
+
+		s[50].Revision++
+		assert.NotEqual(t, a.Root().Hash, merkle.New(s).Root().Hash)
+		assert.Empty(t, merkle.New(nil).Root().Hash)
+	})
+
+	t.Run("Split", func(t *testing.T) {
+		tree := merkle.New(leaves(100))
+		root := tree.Root()
+		parts := tree.Split(root.Start, root.End, 16)
+		require.Len(t, parts, 16)
+		assert.Equal(t, root.Start, parts[0].Start)
+		assert.Equal(t, root.End, parts[15].End)
+
+		var total int
+		for i, r := range parts {
+			n := tree.Len(r.Start, r.End)
+			assert.InDelta(t, 100/16, n, 1)
+			assert.NotEmpty(t, r.Hash)
+			total += n
+			if i > 0 {
+				assert.Equal(t, parts[i-1].End, r.Start)
+			}
+		}
+		assert.Equal(t, 100, total)
+
+		assert.Equal(t, []merkle.Range{tree.Range(1, 4)}, tree.Split(1, 4, 16),
+			"the range with one leaf is not split")
+		assert.Len(t, tree.Split(1, 8, 16), 3)
+	})
+
+	t.Run("Diverging", func(t *testing.T) {
+		local := leaves(1000)
+		server := leaves(1000)
+		server[700].Revision++
+		server = append(server, merkle.Leaf{ID: 5000, Revision: 1})
+		l, s := merkle.New(local), merkle.New(server)
+
+		ranges := []merkle.Range{s.Root()}
+		var found []int64
+		for round := 0; len(ranges) != 0; round++ {
+			require.Less(t, round, 10)
+			var next []merkle.Range
+			for _, r := range ranges {
+				if l.Range(r.Start, r.End).Hash == r.Hash {
+					continue
+				}
+				if s.Len(r.Start, r.End) <= 4 {
+					found = append(found, s.IDs(r.Start, r.End)...)
+					continue
+				}
+				next = append(next, s.Split(r.Start, r.End, 16)...)
+			}
+			ranges = next
+		}
+		assert.Contains(t, found, server[700].ID)
+		assert.Contains(t, found, int64(5000))
+		assert.Less(t, len(found), 10)
+	})
+}
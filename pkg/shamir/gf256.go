@@ -0,0 +1,46 @@
+package shamir
+
+// The arithmetic of GF(2^8) with the x^8 + x^4 + x^3 + x + 1 reducing
+// polynomial, the multiplication uses the logarithm tables
+// of the 3 generator.
+var (
+	expTable [510]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := range 255 {
+		expTable[i] = x
+		expTable[i+255] = x
+		logTable[x] = byte(i)
+		x = add(x, xtime(x))
+	}
+}
+
+// xtime multiplies by x modulo the reducing polynomial.
+func xtime(b byte) byte {
+	if b&0x80 != 0 {
+		return b<<1 ^ 0x1b
+	}
+	return b << 1
+}
+
+func add(a, b byte) byte {
+	return a ^ b
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+// div divides the a by the not zero b.
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
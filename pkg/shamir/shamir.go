@@ -0,0 +1,120 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8).
+//
+// Every byte of the secret is the constant term of its own random
+// polynomial of the threshold-1 degree, the share is the values of
+// the polynomials at the share x coordinate followed by the coordinate.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+const MaxShares = 255
+
+var (
+	ErrInvalidParams = errors.New("invalid shares or threshold number")
+	ErrEmptySecret   = errors.New("secret is empty")
+	ErrInvalidShares = errors.New("invalid shares")
+)
+
+// Split divides the secret into the shares, any threshold number
+// of them rebuilds the secret.
+func Split(secret []byte, shares, threshold int) ([][]byte, error) {
+	const op = "shamir.Split"
+
+	if threshold < 2 || shares < threshold || shares > MaxShares {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidParams)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrEmptySecret)
+	}
+
+	out := make([][]byte, shares)
+	for i := range out {
+		out[i] = make([]byte, len(secret)+1)
+		out[i][len(secret)] = byte(i + 1)
+	}
+
+	coef := make([]byte, threshold)
+	for idx, b := range secret {
+		if _, err := rand.Read(coef[1:]); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		coef[0] = b
+		for _, share := range out {
+			share[idx] = evaluate(coef, share[len(secret)])
+		}
+	}
+	clear(coef)
+	return out, nil
+}
+
+// Combine rebuilds the secret from the shares. The result of the shares
+// less than the threshold number is a random value, not an error.
+func Combine(shares [][]byte) ([]byte, error) {
+	const op = "shamir.Combine"
+
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("%s: at least two shares: %w",
+			op, ErrInvalidShares)
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, fmt.Errorf("%s: short share: %w", op, ErrInvalidShares)
+	}
+
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool, len(shares))
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, fmt.Errorf("%s: different share length: %w",
+				op, ErrInvalidShares)
+		}
+		x := share[size-1]
+		if x == 0 || seen[x] {
+			return nil, fmt.Errorf("%s: duplicate share: %w",
+				op, ErrInvalidShares)
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, size-1)
+	ys := make([]byte, len(shares))
+	for idx := range secret {
+		for i, share := range shares {
+			ys[i] = share[idx]
+		}
+		secret[idx] = interpolate(xs, ys)
+	}
+	return secret, nil
+}
+
+// evaluate returns the polynomial value at the x by Horner's method.
+func evaluate(coef []byte, x byte) byte {
+	var y byte
+	for i := len(coef) - 1; i >= 0; i-- {
+		y = add(mul(y, x), coef[i])
+	}
+	return y
+}
+
+// interpolate returns the value at zero of the polynomial
+// passing through the points by Lagrange interpolation.
+func interpolate(xs, ys []byte) byte {
+	var y byte
+	for i := range xs {
+		basis := byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			basis = mul(basis, div(xs[j], add(xs[i], xs[j])))
+		}
+		y = add(y, mul(ys[i], basis))
+	}
+	return y
+}
@@ -0,0 +1,79 @@
+package shamir_test
+
+import (
+	"testing"
+
+	"github.com/niksmo/gophkeeper/pkg/shamir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	t.Run("AnyThresholdShares", func(t *testing.T) {
+		shares, err := shamir.Split(secret, 5, 3)
+		require.NoError(t, err)
+		require.Len(t, shares, 5)
+
+		for i := range shares {
+			for j := i + 1; j < len(shares); j++ {
+				for k := j + 1; k < len(shares); k++ {
+					actual, err := shamir.Combine(
+						[][]byte{shares[k], shares[i], shares[j]},
+					)
+					require.NoError(t, err)
+					assert.Equal(t, secret, actual)
+				}
+			}
+		}
+
+		actual, err := shamir.Combine(shares)
+		require.NoError(t, err)
+		assert.Equal(t, secret, actual)
+	})
+
+	t.Run("LessThanThreshold", func(t *testing.T) {
+		shares, err := shamir.Split(secret, 5, 3)
+		require.NoError(t, err)
+
+		actual, err := shamir.Combine(shares[:2])
+		require.NoError(t, err)
+		assert.NotEqual(t, secret, actual)
+	})
+
+	t.Run("MaxShares", func(t *testing.T) {
+		shares, err := shamir.Split(secret, shamir.MaxShares, 2)
+		require.NoError(t, err)
+
+		actual, err := shamir.Combine(
+			[][]byte{shares[0], shares[shamir.MaxShares-1]},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, secret, actual)
+	})
+
+	t.Run("InvalidParams", func(t *testing.T) {
+		for _, p := range [][2]int{{5, 1}, {2, 3}, {256, 3}} {
+			_, err := shamir.Split(secret, p[0], p[1])
+			require.ErrorIs(t, err, shamir.ErrInvalidParams)
+		}
+
+		_, err := shamir.Split(nil, 5, 3)
+		require.ErrorIs(t, err, shamir.ErrEmptySecret)
+	})
+
+	t.Run("InvalidShares", func(t *testing.T) {
+		shares, err := shamir.Split(secret, 3, 2)
+		require.NoError(t, err)
+
+		_, err = shamir.Combine(shares[:1])
+		require.ErrorIs(t, err, shamir.ErrInvalidShares)
+
+		_, err = shamir.Combine([][]byte{shares[0], shares[0]})
+		require.ErrorIs(t, err, shamir.ErrInvalidShares)
+
+		_, err = shamir.Combine([][]byte{shares[0], shares[1][1:]})
+		require.ErrorIs(t, err, shamir.ErrInvalidShares)
+	})
+}
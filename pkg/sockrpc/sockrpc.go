@@ -0,0 +1,240 @@
+package sockrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	socketPerm   = 0o600
+	callTimeout  = 30 * time.Second
+	readyTimeout = 10 * time.Second
+	readyTick    = 50 * time.Millisecond
+)
+
+var (
+	ErrUnavailable   = errors.New("socket is unavailable")
+	ErrAddrInUse     = errors.New("socket is already in use")
+	ErrUnknownMethod = errors.New("unknown method")
+	ErrRemote        = errors.New("remote error")
+)
+
+type HandlerFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+type request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Server serves one JSON request per connection on the unix socket
+// accessible only by the owner.
+type Server struct {
+	path     string
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+}
+
+func NewServer(path string) *Server {
+	return &Server{path: path, handlers: make(map[string]HandlerFunc)}
+}
+
+func (s *Server) Handle(method string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Serve listens the socket until the context is done.
+func (s *Server) Serve(ctx context.Context) error {
+	const op = "Server.Serve"
+
+	if err := s.removeStale(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ln, err := listen(s.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(s.path)
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !ownedByUser(conn) {
+			conn.Close()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// umaskMu serializes the umask changes of the process.
+var umaskMu sync.Mutex
+
+// listen creates the socket with the umask keeping the socket accessible
+// only by the owner, so there is no window for the others to connect.
+func listen(path string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	mask := syscall.Umask(0o777 &^ socketPerm)
+	defer syscall.Umask(mask)
+	return net.Listen("unix", path)
+}
+
+// ownedByUser reports whether the peer of the connection runs as the user
+// of the process.
+func ownedByUser(conn net.Conn) bool {
+	uid, err := peerUID(conn)
+	return err == nil && uid == os.Getuid()
+}
+
+func (s *Server) removeStale() error {
+	if _, err := os.Stat(s.path); err != nil {
+		return nil
+	}
+	conn, err := net.Dial("unix", s.path)
+	if err == nil {
+		conn.Close()
+		return ErrAddrInUse
+	}
+	return os.Remove(s.path)
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callTimeout))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	var resp response
+	result, err := s.call(ctx, req)
+	if err != nil {
+		resp.Error = err.Error()
+	} else if result != nil {
+		resp.Result, err = json.Marshal(result)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+func (s *Server) call(ctx context.Context, req request) (any, error) {
+	s.mu.RLock()
+	h, ok := s.handlers[req.Method]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMethod, req.Method)
+	}
+	return h(ctx, req.Params)
+}
+
+type Client struct {
+	path string
+}
+
+func NewClient(path string) *Client {
+	return &Client{path}
+}
+
+// Call sends the params to the method and decodes the result into
+// the result argument if it is not nil. The error returned by the method
+// is wrapped into ErrRemote.
+func (c *Client) Call(
+	ctx context.Context, method string, params, result any,
+) error {
+	const op = "Client.Call"
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(callTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	req := request{Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if resp.Error != "" {
+		return fmt.Errorf("%s: %w: %s", op, ErrRemote, resp.Error)
+	}
+
+	if result != nil && resp.Result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// WaitReady polls ready until it reports true, e.g. until the started
+// process serves its socket. The context error is returned if ready does
+// not report true in time.
+func WaitReady(ctx context.Context, ready func(context.Context) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readyTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if ready(ctx) {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package sockrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/sockrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.sock")
+	s := sockrpc.NewServer(path)
+
+	s.Handle("echo", func(
+		ctx context.Context, params json.RawMessage,
+	) (any, error) {
+		var v string
+		if err := json.Unmarshal(params, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+	s.Handle("fail", func(context.Context, json.RawMessage) (any, error) {
+		return nil, errors.New("failed")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return path
+}
+
+func TestSockRPC(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Ordinary", func(t *testing.T) {
+		path := startServer(t)
+		c := sockrpc.NewClient(path)
+
+		var result string
+		err := c.Call(ctx, "echo", "hello", &result)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", result)
+	})
+
+	t.Run("SocketPerm", func(t *testing.T) {
+		path := startServer(t)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("RemoteError", func(t *testing.T) {
+		path := startServer(t)
+		c := sockrpc.NewClient(path)
+
+		err := c.Call(ctx, "fail", nil, nil)
+		require.ErrorIs(t, err, sockrpc.ErrRemote)
+		assert.ErrorContains(t, err, "failed")
+
+		err = c.Call(ctx, "unknown", nil, nil)
+		require.ErrorIs(t, err, sockrpc.ErrRemote)
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		c := sockrpc.NewClient(filepath.Join(t.TempDir(), "none.sock"))
+		err := c.Call(ctx, "echo", "hello", nil)
+		require.ErrorIs(t, err, sockrpc.ErrUnavailable)
+	})
+
+	t.Run("AddrInUse", func(t *testing.T) {
+		path := startServer(t)
+		err := sockrpc.NewServer(path).Serve(ctx)
+		require.ErrorIs(t, err, sockrpc.ErrAddrInUse)
+	})
+}
+
+func TestWaitReady(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Ready", func(t *testing.T) {
+		var calls int
+		err := sockrpc.WaitReady(ctx, func(context.Context) bool {
+			calls++
+			return calls == 3
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("ContextDone", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		err := sockrpc.WaitReady(ctx, func(context.Context) bool {
+			return false
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
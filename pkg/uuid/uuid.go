@@ -0,0 +1,66 @@
+// Package uuid generates the time ordered UUIDs of the version 7,
+// see RFC 9562. The UUID keeps the Unix time milliseconds in the first
+// 48 bits and the random bits in the rest, except the version and
+// the variant bits.
+package uuid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// New returns the UUID of the current time in the canonical text form.
+func New() string {
+	return NewAt(time.Now())
+}
+
+// NewAt returns the UUID of the time t in the canonical text form.
+func NewAt(t time.Time) string {
+	var b [16]byte
+	rand.Read(b[6:])
+
+	ms := uint64(t.UnixMilli())
+	for i := range 6 {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return format(b)
+}
+
+// Valid reports whether the s is the UUID in the canonical text form.
+func Valid(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range []byte(s) {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			isHex := '0' <= c && c <= '9' || 'a' <= c && c <= 'f' ||
+				'A' <= c && c <= 'F'
+			if !isHex {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func format(b [16]byte) string {
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
@@ -0,0 +1,29 @@
+package uuid_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/niksmo/gophkeeper/pkg/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	now := time.Now()
+	id := uuid.NewAt(now)
+	assert.True(t, uuid.Valid(id))
+	assert.Equal(t, byte('7'), id[14], "version")
+	assert.Contains(t, "89ab", string(id[19]), "variant")
+	assert.NotEqual(t, id, uuid.NewAt(now))
+	assert.Less(t, id, uuid.NewAt(now.Add(time.Millisecond)),
+		"the UUIDs are ordered by the time")
+	assert.Equal(t, "0191", uuid.NewAt(time.UnixMilli(0x0191_0000_0000))[:4])
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, uuid.Valid("0191e7a0-7c8a-7b4e-9f3a-1c2d3e4f5a6b"))
+	assert.False(t, uuid.Valid(""))
+	assert.False(t, uuid.Valid("0191e7a0-7c8a-7b4e-9f3a-1c2d3e4f5a6"))
+	assert.False(t, uuid.Valid("0191e7a0+7c8a-7b4e-9f3a-1c2d3e4f5a6b"))
+	assert.False(t, uuid.Valid("0191e7a0-7c8a-7b4e-9f3a-1c2d3e4f5a6g"))
+}
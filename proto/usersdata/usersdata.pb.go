@@ -24,8 +24,12 @@ const (
 type Comparable struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ID            int64                  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
-	UpdatedAt     int64                  `protobuf:"varint,3,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+	NameIndex     string                 `protobuf:"bytes,4,opt,name=NameIndex,proto3" json:"NameIndex,omitempty"`
+	Revision      int64                  `protobuf:"varint,5,opt,name=Revision,proto3" json:"Revision,omitempty"`
+	Clock         int64                  `protobuf:"varint,6,opt,name=Clock,proto3" json:"Clock,omitempty"`
+	Key           string                 `protobuf:"bytes,7,opt,name=Key,proto3" json:"Key,omitempty"`
+	UUID          string                 `protobuf:"bytes,8,opt,name=UUID,proto3" json:"UUID,omitempty"`
+	Hash          string                 `protobuf:"bytes,9,opt,name=Hash,proto3" json:"Hash,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -67,47 +71,1104 @@ func (x *Comparable) GetID() int64 {
 	return 0
 }
 
-func (x *Comparable) GetName() string {
+func (x *Comparable) GetNameIndex() string {
+	if x != nil {
+		return x.NameIndex
+	}
+	return ""
+}
+
+func (x *Comparable) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *Comparable) GetClock() int64 {
+	if x != nil {
+		return x.Clock
+	}
+	return 0
+}
+
+func (x *Comparable) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Comparable) GetUUID() string {
+	if x != nil {
+		return x.UUID
+	}
+	return ""
+}
+
+func (x *Comparable) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+type Payload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ID            int64                  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=Data,proto3" json:"Data,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,4,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	Deleted       bool                   `protobuf:"varint,6,opt,name=Deleted,proto3" json:"Deleted,omitempty"`
+	NameIndex     string                 `protobuf:"bytes,7,opt,name=NameIndex,proto3" json:"NameIndex,omitempty"`
+	Name          []byte                 `protobuf:"bytes,8,opt,name=Name,proto3" json:"Name,omitempty"`
+	Revision      int64                  `protobuf:"varint,9,opt,name=Revision,proto3" json:"Revision,omitempty"`
+	Clock         int64                  `protobuf:"varint,10,opt,name=Clock,proto3" json:"Clock,omitempty"`
+	Key           string                 `protobuf:"bytes,11,opt,name=Key,proto3" json:"Key,omitempty"`
+	UUID          string                 `protobuf:"bytes,12,opt,name=UUID,proto3" json:"UUID,omitempty"`
+	DataHash      string                 `protobuf:"bytes,13,opt,name=DataHash,proto3" json:"DataHash,omitempty"`
+	DataSize      int64                  `protobuf:"varint,14,opt,name=DataSize,proto3" json:"DataSize,omitempty"`
+	Hash          string                 `protobuf:"bytes,15,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Payload) Reset() {
+	*x = Payload{}
+	mi := &file_proto_usersdata_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Payload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payload) ProtoMessage() {}
+
+func (x *Payload) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payload.ProtoReflect.Descriptor instead.
+func (*Payload) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Payload) GetID() int64 {
+	if x != nil {
+		return x.ID
+	}
+	return 0
+}
+
+func (x *Payload) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Payload) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Payload) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *Payload) GetNameIndex() string {
+	if x != nil {
+		return x.NameIndex
+	}
+	return ""
+}
+
+func (x *Payload) GetName() []byte {
 	if x != nil {
 		return x.Name
 	}
+	return nil
+}
+
+func (x *Payload) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *Payload) GetClock() int64 {
+	if x != nil {
+		return x.Clock
+	}
+	return 0
+}
+
+func (x *Payload) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Payload) GetUUID() string {
+	if x != nil {
+		return x.UUID
+	}
+	return ""
+}
+
+func (x *Payload) GetDataHash() string {
+	if x != nil {
+		return x.DataHash
+	}
+	return ""
+}
+
+func (x *Payload) GetDataSize() int64 {
+	if x != nil {
+		return x.DataSize
+	}
+	return 0
+}
+
+func (x *Payload) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+type Revision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ID            int64                  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Revision      int64                  `protobuf:"varint,2,opt,name=Revision,proto3" json:"Revision,omitempty"`
+	Clock         int64                  `protobuf:"varint,3,opt,name=Clock,proto3" json:"Clock,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Revision) Reset() {
+	*x = Revision{}
+	mi := &file_proto_usersdata_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Revision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Revision) ProtoMessage() {}
+
+func (x *Revision) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Revision.ProtoReflect.Descriptor instead.
+func (*Revision) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Revision) GetID() int64 {
+	if x != nil {
+		return x.ID
+	}
+	return 0
+}
+
+func (x *Revision) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *Revision) GetClock() int64 {
+	if x != nil {
+		return x.Clock
+	}
+	return 0
+}
+
+type GetComparableRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	After         int64                  `protobuf:"varint,3,opt,name=After,proto3" json:"After,omitempty"`
+	Limit         int64                  `protobuf:"varint,4,opt,name=Limit,proto3" json:"Limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetComparableRequest) Reset() {
+	*x = GetComparableRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetComparableRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetComparableRequest) ProtoMessage() {}
+
+func (x *GetComparableRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetComparableRequest.ProtoReflect.Descriptor instead.
+func (*GetComparableRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetComparableRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *GetComparableRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *GetComparableRequest) GetAfter() int64 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+func (x *GetComparableRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetComparableResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []*Comparable          `protobuf:"bytes,1,rep,name=Data,proto3" json:"Data,omitempty"`
+	Cursor        int64                  `protobuf:"varint,2,opt,name=Cursor,proto3" json:"Cursor,omitempty"`
+	Next          int64                  `protobuf:"varint,3,opt,name=Next,proto3" json:"Next,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetComparableResponse) Reset() {
+	*x = GetComparableResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetComparableResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetComparableResponse) ProtoMessage() {}
+
+func (x *GetComparableResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetComparableResponse.ProtoReflect.Descriptor instead.
+func (*GetComparableResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetComparableResponse) GetData() []*Comparable {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *GetComparableResponse) GetCursor() int64 {
+	if x != nil {
+		return x.Cursor
+	}
+	return 0
+}
+
+func (x *GetComparableResponse) GetNext() int64 {
+	if x != nil {
+		return x.Next
+	}
+	return 0
+}
+
+type Range struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int64                  `protobuf:"varint,1,opt,name=Start,proto3" json:"Start,omitempty"`
+	End           int64                  `protobuf:"varint,2,opt,name=End,proto3" json:"End,omitempty"`
+	Hash          string                 `protobuf:"bytes,3,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Range) Reset() {
+	*x = Range{}
+	mi := &file_proto_usersdata_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Range) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Range) ProtoMessage() {}
+
+func (x *Range) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Range.ProtoReflect.Descriptor instead.
+func (*Range) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Range) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Range) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Range) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+type ReconcileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	Ranges        []*Range               `protobuf:"bytes,3,rep,name=Ranges,proto3" json:"Ranges,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileRequest) Reset() {
+	*x = ReconcileRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileRequest) ProtoMessage() {}
+
+func (x *ReconcileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ReconcileRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ReconcileRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *ReconcileRequest) GetRanges() []*Range {
+	if x != nil {
+		return x.Ranges
+	}
+	return nil
+}
+
+type ReconcileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ranges        []*Range               `protobuf:"bytes,1,rep,name=Ranges,proto3" json:"Ranges,omitempty"`
+	Data          []*Comparable          `protobuf:"bytes,2,rep,name=Data,proto3" json:"Data,omitempty"`
+	Cursor        int64                  `protobuf:"varint,3,opt,name=Cursor,proto3" json:"Cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileResponse) Reset() {
+	*x = ReconcileResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileResponse) ProtoMessage() {}
+
+func (x *ReconcileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileResponse.ProtoReflect.Descriptor instead.
+func (*ReconcileResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ReconcileResponse) GetRanges() []*Range {
+	if x != nil {
+		return x.Ranges
+	}
+	return nil
+}
+
+func (x *ReconcileResponse) GetData() []*Comparable {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ReconcileResponse) GetCursor() int64 {
+	if x != nil {
+		return x.Cursor
+	}
+	return 0
+}
+
+type GetAllRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	After         int64                  `protobuf:"varint,3,opt,name=After,proto3" json:"After,omitempty"`
+	Limit         int64                  `protobuf:"varint,4,opt,name=Limit,proto3" json:"Limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAllRequest) Reset() {
+	*x = GetAllRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAllRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAllRequest) ProtoMessage() {}
+
+func (x *GetAllRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAllRequest.ProtoReflect.Descriptor instead.
+func (*GetAllRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetAllRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *GetAllRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *GetAllRequest) GetAfter() int64 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+func (x *GetAllRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetAllResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []*Payload             `protobuf:"bytes,1,rep,name=Data,proto3" json:"Data,omitempty"`
+	Next          int64                  `protobuf:"varint,2,opt,name=Next,proto3" json:"Next,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAllResponse) Reset() {
+	*x = GetAllResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAllResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAllResponse) ProtoMessage() {}
+
+func (x *GetAllResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAllResponse.ProtoReflect.Descriptor instead.
+func (*GetAllResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetAllResponse) GetData() []*Payload {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *GetAllResponse) GetNext() int64 {
+	if x != nil {
+		return x.Next
+	}
+	return 0
+}
+
+type GetSliceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	IDs           []int64                `protobuf:"varint,3,rep,packed,name=IDs,proto3" json:"IDs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSliceRequest) Reset() {
+	*x = GetSliceRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSliceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSliceRequest) ProtoMessage() {}
+
+func (x *GetSliceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSliceRequest.ProtoReflect.Descriptor instead.
+func (*GetSliceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetSliceRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *GetSliceRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *GetSliceRequest) GetIDs() []int64 {
+	if x != nil {
+		return x.IDs
+	}
+	return nil
+}
+
+type GetSliceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []*Payload             `protobuf:"bytes,1,rep,name=Data,proto3" json:"Data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSliceResponse) Reset() {
+	*x = GetSliceResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSliceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSliceResponse) ProtoMessage() {}
+
+func (x *GetSliceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSliceResponse.ProtoReflect.Descriptor instead.
+func (*GetSliceResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetSliceResponse) GetData() []*Payload {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type UpdateSliceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	Data          []*Payload             `protobuf:"bytes,3,rep,name=Data,proto3" json:"Data,omitempty"`
+	Origin        string                 `protobuf:"bytes,4,opt,name=Origin,proto3" json:"Origin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateSliceRequest) Reset() {
+	*x = UpdateSliceRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateSliceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateSliceRequest) ProtoMessage() {}
+
+func (x *UpdateSliceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateSliceRequest.ProtoReflect.Descriptor instead.
+func (*UpdateSliceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateSliceRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *UpdateSliceRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *UpdateSliceRequest) GetData() []*Payload {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *UpdateSliceRequest) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+type UpdateSliceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Revisions     []*Revision            `protobuf:"bytes,2,rep,name=Revisions,proto3" json:"Revisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateSliceResponse) Reset() {
+	*x = UpdateSliceResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateSliceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateSliceResponse) ProtoMessage() {}
+
+func (x *UpdateSliceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateSliceResponse.ProtoReflect.Descriptor instead.
+func (*UpdateSliceResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *UpdateSliceResponse) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *UpdateSliceResponse) GetRevisions() []*Revision {
+	if x != nil {
+		return x.Revisions
+	}
+	return nil
+}
+
+type InsertSliceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	Data          []*Payload             `protobuf:"bytes,3,rep,name=Data,proto3" json:"Data,omitempty"`
+	Origin        string                 `protobuf:"bytes,4,opt,name=Origin,proto3" json:"Origin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertSliceRequest) Reset() {
+	*x = InsertSliceRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertSliceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertSliceRequest) ProtoMessage() {}
+
+func (x *InsertSliceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertSliceRequest.ProtoReflect.Descriptor instead.
+func (*InsertSliceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *InsertSliceRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *InsertSliceRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *InsertSliceRequest) GetData() []*Payload {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *InsertSliceRequest) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+type InsertSliceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revisions     []*Revision            `protobuf:"bytes,2,rep,name=Revisions,proto3" json:"Revisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertSliceResponse) Reset() {
+	*x = InsertSliceResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertSliceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertSliceResponse) ProtoMessage() {}
+
+func (x *InsertSliceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertSliceResponse.ProtoReflect.Descriptor instead.
+func (*InsertSliceResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *InsertSliceResponse) GetRevisions() []*Revision {
+	if x != nil {
+		return x.Revisions
+	}
+	return nil
+}
+
+type GetChangesSinceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	Cursor        int64                  `protobuf:"varint,3,opt,name=Cursor,proto3" json:"Cursor,omitempty"`
+	Limit         int64                  `protobuf:"varint,4,opt,name=Limit,proto3" json:"Limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChangesSinceRequest) Reset() {
+	*x = GetChangesSinceRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChangesSinceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChangesSinceRequest) ProtoMessage() {}
+
+func (x *GetChangesSinceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChangesSinceRequest.ProtoReflect.Descriptor instead.
+func (*GetChangesSinceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *GetChangesSinceRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *GetChangesSinceRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
 	return ""
 }
 
-func (x *Comparable) GetUpdatedAt() int64 {
+func (x *GetChangesSinceRequest) GetCursor() int64 {
 	if x != nil {
-		return x.UpdatedAt
+		return x.Cursor
 	}
 	return 0
 }
 
-type Payload struct {
+func (x *GetChangesSinceRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetChangesSinceResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	ID            int64                  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
-	Data          []byte                 `protobuf:"bytes,3,opt,name=Data,proto3" json:"Data,omitempty"`
-	CreatedAt     int64                  `protobuf:"varint,4,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
-	UpdatedAt     int64                  `protobuf:"varint,5,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
-	Deleted       bool                   `protobuf:"varint,6,opt,name=Deleted,proto3" json:"Deleted,omitempty"`
+	Data          []*Payload             `protobuf:"bytes,1,rep,name=Data,proto3" json:"Data,omitempty"`
+	Cursor        int64                  `protobuf:"varint,2,opt,name=Cursor,proto3" json:"Cursor,omitempty"`
+	Next          int64                  `protobuf:"varint,3,opt,name=Next,proto3" json:"Next,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Payload) Reset() {
-	*x = Payload{}
-	mi := &file_proto_usersdata_proto_msgTypes[1]
+func (x *GetChangesSinceResponse) Reset() {
+	*x = GetChangesSinceResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Payload) String() string {
+func (x *GetChangesSinceResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Payload) ProtoMessage() {}
+func (*GetChangesSinceResponse) ProtoMessage() {}
 
-func (x *Payload) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[1]
+func (x *GetChangesSinceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -118,76 +1179,55 @@ func (x *Payload) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Payload.ProtoReflect.Descriptor instead.
-func (*Payload) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *Payload) GetID() int64 {
-	if x != nil {
-		return x.ID
-	}
-	return 0
-}
-
-func (x *Payload) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
+// Deprecated: Use GetChangesSinceResponse.ProtoReflect.Descriptor instead.
+func (*GetChangesSinceResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *Payload) GetData() []byte {
+func (x *GetChangesSinceResponse) GetData() []*Payload {
 	if x != nil {
 		return x.Data
 	}
 	return nil
 }
 
-func (x *Payload) GetCreatedAt() int64 {
+func (x *GetChangesSinceResponse) GetCursor() int64 {
 	if x != nil {
-		return x.CreatedAt
+		return x.Cursor
 	}
 	return 0
 }
 
-func (x *Payload) GetUpdatedAt() int64 {
+func (x *GetChangesSinceResponse) GetNext() int64 {
 	if x != nil {
-		return x.UpdatedAt
+		return x.Next
 	}
 	return 0
 }
 
-func (x *Payload) GetDeleted() bool {
-	if x != nil {
-		return x.Deleted
-	}
-	return false
-}
-
-type GetComparableRequest struct {
+type SubscribeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
-	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	Origin        string                 `protobuf:"bytes,2,opt,name=Origin,proto3" json:"Origin,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetComparableRequest) Reset() {
-	*x = GetComparableRequest{}
-	mi := &file_proto_usersdata_proto_msgTypes[2]
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetComparableRequest) String() string {
+func (x *SubscribeRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetComparableRequest) ProtoMessage() {}
+func (*SubscribeRequest) ProtoMessage() {}
 
-func (x *GetComparableRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[2]
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -198,47 +1238,48 @@ func (x *GetComparableRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetComparableRequest.ProtoReflect.Descriptor instead.
-func (*GetComparableRequest) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{18}
 }
 
-func (x *GetComparableRequest) GetToken() string {
+func (x *SubscribeRequest) GetToken() string {
 	if x != nil {
 		return x.Token
 	}
 	return ""
 }
 
-func (x *GetComparableRequest) GetEntity() string {
+func (x *SubscribeRequest) GetOrigin() string {
 	if x != nil {
-		return x.Entity
+		return x.Origin
 	}
 	return ""
 }
 
-type GetComparableResponse struct {
+type ChangeEvent struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          []*Comparable          `protobuf:"bytes,1,rep,name=Data,proto3" json:"Data,omitempty"`
+	Entity        string                 `protobuf:"bytes,1,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	Revision      int64                  `protobuf:"varint,2,opt,name=Revision,proto3" json:"Revision,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetComparableResponse) Reset() {
-	*x = GetComparableResponse{}
-	mi := &file_proto_usersdata_proto_msgTypes[3]
+func (x *ChangeEvent) Reset() {
+	*x = ChangeEvent{}
+	mi := &file_proto_usersdata_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetComparableResponse) String() string {
+func (x *ChangeEvent) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetComparableResponse) ProtoMessage() {}
+func (*ChangeEvent) ProtoMessage() {}
 
-func (x *GetComparableResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[3]
+func (x *ChangeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -249,41 +1290,48 @@ func (x *GetComparableResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetComparableResponse.ProtoReflect.Descriptor instead.
-func (*GetComparableResponse) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use ChangeEvent.ProtoReflect.Descriptor instead.
+func (*ChangeEvent) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *GetComparableResponse) GetData() []*Comparable {
+func (x *ChangeEvent) GetEntity() string {
 	if x != nil {
-		return x.Data
+		return x.Entity
 	}
-	return nil
+	return ""
 }
 
-type GetAllRequest struct {
+func (x *ChangeEvent) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type GetUploadOffsetRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
-	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
+	Hash          string                 `protobuf:"bytes,2,opt,name=Hash,proto3" json:"Hash,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetAllRequest) Reset() {
-	*x = GetAllRequest{}
-	mi := &file_proto_usersdata_proto_msgTypes[4]
+func (x *GetUploadOffsetRequest) Reset() {
+	*x = GetUploadOffsetRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetAllRequest) String() string {
+func (x *GetUploadOffsetRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetAllRequest) ProtoMessage() {}
+func (*GetUploadOffsetRequest) ProtoMessage() {}
 
-func (x *GetAllRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[4]
+func (x *GetUploadOffsetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -294,47 +1342,47 @@ func (x *GetAllRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetAllRequest.ProtoReflect.Descriptor instead.
-func (*GetAllRequest) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use GetUploadOffsetRequest.ProtoReflect.Descriptor instead.
+func (*GetUploadOffsetRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *GetAllRequest) GetToken() string {
+func (x *GetUploadOffsetRequest) GetToken() string {
 	if x != nil {
 		return x.Token
 	}
 	return ""
 }
 
-func (x *GetAllRequest) GetEntity() string {
+func (x *GetUploadOffsetRequest) GetHash() string {
 	if x != nil {
-		return x.Entity
+		return x.Hash
 	}
 	return ""
 }
 
-type GetAllResponse struct {
+type GetUploadOffsetResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          []*Payload             `protobuf:"bytes,1,rep,name=Data,proto3" json:"Data,omitempty"`
+	Offset        int64                  `protobuf:"varint,1,opt,name=Offset,proto3" json:"Offset,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetAllResponse) Reset() {
-	*x = GetAllResponse{}
-	mi := &file_proto_usersdata_proto_msgTypes[5]
+func (x *GetUploadOffsetResponse) Reset() {
+	*x = GetUploadOffsetResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetAllResponse) String() string {
+func (x *GetUploadOffsetResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetAllResponse) ProtoMessage() {}
+func (*GetUploadOffsetResponse) ProtoMessage() {}
 
-func (x *GetAllResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[5]
+func (x *GetUploadOffsetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -345,42 +1393,44 @@ func (x *GetAllResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetAllResponse.ProtoReflect.Descriptor instead.
-func (*GetAllResponse) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use GetUploadOffsetResponse.ProtoReflect.Descriptor instead.
+func (*GetUploadOffsetResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{21}
 }
 
-func (x *GetAllResponse) GetData() []*Payload {
+func (x *GetUploadOffsetResponse) GetOffset() int64 {
 	if x != nil {
-		return x.Data
+		return x.Offset
 	}
-	return nil
+	return 0
 }
 
-type GetSliceRequest struct {
+type UploadChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
-	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
-	IDs           []int64                `protobuf:"varint,3,rep,packed,name=IDs,proto3" json:"IDs,omitempty"`
+	Hash          string                 `protobuf:"bytes,2,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=Size,proto3" json:"Size,omitempty"`
+	Offset        int64                  `protobuf:"varint,4,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Data          []byte                 `protobuf:"bytes,5,opt,name=Data,proto3" json:"Data,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetSliceRequest) Reset() {
-	*x = GetSliceRequest{}
-	mi := &file_proto_usersdata_proto_msgTypes[6]
+func (x *UploadChunk) Reset() {
+	*x = UploadChunk{}
+	mi := &file_proto_usersdata_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetSliceRequest) String() string {
+func (x *UploadChunk) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetSliceRequest) ProtoMessage() {}
+func (*UploadChunk) ProtoMessage() {}
 
-func (x *GetSliceRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[6]
+func (x *UploadChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -391,54 +1441,68 @@ func (x *GetSliceRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetSliceRequest.ProtoReflect.Descriptor instead.
-func (*GetSliceRequest) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use UploadChunk.ProtoReflect.Descriptor instead.
+func (*UploadChunk) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *GetSliceRequest) GetToken() string {
+func (x *UploadChunk) GetToken() string {
 	if x != nil {
 		return x.Token
 	}
 	return ""
 }
 
-func (x *GetSliceRequest) GetEntity() string {
+func (x *UploadChunk) GetHash() string {
 	if x != nil {
-		return x.Entity
+		return x.Hash
 	}
 	return ""
 }
 
-func (x *GetSliceRequest) GetIDs() []int64 {
+func (x *UploadChunk) GetSize() int64 {
 	if x != nil {
-		return x.IDs
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadChunk) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *UploadChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
 	}
 	return nil
 }
 
-type GetSliceResponse struct {
+type UploadResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          []*Payload             `protobuf:"bytes,1,rep,name=Data,proto3" json:"Data,omitempty"`
+	Offset        int64                  `protobuf:"varint,1,opt,name=Offset,proto3" json:"Offset,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetSliceResponse) Reset() {
-	*x = GetSliceResponse{}
-	mi := &file_proto_usersdata_proto_msgTypes[7]
+func (x *UploadResponse) Reset() {
+	*x = UploadResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetSliceResponse) String() string {
+func (x *UploadResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetSliceResponse) ProtoMessage() {}
+func (*UploadResponse) ProtoMessage() {}
 
-func (x *GetSliceResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[7]
+func (x *UploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -449,42 +1513,44 @@ func (x *GetSliceResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetSliceResponse.ProtoReflect.Descriptor instead.
-func (*GetSliceResponse) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
+func (*UploadResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{23}
 }
 
-func (x *GetSliceResponse) GetData() []*Payload {
+func (x *UploadResponse) GetOffset() int64 {
 	if x != nil {
-		return x.Data
+		return x.Offset
 	}
-	return nil
+	return 0
 }
 
-type UpdateSliceRequest struct {
+type DownloadRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
 	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
-	Data          []*Payload             `protobuf:"bytes,3,rep,name=Data,proto3" json:"Data,omitempty"`
+	ID            int64                  `protobuf:"varint,3,opt,name=ID,proto3" json:"ID,omitempty"`
+	Hash          string                 `protobuf:"bytes,4,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	Offset        int64                  `protobuf:"varint,5,opt,name=Offset,proto3" json:"Offset,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UpdateSliceRequest) Reset() {
-	*x = UpdateSliceRequest{}
-	mi := &file_proto_usersdata_proto_msgTypes[8]
+func (x *DownloadRequest) Reset() {
+	*x = DownloadRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UpdateSliceRequest) String() string {
+func (x *DownloadRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UpdateSliceRequest) ProtoMessage() {}
+func (*DownloadRequest) ProtoMessage() {}
 
-func (x *UpdateSliceRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[8]
+func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -495,54 +1561,69 @@ func (x *UpdateSliceRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UpdateSliceRequest.ProtoReflect.Descriptor instead.
-func (*UpdateSliceRequest) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use DownloadRequest.ProtoReflect.Descriptor instead.
+func (*DownloadRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{24}
 }
 
-func (x *UpdateSliceRequest) GetToken() string {
+func (x *DownloadRequest) GetToken() string {
 	if x != nil {
 		return x.Token
 	}
 	return ""
 }
 
-func (x *UpdateSliceRequest) GetEntity() string {
+func (x *DownloadRequest) GetEntity() string {
 	if x != nil {
 		return x.Entity
 	}
 	return ""
 }
 
-func (x *UpdateSliceRequest) GetData() []*Payload {
+func (x *DownloadRequest) GetID() int64 {
 	if x != nil {
-		return x.Data
+		return x.ID
 	}
-	return nil
+	return 0
 }
 
-type UpdateSliceResponse struct {
+func (x *DownloadRequest) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *DownloadRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type DownloadChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Offset        int64                  `protobuf:"varint,1,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UpdateSliceResponse) Reset() {
-	*x = UpdateSliceResponse{}
-	mi := &file_proto_usersdata_proto_msgTypes[9]
+func (x *DownloadChunk) Reset() {
+	*x = DownloadChunk{}
+	mi := &file_proto_usersdata_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UpdateSliceResponse) String() string {
+func (x *DownloadChunk) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UpdateSliceResponse) ProtoMessage() {}
+func (*DownloadChunk) ProtoMessage() {}
 
-func (x *UpdateSliceResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[9]
+func (x *DownloadChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -553,42 +1634,47 @@ func (x *UpdateSliceResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UpdateSliceResponse.ProtoReflect.Descriptor instead.
-func (*UpdateSliceResponse) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use DownloadChunk.ProtoReflect.Descriptor instead.
+func (*DownloadChunk) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{25}
 }
 
-func (x *UpdateSliceResponse) GetOk() bool {
+func (x *DownloadChunk) GetOffset() int64 {
 	if x != nil {
-		return x.Ok
+		return x.Offset
 	}
-	return false
+	return 0
 }
 
-type InsertSliceRequest struct {
+func (x *DownloadChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type GetIndexSaltRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
-	Entity        string                 `protobuf:"bytes,2,opt,name=Entity,proto3" json:"Entity,omitempty"`
-	Data          []*Payload             `protobuf:"bytes,3,rep,name=Data,proto3" json:"Data,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *InsertSliceRequest) Reset() {
-	*x = InsertSliceRequest{}
-	mi := &file_proto_usersdata_proto_msgTypes[10]
+func (x *GetIndexSaltRequest) Reset() {
+	*x = GetIndexSaltRequest{}
+	mi := &file_proto_usersdata_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *InsertSliceRequest) String() string {
+func (x *GetIndexSaltRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*InsertSliceRequest) ProtoMessage() {}
+func (*GetIndexSaltRequest) ProtoMessage() {}
 
-func (x *InsertSliceRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[10]
+func (x *GetIndexSaltRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -599,54 +1685,40 @@ func (x *InsertSliceRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use InsertSliceRequest.ProtoReflect.Descriptor instead.
-func (*InsertSliceRequest) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use GetIndexSaltRequest.ProtoReflect.Descriptor instead.
+func (*GetIndexSaltRequest) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *InsertSliceRequest) GetToken() string {
+func (x *GetIndexSaltRequest) GetToken() string {
 	if x != nil {
 		return x.Token
 	}
 	return ""
 }
 
-func (x *InsertSliceRequest) GetEntity() string {
-	if x != nil {
-		return x.Entity
-	}
-	return ""
-}
-
-func (x *InsertSliceRequest) GetData() []*Payload {
-	if x != nil {
-		return x.Data
-	}
-	return nil
-}
-
-type InsertSliceResponse struct {
+type GetIndexSaltResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	IDs           []int64                `protobuf:"varint,1,rep,packed,name=IDs,proto3" json:"IDs,omitempty"`
+	Salt          []byte                 `protobuf:"bytes,1,opt,name=Salt,proto3" json:"Salt,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *InsertSliceResponse) Reset() {
-	*x = InsertSliceResponse{}
-	mi := &file_proto_usersdata_proto_msgTypes[11]
+func (x *GetIndexSaltResponse) Reset() {
+	*x = GetIndexSaltResponse{}
+	mi := &file_proto_usersdata_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *InsertSliceResponse) String() string {
+func (x *GetIndexSaltResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*InsertSliceResponse) ProtoMessage() {}
+func (*GetIndexSaltResponse) ProtoMessage() {}
 
-func (x *InsertSliceResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_usersdata_proto_msgTypes[11]
+func (x *GetIndexSaltResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_usersdata_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -657,14 +1729,14 @@ func (x *InsertSliceResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use InsertSliceResponse.ProtoReflect.Descriptor instead.
-func (*InsertSliceResponse) Descriptor() ([]byte, []int) {
-	return file_proto_usersdata_proto_rawDescGZIP(), []int{11}
+// Deprecated: Use GetIndexSaltResponse.ProtoReflect.Descriptor instead.
+func (*GetIndexSaltResponse) Descriptor() ([]byte, []int) {
+	return file_proto_usersdata_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *InsertSliceResponse) GetIDs() []int64 {
+func (x *GetIndexSaltResponse) GetSalt() []byte {
 	if x != nil {
-		return x.IDs
+		return x.Salt
 	}
 	return nil
 }
@@ -673,53 +1745,139 @@ var File_proto_usersdata_proto protoreflect.FileDescriptor
 
 const file_proto_usersdata_proto_rawDesc = "" +
 	"\n" +
-	"\x15proto/usersdata.proto\x12\tusersdata\"N\n" +
+	"\x15proto/usersdata.proto\x12\tusersdata\"\xb2\x01\n" +
 	"\n" +
 	"Comparable\x12\x0e\n" +
-	"\x02ID\x18\x01 \x01(\x03R\x02ID\x12\x12\n" +
-	"\x04Name\x18\x02 \x01(\tR\x04Name\x12\x1c\n" +
-	"\tUpdatedAt\x18\x03 \x01(\x03R\tUpdatedAt\"\x97\x01\n" +
+	"\x02ID\x18\x01 \x01(\x03R\x02ID\x12\x1c\n" +
+	"\tNameIndex\x18\x04 \x01(\tR\tNameIndex\x12\x1a\n" +
+	"\bRevision\x18\x05 \x01(\x03R\bRevision\x12\x14\n" +
+	"\x05Clock\x18\x06 \x01(\x03R\x05Clock\x12\x10\n" +
+	"\x03Key\x18\a \x01(\tR\x03Key\x12\x12\n" +
+	"\x04UUID\x18\b \x01(\tR\x04UUID\x12\x12\n" +
+	"\x04Hash\x18\t \x01(\tR\x04HashJ\x04\b\x02\x10\x03J\x04\b\x03\x10\x04\"\xc7\x02\n" +
 	"\aPayload\x12\x0e\n" +
 	"\x02ID\x18\x01 \x01(\x03R\x02ID\x12\x12\n" +
-	"\x04Name\x18\x02 \x01(\tR\x04Name\x12\x12\n" +
 	"\x04Data\x18\x03 \x01(\fR\x04Data\x12\x1c\n" +
-	"\tCreatedAt\x18\x04 \x01(\x03R\tCreatedAt\x12\x1c\n" +
-	"\tUpdatedAt\x18\x05 \x01(\x03R\tUpdatedAt\x12\x18\n" +
-	"\aDeleted\x18\x06 \x01(\bR\aDeleted\"D\n" +
+	"\tCreatedAt\x18\x04 \x01(\x03R\tCreatedAt\x12\x18\n" +
+	"\aDeleted\x18\x06 \x01(\bR\aDeleted\x12\x1c\n" +
+	"\tNameIndex\x18\a \x01(\tR\tNameIndex\x12\x12\n" +
+	"\x04Name\x18\b \x01(\fR\x04Name\x12\x1a\n" +
+	"\bRevision\x18\t \x01(\x03R\bRevision\x12\x14\n" +
+	"\x05Clock\x18\n" +
+	" \x01(\x03R\x05Clock\x12\x10\n" +
+	"\x03Key\x18\v \x01(\tR\x03Key\x12\x12\n" +
+	"\x04UUID\x18\f \x01(\tR\x04UUID\x12\x1a\n" +
+	"\bDataHash\x18\r \x01(\tR\bDataHash\x12\x1a\n" +
+	"\bDataSize\x18\x0e \x01(\x03R\bDataSize\x12\x12\n" +
+	"\x04Hash\x18\x0f \x01(\tR\x04HashJ\x04\b\x02\x10\x03J\x04\b\x05\x10\x06\"L\n" +
+	"\bRevision\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\x03R\x02ID\x12\x1a\n" +
+	"\bRevision\x18\x02 \x01(\x03R\bRevision\x12\x14\n" +
+	"\x05Clock\x18\x03 \x01(\x03R\x05Clock\"p\n" +
 	"\x14GetComparableRequest\x12\x14\n" +
 	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
-	"\x06Entity\x18\x02 \x01(\tR\x06Entity\"B\n" +
+	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12\x14\n" +
+	"\x05After\x18\x03 \x01(\x03R\x05After\x12\x14\n" +
+	"\x05Limit\x18\x04 \x01(\x03R\x05Limit\"n\n" +
 	"\x15GetComparableResponse\x12)\n" +
-	"\x04Data\x18\x01 \x03(\v2\x15.usersdata.ComparableR\x04Data\"=\n" +
+	"\x04Data\x18\x01 \x03(\v2\x15.usersdata.ComparableR\x04Data\x12\x16\n" +
+	"\x06Cursor\x18\x02 \x01(\x03R\x06Cursor\x12\x12\n" +
+	"\x04Next\x18\x03 \x01(\x03R\x04Next\"C\n" +
+	"\x05Range\x12\x14\n" +
+	"\x05Start\x18\x01 \x01(\x03R\x05Start\x12\x10\n" +
+	"\x03End\x18\x02 \x01(\x03R\x03End\x12\x12\n" +
+	"\x04Hash\x18\x03 \x01(\tR\x04Hash\"j\n" +
+	"\x10ReconcileRequest\x12\x14\n" +
+	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
+	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12(\n" +
+	"\x06Ranges\x18\x03 \x03(\v2\x10.usersdata.RangeR\x06Ranges\"\x80\x01\n" +
+	"\x11ReconcileResponse\x12(\n" +
+	"\x06Ranges\x18\x01 \x03(\v2\x10.usersdata.RangeR\x06Ranges\x12)\n" +
+	"\x04Data\x18\x02 \x03(\v2\x15.usersdata.ComparableR\x04Data\x12\x16\n" +
+	"\x06Cursor\x18\x03 \x01(\x03R\x06Cursor\"i\n" +
 	"\rGetAllRequest\x12\x14\n" +
 	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
-	"\x06Entity\x18\x02 \x01(\tR\x06Entity\"8\n" +
+	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12\x14\n" +
+	"\x05After\x18\x03 \x01(\x03R\x05After\x12\x14\n" +
+	"\x05Limit\x18\x04 \x01(\x03R\x05Limit\"L\n" +
 	"\x0eGetAllResponse\x12&\n" +
-	"\x04Data\x18\x01 \x03(\v2\x12.usersdata.PayloadR\x04Data\"Q\n" +
+	"\x04Data\x18\x01 \x03(\v2\x12.usersdata.PayloadR\x04Data\x12\x12\n" +
+	"\x04Next\x18\x02 \x01(\x03R\x04Next\"Q\n" +
 	"\x0fGetSliceRequest\x12\x14\n" +
 	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
 	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12\x10\n" +
 	"\x03IDs\x18\x03 \x03(\x03R\x03IDs\":\n" +
 	"\x10GetSliceResponse\x12&\n" +
-	"\x04Data\x18\x01 \x03(\v2\x12.usersdata.PayloadR\x04Data\"j\n" +
+	"\x04Data\x18\x01 \x03(\v2\x12.usersdata.PayloadR\x04Data\"\x82\x01\n" +
 	"\x12UpdateSliceRequest\x12\x14\n" +
 	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
 	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12&\n" +
-	"\x04Data\x18\x03 \x03(\v2\x12.usersdata.PayloadR\x04Data\"%\n" +
+	"\x04Data\x18\x03 \x03(\v2\x12.usersdata.PayloadR\x04Data\x12\x16\n" +
+	"\x06Origin\x18\x04 \x01(\tR\x06Origin\"X\n" +
 	"\x13UpdateSliceResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok\"j\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x121\n" +
+	"\tRevisions\x18\x02 \x03(\v2\x13.usersdata.RevisionR\tRevisions\"\x82\x01\n" +
 	"\x12InsertSliceRequest\x12\x14\n" +
 	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
 	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12&\n" +
-	"\x04Data\x18\x03 \x03(\v2\x12.usersdata.PayloadR\x04Data\"'\n" +
-	"\x13InsertSliceResponse\x12\x10\n" +
-	"\x03IDs\x18\x01 \x03(\x03R\x03IDs2\x89\x03\n" +
+	"\x04Data\x18\x03 \x03(\v2\x12.usersdata.PayloadR\x04Data\x12\x16\n" +
+	"\x06Origin\x18\x04 \x01(\tR\x06Origin\"N\n" +
+	"\x13InsertSliceResponse\x121\n" +
+	"\tRevisions\x18\x02 \x03(\v2\x13.usersdata.RevisionR\tRevisionsJ\x04\b\x01\x10\x02\"t\n" +
+	"\x16GetChangesSinceRequest\x12\x14\n" +
+	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
+	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12\x16\n" +
+	"\x06Cursor\x18\x03 \x01(\x03R\x06Cursor\x12\x14\n" +
+	"\x05Limit\x18\x04 \x01(\x03R\x05Limit\"m\n" +
+	"\x17GetChangesSinceResponse\x12&\n" +
+	"\x04Data\x18\x01 \x03(\v2\x12.usersdata.PayloadR\x04Data\x12\x16\n" +
+	"\x06Cursor\x18\x02 \x01(\x03R\x06Cursor\x12\x12\n" +
+	"\x04Next\x18\x03 \x01(\x03R\x04Next\"@\n" +
+	"\x10SubscribeRequest\x12\x14\n" +
+	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
+	"\x06Origin\x18\x02 \x01(\tR\x06Origin\"A\n" +
+	"\vChangeEvent\x12\x16\n" +
+	"\x06Entity\x18\x01 \x01(\tR\x06Entity\x12\x1a\n" +
+	"\bRevision\x18\x02 \x01(\x03R\bRevision\"B\n" +
+	"\x16GetUploadOffsetRequest\x12\x14\n" +
+	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x12\n" +
+	"\x04Hash\x18\x02 \x01(\tR\x04Hash\"1\n" +
+	"\x17GetUploadOffsetResponse\x12\x16\n" +
+	"\x06Offset\x18\x01 \x01(\x03R\x06Offset\"w\n" +
+	"\vUploadChunk\x12\x14\n" +
+	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x12\n" +
+	"\x04Hash\x18\x02 \x01(\tR\x04Hash\x12\x12\n" +
+	"\x04Size\x18\x03 \x01(\x03R\x04Size\x12\x16\n" +
+	"\x06Offset\x18\x04 \x01(\x03R\x06Offset\x12\x12\n" +
+	"\x04Data\x18\x05 \x01(\fR\x04Data\"(\n" +
+	"\x0eUploadResponse\x12\x16\n" +
+	"\x06Offset\x18\x01 \x01(\x03R\x06Offset\"{\n" +
+	"\x0fDownloadRequest\x12\x14\n" +
+	"\x05Token\x18\x01 \x01(\tR\x05Token\x12\x16\n" +
+	"\x06Entity\x18\x02 \x01(\tR\x06Entity\x12\x0e\n" +
+	"\x02ID\x18\x03 \x01(\x03R\x02ID\x12\x12\n" +
+	"\x04Hash\x18\x04 \x01(\tR\x04Hash\x12\x16\n" +
+	"\x06Offset\x18\x05 \x01(\x03R\x06Offset\";\n" +
+	"\rDownloadChunk\x12\x16\n" +
+	"\x06Offset\x18\x01 \x01(\x03R\x06Offset\x12\x12\n" +
+	"\x04Data\x18\x02 \x01(\fR\x04Data\"+\n" +
+	"\x13GetIndexSaltRequest\x12\x14\n" +
+	"\x05Token\x18\x01 \x01(\tR\x05Token\"*\n" +
+	"\x14GetIndexSaltResponse\x12\x12\n" +
+	"\x04Salt\x18\x01 \x01(\fR\x04Salt2\xab\a\n" +
 	"\tUsersData\x12T\n" +
 	"\rGetComparable\x12\x1f.usersdata.GetComparableRequest\x1a .usersdata.GetComparableResponse\"\x00\x12?\n" +
 	"\x06GetAll\x12\x18.usersdata.GetAllRequest\x1a\x19.usersdata.GetAllResponse\"\x00\x12E\n" +
 	"\bGetSlice\x12\x1a.usersdata.GetSliceRequest\x1a\x1b.usersdata.GetSliceResponse\"\x00\x12N\n" +
 	"\vUpdateSlice\x12\x1d.usersdata.UpdateSliceRequest\x1a\x1e.usersdata.UpdateSliceResponse\"\x00\x12N\n" +
-	"\vInsertSlice\x12\x1d.usersdata.InsertSliceRequest\x1a\x1e.usersdata.InsertSliceResponse\"\x00B:Z8github.com/niksmo/gophkeeper/proto/usersdata;usersdatapbb\x06proto3"
+	"\vInsertSlice\x12\x1d.usersdata.InsertSliceRequest\x1a\x1e.usersdata.InsertSliceResponse\"\x00\x12Z\n" +
+	"\x0fGetChangesSince\x12!.usersdata.GetChangesSinceRequest\x1a\".usersdata.GetChangesSinceResponse\"\x00\x12D\n" +
+	"\tSubscribe\x12\x1b.usersdata.SubscribeRequest\x1a\x16.usersdata.ChangeEvent\"\x000\x01\x12Z\n" +
+	"\x0fGetUploadOffset\x12!.usersdata.GetUploadOffsetRequest\x1a\".usersdata.GetUploadOffsetResponse\"\x00\x12?\n" +
+	"\x06Upload\x12\x16.usersdata.UploadChunk\x1a\x19.usersdata.UploadResponse\"\x00(\x01\x12D\n" +
+	"\bDownload\x12\x1a.usersdata.DownloadRequest\x1a\x18.usersdata.DownloadChunk\"\x000\x01\x12H\n" +
+	"\tReconcile\x12\x1b.usersdata.ReconcileRequest\x1a\x1c.usersdata.ReconcileResponse\"\x00\x12Q\n" +
+	"\fGetIndexSalt\x12\x1e.usersdata.GetIndexSaltRequest\x1a\x1f.usersdata.GetIndexSaltResponse\"\x00B:Z8github.com/niksmo/gophkeeper/proto/usersdata;usersdatapbb\x06proto3"
 
 var (
 	file_proto_usersdata_proto_rawDescOnce sync.Once
@@ -733,42 +1891,78 @@ func file_proto_usersdata_proto_rawDescGZIP() []byte {
 	return file_proto_usersdata_proto_rawDescData
 }
 
-var file_proto_usersdata_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_proto_usersdata_proto_msgTypes = make([]protoimpl.MessageInfo, 28)
 var file_proto_usersdata_proto_goTypes = []any{
-	(*Comparable)(nil),            // 0: usersdata.Comparable
-	(*Payload)(nil),               // 1: usersdata.Payload
-	(*GetComparableRequest)(nil),  // 2: usersdata.GetComparableRequest
-	(*GetComparableResponse)(nil), // 3: usersdata.GetComparableResponse
-	(*GetAllRequest)(nil),         // 4: usersdata.GetAllRequest
-	(*GetAllResponse)(nil),        // 5: usersdata.GetAllResponse
-	(*GetSliceRequest)(nil),       // 6: usersdata.GetSliceRequest
-	(*GetSliceResponse)(nil),      // 7: usersdata.GetSliceResponse
-	(*UpdateSliceRequest)(nil),    // 8: usersdata.UpdateSliceRequest
-	(*UpdateSliceResponse)(nil),   // 9: usersdata.UpdateSliceResponse
-	(*InsertSliceRequest)(nil),    // 10: usersdata.InsertSliceRequest
-	(*InsertSliceResponse)(nil),   // 11: usersdata.InsertSliceResponse
+	(*Comparable)(nil),              // 0: usersdata.Comparable
+	(*Payload)(nil),                 // 1: usersdata.Payload
+	(*Revision)(nil),                // 2: usersdata.Revision
+	(*GetComparableRequest)(nil),    // 3: usersdata.GetComparableRequest
+	(*GetComparableResponse)(nil),   // 4: usersdata.GetComparableResponse
+	(*Range)(nil),                   // 5: usersdata.Range
+	(*ReconcileRequest)(nil),        // 6: usersdata.ReconcileRequest
+	(*ReconcileResponse)(nil),       // 7: usersdata.ReconcileResponse
+	(*GetAllRequest)(nil),           // 8: usersdata.GetAllRequest
+	(*GetAllResponse)(nil),          // 9: usersdata.GetAllResponse
+	(*GetSliceRequest)(nil),         // 10: usersdata.GetSliceRequest
+	(*GetSliceResponse)(nil),        // 11: usersdata.GetSliceResponse
+	(*UpdateSliceRequest)(nil),      // 12: usersdata.UpdateSliceRequest
+	(*UpdateSliceResponse)(nil),     // 13: usersdata.UpdateSliceResponse
+	(*InsertSliceRequest)(nil),      // 14: usersdata.InsertSliceRequest
+	(*InsertSliceResponse)(nil),     // 15: usersdata.InsertSliceResponse
+	(*GetChangesSinceRequest)(nil),  // 16: usersdata.GetChangesSinceRequest
+	(*GetChangesSinceResponse)(nil), // 17: usersdata.GetChangesSinceResponse
+	(*SubscribeRequest)(nil),        // 18: usersdata.SubscribeRequest
+	(*ChangeEvent)(nil),             // 19: usersdata.ChangeEvent
+	(*GetUploadOffsetRequest)(nil),  // 20: usersdata.GetUploadOffsetRequest
+	(*GetUploadOffsetResponse)(nil), // 21: usersdata.GetUploadOffsetResponse
+	(*UploadChunk)(nil),             // 22: usersdata.UploadChunk
+	(*UploadResponse)(nil),          // 23: usersdata.UploadResponse
+	(*DownloadRequest)(nil),         // 24: usersdata.DownloadRequest
+	(*DownloadChunk)(nil),           // 25: usersdata.DownloadChunk
+	(*GetIndexSaltRequest)(nil),     // 26: usersdata.GetIndexSaltRequest
+	(*GetIndexSaltResponse)(nil),    // 27: usersdata.GetIndexSaltResponse
 }
 var file_proto_usersdata_proto_depIdxs = []int32{
 	0,  // 0: usersdata.GetComparableResponse.Data:type_name -> usersdata.Comparable
-	1,  // 1: usersdata.GetAllResponse.Data:type_name -> usersdata.Payload
-	1,  // 2: usersdata.GetSliceResponse.Data:type_name -> usersdata.Payload
-	1,  // 3: usersdata.UpdateSliceRequest.Data:type_name -> usersdata.Payload
-	1,  // 4: usersdata.InsertSliceRequest.Data:type_name -> usersdata.Payload
-	2,  // 5: usersdata.UsersData.GetComparable:input_type -> usersdata.GetComparableRequest
-	4,  // 6: usersdata.UsersData.GetAll:input_type -> usersdata.GetAllRequest
-	6,  // 7: usersdata.UsersData.GetSlice:input_type -> usersdata.GetSliceRequest
-	8,  // 8: usersdata.UsersData.UpdateSlice:input_type -> usersdata.UpdateSliceRequest
-	10, // 9: usersdata.UsersData.InsertSlice:input_type -> usersdata.InsertSliceRequest
-	3,  // 10: usersdata.UsersData.GetComparable:output_type -> usersdata.GetComparableResponse
-	5,  // 11: usersdata.UsersData.GetAll:output_type -> usersdata.GetAllResponse
-	7,  // 12: usersdata.UsersData.GetSlice:output_type -> usersdata.GetSliceResponse
-	9,  // 13: usersdata.UsersData.UpdateSlice:output_type -> usersdata.UpdateSliceResponse
-	11, // 14: usersdata.UsersData.InsertSlice:output_type -> usersdata.InsertSliceResponse
-	10, // [10:15] is the sub-list for method output_type
-	5,  // [5:10] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+	5,  // 1: usersdata.ReconcileRequest.Ranges:type_name -> usersdata.Range
+	5,  // 2: usersdata.ReconcileResponse.Ranges:type_name -> usersdata.Range
+	0,  // 3: usersdata.ReconcileResponse.Data:type_name -> usersdata.Comparable
+	1,  // 4: usersdata.GetAllResponse.Data:type_name -> usersdata.Payload
+	1,  // 5: usersdata.GetSliceResponse.Data:type_name -> usersdata.Payload
+	1,  // 6: usersdata.UpdateSliceRequest.Data:type_name -> usersdata.Payload
+	2,  // 7: usersdata.UpdateSliceResponse.Revisions:type_name -> usersdata.Revision
+	1,  // 8: usersdata.InsertSliceRequest.Data:type_name -> usersdata.Payload
+	2,  // 9: usersdata.InsertSliceResponse.Revisions:type_name -> usersdata.Revision
+	1,  // 10: usersdata.GetChangesSinceResponse.Data:type_name -> usersdata.Payload
+	3,  // 11: usersdata.UsersData.GetComparable:input_type -> usersdata.GetComparableRequest
+	8,  // 12: usersdata.UsersData.GetAll:input_type -> usersdata.GetAllRequest
+	10, // 13: usersdata.UsersData.GetSlice:input_type -> usersdata.GetSliceRequest
+	12, // 14: usersdata.UsersData.UpdateSlice:input_type -> usersdata.UpdateSliceRequest
+	14, // 15: usersdata.UsersData.InsertSlice:input_type -> usersdata.InsertSliceRequest
+	16, // 16: usersdata.UsersData.GetChangesSince:input_type -> usersdata.GetChangesSinceRequest
+	18, // 17: usersdata.UsersData.Subscribe:input_type -> usersdata.SubscribeRequest
+	20, // 18: usersdata.UsersData.GetUploadOffset:input_type -> usersdata.GetUploadOffsetRequest
+	22, // 19: usersdata.UsersData.Upload:input_type -> usersdata.UploadChunk
+	24, // 20: usersdata.UsersData.Download:input_type -> usersdata.DownloadRequest
+	6,  // 21: usersdata.UsersData.Reconcile:input_type -> usersdata.ReconcileRequest
+	26, // 22: usersdata.UsersData.GetIndexSalt:input_type -> usersdata.GetIndexSaltRequest
+	4,  // 23: usersdata.UsersData.GetComparable:output_type -> usersdata.GetComparableResponse
+	9,  // 24: usersdata.UsersData.GetAll:output_type -> usersdata.GetAllResponse
+	11, // 25: usersdata.UsersData.GetSlice:output_type -> usersdata.GetSliceResponse
+	13, // 26: usersdata.UsersData.UpdateSlice:output_type -> usersdata.UpdateSliceResponse
+	15, // 27: usersdata.UsersData.InsertSlice:output_type -> usersdata.InsertSliceResponse
+	17, // 28: usersdata.UsersData.GetChangesSince:output_type -> usersdata.GetChangesSinceResponse
+	19, // 29: usersdata.UsersData.Subscribe:output_type -> usersdata.ChangeEvent
+	21, // 30: usersdata.UsersData.GetUploadOffset:output_type -> usersdata.GetUploadOffsetResponse
+	23, // 31: usersdata.UsersData.Upload:output_type -> usersdata.UploadResponse
+	25, // 32: usersdata.UsersData.Download:output_type -> usersdata.DownloadChunk
+	7,  // 33: usersdata.UsersData.Reconcile:output_type -> usersdata.ReconcileResponse
+	27, // 34: usersdata.UsersData.GetIndexSalt:output_type -> usersdata.GetIndexSaltResponse
+	23, // [23:35] is the sub-list for method output_type
+	11, // [11:23] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_proto_usersdata_proto_init() }
@@ -782,7 +1976,7 @@ func file_proto_usersdata_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_usersdata_proto_rawDesc), len(file_proto_usersdata_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   28,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,11 +19,18 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	UsersData_GetComparable_FullMethodName = "/usersdata.UsersData/GetComparable"
-	UsersData_GetAll_FullMethodName        = "/usersdata.UsersData/GetAll"
-	UsersData_GetSlice_FullMethodName      = "/usersdata.UsersData/GetSlice"
-	UsersData_UpdateSlice_FullMethodName   = "/usersdata.UsersData/UpdateSlice"
-	UsersData_InsertSlice_FullMethodName   = "/usersdata.UsersData/InsertSlice"
+	UsersData_GetComparable_FullMethodName   = "/usersdata.UsersData/GetComparable"
+	UsersData_GetAll_FullMethodName          = "/usersdata.UsersData/GetAll"
+	UsersData_GetSlice_FullMethodName        = "/usersdata.UsersData/GetSlice"
+	UsersData_UpdateSlice_FullMethodName     = "/usersdata.UsersData/UpdateSlice"
+	UsersData_InsertSlice_FullMethodName     = "/usersdata.UsersData/InsertSlice"
+	UsersData_GetChangesSince_FullMethodName = "/usersdata.UsersData/GetChangesSince"
+	UsersData_Subscribe_FullMethodName       = "/usersdata.UsersData/Subscribe"
+	UsersData_GetUploadOffset_FullMethodName = "/usersdata.UsersData/GetUploadOffset"
+	UsersData_Upload_FullMethodName          = "/usersdata.UsersData/Upload"
+	UsersData_Download_FullMethodName        = "/usersdata.UsersData/Download"
+	UsersData_Reconcile_FullMethodName       = "/usersdata.UsersData/Reconcile"
+	UsersData_GetIndexSalt_FullMethodName    = "/usersdata.UsersData/GetIndexSalt"
 )
 
 // UsersDataClient is the client API for UsersData service.
@@ -35,6 +42,13 @@ type UsersDataClient interface {
 	GetSlice(ctx context.Context, in *GetSliceRequest, opts ...grpc.CallOption) (*GetSliceResponse, error)
 	UpdateSlice(ctx context.Context, in *UpdateSliceRequest, opts ...grpc.CallOption) (*UpdateSliceResponse, error)
 	InsertSlice(ctx context.Context, in *InsertSliceRequest, opts ...grpc.CallOption) (*InsertSliceResponse, error)
+	GetChangesSince(ctx context.Context, in *GetChangesSinceRequest, opts ...grpc.CallOption) (*GetChangesSinceResponse, error)
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error)
+	GetUploadOffset(ctx context.Context, in *GetUploadOffsetRequest, opts ...grpc.CallOption) (*GetUploadOffsetResponse, error)
+	Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadChunk, UploadResponse], error)
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error)
+	Reconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*ReconcileResponse, error)
+	GetIndexSalt(ctx context.Context, in *GetIndexSaltRequest, opts ...grpc.CallOption) (*GetIndexSaltResponse, error)
 }
 
 type usersDataClient struct {
@@ -95,6 +109,97 @@ func (c *usersDataClient) InsertSlice(ctx context.Context, in *InsertSliceReques
 	return out, nil
 }
 
+func (c *usersDataClient) GetChangesSince(ctx context.Context, in *GetChangesSinceRequest, opts ...grpc.CallOption) (*GetChangesSinceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetChangesSinceResponse)
+	err := c.cc.Invoke(ctx, UsersData_GetChangesSince_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersDataClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UsersData_ServiceDesc.Streams[0], UsersData_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, ChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsersData_SubscribeClient = grpc.ServerStreamingClient[ChangeEvent]
+
+func (c *usersDataClient) GetUploadOffset(ctx context.Context, in *GetUploadOffsetRequest, opts ...grpc.CallOption) (*GetUploadOffsetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUploadOffsetResponse)
+	err := c.cc.Invoke(ctx, UsersData_GetUploadOffset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersDataClient) Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadChunk, UploadResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UsersData_ServiceDesc.Streams[1], UsersData_Upload_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadChunk, UploadResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsersData_UploadClient = grpc.ClientStreamingClient[UploadChunk, UploadResponse]
+
+func (c *usersDataClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UsersData_ServiceDesc.Streams[2], UsersData_Download_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadRequest, DownloadChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsersData_DownloadClient = grpc.ServerStreamingClient[DownloadChunk]
+
+func (c *usersDataClient) Reconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*ReconcileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReconcileResponse)
+	err := c.cc.Invoke(ctx, UsersData_Reconcile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersDataClient) GetIndexSalt(ctx context.Context, in *GetIndexSaltRequest, opts ...grpc.CallOption) (*GetIndexSaltResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetIndexSaltResponse)
+	err := c.cc.Invoke(ctx, UsersData_GetIndexSalt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UsersDataServer is the server API for UsersData service.
 // All implementations must embed UnimplementedUsersDataServer
 // for forward compatibility.
@@ -104,6 +209,13 @@ type UsersDataServer interface {
 	GetSlice(context.Context, *GetSliceRequest) (*GetSliceResponse, error)
 	UpdateSlice(context.Context, *UpdateSliceRequest) (*UpdateSliceResponse, error)
 	InsertSlice(context.Context, *InsertSliceRequest) (*InsertSliceResponse, error)
+	GetChangesSince(context.Context, *GetChangesSinceRequest) (*GetChangesSinceResponse, error)
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[ChangeEvent]) error
+	GetUploadOffset(context.Context, *GetUploadOffsetRequest) (*GetUploadOffsetResponse, error)
+	Upload(grpc.ClientStreamingServer[UploadChunk, UploadResponse]) error
+	Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error
+	Reconcile(context.Context, *ReconcileRequest) (*ReconcileResponse, error)
+	GetIndexSalt(context.Context, *GetIndexSaltRequest) (*GetIndexSaltResponse, error)
 	mustEmbedUnimplementedUsersDataServer()
 }
 
@@ -129,6 +241,27 @@ func (UnimplementedUsersDataServer) UpdateSlice(context.Context, *UpdateSliceReq
 func (UnimplementedUsersDataServer) InsertSlice(context.Context, *InsertSliceRequest) (*InsertSliceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InsertSlice not implemented")
 }
+func (UnimplementedUsersDataServer) GetChangesSince(context.Context, *GetChangesSinceRequest) (*GetChangesSinceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChangesSince not implemented")
+}
+func (UnimplementedUsersDataServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[ChangeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedUsersDataServer) GetUploadOffset(context.Context, *GetUploadOffsetRequest) (*GetUploadOffsetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUploadOffset not implemented")
+}
+func (UnimplementedUsersDataServer) Upload(grpc.ClientStreamingServer[UploadChunk, UploadResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedUsersDataServer) Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedUsersDataServer) Reconcile(context.Context, *ReconcileRequest) (*ReconcileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reconcile not implemented")
+}
+func (UnimplementedUsersDataServer) GetIndexSalt(context.Context, *GetIndexSaltRequest) (*GetIndexSaltResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetIndexSalt not implemented")
+}
 func (UnimplementedUsersDataServer) mustEmbedUnimplementedUsersDataServer() {}
 func (UnimplementedUsersDataServer) testEmbeddedByValue()                   {}
 
@@ -240,6 +373,107 @@ func _UsersData_InsertSlice_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UsersData_GetChangesSince_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChangesSinceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersDataServer).GetChangesSince(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsersData_GetChangesSince_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersDataServer).GetChangesSince(ctx, req.(*GetChangesSinceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UsersData_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UsersDataServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, ChangeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsersData_SubscribeServer = grpc.ServerStreamingServer[ChangeEvent]
+
+func _UsersData_GetUploadOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUploadOffsetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersDataServer).GetUploadOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsersData_GetUploadOffset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersDataServer).GetUploadOffset(ctx, req.(*GetUploadOffsetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UsersData_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(UsersDataServer).Upload(&grpc.GenericServerStream[UploadChunk, UploadResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsersData_UploadServer = grpc.ClientStreamingServer[UploadChunk, UploadResponse]
+
+func _UsersData_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UsersDataServer).Download(m, &grpc.GenericServerStream[DownloadRequest, DownloadChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsersData_DownloadServer = grpc.ServerStreamingServer[DownloadChunk]
+
+func _UsersData_Reconcile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersDataServer).Reconcile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsersData_Reconcile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersDataServer).Reconcile(ctx, req.(*ReconcileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UsersData_GetIndexSalt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetIndexSaltRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersDataServer).GetIndexSalt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsersData_GetIndexSalt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersDataServer).GetIndexSalt(ctx, req.(*GetIndexSaltRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UsersData_ServiceDesc is the grpc.ServiceDesc for UsersData service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -267,7 +501,39 @@ var UsersData_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "InsertSlice",
 			Handler:    _UsersData_InsertSlice_Handler,
 		},
+		{
+			MethodName: "GetChangesSince",
+			Handler:    _UsersData_GetChangesSince_Handler,
+		},
+		{
+			MethodName: "GetUploadOffset",
+			Handler:    _UsersData_GetUploadOffset_Handler,
+		},
+		{
+			MethodName: "Reconcile",
+			Handler:    _UsersData_Reconcile_Handler,
+		},
+		{
+			MethodName: "GetIndexSalt",
+			Handler:    _UsersData_GetIndexSalt_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _UsersData_Subscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Upload",
+			Handler:       _UsersData_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Download",
+			Handler:       _UsersData_Download_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/usersdata.proto",
 }